@@ -0,0 +1,395 @@
+package cache_tier
+
+import (
+	"context"
+	"errors"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// errUploading is returned by the operations which can't be applied to the
+// files not uploaded yet
+var errUploading = errors.New("some of the files are being uploaded, try again later")
+
+type CacheTier struct {
+	model.Storage
+	Addition
+
+	mu         sync.Mutex
+	entries    map[string]*entry
+	used       int64
+	populating map[string]struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func (d *CacheTier) Config() driver.Config {
+	return config
+}
+
+func (d *CacheTier) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *CacheTier) Init(ctx context.Context) error {
+	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
+	if utils.IsSubPath(d.MountPath, d.RemotePath) {
+		return errors.New("the remote path is inside the storage itself")
+	}
+	if d.CacheDir == "" {
+		return errors.New("the cache dir is required")
+	}
+	var err error
+	if d.CacheDir, err = filepath.Abs(d.CacheDir); err != nil {
+		return err
+	}
+	if d.RetryInterval <= 0 {
+		d.RetryInterval = 5
+	}
+	_ = os.RemoveAll(d.tmpDir())
+	for _, dir := range []string{d.filesDir(), d.tmpDir()} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			return err
+		}
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.loadIndex(); err != nil {
+		return err
+	}
+	d.populating = make(map[string]struct{})
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	go d.background(d.ctx)
+	return nil
+}
+
+func (d *CacheTier) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries != nil {
+		d.saveIndex()
+	}
+	return nil
+}
+
+func (d *CacheTier) background(ctx context.Context) {
+	// the uploads left by the last run are queued at once
+	d.checkUploads(ctx)
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.checkUploads(ctx)
+		}
+	}
+}
+
+func (d *CacheTier) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{
+		Name:     "root",
+		Path:     "/",
+		IsFolder: true,
+		Modified: d.Modified,
+	}, nil
+}
+
+func entryObj(e *entry) model.Obj {
+	return &model.Object{
+		Path:     e.Path,
+		Name:     stdpath.Base(e.Path),
+		Size:     e.Size,
+		Modified: e.Modified,
+	}
+}
+
+func remoteObj(obj model.Obj, path string) model.Obj {
+	objRes := model.Object{
+		Path:     path,
+		Name:     obj.GetName(),
+		Size:     obj.GetSize(),
+		Modified: obj.ModTime(),
+		Ctime:    obj.CreateTime(),
+		IsFolder: obj.IsDir(),
+		HashInfo: obj.GetHash(),
+	}
+	if thumb, ok := model.GetThumb(obj); ok {
+		return &model.ObjThumb{
+			Object: objRes,
+			Thumbnail: model.Thumbnail{
+				Thumbnail: thumb,
+			},
+		}
+	}
+	return &objRes
+}
+
+// Get returns the local file if it is not uploaded yet, or the remote one
+func (d *CacheTier) Get(ctx context.Context, path string) (model.Obj, error) {
+	d.mu.Lock()
+	if e, ok := d.entries[path]; ok && e.Dirty {
+		d.mu.Unlock()
+		return entryObj(e), nil
+	}
+	d.mu.Unlock()
+	obj, err := fs.Get(ctx, stdpath.Join(d.RemotePath, path), &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return nil, err
+	}
+	return remoteObj(obj, path), nil
+}
+
+// List lists the remote directory with the local files not uploaded yet
+func (d *CacheTier) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	objs, err := fs.List(ctx, stdpath.Join(d.RemotePath, dir.GetPath()), &fs.ListArgs{NoLog: true, Refresh: args.Refresh})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Obj, 0, len(objs))
+	index := make(map[string]int, len(objs))
+	for _, obj := range objs {
+		index[obj.GetName()] = len(result)
+		result = append(result, remoteObj(obj, stdpath.Join(dir.GetPath(), obj.GetName())))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.entries {
+		if !e.Dirty || stdpath.Dir(e.Path) != dir.GetPath() {
+			continue
+		}
+		if i, ok := index[stdpath.Base(e.Path)]; ok {
+			result[i] = entryObj(e)
+		} else {
+			result = append(result, entryObj(e))
+		}
+	}
+	return result, nil
+}
+
+// Link serves the local copy if it is fresh, otherwise it serves the remote
+// file and copies it to the cache in the background
+func (d *CacheTier) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	path := file.GetPath()
+	d.mu.Lock()
+	if e, ok := d.entries[path]; ok {
+		if e.Dirty || (e.Size == file.GetSize() && e.Modified.Unix() == file.ModTime().Unix()) {
+			e.Accessed = time.Now()
+			f, err := os.Open(d.localPath(path))
+			d.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return &model.Link{
+				RangeReader:      stream.GetRangeReaderFromMFile(e.Size, f),
+				ContentLength:    e.Size,
+				SyncClosers:      utils.NewSyncClosers(f),
+				RequireReference: true,
+			}, nil
+		}
+		d.removeEntry(e)
+		d.saveIndex()
+	}
+	d.mu.Unlock()
+	storage, actualPath, err := op.GetStorageAndActualPath(stdpath.Join(d.RemotePath, path))
+	if err != nil {
+		return nil, err
+	}
+	l, obj, err := op.Link(ctx, storage, actualPath, args)
+	if err != nil {
+		return nil, err
+	}
+	d.populate(path, obj)
+	return l.Clone(), nil
+}
+
+// checkClean fails if some files under path are not uploaded yet
+func (d *CacheTier) checkClean(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.entries {
+		if e.Dirty && utils.IsSubPath(path, e.Path) {
+			return errUploading
+		}
+	}
+	return nil
+}
+
+// dropLocal removes the cached copies under path
+func (d *CacheTier) dropLocal(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.entries {
+		if utils.IsSubPath(path, e.Path) {
+			d.removeEntry(e)
+		}
+	}
+	d.saveIndex()
+}
+
+func (d *CacheTier) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	return fs.MakeDir(ctx, stdpath.Join(d.RemotePath, parentDir.GetPath(), dirName))
+}
+
+func (d *CacheTier) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	if err := d.checkClean(srcObj.GetPath()); err != nil {
+		return err
+	}
+	_, err := fs.Move(ctx, stdpath.Join(d.RemotePath, srcObj.GetPath()), stdpath.Join(d.RemotePath, dstDir.GetPath()))
+	if err == nil {
+		d.dropLocal(srcObj.GetPath())
+	}
+	return err
+}
+
+func (d *CacheTier) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	if err := d.checkClean(srcObj.GetPath()); err != nil {
+		return err
+	}
+	err := fs.Rename(ctx, stdpath.Join(d.RemotePath, srcObj.GetPath()), newName)
+	if err == nil {
+		d.dropLocal(srcObj.GetPath())
+	}
+	return err
+}
+
+func (d *CacheTier) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	if err := d.checkClean(srcObj.GetPath()); err != nil {
+		return err
+	}
+	_, err := fs.Copy(ctx, stdpath.Join(d.RemotePath, srcObj.GetPath()), stdpath.Join(d.RemotePath, dstDir.GetPath()))
+	return err
+}
+
+// Remove cancels the uploads of the files removed
+func (d *CacheTier) Remove(ctx context.Context, obj model.Obj) error {
+	err := fs.Remove(ctx, stdpath.Join(d.RemotePath, obj.GetPath()))
+	if errs.IsObjectNotFound(err) {
+		d.mu.Lock()
+		if e, ok := d.entries[obj.GetPath()]; ok && e.Dirty {
+			err = nil
+		}
+		d.mu.Unlock()
+	}
+	if err == nil {
+		d.dropLocal(obj.GetPath())
+	}
+	return err
+}
+
+// Put writes the file locally and queues its upload
+func (d *CacheTier) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	tmp, err := os.CreateTemp(d.tmpDir(), "put-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	n, err := utils.CopyWithBuffer(tmp, &driver.ReaderUpdatingProgress{
+		Reader:         file,
+		UpdateProgress: up,
+	})
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	modified := file.ModTime()
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	path := stdpath.Join(dstDir.GetPath(), file.GetName())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if old, ok := d.entries[path]; ok {
+		d.removeEntry(old)
+	}
+	local := d.localPath(path)
+	if err := os.MkdirAll(filepath.Dir(local), 0o777); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return err
+	}
+	e := &entry{
+		Path:     path,
+		Size:     n,
+		Modified: modified,
+		Accessed: time.Now(),
+		Dirty:    true,
+	}
+	d.entries[path] = e
+	d.used += n
+	d.upload(context.WithoutCancel(ctx), e)
+	d.evict()
+	d.saveIndex()
+	return nil
+}
+
+type dirtyStatus struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	State     string `json:"state"`
+	LastError string `json:"last_error"`
+}
+
+// Other reports the usage of the cache and the files not uploaded yet with
+// the "status" method, and evicts all the cached copies with "clear"
+func (d *CacheTier) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch args.Method {
+	case "status":
+		dirty := make([]dirtyStatus, 0)
+		for _, e := range d.entries {
+			if !e.Dirty {
+				continue
+			}
+			s := dirtyStatus{Path: e.Path, Size: e.Size, State: "waiting retry", LastError: e.lastErr}
+			if e.task != nil {
+				s.State = "uploading"
+			}
+			dirty = append(dirty, s)
+		}
+		return map[string]any{
+			"used":     d.used,
+			"max_size": int64(d.MaxSize) * utils.MB,
+			"files":    len(d.entries),
+			"dirty":    dirty,
+		}, nil
+	case "clear":
+		for _, e := range d.entries {
+			if !e.Dirty {
+				d.removeEntry(e)
+			}
+		}
+		d.saveIndex()
+		return nil, nil
+	}
+	return nil, errs.NotSupport
+}
+
+var _ driver.Driver = (*CacheTier)(nil)
@@ -0,0 +1,288 @@
+package cache_tier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/tache"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+	fs.UploadTaskManager = tache.NewManager[*fs.UploadTask](tache.WithWorks(1))
+}
+
+// setupCacheTier mounts a temp dir as the remote path of a cache tier
+// storage caching up to maxSize MB
+func setupCacheTier(t *testing.T, name string, maxSize int) (*CacheTier, string) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	remote := "/" + name + "_remote"
+	_, err := op.CreateStorage(ctx, model.Storage{
+		Driver:    "Local",
+		MountPath: remote,
+		Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed create the remote storage: %+v", err)
+	}
+	_, err = op.CreateStorage(ctx, model.Storage{
+		Driver:    "CacheTier",
+		MountPath: "/" + name,
+		Addition: fmt.Sprintf(`{"remote_path":%q,"cache_dir":%q,"max_size":%d,"max_file_size":1,"retry_interval":5}`,
+			remote, t.TempDir(), maxSize),
+	})
+	if err != nil {
+		t.Fatalf("failed create the cache tier storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := storage.(*CacheTier)
+	t.Cleanup(func() { _ = d.Drop(context.Background()) })
+	return d, dir
+}
+
+// waitFor polls cond until it holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (d *CacheTier) cached(path string) (*entry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[path]
+	return e, ok
+}
+
+// readLink reads the whole file of the link and closes it
+func readLink(t *testing.T, l *model.Link) string {
+	defer l.Close()
+	rr, err := stream.GetRangeReaderFromLink(l.ContentLength, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := rr.RangeRead(context.Background(), http_range.Range{Length: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPromotion(t *testing.T) {
+	d, dir := setupCacheTier(t, "cache_tier_promotion", 10)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("remote content"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	obj, err := d.Get(ctx, "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readLink(t, l); got != "remote content" {
+		t.Errorf("read %q from the remote", got)
+	}
+	waitFor(t, "the promotion", func() bool {
+		_, ok := d.cached("/a.txt")
+		return ok
+	})
+
+	// the cached copy is served even though the remote file is changed in
+	// place, as long as it keeps the size and the modified time
+	remote := filepath.Join(dir, "a.txt")
+	info, err := os.Stat(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(remote, []byte("REMOTE CONTENT"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(remote, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	l, err = d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readLink(t, l); got != "remote content" {
+		t.Errorf("read %q, want the cached copy", got)
+	}
+
+	// a stale copy is dropped
+	if err := os.WriteFile(remote, []byte("changed"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	root, err := d.GetRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.List(ctx, root, model.ListArgs{Refresh: true}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err = d.Get(ctx, "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readLink(t, l); got != "changed" {
+		t.Errorf("read %q, want the remote file changed", got)
+	}
+	waitFor(t, "the promotion of the changed file", func() bool {
+		e, ok := d.cached("/a.txt")
+		return ok && e.Size == int64(len("changed"))
+	})
+}
+
+func TestEviction(t *testing.T) {
+	d, dir := setupCacheTier(t, "cache_tier_eviction", 1)
+	ctx := context.Background()
+	content := strings.Repeat("x", 400*utils.KB)
+	objs := make(map[string]model.Obj)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := d.Get(ctx, "/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs[name] = obj
+	}
+	cache := func(name string) {
+		if err := d.download(ctx, "/"+name, objs[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache("a")
+	cache("b")
+	// a is read after b is cached, so b is the least recently read when c
+	// overflows the cache
+	l, err := d.Link(ctx, objs["a"], model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readLink(t, l); got != content {
+		t.Errorf("read %d bytes of the cached a", len(got))
+	}
+	cache("c")
+
+	if _, ok := d.cached("/b"); ok {
+		t.Errorf("the file least recently read isn't evicted")
+	}
+	for _, name := range []string{"/a", "/c"} {
+		if _, ok := d.cached(name); !ok {
+			t.Errorf("%s is evicted", name)
+		}
+	}
+	if _, err := os.Stat(d.localPath("/b")); !os.IsNotExist(err) {
+		t.Errorf("the file evicted is left in the cache dir: %v", err)
+	}
+	if d.used != int64(2*len(content)) {
+		t.Errorf("used %d bytes, want %d", d.used, 2*len(content))
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	d, dir := setupCacheTier(t, "cache_tier_write", 1)
+	ctx := context.Background()
+	root, err := d.GetRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Repeat("y", 700*utils.KB)
+	// fills the cache with a clean file, which is evicted for the dirty one
+	if err := os.WriteFile(filepath.Join(dir, "clean"), []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Get(ctx, "/clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.download(ctx, "/clean", obj); err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Put(ctx, root, &stream.FileStream{
+		Obj:    &model.Object{Name: "new.txt", Size: int64(len(content)), Modified: time.Now()},
+		Reader: strings.NewReader(content),
+	}, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := d.cached("/new.txt")
+	if !ok || !e.Dirty {
+		t.Fatalf("the file put isn't kept as dirty")
+	}
+	if _, ok := d.cached("/clean"); ok {
+		t.Errorf("the clean file isn't evicted for the dirty one")
+	}
+	if obj, err := d.Get(ctx, "/new.txt"); err != nil || obj.GetSize() != int64(len(content)) {
+		t.Errorf("failed get the file put: %v", err)
+	}
+
+	waitFor(t, "the upload", func() bool {
+		d.checkUploads(ctx)
+		e, ok := d.cached("/new.txt")
+		return ok && !e.Dirty
+	})
+	data, err := os.ReadFile(filepath.Join(dir, "new.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("the file uploaded has %d bytes, want %d", len(data), len(content))
+	}
+
+	// another dirty file overflows the cache, the clean copy is evicted now
+	err = d.Put(ctx, root, &stream.FileStream{
+		Obj:    &model.Object{Name: "next.txt", Size: int64(len(content)), Modified: time.Now()},
+		Reader: strings.NewReader(content),
+	}, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.cached("/new.txt"); ok {
+		t.Errorf("the file uploaded isn't evicted")
+	}
+}
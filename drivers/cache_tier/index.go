@@ -0,0 +1,299 @@
+package cache_tier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/tache"
+	log "github.com/sirupsen/logrus"
+)
+
+// entry is a file in the cache. A dirty entry is written locally and not
+// uploaded yet, a clean one is a copy of the remote file of the same size
+// and modified time.
+type entry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Accessed time.Time `json:"accessed"`
+	Dirty    bool      `json:"dirty"`
+
+	task     task.TaskExtensionInfo
+	failedAt time.Time
+	lastErr  string
+}
+
+func (d *CacheTier) filesDir() string {
+	return filepath.Join(d.CacheDir, "files")
+}
+
+func (d *CacheTier) tmpDir() string {
+	return filepath.Join(d.CacheDir, "tmp")
+}
+
+func (d *CacheTier) indexFile() string {
+	return filepath.Join(d.CacheDir, "index.json")
+}
+
+func (d *CacheTier) localPath(path string) string {
+	return filepath.Join(d.filesDir(), filepath.FromSlash(utils.FixAndCleanPath(path)))
+}
+
+// loadIndex loads the entries kept by the last run whose files are intact
+func (d *CacheTier) loadIndex() error {
+	d.entries = make(map[string]*entry)
+	d.used = 0
+	data, err := os.ReadFile(d.indexFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []*entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Warnf("cache tier %s: ignored the corrupted index: %+v", d.MountPath, err)
+		return nil
+	}
+	for _, e := range entries {
+		info, err := os.Stat(d.localPath(e.Path))
+		if err != nil || info.Size() != e.Size {
+			continue
+		}
+		d.entries[e.Path] = e
+		d.used += e.Size
+	}
+	return nil
+}
+
+// saveIndex must be called with mu held
+func (d *CacheTier) saveIndex() {
+	entries := make([]*entry, 0, len(d.entries))
+	for _, e := range d.entries {
+		entries = append(entries, e)
+	}
+	data, err := json.Marshal(entries)
+	if err == nil {
+		tmp := d.indexFile() + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, d.indexFile())
+		}
+	}
+	if err != nil {
+		log.Errorf("cache tier %s: failed save the index: %+v", d.MountPath, err)
+	}
+}
+
+// removeEntry must be called with mu held
+func (d *CacheTier) removeEntry(e *entry) {
+	if e.task != nil {
+		e.task.Cancel()
+	}
+	if err := os.Remove(d.localPath(e.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("cache tier %s: failed remove the cached %s: %+v", d.MountPath, e.Path, err)
+	}
+	delete(d.entries, e.Path)
+	d.used -= e.Size
+}
+
+// evict removes the clean entries least recently read until the cache fits
+// its size, it must be called with mu held and the index saved afterwards
+func (d *CacheTier) evict() {
+	max := int64(d.MaxSize) * utils.MB
+	if d.used <= max {
+		return
+	}
+	var clean []*entry
+	for _, e := range d.entries {
+		if !e.Dirty {
+			clean = append(clean, e)
+		}
+	}
+	slices.SortFunc(clean, func(a, b *entry) int {
+		return a.Accessed.Compare(b.Accessed)
+	})
+	for _, e := range clean {
+		if d.used <= max {
+			break
+		}
+		d.removeEntry(e)
+	}
+}
+
+// populate copies a remote file to the cache in the background
+func (d *CacheTier) populate(path string, obj model.Obj) {
+	if obj.GetSize() > int64(d.MaxFileSize)*utils.MB {
+		return
+	}
+	d.mu.Lock()
+	if _, ok := d.populating[path]; ok {
+		d.mu.Unlock()
+		return
+	}
+	d.populating[path] = struct{}{}
+	d.mu.Unlock()
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			delete(d.populating, path)
+			d.mu.Unlock()
+		}()
+		if err := d.download(d.ctx, path, obj); err != nil {
+			log.Warnf("cache tier %s: failed cache %s: %+v", d.MountPath, path, err)
+		}
+	}()
+}
+
+func (d *CacheTier) download(ctx context.Context, path string, obj model.Obj) error {
+	link, _, err := fs.Link(ctx, stdpath.Join(d.RemotePath, path), model.LinkArgs{})
+	if err != nil {
+		return err
+	}
+	defer link.Close()
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tmp, err := os.CreateTemp(d.tmpDir(), "download-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	n, err := utils.CopyWithBuffer(tmp, rc)
+	if err != nil {
+		return err
+	}
+	if n != obj.GetSize() {
+		return io.ErrUnexpectedEOF
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.entries[path]; ok {
+		if e.Dirty {
+			// written meanwhile
+			return nil
+		}
+		d.removeEntry(e)
+	}
+	local := d.localPath(path)
+	if err := os.MkdirAll(filepath.Dir(local), 0o777); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return err
+	}
+	d.entries[path] = &entry{
+		Path:     path,
+		Size:     n,
+		Modified: obj.ModTime(),
+		Accessed: time.Now(),
+	}
+	d.used += n
+	d.evict()
+	d.saveIndex()
+	return nil
+}
+
+// upload queues the upload of a dirty entry to the upload task manager, it
+// must be called with mu held
+func (d *CacheTier) upload(ctx context.Context, e *entry) {
+	f, err := os.Open(d.localPath(e.Path))
+	if err == nil {
+		dir, name := stdpath.Split(e.Path)
+		e.task, err = fs.PutAsTask(ctx, stdpath.Join(d.RemotePath, dir), &stream.FileStream{
+			Obj: &model.Object{
+				Name:     name,
+				Size:     e.Size,
+				Modified: e.Modified,
+			},
+			Reader:   f,
+			Mimetype: utils.GetMimeType(name),
+			Closers:  utils.Closers{f},
+		})
+		if err != nil {
+			_ = f.Close()
+		}
+	}
+	if err != nil {
+		log.Warnf("cache tier %s: failed queue the upload of %s: %+v", d.MountPath, e.Path, err)
+		e.task = nil
+		e.failedAt = time.Now()
+		e.lastErr = err.Error()
+	}
+}
+
+// checkUploads marks the entries uploaded as clean, and queues the uploads
+// of the dirty entries failed again after the retry interval
+func (d *CacheTier) checkUploads(ctx context.Context) {
+	var uploaded []*entry
+	d.mu.Lock()
+	for _, e := range d.entries {
+		if !e.Dirty {
+			continue
+		}
+		if e.task == nil {
+			if time.Since(e.failedAt) >= time.Duration(d.RetryInterval)*time.Minute {
+				d.upload(ctx, e)
+			}
+			continue
+		}
+		switch e.task.GetState() {
+		case tache.StateSucceeded:
+			e.Dirty = false
+			e.task = nil
+			e.lastErr = ""
+			uploaded = append(uploaded, e)
+		case tache.StateFailed, tache.StateCanceled:
+			if err := e.task.GetErr(); err != nil {
+				e.lastErr = err.Error()
+			}
+			e.task = nil
+			e.failedAt = time.Now()
+		}
+	}
+	d.mu.Unlock()
+
+	// the cached copies are fresh as long as the remote files keep their
+	// modified time, which the remote storage may have set on upload
+	for _, e := range uploaded {
+		obj, err := fs.Get(ctx, stdpath.Join(d.RemotePath, e.Path), &fs.GetArgs{NoLog: true})
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		if d.entries[e.Path] == e && !e.Dirty && obj.GetSize() == e.Size {
+			e.Modified = obj.ModTime()
+		}
+		d.mu.Unlock()
+	}
+	if len(uploaded) > 0 {
+		d.mu.Lock()
+		d.saveIndex()
+		d.mu.Unlock()
+	}
+}
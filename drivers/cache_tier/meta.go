@@ -0,0 +1,28 @@
+package cache_tier
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	RemotePath    string `json:"remote_path" required:"true" help:"The mount path of the storage to cache"`
+	CacheDir      string `json:"cache_dir" required:"true" help:"The local directory keeping the cached and the uploading files"`
+	MaxSize       int    `json:"max_size" type:"number" default:"10240" help:"MB of the cache, the files least recently read are evicted beyond it. The files not uploaded yet are never evicted."`
+	MaxFileSize   int    `json:"max_file_size" type:"number" default:"1024" help:"MB, larger files are read from the remote without being cached"`
+	RetryInterval int    `json:"retry_interval" type:"number" default:"5" help:"Minutes before retrying a failed upload"`
+}
+
+var config = driver.Config{
+	Name:        "CacheTier",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &CacheTier{}
+	})
+}
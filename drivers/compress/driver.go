@@ -0,0 +1,315 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+type Compress struct {
+	model.Storage
+	Addition
+	algo byte
+	skip map[string]struct{}
+}
+
+// compressedObject is a compressed file, its path is the path of the
+// compressed file in the remote path while its name and size are the
+// original ones
+type compressedObject struct {
+	model.Object
+}
+
+func (d *Compress) Config() driver.Config {
+	return config
+}
+
+func (d *Compress) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Compress) Init(ctx context.Context) error {
+	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
+	if d.BlockSize <= 0 {
+		d.BlockSize = 1024
+	}
+	if d.Suffix == "" {
+		d.Suffix = ".olz"
+	}
+	d.algo = algoByName(d.Algorithm)
+	d.skip = make(map[string]struct{})
+	for _, ext := range strings.Split(d.SkipExtensions, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			d.skip[ext] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (d *Compress) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (*Compress) GetRootPath() string {
+	return ""
+}
+
+// encodeName returns the name of the compressed file of a file, it keeps the
+// original size so that listing doesn't need to read the files
+func (d *Compress) encodeName(name string, size int64) string {
+	return fmt.Sprintf("%s.%d%s", name, size, d.Suffix)
+}
+
+func (d *Compress) decodeName(remoteName string) (string, int64, bool) {
+	base, ok := strings.CutSuffix(remoteName, d.Suffix)
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(base, '.')
+	if i <= 0 {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(base[i+1:], 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, false
+	}
+	return base[:i], size, true
+}
+
+func (d *Compress) convert(obj model.Obj, dir string) model.Obj {
+	if !obj.IsDir() {
+		if name, size, ok := d.decodeName(obj.GetName()); ok {
+			return &compressedObject{model.Object{
+				Path:     stdpath.Join(dir, obj.GetName()),
+				Name:     name,
+				Size:     size,
+				Modified: obj.ModTime(),
+				Ctime:    obj.CreateTime(),
+			}}
+		}
+	}
+	objRes := model.Object{
+		Path:     stdpath.Join(dir, obj.GetName()),
+		Name:     obj.GetName(),
+		Size:     obj.GetSize(),
+		Modified: obj.ModTime(),
+		Ctime:    obj.CreateTime(),
+		IsFolder: obj.IsDir(),
+		HashInfo: obj.GetHash(),
+	}
+	if thumb, ok := model.GetThumb(obj); ok {
+		return &model.ObjThumb{
+			Object: objRes,
+			Thumbnail: model.Thumbnail{
+				Thumbnail: thumb,
+			},
+		}
+	}
+	return &objRes
+}
+
+func (d *Compress) Get(ctx context.Context, path string) (model.Obj, error) {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := stdpath.Split(path)
+	if remoteObj, err := op.Get(ctx, remoteStorage, stdpath.Join(remoteActualPath, path)); err == nil {
+		if _, _, ok := d.decodeName(name); !ok || remoteObj.IsDir() {
+			return d.convert(remoteObj, dir), nil
+		}
+	}
+	remoteObjs, err := op.List(ctx, remoteStorage, stdpath.Join(remoteActualPath, dir), model.ListArgs{})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range remoteObjs {
+		if n, _, ok := d.decodeName(obj.GetName()); ok && n == name && !obj.IsDir() {
+			return d.convert(obj, dir), nil
+		}
+	}
+	return nil, errs.ObjectNotFound
+}
+
+func (d *Compress) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return nil, err
+	}
+	remoteObjs, err := op.List(ctx, remoteStorage, stdpath.Join(remoteActualPath, dir.GetPath()), model.ListArgs{
+		ReqPath: args.ReqPath,
+		Refresh: args.Refresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Obj, 0, len(remoteObjs))
+	for _, obj := range remoteObjs {
+		result = append(result, d.convert(obj, dir.GetPath()))
+	}
+	return result, nil
+}
+
+func (d *Compress) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return nil, err
+	}
+	l, remoteObj, err := op.Link(ctx, remoteStorage, stdpath.Join(remoteActualPath, file.GetPath()), args)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := file.(*compressedObject); !ok {
+		return l.Clone(), nil
+	}
+	remoteSize := l.ContentLength
+	if remoteSize <= 0 {
+		remoteSize = remoteObj.GetSize()
+	}
+	rr, err := stream.GetRangeReaderFromLink(remoteSize, l)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	idx, err := readIndex(ctx, rr, remoteSize)
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return &model.Link{
+		RangeReader: stream.RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+			return idx.rangeRead(ctx, rr, httpRange)
+		}),
+		ContentLength:    idx.size,
+		SyncClosers:      utils.NewSyncClosers(l),
+		RequireReference: l.RequireReference,
+	}, nil
+}
+
+func (d *Compress) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	return fs.MakeDir(ctx, stdpath.Join(d.RemotePath, parentDir.GetPath(), dirName))
+}
+
+func (d *Compress) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	src := stdpath.Join(d.RemotePath, srcObj.GetPath())
+	dst := stdpath.Join(d.RemotePath, dstDir.GetPath())
+	_, err := fs.Move(ctx, src, dst)
+	return err
+}
+
+func (d *Compress) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	if _, ok := srcObj.(*compressedObject); ok {
+		newName = d.encodeName(newName, srcObj.GetSize())
+	}
+	return fs.Rename(ctx, stdpath.Join(d.RemotePath, srcObj.GetPath()), newName)
+}
+
+func (d *Compress) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	src := stdpath.Join(d.RemotePath, srcObj.GetPath())
+	dst := stdpath.Join(d.RemotePath, dstDir.GetPath())
+	_, err := fs.Copy(ctx, src, dst)
+	return err
+}
+
+func (d *Compress) Remove(ctx context.Context, obj model.Obj) error {
+	return fs.Remove(ctx, stdpath.Join(d.RemotePath, obj.GetPath()))
+}
+
+func (d *Compress) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return err
+	}
+	dst := stdpath.Join(remoteActualPath, dstDir.GetPath())
+	name := file.GetName()
+	if _, ok := d.skip[utils.Ext(name)]; ok {
+		if err := op.Put(ctx, remoteStorage, dst, file, up); err != nil {
+			return err
+		}
+		d.removeReplaced(ctx, remoteStorage, dst, name, name)
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(conf.Conf.TempDir, "compress-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	bw, err := newBlockWriter(tmp, d.algo, d.Level, d.BlockSize*utils.KB)
+	if err != nil {
+		return err
+	}
+	_, err = utils.CopyWithBuffer(bw, &driver.ReaderUpdatingProgress{
+		Reader:         file,
+		UpdateProgress: model.UpdateProgressWithRange(up, 0, 50),
+	})
+	if err == nil {
+		err = bw.Close()
+	}
+	if err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	remoteName := d.encodeName(name, bw.size)
+	err = op.Put(ctx, remoteStorage, dst, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     remoteName,
+			Size:     size,
+			Modified: file.ModTime(),
+		},
+		Mimetype: "application/octet-stream",
+		Reader:   tmp,
+	}, model.UpdateProgressWithRange(up, 50, 100))
+	if err != nil {
+		return err
+	}
+	d.removeReplaced(ctx, remoteStorage, dst, name, remoteName)
+	return nil
+}
+
+// removeReplaced removes the remote files of the file name other than the
+// one just put, as the name of a compressed file changes with its size
+func (d *Compress) removeReplaced(ctx context.Context, storage driver.Driver, dir, name, keep string) {
+	objs, err := op.List(ctx, storage, dir, model.ListArgs{})
+	if err != nil {
+		log.Warnf("compress: failed list %s to remove the replaced files: %+v", dir, err)
+		return
+	}
+	for _, obj := range objs {
+		if obj.IsDir() || obj.GetName() == keep {
+			continue
+		}
+		n, _, ok := d.decodeName(obj.GetName())
+		if obj.GetName() == name || (ok && n == name) {
+			if err := op.Remove(ctx, storage, stdpath.Join(dir, obj.GetName())); err != nil {
+				log.Warnf("compress: failed remove the replaced %s: %+v", obj.GetName(), err)
+			}
+		}
+	}
+}
+
+var _ driver.Driver = (*Compress)(nil)
@@ -0,0 +1,260 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A compressed file is made of blocks of the original data compressed
+// independently, followed by the compressed sizes of the blocks and a
+// footer, so that a range is read by decompressing only the blocks it covers.
+//
+//	block 0 | ... | block n-1 | n * uint32 compressed size | footer
+//
+// The footer is the magic, the algorithm, 3 reserved bytes, the block size,
+// the block count and the original size, in big endian.
+const (
+	magic      = "OLZ1"
+	footerSize = 24
+)
+
+const (
+	algoZstd byte = iota + 1
+	algoGzip
+)
+
+func algoByName(name string) byte {
+	if name == "gzip" {
+		return algoGzip
+	}
+	return algoZstd
+}
+
+// blockWriter compresses the data written to it block by block
+type blockWriter struct {
+	w         io.Writer
+	algo      byte
+	level     int
+	blockSize int
+	buf       []byte
+	sizes     []uint32
+	size      int64
+	zenc      *zstd.Encoder
+	gbuf      bytes.Buffer
+}
+
+func newBlockWriter(w io.Writer, algo byte, level, blockSize int) (*blockWriter, error) {
+	bw := &blockWriter{
+		w:         w,
+		algo:      algo,
+		level:     level,
+		blockSize: blockSize,
+		buf:       make([]byte, 0, blockSize),
+	}
+	if algo == algoZstd {
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		enc, err := zstd.NewWriter(nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		bw.zenc = enc
+	} else if level <= 0 || level > gzip.BestCompression {
+		bw.level = gzip.DefaultCompression
+	}
+	return bw, nil
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		m := min(len(p), bw.blockSize-len(bw.buf))
+		bw.buf = append(bw.buf, p[:m]...)
+		p = p[m:]
+		if len(bw.buf) == bw.blockSize {
+			if err := bw.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (bw *blockWriter) flush() error {
+	if len(bw.buf) == 0 {
+		return nil
+	}
+	var block []byte
+	if bw.algo == algoZstd {
+		block = bw.zenc.EncodeAll(bw.buf, nil)
+	} else {
+		bw.gbuf.Reset()
+		gw, err := gzip.NewWriterLevel(&bw.gbuf, bw.level)
+		if err != nil {
+			return err
+		}
+		if _, err = gw.Write(bw.buf); err != nil {
+			return err
+		}
+		if err = gw.Close(); err != nil {
+			return err
+		}
+		block = bw.gbuf.Bytes()
+	}
+	if _, err := bw.w.Write(block); err != nil {
+		return err
+	}
+	bw.sizes = append(bw.sizes, uint32(len(block)))
+	bw.size += int64(len(bw.buf))
+	bw.buf = bw.buf[:0]
+	return nil
+}
+
+// Close writes the last block with the index and the footer
+func (bw *blockWriter) Close() error {
+	if bw.zenc != nil {
+		defer bw.zenc.Close()
+	}
+	if err := bw.flush(); err != nil {
+		return err
+	}
+	tail := make([]byte, 4*len(bw.sizes)+footerSize)
+	for i, s := range bw.sizes {
+		binary.BigEndian.PutUint32(tail[4*i:], s)
+	}
+	footer := tail[4*len(bw.sizes):]
+	copy(footer, magic)
+	footer[4] = bw.algo
+	binary.BigEndian.PutUint32(footer[8:], uint32(bw.blockSize))
+	binary.BigEndian.PutUint32(footer[12:], uint32(len(bw.sizes)))
+	binary.BigEndian.PutUint64(footer[16:], uint64(bw.size))
+	_, err := bw.w.Write(tail)
+	return err
+}
+
+type index struct {
+	algo      byte
+	blockSize int64
+	size      int64
+	// offsets of the blocks in the compressed file, with the end of the last
+	offsets []int64
+}
+
+func readAll(ctx context.Context, rr model.RangeReaderIF, r http_range.Range) ([]byte, error) {
+	rc, err := rr.RangeRead(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	buf := make([]byte, r.Length)
+	_, err = io.ReadFull(rc, buf)
+	return buf, err
+}
+
+// readIndex reads the index of a compressed file of size
+func readIndex(ctx context.Context, rr model.RangeReaderIF, size int64) (*index, error) {
+	if size < footerSize {
+		return nil, errors.New("not a compressed file")
+	}
+	footer, err := readAll(ctx, rr, http_range.Range{Start: size - footerSize, Length: footerSize})
+	if err != nil {
+		return nil, err
+	}
+	if string(footer[:4]) != magic {
+		return nil, errors.New("not a compressed file")
+	}
+	idx := &index{
+		algo:      footer[4],
+		blockSize: int64(binary.BigEndian.Uint32(footer[8:])),
+		size:      int64(binary.BigEndian.Uint64(footer[16:])),
+	}
+	count := int64(binary.BigEndian.Uint32(footer[12:]))
+	// every block but the last is full, the ranges are mapped to the blocks
+	// by the block size
+	if idx.blockSize <= 0 || idx.size < 0 || count != (idx.size+idx.blockSize-1)/idx.blockSize {
+		return nil, errors.New("corrupted compressed file")
+	}
+	indexSize := 4 * count
+	if indexSize > size-footerSize {
+		return nil, errors.New("corrupted compressed file")
+	}
+	idx.offsets = make([]int64, count+1)
+	if count > 0 {
+		data, err := readAll(ctx, rr, http_range.Range{Start: size - footerSize - indexSize, Length: indexSize})
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < count; i++ {
+			idx.offsets[i+1] = idx.offsets[i] + int64(binary.BigEndian.Uint32(data[4*i:]))
+		}
+	}
+	if idx.offsets[count] != size-footerSize-indexSize {
+		return nil, errors.New("corrupted compressed file")
+	}
+	return idx, nil
+}
+
+// rangeRead reads a range of the original data by decompressing the blocks
+// covering it
+func (idx *index) rangeRead(ctx context.Context, rr model.RangeReaderIF, r http_range.Range) (io.ReadCloser, error) {
+	if r.Length < 0 || r.Start+r.Length > idx.size {
+		r.Length = idx.size - r.Start
+	}
+	if r.Start < 0 || r.Length < 0 {
+		return nil, fmt.Errorf("invalid range: start=%d,length=%d,size=%d", r.Start, r.Length, idx.size)
+	}
+	if r.Length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	first := r.Start / idx.blockSize
+	last := (r.Start + r.Length - 1) / idx.blockSize
+	rc, err := rr.RangeRead(ctx, http_range.Range{
+		Start:  idx.offsets[first],
+		Length: idx.offsets[last+1] - idx.offsets[first],
+	})
+	if err != nil {
+		return nil, err
+	}
+	var dec io.Reader
+	closers := utils.Closers{rc}
+	if idx.algo == algoGzip {
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			_ = rc.Close()
+			return nil, err
+		}
+		dec = gr
+		closers = append(closers, gr)
+	} else {
+		zr, err := zstd.NewReader(rc, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			_ = rc.Close()
+			return nil, err
+		}
+		dec = zr
+		closers = append(closers, utils.CloseFunc(func() error {
+			zr.Close()
+			return nil
+		}))
+	}
+	if _, err := io.CopyN(io.Discard, dec, r.Start-first*idx.blockSize); err != nil {
+		_ = closers.Close()
+		return nil, err
+	}
+	return utils.ReadCloser{
+		Reader: io.LimitReader(dec, r.Length),
+		Closer: &closers,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+)
+
+type bytesRangeReader []byte
+
+func (b bytesRangeReader) RangeRead(_ context.Context, r http_range.Range) (io.ReadCloser, error) {
+	end := int64(len(b))
+	if r.Length >= 0 {
+		end = r.Start + r.Length
+	}
+	return io.NopCloser(bytes.NewReader(b[r.Start:end])), nil
+}
+
+func TestRangeRead(t *testing.T) {
+	data := make([]byte, 10000)
+	rnd := rand.New(rand.NewSource(1))
+	for i := range data {
+		data[i] = byte('a' + rnd.Intn(4))
+	}
+	for _, algo := range []byte{algoZstd, algoGzip} {
+		var buf bytes.Buffer
+		bw, err := newBlockWriter(&buf, algo, 0, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = bw.Write(data[:3000]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = bw.Write(data[3000:]); err != nil {
+			t.Fatal(err)
+		}
+		if err = bw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= len(data) {
+			t.Errorf("algo %d: not compressed, %d bytes", algo, buf.Len())
+		}
+		rr := bytesRangeReader(buf.Bytes())
+		idx, err := readIndex(context.Background(), rr, int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx.size != int64(len(data)) || len(idx.offsets) != 11 {
+			t.Fatalf("algo %d: got size %d with %d offsets", algo, idx.size, len(idx.offsets))
+		}
+		for _, r := range []http_range.Range{
+			{Start: 0, Length: -1},
+			{Start: 1000, Length: 100},
+			{Start: 1020, Length: 2000},
+			{Start: 9990, Length: 100},
+		} {
+			rc, err := idx.rangeRead(context.Background(), rr, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			end := min(r.Start+r.Length, int64(len(data)))
+			if r.Length < 0 {
+				end = int64(len(data))
+			}
+			if !bytes.Equal(got, data[r.Start:end]) {
+				t.Errorf("algo %d: range %+v mismatch", algo, r)
+			}
+		}
+	}
+}
+
+func TestReadCorruptedIndex(t *testing.T) {
+	var buf bytes.Buffer
+	bw, err := newBlockWriter(&buf, algoGzip, 0, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bw.Write(make([]byte, 3000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, corrupt := range map[string]func(footer []byte){
+		"zero block size":  func(footer []byte) { binary.BigEndian.PutUint32(footer[8:], 0) },
+		"small block size": func(footer []byte) { binary.BigEndian.PutUint32(footer[8:], 512) },
+		"large size":       func(footer []byte) { binary.BigEndian.PutUint64(footer[16:], 1<<40) },
+		"negative size":    func(footer []byte) { binary.BigEndian.PutUint64(footer[16:], 1<<63) },
+	} {
+		data := bytes.Clone(buf.Bytes())
+		corrupt(data[len(data)-footerSize:])
+		if _, err := readIndex(context.Background(), bytesRangeReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: the corrupted index is read", name)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	d := &Compress{Addition: Addition{Suffix: ".olz"}}
+	name := d.encodeName("a.b.txt", 123)
+	n, size, ok := d.decodeName(name)
+	if !ok || n != "a.b.txt" || size != 123 {
+		t.Fatalf("decode %s: %s %d %v", name, n, size, ok)
+	}
+	if _, _, ok := d.decodeName("plain.olz"); ok {
+		t.Fatal("decoded a name without size")
+	}
+}
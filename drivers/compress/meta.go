@@ -0,0 +1,30 @@
+package compress
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	RemotePath     string `json:"remote_path" required:"true"`
+	Algorithm      string `json:"algorithm" type:"select" options:"zstd,gzip" default:"zstd"`
+	Level          int    `json:"level" type:"number" default:"0" help:"0 for the default level of the algorithm, 1-22 for zstd and 1-9 for gzip"`
+	BlockSize      int    `json:"block_size" type:"number" default:"1024" help:"KB of the original data compressed in each block, a range request decompresses only the blocks it covers"`
+	SkipExtensions string `json:"skip_extensions" type:"text" default:"zip,rar,7z,gz,tgz,bz2,xz,zst,br,lz4,jpg,jpeg,png,gif,webp,avif,heic,mp3,aac,m4a,flac,ogg,opus,mp4,mkv,mov,avi,webm,flv,rmvb,pdf,docx,xlsx,pptx,apk,iso" help:"extensions of the files stored as they are, as they are already compressed"`
+	Suffix         string `json:"suffix" type:"string" default:".olz" help:"suffix of the compressed files in the remote path"`
+}
+
+var config = driver.Config{
+	Name:        "Compress",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+	NoLinkURL:   true,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Compress{}
+	})
+}
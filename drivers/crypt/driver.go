@@ -31,6 +31,10 @@ type Crypt struct {
 	model.Storage
 	Addition
 	cipher *rcCrypt.Cipher
+
+	mu   sync.Mutex
+	job  *jobResult
+	task *fs.StorageJobTask
 }
 
 const obfuscatedPrefix = "___Obfuscated___"
@@ -62,26 +66,31 @@ func (d *Crypt) Init(ctx context.Context) error {
 	d.EncryptedSuffix = utils.GetNoneEmpty(d.EncryptedSuffix, ".bin")
 	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
 
-	p, _ := strings.CutPrefix(d.Password, obfuscatedPrefix)
-	p2, _ := strings.CutPrefix(d.Salt, obfuscatedPrefix)
-	config := configmap.Simple{
-		"password":                  p,
-		"password2":                 p2,
-		"filename_encryption":       d.FileNameEnc,
-		"directory_name_encryption": d.DirNameEnc,
-		"filename_encoding":         d.FileNameEncoding,
-		"suffix":                    d.EncryptedSuffix,
-		"pass_bad_blocks":           "",
-	}
-	c, err := rcCrypt.NewCipher(config)
+	c, err := newCipher(d.Addition)
 	if err != nil {
 		return fmt.Errorf("failed to create Cipher: %w", err)
 	}
 	d.cipher = c
-
 	return nil
 }
 
+// newCipher creates the cipher of the addition whose password and salt are
+// obfuscated
+func newCipher(a Addition) (*rcCrypt.Cipher, error) {
+	p, _ := strings.CutPrefix(a.Password, obfuscatedPrefix)
+	p2, _ := strings.CutPrefix(a.Salt, obfuscatedPrefix)
+	config := configmap.Simple{
+		"password":                  p,
+		"password2":                 p2,
+		"filename_encryption":       a.FileNameEnc,
+		"directory_name_encryption": a.DirNameEnc,
+		"filename_encoding":         a.FileNameEncoding,
+		"suffix":                    a.EncryptedSuffix,
+		"pass_bad_blocks":           "",
+	}
+	return rcCrypt.NewCipher(config)
+}
+
 func (d *Crypt) updateObfusParm(str *string) error {
 	temp := *str
 	if !strings.HasPrefix(temp, obfuscatedPrefix) {
@@ -96,6 +105,11 @@ func (d *Crypt) updateObfusParm(str *string) error {
 }
 
 func (d *Crypt) Drop(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.task != nil && !d.task.Finished() {
+		fs.StorageJobTaskManager.Cancel(d.task.GetID())
+	}
 	return nil
 }
 
@@ -0,0 +1,485 @@
+package crypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdpath "path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	rcCrypt "github.com/rclone/rclone/backend/crypt"
+	"github.com/rclone/rclone/fs/config/obscure"
+	log "github.com/sirupsen/logrus"
+)
+
+type corruptFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// jobResult is the progress of a verification or a re-key, the paths in it
+// are the encrypted ones of the remote storage
+type jobResult struct {
+	mu       sync.Mutex
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Target   string        `json:"target,omitempty"`
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Checked  int           `json:"checked"`
+	Written  int           `json:"written"`
+	Skipped  int           `json:"skipped"`
+	Corrupt  []corruptFile `json:"corrupt"`
+	Errors   []string      `json:"errors"`
+	// Addition is the configuration of a storage reading the re-keyed tree
+	Addition *Addition `json:"addition,omitempty"`
+}
+
+func (r *jobResult) corrupt(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Corrupt = append(r.Corrupt, corruptFile{Path: path, Error: err.Error()})
+}
+
+func (r *jobResult) addErr(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %s", path, err.Error()))
+}
+
+func (r *jobResult) inc(counter *int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*counter++
+}
+
+// snapshot returns a copy of the result which the job doesn't update
+func (r *jobResult) snapshot() *jobResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &jobResult{
+		Method:   r.Method,
+		Path:     r.Path,
+		Target:   r.Target,
+		Started:  r.Started,
+		Finished: r.Finished,
+		Checked:  r.Checked,
+		Written:  r.Written,
+		Skipped:  r.Skipped,
+		Corrupt:  slices.Clone(r.Corrupt),
+		Errors:   slices.Clone(r.Errors),
+		Addition: r.Addition,
+	}
+}
+
+type verifyArgs struct {
+	// NamesOnly skips reading the bodies of the files
+	NamesOnly bool `json:"names_only"`
+}
+
+type rekeyArgs struct {
+	// TargetPath is where the tree re-encrypted is written to, which must be
+	// outside of the remote path
+	TargetPath       string `json:"target_path"`
+	Password         string `json:"password"`
+	Salt             string `json:"salt"`
+	FileNameEnc      string `json:"filename_encryption"`
+	DirNameEnc       string `json:"directory_name_encryption"`
+	FileNameEncoding string `json:"filename_encoding"`
+	EncryptedSuffix  string `json:"encrypted_suffix"`
+}
+
+type rcloneConfigArgs struct {
+	Name   string `json:"name"`
+	Remote string `json:"remote"`
+}
+
+// Other verifies that the tree of the object decrypts cleanly with the
+// "verify" method, re-encrypts it with another password or name encryption
+// to a target path with "rekey", and reports the progress of both with
+// "status". "rclone_config" exports the section of rclone.conf reading the
+// same remote with the rclone CLI. As the passwords are exposed by them,
+// they are available to the admin only.
+func (d *Crypt) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	if user, ok := ctx.Value(conf.UserKey).(*model.User); !ok || !user.IsAdmin() {
+		return nil, errs.PermissionDenied
+	}
+	switch args.Method {
+	case "status":
+		d.mu.Lock()
+		job, t := d.job, d.task
+		d.mu.Unlock()
+		if job == nil {
+			return map[string]any{"running": false, "job": nil}, nil
+		}
+		return map[string]any{
+			"running": !t.Finished(),
+			"task_id": t.GetID(),
+			"job":     job.snapshot(),
+		}, nil
+	case "verify":
+		var va verifyArgs
+		if err := utils.JsonConvert(args.Data, &va); err != nil {
+			return nil, err
+		}
+		if err := d.startJob(ctx, args.Obj, "verify", "", func(ctx context.Context, res *jobResult, plain string) {
+			d.verify(ctx, res, args.Obj, va.NamesOnly)
+		}); err != nil {
+			return nil, err
+		}
+		return "verification started", nil
+	case "rekey":
+		var ra rekeyArgs
+		if err := utils.JsonConvert(args.Data, &ra); err != nil {
+			return nil, err
+		}
+		target, c, err := d.rekeyTarget(ra)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.startJob(ctx, args.Obj, "rekey", target.RemotePath, func(ctx context.Context, res *jobResult, plain string) {
+			d.rekey(ctx, res, args.Obj, plain, c, target)
+		}); err != nil {
+			return nil, err
+		}
+		return "re-key started", nil
+	case "rclone_config":
+		var rc rcloneConfigArgs
+		if err := utils.JsonConvert(args.Data, &rc); err != nil {
+			return nil, err
+		}
+		return d.rcloneConfig(rc)
+	}
+	return nil, errs.NotSupport
+}
+
+// startJob runs a job on the tree of obj as a task, one at a time
+func (d *Crypt) startJob(ctx context.Context, obj model.Obj, method, target string, run func(ctx context.Context, res *jobResult, plain string)) error {
+	plain, err := d.plainPath(obj)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.task != nil && !d.task.Finished() {
+		return fmt.Errorf("a %s is already running", d.job.Method)
+	}
+	res := &jobResult{Method: method, Path: obj.GetPath(), Target: target, Started: time.Now()}
+	d.job = res
+	d.task = fs.AddStorageJob(ctx, fmt.Sprintf("crypt %s of [%s](%s)", method, d.MountPath, obj.GetPath()), func(ctx context.Context) error {
+		run(ctx, res, plain)
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		res.Finished = time.Now()
+		log.Infof("crypt %s: %s of %s checked %d files, %d corrupt, %d errors",
+			d.MountPath, method, res.Path, res.Checked, len(res.Corrupt), len(res.Errors))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(res.Corrupt) > 0 || len(res.Errors) > 0 {
+			return fmt.Errorf("%d corrupt files, %d errors", len(res.Corrupt), len(res.Errors))
+		}
+		return nil
+	}, func() string {
+		res.mu.Lock()
+		defer res.mu.Unlock()
+		return fmt.Sprintf("checked %d files, %d corrupt, %d errors", res.Checked, len(res.Corrupt), len(res.Errors))
+	})
+	return nil
+}
+
+// plainPath returns the decrypted path of obj relative to the remote path
+func (d *Crypt) plainPath(obj model.Obj) (string, error) {
+	if !utils.IsSubPath(d.RemotePath, obj.GetPath()) {
+		return "", errors.New("the object is outside of the remote path")
+	}
+	rel := strings.Trim(strings.TrimPrefix(obj.GetPath(), d.RemotePath), "/")
+	if rel == "" {
+		return "/", nil
+	}
+	if obj.IsDir() {
+		plain, err := d.cipher.DecryptDirName(rel)
+		return "/" + plain, err
+	}
+	dir, name := stdpath.Split(rel)
+	plainName, err := d.cipher.DecryptFileName(name)
+	if err != nil {
+		return "", err
+	}
+	plainDir, err := d.cipher.DecryptDirName(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return "", err
+	}
+	return stdpath.Join("/", plainDir, plainName), nil
+}
+
+// walk calls fn on every object of the remote dir with its decrypted path,
+// the objects whose names don't decrypt are reported as corrupt
+func (d *Crypt) walk(ctx context.Context, res *jobResult, dir, plain string, fn func(remote, plain string, obj model.Obj) error) error {
+	objs, err := fs.List(ctx, dir, &fs.ListArgs{NoLog: true, Refresh: true})
+	if err != nil {
+		res.addErr(dir, err)
+		return nil
+	}
+	for _, obj := range objs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if model.GetObjMask(obj)&model.Virtual != 0 {
+			continue
+		}
+		remote := stdpath.Join(dir, obj.GetName())
+		var name string
+		if obj.IsDir() {
+			name, err = d.cipher.DecryptDirName(model.UnwrapObjName(obj).GetName())
+		} else {
+			name, err = d.cipher.DecryptFileName(model.UnwrapObjName(obj).GetName())
+		}
+		if err != nil {
+			res.corrupt(remote, fmt.Errorf("invalid name: %w", err))
+			continue
+		}
+		p := stdpath.Join(plain, name)
+		if err := fn(remote, p, obj); err != nil {
+			return err
+		}
+		if obj.IsDir() {
+			if err := d.walk(ctx, res, remote, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkObj walks the tree of obj, or calls fn on obj only if it is a file
+func (d *Crypt) walkObj(ctx context.Context, res *jobResult, obj model.Obj, plain string, fn func(remote, plain string, obj model.Obj) error) {
+	var err error
+	if obj.IsDir() {
+		err = d.walk(ctx, res, obj.GetPath(), plain, fn)
+	} else {
+		err = fn(obj.GetPath(), plain, obj)
+	}
+	if err != nil {
+		res.addErr(obj.GetPath(), err)
+	}
+}
+
+// openDecrypted opens the body of a remote file decrypted
+func (d *Crypt) openDecrypted(ctx context.Context, remote string, size int64) (io.ReadCloser, error) {
+	link, _, err := fs.Link(ctx, remote, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	rr, err := stream.GetRangeReaderFromLink(size, link)
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	decrypted, err := d.cipher.DecryptData(rc)
+	if err != nil {
+		_ = rc.Close()
+		_ = link.Close()
+		return nil, err
+	}
+	return utils.ReadCloser{
+		Reader: decrypted,
+		Closer: utils.CloseFunc(func() error {
+			return errors.Join(decrypted.Close(), link.Close())
+		}),
+	}, nil
+}
+
+func (d *Crypt) verify(ctx context.Context, res *jobResult, root model.Obj, namesOnly bool) {
+	d.walkObj(ctx, res, root, "/", func(remote, _ string, obj model.Obj) error {
+		if obj.IsDir() {
+			return nil
+		}
+		size, err := d.cipher.DecryptedSize(obj.GetSize())
+		if err != nil {
+			res.corrupt(remote, fmt.Errorf("invalid size: %w", err))
+			return nil
+		}
+		if !namesOnly {
+			rc, err := d.openDecrypted(ctx, remote, obj.GetSize())
+			if err != nil {
+				res.addErr(remote, err)
+				return nil
+			}
+			n, err := utils.CopyWithBuffer(io.Discard, rc)
+			_ = rc.Close()
+			if err == nil && n != size {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				res.corrupt(remote, err)
+				return nil
+			}
+		}
+		res.inc(&res.Checked)
+		return nil
+	})
+}
+
+// rekeyTarget returns the addition of the re-keyed tree and its cipher
+func (d *Crypt) rekeyTarget(ra rekeyArgs) (*Addition, *rcCrypt.Cipher, error) {
+	if ra.TargetPath == "" || ra.Password == "" {
+		return nil, nil, errors.New("the target path and the password are required")
+	}
+	target := d.Addition
+	target.RemotePath = utils.FixAndCleanPath(ra.TargetPath)
+	if utils.IsSubPath(target.RemotePath, d.RemotePath) || utils.IsSubPath(d.RemotePath, target.RemotePath) ||
+		utils.IsSubPath(d.MountPath, target.RemotePath) {
+		return nil, nil, errors.New("the target path must be outside of the remote path and the storage")
+	}
+	target.Password = ra.Password
+	target.Salt = ra.Salt
+	target.FileNameEnc = utils.GetNoneEmpty(ra.FileNameEnc, d.FileNameEnc)
+	target.DirNameEnc = utils.GetNoneEmpty(ra.DirNameEnc, d.DirNameEnc)
+	target.FileNameEncoding = utils.GetNoneEmpty(ra.FileNameEncoding, d.FileNameEncoding)
+	target.EncryptedSuffix = utils.GetNoneEmpty(ra.EncryptedSuffix, d.EncryptedSuffix)
+	if err := d.updateObfusParm(&target.Password); err != nil {
+		return nil, nil, err
+	}
+	if err := d.updateObfusParm(&target.Salt); err != nil {
+		return nil, nil, err
+	}
+	c, err := newCipher(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &target, c, nil
+}
+
+// rekey writes the tree of root re-encrypted by c to the target path, the
+// files written already with the expected size are skipped so that a re-key
+// interrupted can be resumed
+func (d *Crypt) rekey(ctx context.Context, res *jobResult, root model.Obj, rootPlain string, c *rcCrypt.Cipher, target *Addition) {
+	dstPath := func(plain string, isDir bool) string {
+		if plain == "/" {
+			return target.RemotePath
+		}
+		if isDir {
+			return stdpath.Join(target.RemotePath, c.EncryptDirName(strings.TrimPrefix(plain, "/")))
+		}
+		dir, name := stdpath.Split(plain)
+		return stdpath.Join(target.RemotePath, c.EncryptDirName(strings.Trim(dir, "/")), c.EncryptFileName(name))
+	}
+	if root.IsDir() {
+		if err := fs.MakeDir(ctx, dstPath(rootPlain, true)); err != nil {
+			res.addErr(root.GetPath(), err)
+			return
+		}
+	}
+	d.walkObj(ctx, res, root, rootPlain, func(remote, plain string, obj model.Obj) error {
+		dst := dstPath(plain, obj.IsDir())
+		if obj.IsDir() {
+			if err := fs.MakeDir(ctx, dst); err != nil {
+				res.addErr(remote, err)
+			}
+			return nil
+		}
+		res.inc(&res.Checked)
+		size, err := d.cipher.DecryptedSize(obj.GetSize())
+		if err != nil {
+			res.corrupt(remote, fmt.Errorf("invalid size: %w", err))
+			return nil
+		}
+		encryptedSize := c.EncryptedSize(size)
+		if exist, err := fs.Get(ctx, dst, &fs.GetArgs{NoLog: true}); err == nil && exist.GetSize() == encryptedSize {
+			res.inc(&res.Skipped)
+			return nil
+		}
+		if err := d.rekeyFile(ctx, c, remote, obj, dst, encryptedSize); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			res.addErr(remote, err)
+			return nil
+		}
+		res.inc(&res.Written)
+		return nil
+	})
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if len(res.Errors) == 0 && len(res.Corrupt) == 0 {
+		res.Addition = target
+	}
+}
+
+func (d *Crypt) rekeyFile(ctx context.Context, c *rcCrypt.Cipher, remote string, obj model.Obj, dst string, encryptedSize int64) error {
+	rc, err := d.openDecrypted(ctx, remote, obj.GetSize())
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	encrypted, err := c.EncryptData(rc)
+	if err != nil {
+		return err
+	}
+	dir, name := stdpath.Split(dst)
+	return fs.PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     encryptedSize,
+			Modified: obj.ModTime(),
+		},
+		Reader:            encrypted,
+		Mimetype:          "application/octet-stream",
+		ForceStreamUpload: true,
+	})
+}
+
+// rcloneConfig returns the section of rclone.conf of a crypt remote on the
+// remote given, which defaults to a remote named "remote" holding the remote
+// path of the storage at the same path
+func (d *Crypt) rcloneConfig(args rcloneConfigArgs) (string, error) {
+	name := utils.GetNoneEmpty(args.Name, "openlist-crypt")
+	remote := args.Remote
+	if remote == "" {
+		path := d.RemotePath
+		if _, actualPath, err := op.GetStorageAndActualPath(d.RemotePath); err == nil {
+			path = actualPath
+		}
+		remote = "remote:" + strings.TrimPrefix(path, "/")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", name)
+	fmt.Fprintf(&b, "type = crypt\n")
+	fmt.Fprintf(&b, "remote = %s\n", remote)
+	fmt.Fprintf(&b, "filename_encryption = %s\n", d.FileNameEnc)
+	fmt.Fprintf(&b, "directory_name_encryption = %s\n", d.DirNameEnc)
+	fmt.Fprintf(&b, "filename_encoding = %s\n", d.FileNameEncoding)
+	fmt.Fprintf(&b, "suffix = %s\n", d.EncryptedSuffix)
+	// both are obscured the same way as rclone does
+	fmt.Fprintf(&b, "password = %s\n", strings.TrimPrefix(d.Password, obfuscatedPrefix))
+	salt := strings.TrimPrefix(d.Salt, obfuscatedPrefix)
+	revealed, err := obscure.Reveal(salt)
+	if err != nil {
+		return "", fmt.Errorf("failed reveal the salt: %w", err)
+	}
+	if revealed != "" {
+		fmt.Fprintf(&b, "password2 = %s\n", salt)
+	}
+	return b.String(), nil
+}
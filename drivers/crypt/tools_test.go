@@ -0,0 +1,186 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/tache"
+	"github.com/glebarez/sqlite"
+	rcCrypt "github.com/rclone/rclone/backend/crypt"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+	fs.StorageJobTaskManager = tache.NewManager[*fs.StorageJobTask](tache.WithWorks(1))
+}
+
+// setupCrypt mounts a temp dir as the remote path of a crypt storage
+func setupCrypt(t *testing.T, name string) (*Crypt, string) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	remote := "/" + name + "_remote"
+	_, err := op.CreateStorage(ctx, model.Storage{
+		Driver:    "Local",
+		MountPath: remote,
+		Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed create the remote storage: %+v", err)
+	}
+	_, err = op.CreateStorage(ctx, model.Storage{
+		Driver:    "Crypt",
+		MountPath: "/" + name,
+		Addition: fmt.Sprintf(`{"remote_path":%q,"password":"pass","salt":"salt","filename_encryption":"standard",`+
+			`"directory_name_encryption":"true","encrypted_suffix":".bin","filename_encoding":"base64"}`, remote),
+	})
+	if err != nil {
+		t.Fatalf("failed create the crypt storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.(*Crypt), dir
+}
+
+// writeEncrypted writes the file of content encrypted by c into dir
+func writeEncrypted(t *testing.T, c *rcCrypt.Cipher, dir, name, content string) string {
+	r, err := c.EncryptData(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, c.EncryptFileName(name))
+	if err := os.WriteFile(path, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// root returns the root of d as it is given to Other
+func root(t *testing.T, d *Crypt) model.Obj {
+	obj, err := op.GetUnwrap(context.Background(), d, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestVerify(t *testing.T) {
+	d, dir := setupCrypt(t, "crypt_verify")
+	writeEncrypted(t, d.cipher, dir, "good.txt", "good content")
+	bad := writeEncrypted(t, d.cipher, dir, "bad.txt", "bad content")
+	data, err := os.ReadFile(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(bad, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("plain"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &jobResult{}
+	d.verify(context.Background(), res, root(t, d), false)
+	if len(res.Errors) > 0 {
+		t.Fatalf("verify failed: %v", res.Errors)
+	}
+	if res.Checked != 1 || len(res.Corrupt) != 2 {
+		t.Errorf("checked %d files and found %d corrupt, want 1 and 2: %+v", res.Checked, len(res.Corrupt), res.Corrupt)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	d, dir := setupCrypt(t, "crypt_rekey")
+	writeEncrypted(t, d.cipher, dir, "a.txt", "rekeyed content")
+	targetDir := t.TempDir()
+	_, err := op.CreateStorage(context.Background(), model.Storage{
+		Driver:    "Local",
+		MountPath: "/crypt_rekey_target",
+		Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, targetDir),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, c, err := d.rekeyTarget(rekeyArgs{TargetPath: "/crypt_rekey_target", Password: "another"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &jobResult{}
+	d.rekey(context.Background(), res, root(t, d), "/", c, target)
+	if len(res.Errors) > 0 || len(res.Corrupt) > 0 {
+		t.Fatalf("rekey failed: %v %v", res.Errors, res.Corrupt)
+	}
+	if res.Written != 1 || res.Addition == nil {
+		t.Fatalf("wrote %d files, want 1", res.Written)
+	}
+	f, err := os.Open(filepath.Join(targetDir, c.EncryptFileName("a.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := c.DecryptData(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "rekeyed content" {
+		t.Errorf("the file re-keyed is %q", got)
+	}
+}
+
+func TestJobAsTask(t *testing.T) {
+	d, dir := setupCrypt(t, "crypt_task")
+	writeEncrypted(t, d.cipher, dir, "a.txt", "content")
+	ctx := context.WithValue(context.Background(), conf.UserKey, &model.User{Username: "admin", Role: model.ADMIN})
+	args := model.OtherArgs{Obj: root(t, d), Method: "verify"}
+	if _, err := d.Other(ctx, args); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !d.task.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatal("the verification doesn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d.task.GetState() != tache.StateSucceeded {
+		t.Errorf("the task is in state %v: %v", d.task.GetState(), d.task.GetErr())
+	}
+	if _, ok := fs.StorageJobTaskManager.GetByID(d.task.GetID()); !ok {
+		t.Errorf("the job isn't in the task manager")
+	}
+	if got := d.task.GetStatus(); got != "checked 1 files, 0 corrupt, 0 errors" {
+		t.Errorf("the status of the task is %q", got)
+	}
+}
+
+func TestRcloneConfigInvalidSalt(t *testing.T) {
+	d := &Crypt{Addition: Addition{Salt: obfuscatedPrefix + "not obscured"}}
+	if _, err := d.rcloneConfig(rcloneConfigArgs{Remote: "remote:"}); err == nil {
+		t.Errorf("the salt which can't be revealed is exported")
+	}
+}
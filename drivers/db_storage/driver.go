@@ -0,0 +1,356 @@
+package db_storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/node_tree"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// orphanGrace keeps the blobs being written, whose files are not created yet
+const orphanGrace = 24 * time.Hour
+
+// DBStorage keeps the tree of its files and their bodies in chunks in the
+// database of OpenList
+type DBStorage struct {
+	model.Storage
+	Addition
+	tree *node_tree.Tree[model.DBStorageNode, *model.DBStorageNode]
+}
+
+func (d *DBStorage) Config() driver.Config {
+	return config
+}
+
+func (d *DBStorage) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *DBStorage) Init(ctx context.Context) error {
+	if d.ChunkSize <= 0 {
+		d.ChunkSize = 1024
+	}
+	d.tree = &node_tree.Tree[model.DBStorageNode, *model.DBStorageNode]{Store: nodeStore(d.ID)}
+	if err := op.DeleteOrphanDBStorageData(time.Now().Add(-orphanGrace)); err != nil {
+		log.Warnf("db storage %s: failed delete the orphan data: %+v", d.MountPath, err)
+	}
+	return nil
+}
+
+func (d *DBStorage) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *DBStorage) GetRoot(ctx context.Context) (model.Obj, error) {
+	return node_tree.Root(d.Modified), nil
+}
+
+// nodeStore keeps the nodes of the storage of the id
+type nodeStore uint
+
+func (s nodeStore) GetNode(parentID uint, name string) (*model.DBStorageNode, error) {
+	return op.GetDBStorageNode(uint(s), parentID, name)
+}
+
+func (s nodeStore) GetNodeById(id uint) (*model.DBStorageNode, error) {
+	n, err := op.GetDBStorageNodeById(id)
+	if err == nil && n.StorageID != uint(s) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return n, err
+}
+
+func (s nodeStore) GetChildren(parentID uint) ([]model.DBStorageNode, error) {
+	return op.GetDBStorageChildren(uint(s), parentID)
+}
+
+func (s nodeStore) CreateNode(n *model.DBStorageNode) error {
+	return op.CreateDBStorageNode(n)
+}
+
+func (s nodeStore) UpdateNode(n *model.DBStorageNode) error {
+	return op.UpdateDBStorageNode(n)
+}
+
+func (s nodeStore) DeleteNodes(ids []uint) error {
+	return op.DeleteDBStorageNodes(ids)
+}
+
+func (d *DBStorage) Get(ctx context.Context, path string) (model.Obj, error) {
+	path = utils.FixAndCleanPath(path)
+	if path == "/" {
+		return d.GetRoot(ctx)
+	}
+	return d.tree.Get(path)
+}
+
+func (d *DBStorage) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return d.tree.List(dir)
+}
+
+func (d *DBStorage) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	n, err := d.tree.GetNodeById(node_tree.ID(file))
+	if err != nil {
+		return nil, err
+	}
+	if n.IsDir {
+		return nil, errs.NotFile
+	}
+	var chunkSize int64
+	if n.Size > 0 {
+		blob, err := op.GetDBStorageBlob(n.BlobID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ObjectNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunkSize = blob.ChunkSize
+	}
+	return &model.Link{
+		RangeReader: stream.RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+			length := httpRange.Length
+			if length < 0 || httpRange.Start+length > n.Size {
+				length = n.Size - httpRange.Start
+			}
+			if length <= 0 {
+				return io.NopCloser(strings.NewReader("")), nil
+			}
+			r := &chunkReader{
+				ctx:       ctx,
+				blobID:    n.BlobID,
+				seq:       int(httpRange.Start / chunkSize),
+				skip:      httpRange.Start % chunkSize,
+				remaining: length,
+			}
+			return io.NopCloser(r), nil
+		}),
+		ContentLength: n.Size,
+	}, nil
+}
+
+// chunkReader reads a blob from the chunk seq, skipping the first bytes
+type chunkReader struct {
+	ctx       context.Context
+	blobID    uint
+	seq       int
+	skip      int64
+	buf       []byte
+	remaining int64
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if len(r.buf) == 0 {
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+		c, err := op.GetDBStorageChunk(r.blobID, r.seq)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.seq++
+		r.buf = c.Data[min(r.skip, int64(len(c.Data))):]
+		r.skip = 0
+		if len(r.buf) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	n := copy(p[:min(int64(len(p)), r.remaining)], r.buf)
+	r.buf = r.buf[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func (d *DBStorage) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	now := time.Now()
+	return d.tree.MakeDir(node_tree.ID(parentDir), dirName, &model.DBStorageNode{
+		StorageID: d.ID,
+		IsDir:     true,
+		Modified:  now,
+		Created:   now,
+	})
+}
+
+func (d *DBStorage) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.tree.Move(srcObj, dstDir)
+}
+
+func (d *DBStorage) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	return d.tree.Rename(srcObj, newName)
+}
+
+// Copy checks the space for each file, as the size of a directory is unknown
+func (d *DBStorage) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.tree.Copy(ctx, srcObj, dstDir, func(n *model.DBStorageNode) error {
+		if err := d.checkSpace(n.Size, 0); err != nil {
+			return err
+		}
+		blobID, err := d.copyBlob(ctx, n.BlobID, n.Size)
+		if err != nil {
+			return err
+		}
+		n.BlobID = blobID
+		if err := op.SaveDBStorageFile(n); err != nil {
+			_ = op.DeleteDBStorageBlobs([]uint{blobID})
+			return err
+		}
+		return nil
+	})
+}
+
+func (d *DBStorage) copyBlob(ctx context.Context, srcID uint, size int64) (uint, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	src, err := op.GetDBStorageBlob(srcID)
+	if err != nil {
+		return 0, err
+	}
+	blob := &model.DBStorageBlob{StorageID: d.ID, ChunkSize: src.ChunkSize, Created: time.Now()}
+	if err := op.CreateDBStorageBlob(blob); err != nil {
+		return 0, err
+	}
+	count := int((size + src.ChunkSize - 1) / src.ChunkSize)
+	for seq := 0; seq < count; seq++ {
+		err := ctx.Err()
+		var c *model.DBStorageChunk
+		if err == nil {
+			c, err = op.GetDBStorageChunk(srcID, seq)
+		}
+		if err == nil {
+			err = op.CreateDBStorageChunk(&model.DBStorageChunk{BlobID: blob.ID, Seq: seq, Data: c.Data})
+		}
+		if err != nil {
+			_ = op.DeleteDBStorageBlobs([]uint{blob.ID})
+			return 0, err
+		}
+	}
+	return blob.ID, nil
+}
+
+func (d *DBStorage) Remove(ctx context.Context, obj model.Obj) error {
+	return d.tree.Remove(obj)
+}
+
+// checkSpace fails if the files exceed the max size after size is added and
+// freed is removed
+func (d *DBStorage) checkSpace(size, freed int64) error {
+	if d.MaxSize <= 0 {
+		return nil
+	}
+	used, err := op.GetDBStorageUsed(d.ID)
+	if err != nil {
+		return err
+	}
+	if used-freed+size > int64(d.MaxSize)*utils.MB {
+		return fmt.Errorf("not enough space, %d bytes used of %d MB", used, d.MaxSize)
+	}
+	return nil
+}
+
+func (d *DBStorage) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	replaced, err := d.tree.CheckName(node_tree.ID(dstDir), file.GetName(), false)
+	if err != nil {
+		return err
+	}
+	var freed int64
+	if replaced != nil {
+		freed = replaced.Size
+	}
+	if err := d.checkSpace(file.GetSize(), freed); err != nil {
+		return err
+	}
+	chunkSize := int64(d.ChunkSize) * utils.KB
+	blob := &model.DBStorageBlob{StorageID: d.ID, ChunkSize: chunkSize, Created: time.Now()}
+	if err := op.CreateDBStorageBlob(blob); err != nil {
+		return err
+	}
+	size, err := d.writeChunks(ctx, blob.ID, chunkSize, &driver.ReaderUpdatingProgress{
+		Reader:         file,
+		UpdateProgress: up,
+	})
+	if err == nil && file.GetSize() > 0 && size != file.GetSize() {
+		err = errs.StreamIncomplete
+	}
+	if err != nil {
+		_ = op.DeleteDBStorageBlobs([]uint{blob.ID})
+		return err
+	}
+	n := &model.DBStorageNode{
+		StorageID: d.ID,
+		ParentID:  node_tree.ID(dstDir),
+		Name:      file.GetName(),
+		Size:      size,
+		BlobID:    blob.ID,
+		Modified:  file.ModTime(),
+		Created:   time.Now(),
+	}
+	if replaced != nil {
+		n.ID = replaced.ID
+		n.Created = replaced.Created
+	}
+	if err := op.SaveDBStorageFile(n); err != nil {
+		_ = op.DeleteDBStorageBlobs([]uint{blob.ID})
+		return err
+	}
+	return nil
+}
+
+func (d *DBStorage) writeChunks(ctx context.Context, blobID uint, chunkSize int64, r io.Reader) (int64, error) {
+	buf := make([]byte, chunkSize)
+	var size int64
+	for seq := 0; ; seq++ {
+		if err := ctx.Err(); err != nil {
+			return size, err
+		}
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := op.CreateDBStorageChunk(&model.DBStorageChunk{BlobID: blobID, Seq: seq, Data: buf[:n]}); err != nil {
+				return size, err
+			}
+			size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+	}
+}
+
+// GetDetails reports the space used by the files, the total space is the
+// max size or 0 if it is unlimited
+func (d *DBStorage) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
+	used, err := op.GetDBStorageUsed(d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StorageDetails{
+		DiskUsage: model.DiskUsage{
+			TotalSpace: int64(d.MaxSize) * utils.MB,
+			UsedSpace:  used,
+		},
+	}, nil
+}
+
+var _ driver.Driver = (*DBStorage)(nil)
@@ -0,0 +1,116 @@
+package db_storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/node_tree"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+}
+
+// setupDBStorage creates a db storage of chunks of 1 KB holding up to
+// maxSize MB
+func setupDBStorage(t *testing.T, name string, maxSize int) *DBStorage {
+	_, err := op.CreateStorage(context.Background(), model.Storage{
+		Driver:    "DB Storage",
+		MountPath: "/" + name,
+		Addition:  fmt.Sprintf(`{"chunk_size":1,"max_size":%d}`, maxSize),
+	})
+	if err != nil {
+		t.Fatalf("failed create the db storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.(*DBStorage)
+}
+
+func put(t *testing.T, d *DBStorage, dir, name, content string) {
+	ctx := context.Background()
+	dirObj, err := d.Get(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Put(ctx, dirObj, &stream.FileStream{
+		Obj:    &model.Object{Name: name, Size: int64(len(content)), Modified: time.Now()},
+		Reader: strings.NewReader(content),
+	}, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteStorageData(t *testing.T) {
+	d := setupDBStorage(t, "db_storage_delete", 0)
+	put(t, d, "/", "a.txt", "content")
+	obj, err := d.Get(context.Background(), "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := op.GetDBStorageNodeById(node_tree.ID(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := op.DeleteStorageById(context.Background(), d.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.GetDBStorageNodeById(n.ID); err == nil {
+		t.Errorf("the file of the storage deleted is kept")
+	}
+	if _, err := op.GetDBStorageBlob(n.BlobID); err == nil {
+		t.Errorf("the blob of the storage deleted is kept")
+	}
+	if _, err := op.GetDBStorageChunk(n.BlobID, 0); err == nil {
+		t.Errorf("the chunks of the storage deleted are kept")
+	}
+}
+
+func TestCopyDirChecksSpace(t *testing.T) {
+	d := setupDBStorage(t, "db_storage_copy", 1)
+	ctx := context.Background()
+	root, err := d.GetRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.MakeDir(ctx, root, "dir"); err != nil {
+		t.Fatal(err)
+	}
+	put(t, d, "/dir", "a", strings.Repeat("a", 400*1024))
+	put(t, d, "/dir", "b", strings.Repeat("b", 400*1024))
+	if err := d.MakeDir(ctx, root, "copy"); err != nil {
+		t.Fatal(err)
+	}
+	src, err := d.Get(ctx, "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := d.Get(ctx, "/copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Copy(ctx, src, dst); err == nil || !strings.Contains(err.Error(), "not enough space") {
+		t.Errorf("the dir is copied beyond the max size: %v", err)
+	}
+	if used, err := op.GetDBStorageUsed(d.ID); err != nil || used > 1024*1024 {
+		t.Errorf("used %d bytes of 1 MB: %v", used, err)
+	}
+}
@@ -0,0 +1,26 @@
+package db_storage
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	ChunkSize int `json:"chunk_size" type:"number" default:"1024" help:"The size in KB of the chunks the files are stored in"`
+	MaxSize   int `json:"max_size" type:"number" default:"0" help:"The total size in MB of the files, 0 for unlimited"`
+}
+
+var config = driver.Config{
+	Name:        "DB Storage",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+	NoLinkURL:   true,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &DBStorage{}
+	})
+}
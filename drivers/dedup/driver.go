@@ -0,0 +1,263 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/node_tree"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Dedup keeps the tree of its files in the database while their bodies are
+// kept once per content in the backing path, at /<hash[0:2]>/<hash[2:4]>/<hash>
+type Dedup struct {
+	model.Storage
+	Addition
+
+	tree   *node_tree.Tree[model.DedupNode, *model.DedupNode]
+	mu     sync.Mutex
+	gcing  bool
+	lastGC *gcResult
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (d *Dedup) Config() driver.Config {
+	return config
+}
+
+func (d *Dedup) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Dedup) Init(ctx context.Context) error {
+	d.BackingPath = utils.FixAndCleanPath(d.BackingPath)
+	if utils.IsSubPath(d.MountPath, d.BackingPath) {
+		return errors.New("the backing path is inside the storage itself")
+	}
+	d.tree = &node_tree.Tree[model.DedupNode, *model.DedupNode]{Store: nodeStore(d.ID)}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if d.GCInterval > 0 {
+		go d.background(d.ctx)
+	}
+	return nil
+}
+
+func (d *Dedup) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+	return nil
+}
+
+func (d *Dedup) GetRoot(ctx context.Context) (model.Obj, error) {
+	return node_tree.Root(d.Modified), nil
+}
+
+func blobPath(hash string) string {
+	return stdpath.Join("/", hash[0:2], hash[2:4], hash)
+}
+
+// nodeStore keeps the nodes of the storage of the id
+type nodeStore uint
+
+func (s nodeStore) GetNode(parentID uint, name string) (*model.DedupNode, error) {
+	return op.GetDedupNode(uint(s), parentID, name)
+}
+
+func (s nodeStore) GetNodeById(id uint) (*model.DedupNode, error) {
+	n, err := op.GetDedupNodeById(id)
+	if err == nil && n.StorageID != uint(s) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return n, err
+}
+
+func (s nodeStore) GetChildren(parentID uint) ([]model.DedupNode, error) {
+	return op.GetDedupChildren(uint(s), parentID)
+}
+
+func (s nodeStore) CreateNode(n *model.DedupNode) error {
+	return op.CreateDedupNode(n)
+}
+
+func (s nodeStore) UpdateNode(n *model.DedupNode) error {
+	return op.UpdateDedupNode(n)
+}
+
+func (s nodeStore) DeleteNodes(ids []uint) error {
+	return op.DeleteDedupNodes(ids)
+}
+
+func (d *Dedup) Get(ctx context.Context, path string) (model.Obj, error) {
+	path = utils.FixAndCleanPath(path)
+	if path == "/" {
+		return d.GetRoot(ctx)
+	}
+	return d.tree.Get(path)
+}
+
+func (d *Dedup) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return d.tree.List(dir)
+}
+
+func (d *Dedup) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	hash := file.GetHash().GetHash(utils.SHA256)
+	if len(hash) != sha256.Size*2 {
+		return nil, errs.NotFile
+	}
+	storage, actualPath, err := op.GetStorageAndActualPath(stdpath.Join(d.BackingPath, blobPath(hash)))
+	if err != nil {
+		return nil, err
+	}
+	l, _, err := op.Link(ctx, storage, actualPath, args)
+	if err != nil {
+		return nil, err
+	}
+	link := l.Clone()
+	if link.ContentLength == 0 {
+		link.ContentLength = file.GetSize()
+	}
+	return link, nil
+}
+
+func (d *Dedup) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	now := time.Now()
+	return d.tree.MakeDir(node_tree.ID(parentDir), dirName, &model.DedupNode{
+		StorageID: d.ID,
+		IsDir:     true,
+		Modified:  now,
+		Created:   now,
+	})
+}
+
+func (d *Dedup) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.tree.Move(srcObj, dstDir)
+}
+
+func (d *Dedup) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	return d.tree.Rename(srcObj, newName)
+}
+
+// Copy copies only the nodes, the bodies are shared
+func (d *Dedup) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.tree.Copy(ctx, srcObj, dstDir, func(n *model.DedupNode) error {
+		if n.ID != 0 {
+			return op.UpdateDedupNode(n)
+		}
+		return op.CreateDedupNode(n)
+	})
+}
+
+// Remove removes only the nodes, the bodies are removed by the gc once no
+// file refers to them
+func (d *Dedup) Remove(ctx context.Context, obj model.Obj) error {
+	return d.tree.Remove(obj)
+}
+
+func (d *Dedup) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	replaced, err := d.tree.CheckName(node_tree.ID(dstDir), file.GetName(), false)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	f, err := file.CacheFullAndWriter(&up, h)
+	if err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	// the gc doesn't remove the blob until the node refers to it
+	gcLock.RLock()
+	defer gcLock.RUnlock()
+	storage, actualPath, err := op.GetStorageAndActualPath(stdpath.Join(d.BackingPath, blobPath(hash)))
+	if err != nil {
+		return err
+	}
+	if blob, err := op.Get(ctx, storage, actualPath); err != nil || blob.GetSize() != file.GetSize() {
+		err = op.Put(ctx, storage, stdpath.Dir(actualPath), &stream.FileStream{
+			Obj: &model.Object{
+				Name:     hash,
+				Size:     file.GetSize(),
+				Modified: time.Now(),
+				HashInfo: utils.NewHashInfo(utils.SHA256, hash),
+			},
+			Mimetype: "application/octet-stream",
+			Reader:   f,
+		}, up)
+		if err != nil {
+			return fmt.Errorf("failed put the body: %w", err)
+		}
+	} else {
+		up(100)
+	}
+	n := &model.DedupNode{
+		StorageID: d.ID,
+		ParentID:  node_tree.ID(dstDir),
+		Name:      file.GetName(),
+		Size:      file.GetSize(),
+		Hash:      hash,
+		Modified:  file.ModTime(),
+		Created:   time.Now(),
+	}
+	if replaced != nil {
+		n.ID = replaced.ID
+		n.Created = replaced.Created
+		return op.UpdateDedupNode(n)
+	}
+	return op.CreateDedupNode(n)
+}
+
+// Other starts the gc with the "gc" method and reports the last one with
+// "status"
+func (d *Dedup) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "gc":
+		if !d.startGC() {
+			return nil, errors.New("the gc is already running")
+		}
+		return "gc started", nil
+	case "status":
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return map[string]any{
+			"gc_running": d.gcing,
+			"last_gc":    d.lastGC,
+		}, nil
+	}
+	return nil, errs.NotSupport
+}
+
+func (d *Dedup) background(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(d.GCInterval) * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !d.startGC() {
+				log.Warnf("dedup %s: skipped the gc as the last one is still running", d.MountPath)
+			}
+		}
+	}
+}
+
+var _ driver.Driver = (*Dedup)(nil)
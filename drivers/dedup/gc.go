@@ -0,0 +1,156 @@
+package dedup
+
+import (
+	"context"
+	"encoding/hex"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	log "github.com/sirupsen/logrus"
+)
+
+// gcGrace keeps the blobs put recently, whose nodes may be not created yet
+const gcGrace = time.Hour
+
+// gcLock is held by the puts while they refer to a blob, and by the gc while
+// it removes the blobs, which may be shared by several dedup storages
+var gcLock sync.RWMutex
+
+type gcResult struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Blobs    int       `json:"blobs"`
+	Removed  int       `json:"removed"`
+	Freed    int64     `json:"freed"`
+	Errors   []string  `json:"errors"`
+}
+
+func (d *Dedup) startGC() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.gcing {
+		return false
+	}
+	d.gcing = true
+	go func() {
+		res := d.gc(d.ctx)
+		log.Infof("dedup %s: gc checked %d blobs, removed %d, freed %d bytes, %d errors",
+			d.MountPath, res.Blobs, res.Removed, res.Freed, len(res.Errors))
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.gcing = false
+		d.lastGC = res
+	}()
+	return true
+}
+
+func isBlobName(name string) bool {
+	if len(name) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// gc removes the blobs of the backing path no file of any dedup storage
+// refers to
+func (d *Dedup) gc(ctx context.Context) *gcResult {
+	res := &gcResult{Started: time.Now()}
+	defer func() { res.Finished = time.Now() }()
+	addErr := func(err error) {
+		res.Errors = append(res.Errors, err.Error())
+	}
+	if err := op.DeleteOrphanDedupNodes(); err != nil {
+		addErr(err)
+	}
+	storage, actualPath, err := op.GetStorageAndActualPath(d.BackingPath)
+	if err != nil {
+		addErr(err)
+		return res
+	}
+	referenced, err := d.referenced()
+	if err != nil {
+		addErr(err)
+		return res
+	}
+	var candidates []model.Obj
+	var candidatePaths []string
+	// the blobs are 2 levels deep
+	dirs := []string{actualPath}
+	for depth := 0; depth < 2; depth++ {
+		var next []string
+		for _, dir := range dirs {
+			objs, err := op.List(ctx, storage, dir, model.ListArgs{Refresh: true})
+			if err != nil {
+				addErr(err)
+				continue
+			}
+			for _, obj := range objs {
+				if obj.IsDir() && len(obj.GetName()) == 2 {
+					next = append(next, stdpath.Join(dir, obj.GetName()))
+				}
+			}
+		}
+		dirs = next
+	}
+	for _, dir := range dirs {
+		if ctx.Err() != nil {
+			addErr(ctx.Err())
+			return res
+		}
+		objs, err := op.List(ctx, storage, dir, model.ListArgs{Refresh: true})
+		if err != nil {
+			addErr(err)
+			continue
+		}
+		for _, obj := range objs {
+			if obj.IsDir() || !isBlobName(obj.GetName()) {
+				continue
+			}
+			res.Blobs++
+			if _, ok := referenced[obj.GetName()]; ok || time.Since(obj.ModTime()) < gcGrace {
+				continue
+			}
+			candidates = append(candidates, obj)
+			candidatePaths = append(candidatePaths, stdpath.Join(dir, obj.GetName()))
+		}
+	}
+	if len(candidates) == 0 {
+		return res
+	}
+
+	gcLock.Lock()
+	defer gcLock.Unlock()
+	// the files put meanwhile may refer to the candidates
+	if referenced, err = d.referenced(); err != nil {
+		addErr(err)
+		return res
+	}
+	for i, obj := range candidates {
+		if _, ok := referenced[obj.GetName()]; ok {
+			continue
+		}
+		if err := op.Remove(ctx, storage, candidatePaths[i]); err != nil {
+			addErr(err)
+			continue
+		}
+		res.Removed++
+		res.Freed += obj.GetSize()
+	}
+	return res
+}
+
+func (d *Dedup) referenced() (map[string]struct{}, error) {
+	hashes, err := op.GetDedupHashes()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]struct{}, len(hashes))
+	for _, h := range hashes {
+		m[h] = struct{}{}
+	}
+	return m, nil
+}
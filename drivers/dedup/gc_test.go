@@ -0,0 +1,158 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+}
+
+// setupGC mounts a temp dir as the backing path and creates a dedup storage
+// on it
+func setupGC(t *testing.T, name string) (*Dedup, string) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	backing := "/" + name + "_backing"
+	_, err := op.CreateStorage(ctx, model.Storage{
+		Driver:    "Local",
+		MountPath: backing,
+		Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed create the backing storage: %+v", err)
+	}
+	id, err := op.CreateStorage(ctx, model.Storage{
+		Driver:    "Dedup",
+		MountPath: "/" + name,
+		Addition:  fmt.Sprintf(`{"backing_path":%q,"gc_interval":0}`, backing),
+	})
+	if err != nil {
+		t.Fatalf("failed create the dedup storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := storage.(*Dedup)
+	if d.ID != id {
+		t.Fatalf("the storage id is %d, want %d", d.ID, id)
+	}
+	return d, dir
+}
+
+// writeBlob writes the blob of content into the backing dir, modified at the
+// time given
+func writeBlob(t *testing.T, dir, content string, modified time.Time) string {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(dir, hash[0:2], hash[2:4], hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func blobExists(dir, hash string) bool {
+	_, err := os.Stat(filepath.Join(dir, hash[0:2], hash[2:4], hash))
+	return err == nil
+}
+
+func TestGC(t *testing.T) {
+	d, dir := setupGC(t, "gc")
+	old := time.Now().Add(-2 * gcGrace)
+	referenced := writeBlob(t, dir, "referenced", old)
+	orphan := writeBlob(t, dir, "orphan", old)
+	recent := writeBlob(t, dir, "recent", time.Now())
+	err := op.CreateDedupNode(&model.DedupNode{StorageID: d.ID, Name: "a.txt", Size: 10, Hash: referenced})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := d.gc(context.Background())
+	if len(res.Errors) > 0 {
+		t.Fatalf("gc failed: %v", res.Errors)
+	}
+	if res.Blobs != 3 || res.Removed != 1 || res.Freed != int64(len("orphan")) {
+		t.Errorf("gc checked %d blobs, removed %d, freed %d, want 3, 1, %d",
+			res.Blobs, res.Removed, res.Freed, len("orphan"))
+	}
+	if blobExists(dir, orphan) {
+		t.Errorf("the orphan blob is kept")
+	}
+	if !blobExists(dir, referenced) {
+		t.Errorf("the referenced blob is removed")
+	}
+	if !blobExists(dir, recent) {
+		t.Errorf("the blob put recently is removed")
+	}
+}
+
+func TestGCWaitsForPut(t *testing.T) {
+	d, dir := setupGC(t, "gc_lock")
+	orphan := writeBlob(t, dir, "put", time.Now().Add(-2*gcGrace))
+
+	// a put holds the read lock from writing the blob to creating its node
+	gcLock.RLock()
+	done := make(chan *gcResult)
+	go func() { done <- d.gc(context.Background()) }()
+	select {
+	case <-done:
+		gcLock.RUnlock()
+		t.Fatal("the gc doesn't wait for the put")
+	case <-time.After(100 * time.Millisecond):
+	}
+	err := op.CreateDedupNode(&model.DedupNode{StorageID: d.ID, Name: "put.txt", Size: 3, Hash: orphan})
+	gcLock.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-done
+	if res.Removed != 0 || !blobExists(dir, orphan) {
+		t.Errorf("the blob referred to by the put is removed")
+	}
+}
+
+func TestDeleteStorageNodes(t *testing.T) {
+	d, _ := setupGC(t, "gc_delete")
+	err := op.CreateDedupNode(&model.DedupNode{StorageID: d.ID, Name: "b.txt", Size: 1, Hash: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := op.DeleteStorageById(context.Background(), d.ID); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := op.GetDedupChildren(d.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("%d nodes of the storage deleted are kept", len(nodes))
+	}
+}
@@ -0,0 +1,26 @@
+package dedup
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	BackingPath string `json:"backing_path" required:"true" help:"The mount path keeping the bodies of the files, named by their SHA-256"`
+	GCInterval  int    `json:"gc_interval" type:"number" default:"24" help:"Hours between the removals of the bodies no file refers to, 0 to remove them only on demand"`
+}
+
+var config = driver.Config{
+	Name:        "Dedup",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+	NoLinkURL:   true,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Dedup{}
+	})
+}
@@ -0,0 +1,427 @@
+package git_repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	stdpath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	log "github.com/sirupsen/logrus"
+)
+
+// GitRepo mounts a git repository, with its branches, tags and latest
+// commits in the folders of the same names. The names of the refs are
+// escaped as a path segment, e.g. feature/x is listed as feature%2Fx.
+type GitRepo struct {
+	model.Storage
+	Addition
+
+	repo   *git.Repository
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+const (
+	branchesDir = "branches"
+	tagsDir     = "tags"
+	commitsDir  = "commits"
+)
+
+func (d *GitRepo) Config() driver.Config {
+	return config
+}
+
+func (d *GitRepo) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *GitRepo) Init(ctx context.Context) error {
+	if d.HistoryDepth <= 0 {
+		d.HistoryDepth = 50
+	}
+	d.WriteBranch = utils.GetNoneEmpty(d.WriteBranch, "main")
+	repo, err := git.PlainOpen(d.RepoPath)
+	if errors.Is(err, git.ErrRepositoryNotExists) && d.URL != "" {
+		repo, err = git.PlainCloneContext(ctx, d.RepoPath, true, &git.CloneOptions{
+			URL:    d.URL,
+			Auth:   d.auth(),
+			Mirror: true,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed open the repository: %w", err)
+	}
+	d.repo = repo
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if d.URL != "" && d.FetchInterval > 0 {
+		go d.background(d.ctx)
+	}
+	return nil
+}
+
+func (d *GitRepo) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+	return nil
+}
+
+func (d *GitRepo) background(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(d.FetchInterval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.fetch(ctx); err != nil {
+				log.Warnf("git repository %s: failed fetch: %+v", d.MountPath, err)
+			}
+		}
+	}
+}
+
+func (d *GitRepo) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{
+		Name:     "root",
+		Path:     "/",
+		IsFolder: true,
+		Modified: d.Modified,
+	}, nil
+}
+
+func (d *GitRepo) dirObj(path, name string, modified time.Time) model.Obj {
+	return &model.Object{
+		Path:     stdpath.Join(path, name),
+		Name:     name,
+		Modified: modified,
+		IsFolder: true,
+	}
+}
+
+func (d *GitRepo) Get(ctx context.Context, path string) (model.Obj, error) {
+	path = utils.FixAndCleanPath(path)
+	loc, err := d.locate(path)
+	if err != nil {
+		return nil, err
+	}
+	if loc.kind == "" {
+		return d.GetRoot(ctx)
+	}
+	dir, name := stdpath.Split(path)
+	if loc.ref == "" {
+		return d.dirObj(dir, name, d.Modified), nil
+	}
+	if loc.rel == "" {
+		return d.dirObj(dir, name, loc.modified(d.Modified)), nil
+	}
+	tree, err := loc.tree()
+	if err != nil {
+		return nil, err
+	}
+	e, err := tree.FindEntry(loc.rel)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return d.entryObj(dir, e, loc.modified(d.Modified))
+}
+
+func (d *GitRepo) entryObj(dir string, e *object.TreeEntry, modified time.Time) (model.Obj, error) {
+	obj := &model.Object{
+		ID:       e.Hash.String(),
+		Path:     stdpath.Join(dir, e.Name),
+		Name:     e.Name,
+		Modified: modified,
+		IsFolder: e.Mode == filemode.Dir,
+	}
+	if !obj.IsFolder {
+		size, err := d.repo.Storer.EncodedObjectSize(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		obj.Size = size
+	}
+	return obj, nil
+}
+
+func (d *GitRepo) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	path := dir.GetPath()
+	loc, err := d.locate(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case loc.kind == "":
+		return []model.Obj{
+			d.dirObj(path, branchesDir, d.Modified),
+			d.dirObj(path, tagsDir, d.Modified),
+			d.dirObj(path, commitsDir, d.Modified),
+		}, nil
+	case loc.ref == "":
+		return d.listRefs(path, loc.kind)
+	}
+	tree, err := loc.tree()
+	if err != nil {
+		return nil, err
+	}
+	if loc.rel != "" {
+		if tree, err = tree.Tree(loc.rel); err != nil {
+			return nil, notFound(err)
+		}
+	}
+	modified := loc.modified(d.Modified)
+	objs := make([]model.Obj, 0, len(tree.Entries))
+	for i := range tree.Entries {
+		e := &tree.Entries[i]
+		if e.Mode == filemode.Submodule {
+			continue
+		}
+		obj, err := d.entryObj(path, e, modified)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// listRefs lists the branches, the tags or the latest commits of HEAD
+func (d *GitRepo) listRefs(path, kind string) ([]model.Obj, error) {
+	var objs []model.Obj
+	switch kind {
+	case branchesDir, tagsDir:
+		var iter interface {
+			ForEach(func(*plumbing.Reference) error) error
+		}
+		var err error
+		if kind == branchesDir {
+			iter, err = d.repo.Branches()
+		} else {
+			iter, err = d.repo.Tags()
+		}
+		if err != nil {
+			return nil, err
+		}
+		hasWriteBranch := false
+		err = iter.ForEach(func(ref *plumbing.Reference) error {
+			c, err := d.refCommit(ref.Hash())
+			if err != nil {
+				// a tag of a tree or a blob
+				return nil
+			}
+			name := ref.Name().Short()
+			hasWriteBranch = hasWriteBranch || name == d.WriteBranch
+			objs = append(objs, d.dirObj(path, url.PathEscape(name), c.Committer.When))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if kind == branchesDir && d.ReadWrite && !hasWriteBranch {
+			// the first commit creates it
+			objs = append(objs, d.dirObj(path, url.PathEscape(d.WriteBranch), d.Modified))
+		}
+	case commitsDir:
+		head, err := d.repo.Head()
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// HEAD of a new repository may point to another branch
+			head, err = d.repo.Reference(plumbing.NewBranchReferenceName(d.WriteBranch), true)
+		}
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		iter, err := d.repo.Log(&git.LogOptions{From: head.Hash()})
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+		for len(objs) < d.HistoryDepth {
+			c, err := iter.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, d.dirObj(path, c.Hash.String(), c.Committer.When))
+		}
+	}
+	return objs, nil
+}
+
+func (d *GitRepo) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	hash := plumbing.NewHash(file.GetID())
+	if hash.IsZero() {
+		return nil, errs.NotFile
+	}
+	blob, err := d.repo.BlobObject(hash)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	r := &blobReader{blob: blob}
+	return &model.Link{
+		RangeReader:      r,
+		ContentLength:    blob.Size,
+		SyncClosers:      utils.NewSyncClosers(r),
+		RequireReference: true,
+	}, nil
+}
+
+func (d *GitRepo) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	rel, err := d.writable(parentDir.GetPath())
+	if err != nil {
+		return err
+	}
+	path := stdpath.Join(rel, dirName)
+	// git keeps no empty directory
+	return d.commit(ctx, "mkdir", path, func(root plumbing.Hash) (plumbing.Hash, error) {
+		if _, err := d.getEntry(root, path); err == nil {
+			return root, errs.ObjectAlreadyExists
+		}
+		blob, err := d.writeBlob(strings.NewReader(""), 0)
+		if err != nil {
+			return root, err
+		}
+		return d.setEntry(root, stdpath.Join(path, ".gitkeep"), &object.TreeEntry{Mode: filemode.Regular, Hash: blob})
+	})
+}
+
+func (d *GitRepo) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	src, err := d.writable(srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	dst, err := d.writable(dstDir.GetPath())
+	if err != nil {
+		return err
+	}
+	return d.rename(ctx, "move", src, stdpath.Join(dst, srcObj.GetName()))
+}
+
+func (d *GitRepo) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	src, err := d.writable(srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	return d.rename(ctx, "rename", src, stdpath.Join(stdpath.Dir(src), newName))
+}
+
+func (d *GitRepo) rename(ctx context.Context, action, src, dst string) error {
+	if utils.IsSubPath("/"+src, "/"+dst) {
+		return errors.New("can't move a directory into itself")
+	}
+	return d.commit(ctx, action, src, func(root plumbing.Hash) (plumbing.Hash, error) {
+		e, err := d.getEntry(root, src)
+		if err != nil {
+			return root, err
+		}
+		if err := d.checkReplace(root, dst, e); err != nil {
+			return root, err
+		}
+		if root, err = d.setEntry(root, src, nil); err != nil {
+			return root, err
+		}
+		return d.setEntry(root, dst, e)
+	})
+}
+
+// Copy copies from any ref to the writable branch, only the tree entries are
+// copied as the objects are shared
+func (d *GitRepo) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	loc, err := d.locate(srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	tree, err := loc.tree()
+	if err != nil {
+		return err
+	}
+	e, err := tree.FindEntry(loc.rel)
+	if err != nil {
+		return notFound(err)
+	}
+	dst, err := d.writable(dstDir.GetPath())
+	if err != nil {
+		return err
+	}
+	dst = stdpath.Join(dst, srcObj.GetName())
+	return d.commit(ctx, "copy", dst, func(root plumbing.Hash) (plumbing.Hash, error) {
+		if err := d.checkReplace(root, dst, e); err != nil {
+			return root, err
+		}
+		return d.setEntry(root, dst, e)
+	})
+}
+
+func (d *GitRepo) Remove(ctx context.Context, obj model.Obj) error {
+	rel, err := d.writable(obj.GetPath())
+	if err != nil {
+		return err
+	}
+	return d.commit(ctx, "remove", rel, func(root plumbing.Hash) (plumbing.Hash, error) {
+		return d.setEntry(root, rel, nil)
+	})
+}
+
+func (d *GitRepo) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	rel, err := d.writable(dstDir.GetPath())
+	if err != nil {
+		return err
+	}
+	blob, err := d.writeBlob(&driver.ReaderUpdatingProgress{
+		Reader:         file,
+		UpdateProgress: up,
+	}, file.GetSize())
+	if err != nil {
+		return err
+	}
+	path := stdpath.Join(rel, file.GetName())
+	return d.commit(ctx, "upload", path, func(root plumbing.Hash) (plumbing.Hash, error) {
+		e := &object.TreeEntry{Mode: filemode.Regular, Hash: blob}
+		if old, err := d.getEntry(root, path); err == nil && old.Mode == filemode.Executable {
+			e.Mode = old.Mode
+		}
+		if err := d.checkReplace(root, path, e); err != nil {
+			return root, err
+		}
+		return d.setEntry(root, path, e)
+	})
+}
+
+// Other fetches from the remote repository with the "fetch" method
+func (d *GitRepo) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "fetch":
+		if d.URL == "" {
+			return nil, errors.New("no remote repository")
+		}
+		if err := d.fetch(ctx); err != nil {
+			return nil, err
+		}
+		return "fetched", nil
+	}
+	return nil, errs.NotSupport
+}
+
+var _ driver.Driver = (*GitRepo)(nil)
@@ -0,0 +1,35 @@
+package git_repo
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	RepoPath      string `json:"repo_path" type:"string" required:"true" help:"The local repository, a bare one is cloned to it from the url if it doesn't exist"`
+	URL           string `json:"url" type:"string" help:"The url of the remote repository, optional for a local repository"`
+	Username      string `json:"username" type:"string"`
+	Password      string `json:"password" type:"string" help:"The password or the access token of the remote repository"`
+	FetchInterval int    `json:"fetch_interval" type:"number" default:"0" help:"Fetch from the remote repository every N minutes, 0 to disable"`
+	HistoryDepth  int    `json:"history_depth" type:"number" default:"50" help:"The number of the latest commits listed in the commits folder"`
+	ReadWrite     bool   `json:"read_write" default:"false" help:"Commit the changes of the writable branch"`
+	WriteBranch   string `json:"write_branch" type:"string" default:"main" help:"The only branch which is writable"`
+	Push          bool   `json:"push" default:"false" help:"Push the commits to the remote repository, a commit failed to push is dropped"`
+	AuthorName    string `json:"author_name" type:"string" default:"OpenList"`
+	AuthorEmail   string `json:"author_email" type:"string" default:"openlist@localhost"`
+}
+
+var config = driver.Config{
+	Name:        "Git Repository",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	NoLinkURL:   true,
+	DefaultRoot: "/",
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &GitRepo{}
+	})
+}
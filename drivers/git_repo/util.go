@@ -0,0 +1,480 @@
+package git_repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// location is a path of the storage, a category folder if ref is empty, or
+// rel in the tree of the commit of the ref
+type location struct {
+	kind   string
+	ref    string
+	commit *object.Commit
+	rel    string
+}
+
+func (l *location) tree() (*object.Tree, error) {
+	if l.commit == nil {
+		// the writable branch not created yet
+		return &object.Tree{}, nil
+	}
+	return l.commit.Tree()
+}
+
+func (l *location) modified(def time.Time) time.Time {
+	if l.commit == nil {
+		return def
+	}
+	return l.commit.Committer.When
+}
+
+func notFound(err error) error {
+	for _, e := range []error{
+		plumbing.ErrReferenceNotFound,
+		plumbing.ErrObjectNotFound,
+		git.ErrTagNotFound,
+		object.ErrEntryNotFound,
+		object.ErrDirectoryNotFound,
+		object.ErrFileNotFound,
+	} {
+		if errors.Is(err, e) {
+			return errs.ObjectNotFound
+		}
+	}
+	return err
+}
+
+func (d *GitRepo) locate(path string) (*location, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	loc := &location{}
+	if parts[0] == "" {
+		return loc, nil
+	}
+	switch parts[0] {
+	case branchesDir, tagsDir, commitsDir:
+		loc.kind = parts[0]
+	default:
+		return nil, errs.ObjectNotFound
+	}
+	if len(parts) == 1 {
+		return loc, nil
+	}
+	ref, err := url.PathUnescape(parts[1])
+	if err != nil || ref == "" {
+		return nil, errs.ObjectNotFound
+	}
+	loc.ref = ref
+	loc.rel = strings.Join(parts[2:], "/")
+	loc.commit, err = d.resolve(loc.kind, ref)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) && loc.kind == branchesDir && d.ReadWrite && ref == d.WriteBranch {
+		return loc, nil
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return loc, nil
+}
+
+func (d *GitRepo) resolve(kind, ref string) (*object.Commit, error) {
+	switch kind {
+	case branchesDir:
+		r, err := d.repo.Reference(plumbing.NewBranchReferenceName(ref), true)
+		if err != nil {
+			return nil, err
+		}
+		return d.repo.CommitObject(r.Hash())
+	case tagsDir:
+		r, err := d.repo.Tag(ref)
+		if err != nil {
+			return nil, err
+		}
+		return d.refCommit(r.Hash())
+	default:
+		h, err := d.repo.ResolveRevision(plumbing.Revision(ref))
+		if err != nil {
+			return nil, err
+		}
+		return d.repo.CommitObject(*h)
+	}
+}
+
+// refCommit returns the commit a ref points to, directly or by an annotated
+// tag
+func (d *GitRepo) refCommit(h plumbing.Hash) (*object.Commit, error) {
+	if tag, err := d.repo.TagObject(h); err == nil {
+		return tag.Commit()
+	}
+	return d.repo.CommitObject(h)
+}
+
+func (d *GitRepo) auth() transport.AuthMethod {
+	if d.Username == "" && d.Password == "" || !strings.HasPrefix(d.URL, "http") {
+		return nil
+	}
+	return &http.BasicAuth{
+		Username: utils.GetNoneEmpty(d.Username, "git"),
+		Password: d.Password,
+	}
+}
+
+func (d *GitRepo) remote() *git.Remote {
+	return git.NewRemote(d.repo.Storer, &gitconfig.RemoteConfig{
+		Name: "openlist",
+		URLs: []string{d.URL},
+	})
+}
+
+// fetch updates all the branches and tags from the remote repository
+func (d *GitRepo) fetch(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.remote().FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "openlist",
+		RefSpecs: []gitconfig.RefSpec{
+			"+refs/heads/*:refs/heads/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+		Auth:  d.auth(),
+		Force: true,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// writable returns the path in the tree of the writable branch
+func (d *GitRepo) writable(path string) (string, error) {
+	if !d.ReadWrite {
+		return "", errs.PermissionDenied
+	}
+	loc, err := d.locate(path)
+	if err != nil {
+		return "", err
+	}
+	if loc.kind != branchesDir || loc.ref != d.WriteBranch {
+		return "", fmt.Errorf("only the branch %s is writable", d.WriteBranch)
+	}
+	return loc.rel, nil
+}
+
+func getUsername(ctx context.Context) string {
+	user, ok := ctx.Value(conf.UserKey).(*model.User)
+	if !ok {
+		return "<system>"
+	}
+	return user.Username
+}
+
+// commit commits the tree edit returns from the tree of the writable branch,
+// and pushes it if required. The commit is dropped if it fails to push.
+func (d *GitRepo) commit(ctx context.Context, action, path string, edit func(root plumbing.Hash) (plumbing.Hash, error)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	refName := plumbing.NewBranchReferenceName(d.WriteBranch)
+	old, err := d.repo.Reference(refName, true)
+	root := plumbing.ZeroHash
+	var parents []plumbing.Hash
+	if err == nil {
+		c, err := d.repo.CommitObject(old.Hash())
+		if err != nil {
+			return err
+		}
+		root = c.TreeHash
+		parents = []plumbing.Hash{old.Hash()}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return err
+	} else {
+		old = nil
+	}
+	tree, err := edit(root)
+	if err != nil {
+		return err
+	}
+	if tree == root {
+		return nil
+	}
+	if tree.IsZero() {
+		if tree, err = d.writeTree(nil); err != nil {
+			return err
+		}
+	}
+	sig := object.Signature{Name: d.AuthorName, Email: d.AuthorEmail, When: time.Now()}
+	c := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      fmt.Sprintf("%s %s /%s", getUsername(ctx), action, path),
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	obj := d.repo.Storer.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return err
+	}
+	h, err := d.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+	if err := d.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(refName, h), old); err != nil {
+		return err
+	}
+	if !d.Push || d.URL == "" {
+		return nil
+	}
+	err = d.remote().PushContext(ctx, &git.PushOptions{
+		RemoteName: "openlist",
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(refName + ":" + refName)},
+		Auth:       d.auth(),
+	})
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	if old != nil {
+		_ = d.repo.Storer.SetReference(old)
+	} else {
+		_ = d.repo.Storer.RemoveReference(refName)
+	}
+	return fmt.Errorf("failed push the commit: %w", err)
+}
+
+// lazyWriter is implemented by the filesystem storage, which writes an
+// object to the disk as it is streamed
+type lazyWriter interface {
+	LazyWriter() (io.WriteCloser, func(typ plumbing.ObjectType, size int64) error, error)
+}
+
+// maxMemoryBlob bounds the blobs held in memory by the storages which can't
+// stream them to the disk
+const maxMemoryBlob = 64 * utils.MB
+
+// writeBlob writes the blob of size bytes read from r
+func (d *GitRepo) writeBlob(r io.Reader, size int64) (plumbing.Hash, error) {
+	lw, ok := d.repo.Storer.(lazyWriter)
+	if !ok {
+		return d.writeMemoryBlob(r, size)
+	}
+	w, writeHeader, err := lw.LazyWriter()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := writeHeader(plumbing.BlobObject, size); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+	n, err := utils.CopyWithBuffer(w, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("read %d bytes of the blob of %d bytes", n, size)
+	}
+	// the object broken is left unreferenced
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return w.(interface{ Hash() plumbing.Hash }).Hash(), nil
+}
+
+func (d *GitRepo) writeMemoryBlob(r io.Reader, size int64) (plumbing.Hash, error) {
+	if size > maxMemoryBlob {
+		return plumbing.ZeroHash, fmt.Errorf("the file of %d bytes is larger than %d bytes", size, maxMemoryBlob)
+	}
+	obj := d.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := utils.CopyWithBuffer(w, io.LimitReader(r, maxMemoryBlob+1)); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if obj.Size() > maxMemoryBlob {
+		return plumbing.ZeroHash, fmt.Errorf("the file is larger than %d bytes", maxMemoryBlob)
+	}
+	return d.repo.Storer.SetEncodedObject(obj)
+}
+
+// blobReader reads the ranges of a blob. As the blobs are compressed, it's
+// decompressed into a temp file by the first range not from the start, which
+// the later ranges are read from.
+type blobReader struct {
+	blob   *object.Blob
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+}
+
+func (b *blobReader) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	length := httpRange.Length
+	if length < 0 || httpRange.Start+length > b.blob.Size {
+		length = b.blob.Size - httpRange.Start
+	}
+	if httpRange.Start == 0 {
+		rc, err := b.blob.Reader()
+		if err != nil {
+			return nil, err
+		}
+		return utils.NewLimitReadCloser(rc, rc.Close, length), nil
+	}
+	f, err := b.decode()
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(io.NewSectionReader(f, httpRange.Start, length)), nil
+}
+
+func (b *blobReader) decode() (*os.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, os.ErrClosed
+	}
+	if b.file != nil {
+		return b.file, nil
+	}
+	rc, err := b.blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	f, err := utils.CreateTempFile(rc, b.blob.Size)
+	if err != nil {
+		return nil, err
+	}
+	b.file = f
+	return f, nil
+}
+
+func (b *blobReader) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.file == nil {
+		return nil
+	}
+	_ = b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+func (d *GitRepo) writeTree(entries []object.TreeEntry) (plumbing.Hash, error) {
+	sort.Sort(object.TreeEntrySorter(entries))
+	obj := d.repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return d.repo.Storer.SetEncodedObject(obj)
+}
+
+func (d *GitRepo) getEntry(root plumbing.Hash, path string) (*object.TreeEntry, error) {
+	if root.IsZero() {
+		return nil, errs.ObjectNotFound
+	}
+	tree, err := object.GetTree(d.repo.Storer, root)
+	if err != nil {
+		return nil, err
+	}
+	e, err := tree.FindEntry(path)
+	return e, notFound(err)
+}
+
+// checkReplace fails if e replaces a directory or is a directory replacing
+// a file
+func (d *GitRepo) checkReplace(root plumbing.Hash, path string, e *object.TreeEntry) error {
+	old, err := d.getEntry(root, path)
+	if errs.IsObjectNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if old.Mode == filemode.Dir || e.Mode == filemode.Dir {
+		return errs.ObjectAlreadyExists
+	}
+	return nil
+}
+
+// setEntry returns the root tree with the entry at path set to e, or removed
+// if e is nil. The directories left empty are removed, and the zero hash is
+// returned for an empty root.
+func (d *GitRepo) setEntry(root plumbing.Hash, path string, e *object.TreeEntry) (plumbing.Hash, error) {
+	return d.editTree(root, strings.Split(path, "/"), e)
+}
+
+func (d *GitRepo) editTree(h plumbing.Hash, parts []string, e *object.TreeEntry) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	if !h.IsZero() {
+		tree, err := object.GetTree(d.repo.Storer, h)
+		if err != nil {
+			return h, err
+		}
+		entries = slices.Clone(tree.Entries)
+	}
+	i := slices.IndexFunc(entries, func(te object.TreeEntry) bool {
+		return te.Name == parts[0]
+	})
+	if len(parts) == 1 {
+		switch {
+		case e != nil && i >= 0:
+			entries[i] = object.TreeEntry{Name: parts[0], Mode: e.Mode, Hash: e.Hash}
+		case e != nil:
+			entries = append(entries, object.TreeEntry{Name: parts[0], Mode: e.Mode, Hash: e.Hash})
+		case i >= 0:
+			entries = slices.Delete(entries, i, i+1)
+		default:
+			return h, errs.ObjectNotFound
+		}
+	} else {
+		sub := plumbing.ZeroHash
+		if i >= 0 {
+			if entries[i].Mode != filemode.Dir {
+				return h, errs.NotFolder
+			}
+			sub = entries[i].Hash
+		} else if e == nil {
+			return h, errs.ObjectNotFound
+		}
+		sub, err := d.editTree(sub, parts[1:], e)
+		if err != nil {
+			return h, err
+		}
+		switch {
+		case sub.IsZero() && i >= 0:
+			entries = slices.Delete(entries, i, i+1)
+		case i >= 0:
+			entries[i].Hash = sub
+		case !sub.IsZero():
+			entries = append(entries, object.TreeEntry{Name: parts[0], Mode: filemode.Dir, Hash: sub})
+		}
+	}
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+	return d.writeTree(entries)
+}
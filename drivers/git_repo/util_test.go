@@ -0,0 +1,150 @@
+package git_repo
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func newTestRepo(t *testing.T) *GitRepo {
+	// the blobs read by ranges are decompressed into the temp dir
+	conf.Conf = conf.DefaultConfig(t.TempDir())
+	if err := os.MkdirAll(conf.Conf.TempDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GitRepo{
+		Addition: Addition{ReadWrite: true, WriteBranch: "main", HistoryDepth: 50},
+		repo:     repo,
+	}
+}
+
+func (d *GitRepo) testPut(t *testing.T, path, content string) {
+	blob, err := d.writeBlob(strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.commit(context.Background(), "upload", path, func(root plumbing.Hash) (plumbing.Hash, error) {
+		return d.setEntry(root, path, &object.TreeEntry{Mode: filemode.Regular, Hash: blob})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitAndRead(t *testing.T) {
+	d := newTestRepo(t)
+	ctx := context.Background()
+	d.testPut(t, "a/b/c.txt", "hello world")
+	d.testPut(t, "a/d.txt", "second")
+
+	objs, err := d.List(ctx, &model.Object{Path: "/branches/main/a"}, model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 || objs[0].GetName() != "b" || !objs[0].IsDir() || objs[1].GetSize() != 6 {
+		t.Fatalf("unexpected list %+v", objs)
+	}
+	file, err := d.Get(ctx, "/branches/main/a/b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(ctx, file, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct {
+		rng  http_range.Range
+		want string
+	}{
+		{http_range.Range{Start: 6, Length: 3}, "wor"},
+		{http_range.Range{Start: 0, Length: 5}, "hello"},
+		{http_range.Range{Start: 8, Length: -1}, "rld"},
+	} {
+		rc, err := link.RangeReader.RangeRead(ctx, r.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		if string(got) != r.want {
+			t.Fatalf("read %q of %+v, want %q", got, r.rng, r.want)
+		}
+	}
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Rename(ctx, &model.Object{Path: "/branches/main/a/b", Name: "b", IsFolder: true}, "e"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(ctx, "/branches/main/a/e/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(ctx, &model.Object{Path: "/branches/main/a/e/c.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	// the directory left empty is removed
+	if _, err := d.Get(ctx, "/branches/main/a/e"); err == nil {
+		t.Fatal("the empty directory is kept")
+	}
+	commits, err := d.List(ctx, &model.Object{Path: "/commits"}, model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 4 {
+		t.Fatalf("got %d commits", len(commits))
+	}
+	// the first commit has the file removed
+	if _, err := d.Get(ctx, "/commits/"+commits[3].GetName()+"/a/b/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(ctx, &model.Object{Path: "/tags/v1/a"}); err == nil {
+		t.Fatal("removed from a tag")
+	}
+}
+
+func TestWriteBlobStreamed(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.Storer.(lazyWriter); !ok {
+		t.Fatal("the filesystem storage doesn't stream the objects")
+	}
+	d := &GitRepo{repo: repo}
+	h, err := d.writeBlob(strings.NewReader("streamed"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := repo.BlobObject(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := blob.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "streamed" {
+		t.Errorf("the blob written is %q", got)
+	}
+	if _, err := d.writeBlob(strings.NewReader("short"), 8); err == nil {
+		t.Errorf("the blob shorter than its size is written")
+	}
+}
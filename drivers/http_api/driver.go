@@ -0,0 +1,284 @@
+package http_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/base"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/go-resty/resty/v2"
+)
+
+// HttpApi lists, links and uploads the files by the requests described by
+// a template, so that a simple API needs no code
+type HttpApi struct {
+	model.Storage
+	Addition
+	tmpl *Template
+}
+
+// Config forces the downloads through the proxy if the headers of the
+// template are passed to them, so that they are never sent to the client
+func (d *HttpApi) Config() driver.Config {
+	c := config
+	if d.tmpl != nil && d.tmpl.Link != nil && d.tmpl.Link.WithHeaders {
+		c.OnlyProxy = true
+	}
+	return c
+}
+
+func (d *HttpApi) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *HttpApi) Init(ctx context.Context) error {
+	var tmpl Template
+	if err := utils.Json.UnmarshalFromString(d.Template, &tmpl); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	if tmpl.List.Request.Url == "" {
+		return errors.New("the template has no list request")
+	}
+	if tmpl.Fields.Name == "" {
+		return errors.New("the template has no name field")
+	}
+	d.tmpl = &tmpl
+	return nil
+}
+
+func (d *HttpApi) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *HttpApi) headers(r Request, rep *strings.Replacer) map[string]string {
+	headers := make(map[string]string, len(d.tmpl.Headers)+len(r.Headers))
+	for k, v := range d.tmpl.Headers {
+		headers[k] = rep.Replace(v)
+	}
+	for k, v := range r.Headers {
+		headers[k] = rep.Replace(v)
+	}
+	return headers
+}
+
+// request sends the request with the placeholders replaced, and returns the
+// response decoded as JSON
+func (d *HttpApi) request(ctx context.Context, r Request, p placeholders, callback base.ReqCallback) (any, error) {
+	rep := p.replacer(d.Token)
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req := base.RestyClient.R().SetContext(ctx).SetHeaders(d.headers(r, rep))
+	if r.Body != "" {
+		req.SetBody(rep.Replace(r.Body))
+	}
+	if callback != nil {
+		callback(req)
+	}
+	res, err := req.Execute(strings.ToUpper(method), joinUrl(rep.Replace(d.tmpl.BaseUrl), rep.Replace(r.Url)))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode() == http.StatusNotFound {
+		return nil, errs.ObjectNotFound
+	}
+	if res.StatusCode() >= 300 {
+		body := res.String()
+		if len(body) > 200 {
+			body = body[:200]
+		}
+		return nil, fmt.Errorf("request failed, status code: %d, body: %s", res.StatusCode(), body)
+	}
+	if len(res.Body()) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := utils.Json.Unmarshal(res.Body(), &v); err != nil {
+		return nil, fmt.Errorf("the response isn't json: %w", err)
+	}
+	return v, nil
+}
+
+// toObj maps the fields of an item to an obj in the dir, the name is taken
+// from the url or the path if it's missing. The names which aren't of a
+// single element of a path are rejected.
+func (d *HttpApi) toObj(item any, dir, fallbackName string) (model.Obj, error) {
+	f := d.tmpl.Fields
+	u := toString(lookup(item, f.Url))
+	name := toString(lookup(item, f.Name))
+	if name == "" && u != "" {
+		name = stdpath.Base(strings.SplitN(u, "?", 2)[0])
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+	if name == "" {
+		name = fallbackName
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the item has no name: %v", item)
+	}
+	if strings.Contains(name, "/") || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid name %q of the item", name)
+	}
+	return &model.ObjectURL{
+		Object: model.Object{
+			ID:       toString(lookup(item, f.ID)),
+			Path:     stdpath.Join(dir, name),
+			Name:     name,
+			Size:     toInt64(lookup(item, f.Size)),
+			Modified: toTime(lookup(item, f.Modified), d.tmpl.TimeFormat),
+			IsFolder: toBool(lookup(item, f.IsDir), d.tmpl.DirValue),
+		},
+		Url: model.Url{Url: u},
+	}, nil
+}
+
+func objPlaceholders(obj model.Obj) placeholders {
+	return placeholders{path: obj.GetPath(), name: obj.GetName(), id: obj.GetID()}
+}
+
+func (d *HttpApi) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	step := d.tmpl.List
+	pag := step.Pagination
+	if pag == nil {
+		pag = &Pagination{MaxPages: 1}
+	}
+	p := objPlaceholders(dir)
+	p.page = pag.StartPage
+	if p.page == 0 && (pag.Type == "" || pag.Type == "page") {
+		p.page = 1
+	}
+	maxPages := pag.MaxPages
+	if maxPages <= 0 {
+		maxPages = 1000
+	}
+	var objs []model.Obj
+	for i := 0; i < maxPages; i++ {
+		resp, err := d.request(ctx, step.Request, p, nil)
+		if err != nil {
+			return nil, err
+		}
+		items := resp
+		if step.Items != "" {
+			if items, err = jsonPath(resp, step.Items); err != nil {
+				return nil, err
+			}
+		}
+		list, ok := items.([]any)
+		if !ok && items != nil {
+			return nil, fmt.Errorf("the items at %s isn't an array", step.Items)
+		}
+		for _, item := range list {
+			obj, err := d.toObj(item, dir.GetPath(), "")
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, obj)
+		}
+		if len(list) == 0 || (pag.PageSize > 0 && len(list) < pag.PageSize) {
+			break
+		}
+		if pag.HasMore != "" && !toBool(lookup(resp, pag.HasMore), "") {
+			break
+		}
+		switch pag.Type {
+		case "cursor":
+			if p.cursor = toString(lookup(resp, pag.Next)); p.cursor == "" {
+				return objs, nil
+			}
+		case "offset":
+			p.offset += len(list)
+		default:
+			p.page++
+		}
+	}
+	return objs, nil
+}
+
+func (d *HttpApi) Get(ctx context.Context, path string) (model.Obj, error) {
+	step := d.tmpl.Get
+	if step == nil {
+		return nil, errs.NotImplement
+	}
+	path = stdpath.Join(d.GetRootPath(), path)
+	resp, err := d.request(ctx, step.Request, placeholders{path: path, name: stdpath.Base(path)}, nil)
+	if err != nil {
+		return nil, err
+	}
+	item := resp
+	if step.Item != "" {
+		if item, err = jsonPath(resp, step.Item); err != nil {
+			return nil, err
+		}
+	}
+	if item == nil {
+		return nil, errs.ObjectNotFound
+	}
+	obj, err := d.toObj(item, stdpath.Dir(path), stdpath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	// the path asked is kept, as the name given by the api may differ
+	o := obj.(*model.ObjectURL)
+	o.Path, o.Name = path, stdpath.Base(path)
+	return o, nil
+}
+
+func (d *HttpApi) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	var u string
+	step := d.tmpl.Link
+	if step != nil && step.Request.Url != "" {
+		resp, err := d.request(ctx, step.Request, objPlaceholders(file), nil)
+		if err != nil {
+			return nil, err
+		}
+		u = toString(lookup(resp, step.Url))
+	} else {
+		u, _ = model.GetUrl(file)
+	}
+	if u == "" {
+		return nil, errors.New("no url of the file")
+	}
+	rep := objPlaceholders(file).replacer(d.Token)
+	link := &model.Link{URL: joinUrl(rep.Replace(d.tmpl.BaseUrl), u)}
+	if step != nil && step.WithHeaders {
+		link.Header = http.Header{}
+		for k, v := range d.headers(step.Request, rep) {
+			link.Header.Set(k, v)
+		}
+	}
+	return link, nil
+}
+
+func (d *HttpApi) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	step := d.tmpl.Put
+	if step == nil {
+		return errs.NotImplement
+	}
+	p := placeholders{path: dstDir.GetPath(), name: s.GetName(), id: dstDir.GetID()}
+	r := driver.NewLimitedUploadStream(ctx, &driver.ReaderUpdatingProgress{
+		Reader:         s,
+		UpdateProgress: up,
+	})
+	_, err := d.request(ctx, step.Request, p, func(req *resty.Request) {
+		if step.MultipartField != "" {
+			req.SetFileReader(step.MultipartField, s.GetName(), r)
+			return
+		}
+		req.SetBody(io.Reader(r)).SetHeader("Content-Type", s.GetMimetype())
+	})
+	return err
+}
+
+var _ driver.Driver = (*HttpApi)(nil)
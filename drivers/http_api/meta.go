@@ -0,0 +1,24 @@
+package http_api
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	driver.RootPath
+	Template string `json:"template" type:"text" required:"true" help:"The JSON template describing the requests of list, get, link and put, see drivers/http_api/types.go"`
+	Token    string `json:"token" help:"Replaces {token} in the template, so that the secret is kept out of it"`
+}
+
+var config = driver.Config{
+	Name:        "HTTP API",
+	LocalSort:   true,
+	DefaultRoot: "/",
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &HttpApi{}
+	})
+}
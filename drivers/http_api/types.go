@@ -0,0 +1,107 @@
+package http_api
+
+// Template describes the API, for example:
+//
+//	{
+//	  "base_url": "https://example.com/api",
+//	  "headers": {"Authorization": "Bearer {token}"},
+//	  "fields": {"name": "$.name", "size": "$.size", "modified": "$.mtime", "is_dir": "$.type", "url": "$.url"},
+//	  "dir_value": "folder",
+//	  "list": {
+//	    "request": {"url": "/files?path={path_query}&page={page}"},
+//	    "items": "$.data.files",
+//	    "pagination": {"type": "page", "page_size": 100, "has_more": "$.data.has_more"}
+//	  },
+//	  "link": {"request": {"url": "/download?path={path_query}"}, "url": "$.data.url"},
+//	  "put": {"request": {"method": "POST", "url": "/upload?dir={path_query}"}, "multipart_field": "file"}
+//	}
+//
+// The placeholders in the urls, headers and bodies of the requests are
+// {path} with the segments escaped, {path_query} escaped as a query value,
+// {path_raw}, {name}, {id}, {page}, {offset}, {cursor} and {token}. The
+// paths mapping the fields are a subset of JSONPath, like $.a.b[0]['c d'].
+type Template struct {
+	BaseUrl string            `json:"base_url"`
+	Headers map[string]string `json:"headers"`
+	Fields  Fields            `json:"fields"`
+	// DirValue is the value of the is_dir field meaning a folder, if the
+	// field isn't a boolean
+	DirValue string `json:"dir_value"`
+	// TimeFormat is one of unix, unix_ms and rfc3339, or a layout of Go, the
+	// format is guessed if it's empty
+	TimeFormat string    `json:"time_format"`
+	List       ListStep  `json:"list"`
+	Get        *GetStep  `json:"get"`
+	Link       *LinkStep `json:"link"`
+	Put        *PutStep  `json:"put"`
+}
+
+type Request struct {
+	Method  string            `json:"method"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Fields are the paths of the fields of an item, relative to the item
+type Fields struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Size     string `json:"size"`
+	Modified string `json:"modified"`
+	IsDir    string `json:"is_dir"`
+	Url      string `json:"url"`
+}
+
+type ListStep struct {
+	Request Request `json:"request"`
+	// Items is the path of the array of the items in the response
+	Items      string      `json:"items"`
+	Pagination *Pagination `json:"pagination"`
+}
+
+// Pagination requests the pages until one of the rules tells the last one:
+// a page with no item, or fewer items than PageSize, or HasMore is false, or
+// Next is empty for the cursor type
+type Pagination struct {
+	// Type is one of page, offset and cursor
+	Type      string `json:"type"`
+	StartPage int    `json:"start_page"`
+	PageSize  int    `json:"page_size"`
+	HasMore   string `json:"has_more"`
+	Next      string `json:"next"`
+	MaxPages  int    `json:"max_pages"`
+}
+
+// GetStep gets an item by its path, the obj is found by listing its folder
+// if it's not set
+type GetStep struct {
+	Request Request `json:"request"`
+	Item    string  `json:"item"`
+}
+
+// LinkStep requests the url of a file, the url field of the item is used if
+// it's not set
+type LinkStep struct {
+	Request Request `json:"request"`
+	Url     string  `json:"url"`
+	// WithHeaders passes the headers of the template to the download, which
+	// is always proxied then
+	WithHeaders bool `json:"with_headers"`
+}
+
+// PutStep uploads the body of a file, as the whole request body or as a
+// multipart field
+type PutStep struct {
+	Request        Request `json:"request"`
+	MultipartField string  `json:"multipart_field"`
+}
+
+type placeholders struct {
+	path   string
+	name   string
+	id     string
+	page   int
+	offset int
+	cursor string
+}
@@ -0,0 +1,183 @@
+package http_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// jsonPath returns the value of the path in v, the path is a subset of
+// JSONPath: $, .key, [index] and ['key']
+func jsonPath(v any, path string) (any, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	for path != "" {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("can't get the key %s of a non object", key)
+			}
+			v = m[key]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed bracket in the path")
+			}
+			sel := path[1:end]
+			path = path[end+1:]
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0] {
+				m, ok := v.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("can't get the key %s of a non object", sel)
+				}
+				v = m[sel[1:len(sel)-1]]
+				continue
+			}
+			index, err := strconv.Atoi(sel)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %s in the path", sel)
+			}
+			a, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("can't get the index %d of a non array", index)
+			}
+			if index < 0 {
+				index += len(a)
+			}
+			if index < 0 || index >= len(a) {
+				return nil, nil
+			}
+			v = a[index]
+		default:
+			// the leading dot may be omitted
+			path = "." + path
+		}
+	}
+	return v, nil
+}
+
+// lookup returns the value of the path in v, or nil if the path is empty or
+// not found
+func lookup(v any, path string) any {
+	if path == "" {
+		return nil
+	}
+	ret, _ := jsonPath(v, path)
+	return ret
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt64(v any) int64 {
+	switch v := v.(type) {
+	case float64:
+		return int64(v)
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func toBool(v any, trueValue string) bool {
+	if trueValue != "" {
+		return toString(v) == trueValue
+	}
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(v) {
+		case "true", "1", "dir", "folder", "directory":
+			return true
+		}
+	}
+	return false
+}
+
+func toTime(v any, format string) time.Time {
+	switch format {
+	case "unix":
+		return time.Unix(toInt64(v), 0)
+	case "unix_ms":
+		return time.UnixMilli(toInt64(v))
+	case "rfc3339":
+		t, _ := time.Parse(time.RFC3339, toString(v))
+		return t
+	case "":
+	default:
+		t, _ := time.Parse(format, toString(v))
+		return t
+	}
+	// guess the format
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		if t, err := http.ParseTime(s); err == nil {
+			return t
+		}
+	}
+	i := toInt64(v)
+	if i == 0 {
+		return time.Time{}
+	}
+	// the timestamps after 2286 in seconds are taken as in milliseconds
+	if i > 1e10 {
+		return time.UnixMilli(i)
+	}
+	return time.Unix(i, 0)
+}
+
+func (p placeholders) replacer(token string) *strings.Replacer {
+	return strings.NewReplacer(
+		"{path}", utils.EncodePath(p.path, true),
+		"{path_query}", url.QueryEscape(p.path),
+		"{path_raw}", p.path,
+		"{name}", url.PathEscape(p.name),
+		"{id}", url.PathEscape(p.id),
+		"{page}", strconv.Itoa(p.page),
+		"{offset}", strconv.Itoa(p.offset),
+		"{cursor}", url.QueryEscape(p.cursor),
+		"{token}", token,
+	)
+}
+
+// joinUrl resolves the url relative to the base url, unless it's absolute
+func joinUrl(base, u string) string {
+	if base == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	if strings.HasPrefix(u, "//") {
+		if b, err := url.Parse(base); err == nil {
+			return b.Scheme + ":" + u
+		}
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(u, "/")
+}
@@ -0,0 +1,84 @@
+package http_api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestJsonPath(t *testing.T) {
+	var v any
+	err := utils.Json.UnmarshalFromString(`{"data": {"files": [{"a b": 1}, {"c": [true, "x"]}]}}`, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]any{
+		"$.data.files[0]['a b']": float64(1),
+		"data.files[1].c[-1]":    "x",
+		"$.data.files[5]":        nil,
+		"$.data.missing":         nil,
+	}
+	for path, want := range cases {
+		got, err := jsonPath(v, path)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", path, got, err, want)
+		}
+	}
+	if _, err := jsonPath(v, "$.data.files.name"); err == nil {
+		t.Errorf("got no error for a key of an array")
+	}
+}
+
+func TestToObj(t *testing.T) {
+	d := &HttpApi{tmpl: &Template{
+		Fields:   Fields{Name: "$.n", Size: "$.s", Modified: "$.m", IsDir: "$.t", Url: "$.u"},
+		DirValue: "folder",
+	}}
+	var item any
+	err := utils.Json.UnmarshalFromString(`{"s": "12", "m": 1700000000000, "t": "file", "u": "/files/a%20b.txt?sig=1"}`, &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.toObj(item, "/dir", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetName() != "a b.txt" || obj.GetPath() != "/dir/a b.txt" || obj.GetSize() != 12 || obj.IsDir() {
+		t.Errorf("got wrong obj: %+v", obj)
+	}
+	if !obj.ModTime().Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("got wrong modified: %v", obj.ModTime())
+	}
+	if u, _ := model.GetUrl(obj); u != "/files/a%20b.txt?sig=1" {
+		t.Errorf("got wrong url: %s", u)
+	}
+}
+
+func TestToObjInvalidName(t *testing.T) {
+	d := &HttpApi{tmpl: &Template{Fields: Fields{Name: "$.n", Url: "$.u"}}}
+	for _, item := range []string{`{"n": "a/b"}`, `{"n": ".."}`, `{"u": "/files/..%2F..%2Fetc"}`} {
+		var v any
+		if err := utils.Json.UnmarshalFromString(item, &v); err != nil {
+			t.Fatal(err)
+		}
+		if obj, err := d.toObj(v, "/dir", ""); err == nil {
+			t.Errorf("got %s from %s", obj.GetPath(), item)
+		}
+	}
+}
+
+func TestProxyWithHeaders(t *testing.T) {
+	d := &HttpApi{tmpl: &Template{Link: &LinkStep{}}}
+	if d.Config().MustProxy() {
+		t.Errorf("the links without headers are proxied")
+	}
+	d.tmpl.Link.WithHeaders = true
+	if !d.Config().MustProxy() {
+		t.Errorf("the links with headers aren't proxied")
+	}
+	if config.OnlyProxy {
+		t.Errorf("the config of the driver is changed")
+	}
+}
@@ -0,0 +1,308 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdpath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	log "github.com/sirupsen/logrus"
+)
+
+type Mirror struct {
+	model.Storage
+	Addition
+	replicas []*replica
+
+	mu        sync.Mutex
+	queue     []*repair
+	verifying bool
+	repairMu  sync.Mutex
+	lastCheck *verifyResult
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (d *Mirror) Config() driver.Config {
+	return config
+}
+
+func (d *Mirror) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Mirror) Init(ctx context.Context) error {
+	d.replicas = nil
+	for _, path := range strings.Split(d.Paths, "\n") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		path = utils.FixAndCleanPath(path)
+		if utils.IsSubPath(d.MountPath, path) {
+			return fmt.Errorf("replica %s is inside the mirror itself", path)
+		}
+		d.replicas = append(d.replicas, &replica{path: path})
+	}
+	if len(d.replicas) < 2 {
+		return errors.New("at least two replicas are required")
+	}
+	if d.RepairInterval <= 0 {
+		d.RepairInterval = 5
+	}
+	if err := d.loadRepairs(); err != nil {
+		return err
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	go d.background(d.ctx)
+	return nil
+}
+
+func (d *Mirror) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+	return nil
+}
+
+func (d *Mirror) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{
+		Name:     "root",
+		Path:     "/",
+		IsFolder: true,
+		Modified: d.Modified,
+		Mask:     model.Locked,
+	}, nil
+}
+
+// Get returns the object from the first replica having it, the path of the
+// objects of the driver is the path in the replicas
+func (d *Mirror) Get(ctx context.Context, path string) (model.Obj, error) {
+	var err error
+	for _, r := range d.readOrder() {
+		obj, e := fs.Get(ctx, r.join(path), &fs.GetArgs{NoLog: true})
+		if e != nil {
+			if !errs.IsObjectNotFound(e) {
+				r.fail(e)
+			}
+			err = errors.Join(err, e)
+			continue
+		}
+		return &model.Object{
+			Path:     path,
+			Name:     obj.GetName(),
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			Ctime:    obj.CreateTime(),
+			IsFolder: obj.IsDir(),
+			HashInfo: obj.GetHash(),
+		}, nil
+	}
+	if err == nil {
+		err = errs.ObjectNotFound
+	}
+	return nil, err
+}
+
+func (d *Mirror) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	var err error
+	for _, r := range d.readOrder() {
+		objs, e := fs.List(ctx, r.join(dir.GetPath()), &fs.ListArgs{NoLog: true, Refresh: args.Refresh})
+		if e != nil {
+			r.fail(e)
+			err = errors.Join(err, e)
+			continue
+		}
+		return utils.SliceConvert(objs, func(obj model.Obj) (model.Obj, error) {
+			o := model.Object{
+				Path:     stdpath.Join(dir.GetPath(), obj.GetName()),
+				Name:     obj.GetName(),
+				Size:     obj.GetSize(),
+				Modified: obj.ModTime(),
+				Ctime:    obj.CreateTime(),
+				IsFolder: obj.IsDir(),
+				HashInfo: obj.GetHash(),
+			}
+			if thumb, ok := model.GetThumb(obj); ok {
+				return &model.ObjThumb{Object: o, Thumbnail: model.Thumbnail{Thumbnail: thumb}}, nil
+			}
+			return &o, nil
+		})
+	}
+	return nil, err
+}
+
+// Link returns the link of the first healthy replica, it fails over to the
+// next one if the link fails
+func (d *Mirror) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	var err error
+	for _, r := range d.readOrder() {
+		path := r.join(file.GetPath())
+		storage, actualPath, e := op.GetStorageAndActualPath(path)
+		if e != nil {
+			r.fail(e)
+			err = errors.Join(err, e)
+			continue
+		}
+		if args.Redirect && common.ShouldProxy(storage, stdpath.Base(path)) {
+			return &model.Link{
+				URL: fmt.Sprintf("%s/p%s?sign=%s",
+					common.GetApiUrl(ctx),
+					utils.EncodePath(path, true),
+					sign.Sign(path)),
+			}, nil
+		}
+		link, fi, e := op.Link(ctx, storage, actualPath, args)
+		if e != nil {
+			if !errs.IsObjectNotFound(e) {
+				r.fail(e)
+			}
+			err = errors.Join(err, e)
+			continue
+		}
+		resultLink := link.Clone()
+		resultLink.Expiration = nil
+		if resultLink.ContentLength == 0 {
+			resultLink.ContentLength = fi.GetSize()
+		}
+		return resultLink, nil
+	}
+	return nil, err
+}
+
+func (d *Mirror) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	path := stdpath.Join(parentDir.GetPath(), dirName)
+	return d.apply(func(r *replica) error {
+		return fs.MakeDir(ctx, r.join(path))
+	}, path)
+}
+
+func (d *Mirror) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.apply(func(r *replica) error {
+		_, err := fs.Move(ctx, r.join(srcObj.GetPath()), r.join(dstDir.GetPath()))
+		return err
+	}, srcObj.GetPath(), stdpath.Join(dstDir.GetPath(), srcObj.GetName()))
+}
+
+func (d *Mirror) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	return d.apply(func(r *replica) error {
+		return fs.Rename(ctx, r.join(srcObj.GetPath()), newName)
+	}, srcObj.GetPath(), stdpath.Join(stdpath.Dir(srcObj.GetPath()), newName))
+}
+
+func (d *Mirror) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.apply(func(r *replica) error {
+		_, err := fs.Copy(ctx, r.join(srcObj.GetPath()), r.join(dstDir.GetPath()))
+		return err
+	}, stdpath.Join(dstDir.GetPath(), srcObj.GetName()))
+}
+
+func (d *Mirror) Remove(ctx context.Context, obj model.Obj) error {
+	return d.apply(func(r *replica) error {
+		err := fs.Remove(ctx, r.join(obj.GetPath()))
+		if errs.IsObjectNotFound(err) {
+			return nil
+		}
+		return err
+	}, obj.GetPath())
+}
+
+func (d *Mirror) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	file, err := s.CacheFullAndWriter(nil, nil)
+	if err != nil {
+		return err
+	}
+	count := float64(len(d.replicas) + 1)
+	up(100 / count)
+	i := 0
+	return d.apply(func(r *replica) error {
+		defer func() {
+			i++
+			up(float64(i+1) / count * 100)
+		}()
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return fs.PutDirectly(ctx, r.join(dstDir.GetPath()), &stream.FileStream{
+			Obj:      s,
+			Mimetype: s.GetMimetype(),
+			Reader:   file,
+		})
+	}, stdpath.Join(dstDir.GetPath(), s.GetName()))
+}
+
+// Other reports the state of the replicas with the "status" method, starts
+// a verification with "verify" and retries the failed writes with "repair".
+// The other methods are passed to the first healthy replica.
+func (d *Mirror) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "status":
+		return d.status(), nil
+	case "verify":
+		if !d.startVerify() {
+			return nil, errors.New("a verification is already running")
+		}
+		return "verification started", nil
+	case "repair":
+		go d.runRepairs(d.ctx)
+		return "repair started", nil
+	}
+	var err error
+	for _, r := range d.readOrder() {
+		storage, actualPath, e := op.GetStorageAndActualPath(r.join(args.Obj.GetPath()))
+		if e != nil {
+			err = errors.Join(err, e)
+			continue
+		}
+		return op.Other(ctx, storage, model.FsOtherArgs{
+			Path:   actualPath,
+			Method: args.Method,
+			Data:   args.Data,
+		})
+	}
+	return nil, err
+}
+
+// background retries the failed writes and verifies the replicas
+// periodically until the driver is dropped
+func (d *Mirror) background(ctx context.Context) {
+	repairTicker := time.NewTicker(time.Duration(d.RepairInterval) * time.Minute)
+	defer repairTicker.Stop()
+	var verifyC <-chan time.Time
+	if d.VerifyInterval > 0 {
+		verifyTicker := time.NewTicker(time.Duration(d.VerifyInterval) * time.Hour)
+		defer verifyTicker.Stop()
+		verifyC = verifyTicker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-repairTicker.C:
+			d.runRepairs(ctx)
+		case <-verifyC:
+			if !d.startVerify() {
+				log.Warnf("mirror %s: skipped the verification as the last one is still running", d.MountPath)
+			}
+		}
+	}
+}
+
+var _ driver.Driver = (*Mirror)(nil)
@@ -0,0 +1,29 @@
+package mirror
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	Paths          string `json:"paths" required:"true" type:"text" help:"The mount paths of the replicas, one per line, which are read in this order"`
+	RepairInterval int    `json:"repair_interval" type:"number" default:"5" help:"Minutes between the retries of the writes failed on a replica"`
+	VerifyInterval int    `json:"verify_interval" type:"number" default:"0" help:"Hours between the verifications of the replicas, 0 to verify only on demand"`
+	VerifyHash     bool   `json:"verify_hash" type:"bool" default:"false" help:"Compare the hashes of the files as well as their sizes when the storages provide them"`
+	VerifyDelete   bool   `json:"verify_delete" type:"bool" default:"false" help:"Remove the objects the first replica lacks from the others on verification, instead of copying them to it"`
+}
+
+var config = driver.Config{
+	Name:             "Mirror",
+	LocalSort:        true,
+	NoCache:          true,
+	DefaultRoot:      "/",
+	ProxyRangeOption: true,
+	LinkCacheMode:    driver.LinkCacheAuto,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Mirror{}
+	})
+}
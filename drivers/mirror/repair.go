@@ -0,0 +1,434 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"slices"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRepairAttempts bounds the retries of a repair, it is left to the next
+// verification afterwards
+const maxRepairAttempts = 10
+
+// repair makes a path of the dst replica the same as of the src one, it is
+// kept in the database until it succeeds or is given up
+type repair struct {
+	src, dst *replica
+	rec      model.MirrorRepair
+}
+
+type verifyResult struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Checked  int       `json:"checked"`
+	Skipped  []string  `json:"skipped"`
+	Repaired []string  `json:"repaired"`
+	Errors   []string  `json:"errors"`
+}
+
+func (d *Mirror) saveRepair(r *repair) {
+	if err := op.SaveMirrorRepair(&r.rec); err != nil {
+		log.Errorf("mirror %s: failed save the repair of %s on replica %s: %+v", d.MountPath, r.rec.Path, r.dst.path, err)
+	}
+}
+
+func (d *Mirror) deleteRepair(r *repair) {
+	if r.rec.ID == 0 {
+		return
+	}
+	if err := op.DeleteMirrorRepair(r.rec.ID); err != nil {
+		log.Errorf("mirror %s: failed delete the repair of %s on replica %s: %+v", d.MountPath, r.rec.Path, r.dst.path, err)
+	}
+}
+
+// loadRepairs reads the repairs left by the last run, the ones of the
+// replicas no longer configured are dropped
+func (d *Mirror) loadRepairs() error {
+	recs, err := op.GetMirrorRepairs(d.ID)
+	if err != nil {
+		return err
+	}
+	replicas := make(map[string]*replica, len(d.replicas))
+	for _, r := range d.replicas {
+		replicas[r.path] = r
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queue = nil
+	for _, rec := range recs {
+		r := &repair{src: replicas[rec.Src], dst: replicas[rec.Dst], rec: rec}
+		if r.src == nil || r.dst == nil || r.src == r.dst {
+			if err := op.DeleteMirrorRepair(rec.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		d.queue = append(d.queue, r)
+	}
+	return nil
+}
+
+func (d *Mirror) enqueue(src, dst *replica, path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, q := range d.queue {
+		if q.dst == dst && q.rec.Path == path {
+			q.src = src
+			q.rec.Src = src.path
+			d.saveRepair(q)
+			return
+		}
+	}
+	r := &repair{src: src, dst: dst, rec: model.MirrorRepair{
+		StorageID: d.ID,
+		Src:       src.path,
+		Dst:       dst.path,
+		Path:      path,
+	}}
+	d.saveRepair(r)
+	d.queue = append(d.queue, r)
+}
+
+// runRepairs retries the queued repairs once, it waits for the run already
+// started if any
+func (d *Mirror) runRepairs(ctx context.Context) {
+	d.repairMu.Lock()
+	defer d.repairMu.Unlock()
+	d.mu.Lock()
+	queue := d.queue
+	d.queue = nil
+	d.mu.Unlock()
+
+	var left []*repair
+	for _, r := range queue {
+		if ctx.Err() != nil {
+			left = append(left, r)
+			continue
+		}
+		err := d.sync(ctx, r.src, r.dst, r.rec.Path)
+		if err == nil {
+			r.dst.recovered()
+			d.deleteRepair(r)
+			continue
+		}
+		r.rec.Attempts++
+		r.rec.LastErr = err.Error()
+		if r.rec.Attempts >= maxRepairAttempts {
+			log.Errorf("mirror %s: gave up repairing %s on replica %s: %+v", d.MountPath, r.rec.Path, r.dst.path, err)
+			d.deleteRepair(r)
+			continue
+		}
+		d.saveRepair(r)
+		left = append(left, r)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range left {
+		// a newer repair of the path is queued meanwhile
+		if slices.ContainsFunc(d.queue, func(q *repair) bool {
+			return q.dst == r.dst && q.rec.Path == r.rec.Path
+		}) {
+			d.deleteRepair(r)
+			continue
+		}
+		d.queue = append(d.queue, r)
+	}
+}
+
+// queuedPaths returns the paths of the repairs left in the queue
+func (d *Mirror) queuedPaths() map[string]struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	paths := make(map[string]struct{}, len(d.queue))
+	for _, q := range d.queue {
+		paths[q.rec.Path] = struct{}{}
+	}
+	return paths
+}
+
+// startVerify starts comparing the replicas in the background. The queued
+// repairs are run first, and the paths still queued afterwards are skipped.
+func (d *Mirror) startVerify() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.verifying {
+		return false
+	}
+	d.verifying = true
+	go func() {
+		res := d.verify(d.ctx)
+		log.Infof("mirror %s: verified %d objects, repaired %d, skipped %d, %d errors",
+			d.MountPath, res.Checked, len(res.Repaired), len(res.Skipped), len(res.Errors))
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.verifying = false
+		d.lastCheck = res
+	}()
+	return true
+}
+
+func (d *Mirror) verify(ctx context.Context) *verifyResult {
+	res := &verifyResult{Started: time.Now()}
+	d.runRepairs(ctx)
+	if err := d.verifyPath(ctx, "/", d.queuedPaths(), res); err != nil {
+		res.Errors = append(res.Errors, err.Error())
+	}
+	res.Finished = time.Now()
+	return res
+}
+
+// verifyPath makes path the same on every replica recursively. A file
+// differing between the replicas is copied from the one modified last, the
+// earlier replica wins if they are modified at the same time. The objects
+// missing on some replicas are copied to them, unless VerifyDelete is set and
+// the first replica lacks them, in which case they are removed from the others.
+func (d *Mirror) verifyPath(ctx context.Context, path string, queued map[string]struct{}, res *verifyResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := queued[path]; ok {
+		res.Skipped = append(res.Skipped, path)
+		return nil
+	}
+	objs := make([]model.Obj, len(d.replicas))
+	var hasDir, hasFile bool
+	for i, r := range d.replicas {
+		obj, err := fs.Get(ctx, r.join(path), &fs.GetArgs{NoLog: true})
+		if err != nil {
+			if errs.IsObjectNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("%s: %w", r.join(path), err)
+		}
+		objs[i] = obj
+		if obj.IsDir() {
+			hasDir = true
+		} else {
+			hasFile = true
+		}
+	}
+	res.Checked++
+	if d.VerifyDelete && objs[0] == nil {
+		var err error
+		for i, obj := range objs {
+			if obj != nil {
+				res.Repaired = append(res.Repaired, d.replicas[i].join(path))
+				err = errors.Join(err, fs.Remove(ctx, d.replicas[i].join(path)))
+			}
+		}
+		return err
+	}
+	if hasDir && hasFile {
+		return fmt.Errorf("%s is a folder on some replicas and a file on the others", path)
+	}
+	if hasFile {
+		latest := -1
+		for i, obj := range objs {
+			if obj != nil && (latest == -1 || obj.ModTime().After(objs[latest].ModTime())) {
+				latest = i
+			}
+		}
+		src := objs[latest]
+		var err error
+		for i, obj := range objs {
+			if obj != nil && same(src, obj, d.VerifyHash) {
+				continue
+			}
+			dstPath := d.replicas[i].join(path)
+			res.Repaired = append(res.Repaired, dstPath)
+			err = errors.Join(err, copyFile(ctx, d.replicas[latest].join(path), stdpath.Dir(dstPath), src))
+		}
+		return err
+	}
+	if !hasDir {
+		return nil
+	}
+	var err error
+	var names []string
+	seen := make(map[string]struct{})
+	for i, obj := range objs {
+		dirPath := d.replicas[i].join(path)
+		if obj == nil {
+			res.Repaired = append(res.Repaired, dirPath)
+			if e := fs.MakeDir(ctx, dirPath); e != nil {
+				err = errors.Join(err, e)
+			}
+			continue
+		}
+		children, e := fs.List(ctx, dirPath, &fs.ListArgs{NoLog: true, Refresh: true})
+		if e != nil {
+			err = errors.Join(err, e)
+			continue
+		}
+		for _, child := range children {
+			if _, ok := seen[child.GetName()]; !ok {
+				seen[child.GetName()] = struct{}{}
+				names = append(names, child.GetName())
+			}
+		}
+	}
+	for _, name := range names {
+		err = errors.Join(err, d.verifyPath(ctx, stdpath.Join(path, name), queued, res))
+	}
+	return err
+}
+
+func same(a, b model.Obj, hash bool) bool {
+	if a.GetSize() != b.GetSize() {
+		return false
+	}
+	if hash {
+		for ht, v := range a.GetHash().All() {
+			if h := b.GetHash().GetHash(ht); h != "" && h != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sync makes path of dst the same as of src recursively, the objects dst
+// has but src doesn't are removed, as the write being repaired removed them
+func (d *Mirror) sync(ctx context.Context, src, dst *replica, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	srcPath, dstPath := src.join(path), dst.join(path)
+	srcObj, err := fs.Get(ctx, srcPath, &fs.GetArgs{NoLog: true})
+	if err != nil && !errs.IsObjectNotFound(err) {
+		return err
+	}
+	dstObj, dstErr := fs.Get(ctx, dstPath, &fs.GetArgs{NoLog: true})
+	if dstErr != nil && !errs.IsObjectNotFound(dstErr) {
+		return dstErr
+	}
+	if srcObj == nil {
+		if dstObj == nil {
+			return nil
+		}
+		return fs.Remove(ctx, dstPath)
+	}
+	if dstObj != nil && dstObj.IsDir() != srcObj.IsDir() {
+		if err := fs.Remove(ctx, dstPath); err != nil {
+			return err
+		}
+		dstObj = nil
+	}
+	if !srcObj.IsDir() {
+		if dstObj != nil && same(srcObj, dstObj, false) {
+			return nil
+		}
+		return copyFile(ctx, srcPath, stdpath.Dir(dstPath), srcObj)
+	}
+	if dstObj == nil {
+		if err := fs.MakeDir(ctx, dstPath); err != nil {
+			return err
+		}
+	}
+	srcObjs, err := fs.List(ctx, srcPath, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		return err
+	}
+	dstObjs, err := fs.List(ctx, dstPath, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(srcObjs))
+	err = nil
+	for _, obj := range srcObjs {
+		names[obj.GetName()] = struct{}{}
+		err = errors.Join(err, d.sync(ctx, src, dst, stdpath.Join(path, obj.GetName())))
+	}
+	for _, obj := range dstObjs {
+		if _, ok := names[obj.GetName()]; !ok {
+			err = errors.Join(err, fs.Remove(ctx, stdpath.Join(dstPath, obj.GetName())))
+		}
+	}
+	return err
+}
+
+func copyFile(ctx context.Context, srcPath, dstDir string, obj model.Obj) error {
+	link, _, err := fs.Link(ctx, srcPath, model.LinkArgs{})
+	if err != nil {
+		return err
+	}
+	defer link.Close()
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return fs.PutDirectly(ctx, dstDir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     obj.GetName(),
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			HashInfo: obj.GetHash(),
+		},
+		Reader:   rc,
+		Mimetype: utils.GetMimeType(obj.GetName()),
+	})
+}
+
+type replicaStatus struct {
+	Path      string    `json:"path"`
+	Healthy   bool      `json:"healthy"`
+	FailedAt  time.Time `json:"failed_at"`
+	LastError string    `json:"last_error"`
+}
+
+type repairStatus struct {
+	Replica  string `json:"replica"`
+	Path     string `json:"path"`
+	Attempts int    `json:"attempts"`
+	LastErr  string `json:"last_error"`
+}
+
+func (d *Mirror) status() map[string]any {
+	replicas := make([]replicaStatus, 0, len(d.replicas))
+	for _, r := range d.replicas {
+		healthy := r.healthy()
+		r.mu.Lock()
+		replicas = append(replicas, replicaStatus{
+			Path:      r.path,
+			Healthy:   healthy,
+			FailedAt:  r.failedAt,
+			LastError: r.lastErr,
+		})
+		r.mu.Unlock()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	repairs := make([]repairStatus, 0, len(d.queue))
+	for _, q := range d.queue {
+		repairs = append(repairs, repairStatus{
+			Replica:  q.dst.path,
+			Path:     q.rec.Path,
+			Attempts: q.rec.Attempts,
+			LastErr:  q.rec.LastErr,
+		})
+	}
+	return map[string]any{
+		"replicas":    replicas,
+		"repairs":     repairs,
+		"verifying":   d.verifying,
+		"last_verify": d.lastCheck,
+	}
+}
@@ -0,0 +1,181 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+}
+
+// setupMirror mounts two temp dirs as the replicas of a mirror storage
+func setupMirror(t *testing.T, name string, verifyDelete bool) (*Mirror, [2]string) {
+	ctx := context.Background()
+	var dirs [2]string
+	var paths string
+	for i := range dirs {
+		dirs[i] = t.TempDir()
+		mountPath := fmt.Sprintf("/%s_%d", name, i)
+		_, err := op.CreateStorage(ctx, model.Storage{
+			Driver:    "Local",
+			MountPath: mountPath,
+			Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, dirs[i]),
+		})
+		if err != nil {
+			t.Fatalf("failed create the replica: %+v", err)
+		}
+		paths += mountPath + "\n"
+	}
+	_, err := op.CreateStorage(ctx, model.Storage{
+		Driver:    "Mirror",
+		MountPath: "/" + name,
+		Addition:  fmt.Sprintf(`{"paths":%q,"verify_delete":%t}`, paths, verifyDelete),
+	})
+	if err != nil {
+		t.Fatalf("failed create the mirror: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath("/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.(*Mirror), dirs
+}
+
+func writeFile(t *testing.T, dir, name, content string, modified time.Time) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func TestVerifyLatestWins(t *testing.T) {
+	d, dirs := setupMirror(t, "mirror_latest", false)
+	now := time.Now()
+	writeFile(t, dirs[0], "f.txt", "old", now.Add(-time.Hour))
+	writeFile(t, dirs[1], "f.txt", "newer", now)
+	writeFile(t, dirs[0], "only_first.txt", "first", now)
+	writeFile(t, dirs[1], "sub/only_second.txt", "second", now)
+	// the files of the same size are the same whatever their modified time
+	writeFile(t, dirs[0], "same.txt", "aaa", now)
+	writeFile(t, dirs[1], "same.txt", "bbb", now.Add(-time.Hour))
+
+	res := d.verify(context.Background())
+	if len(res.Errors) > 0 {
+		t.Fatalf("verify failed: %v", res.Errors)
+	}
+	if got := readFile(dirs[0], "f.txt"); got != "newer" {
+		t.Errorf("f.txt on the first replica is %q, want the latest %q", got, "newer")
+	}
+	if got := readFile(dirs[1], "only_first.txt"); got != "first" {
+		t.Errorf("only_first.txt on the second replica is %q, want %q", got, "first")
+	}
+	if got := readFile(dirs[0], "sub/only_second.txt"); got != "second" {
+		t.Errorf("the file missing on the first replica is %q, want it copied", got)
+	}
+	if got := readFile(dirs[1], "sub/only_second.txt"); got != "second" {
+		t.Errorf("the file missing on the first replica is removed")
+	}
+	if readFile(dirs[0], "same.txt") != "aaa" || readFile(dirs[1], "same.txt") != "bbb" {
+		t.Errorf("the files of the same size are copied")
+	}
+}
+
+func TestVerifyDelete(t *testing.T) {
+	d, dirs := setupMirror(t, "mirror_delete", true)
+	writeFile(t, dirs[0], "kept.txt", "kept", time.Now())
+	writeFile(t, dirs[1], "extra.txt", "extra", time.Now())
+
+	res := d.verify(context.Background())
+	if len(res.Errors) > 0 {
+		t.Fatalf("verify failed: %v", res.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[1], "extra.txt")); !os.IsNotExist(err) {
+		t.Errorf("the file the first replica lacks is kept: %v", err)
+	}
+	if got := readFile(dirs[1], "kept.txt"); got != "kept" {
+		t.Errorf("kept.txt on the second replica is %q, want %q", got, "kept")
+	}
+}
+
+func TestVerifySkipsQueued(t *testing.T) {
+	d, dirs := setupMirror(t, "mirror_queued", false)
+	writeFile(t, dirs[0], "q.txt", "queued", time.Now())
+
+	res := &verifyResult{}
+	err := d.verifyPath(context.Background(), "/", map[string]struct{}{"/q.txt": {}}, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0] != "/q.txt" {
+		t.Errorf("skipped %v, want [/q.txt]", res.Skipped)
+	}
+	if got := readFile(dirs[1], "q.txt"); got != "" {
+		t.Errorf("the queued path is verified")
+	}
+}
+
+func TestRepairQueuePersisted(t *testing.T) {
+	d, dirs := setupMirror(t, "mirror_repair", false)
+	writeFile(t, dirs[0], "r.txt", "repair", time.Now())
+	writeFile(t, dirs[1], "removed.txt", "removed", time.Now())
+	d.enqueue(d.replicas[0], d.replicas[1], "/r.txt")
+	d.enqueue(d.replicas[0], d.replicas[1], "/removed.txt")
+	d.enqueue(d.replicas[0], d.replicas[1], "/r.txt")
+
+	// a restarted driver picks up the queue left
+	restarted := &Mirror{Storage: d.Storage, Addition: d.Addition}
+	if err := restarted.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Drop(context.Background())
+	if len(restarted.queue) != 2 {
+		t.Fatalf("%d repairs are loaded, want 2", len(restarted.queue))
+	}
+
+	restarted.runRepairs(context.Background())
+	if got := readFile(dirs[1], "r.txt"); got != "repair" {
+		t.Errorf("r.txt on the second replica is %q, want %q", got, "repair")
+	}
+	if _, err := os.Stat(filepath.Join(dirs[1], "removed.txt")); !os.IsNotExist(err) {
+		t.Errorf("the file removed from the source is kept: %v", err)
+	}
+	recs, err := op.GetMirrorRepairs(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 0 {
+		t.Errorf("%d repairs are left after they succeed", len(recs))
+	}
+}
@@ -0,0 +1,100 @@
+package mirror
+
+import (
+	"errors"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	log "github.com/sirupsen/logrus"
+)
+
+// failureCooldown is how long a replica is read last after it fails
+const failureCooldown = time.Minute
+
+type replica struct {
+	path string
+
+	mu       sync.Mutex
+	failedAt time.Time
+	lastErr  string
+}
+
+func (r *replica) join(path string) string {
+	return stdpath.Join(r.path, path)
+}
+
+func (r *replica) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedAt = time.Now()
+	r.lastErr = err.Error()
+}
+
+func (r *replica) recovered() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failedAt = time.Time{}
+	r.lastErr = ""
+}
+
+// healthy reports whether the storage of the replica works and it has not
+// failed recently
+func (r *replica) healthy() bool {
+	storage, err := fs.GetStorage(r.path, &fs.GetStoragesArgs{})
+	if err != nil {
+		return false
+	}
+	if storage.Config().CheckStatus && storage.GetStorage().Status != op.WORK {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Since(r.failedAt) > failureCooldown
+}
+
+// readOrder returns the replicas to read from, the healthy ones first
+func (d *Mirror) readOrder() []*replica {
+	healthy := make([]*replica, 0, len(d.replicas))
+	var others []*replica
+	for _, r := range d.replicas {
+		if r.healthy() {
+			healthy = append(healthy, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	return append(healthy, others...)
+}
+
+// apply runs a write on every replica. The write fails only if it fails on
+// all of them, otherwise the paths are queued to be repaired on the replicas
+// it failed on, from a replica it succeeded on.
+func (d *Mirror) apply(write func(r *replica) error, paths ...string) error {
+	var err error
+	var src *replica
+	failed := make(map[*replica]error)
+	for _, r := range d.replicas {
+		if e := write(r); e != nil {
+			err = errors.Join(err, e)
+			failed[r] = e
+			continue
+		}
+		if src == nil {
+			src = r
+		}
+	}
+	if src == nil {
+		return err
+	}
+	for r, e := range failed {
+		log.Warnf("mirror %s: write failed on replica %s, queued to repair: %+v", d.MountPath, r.path, e)
+		r.fail(e)
+		for _, path := range paths {
+			d.enqueue(src, r, path)
+		}
+	}
+	return nil
+}
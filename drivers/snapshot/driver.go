@@ -0,0 +1,370 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	snap "github.com/OpenListTeam/OpenList/v4/internal/snapshot"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const nameFormat = "2006-01-02_15-04-05"
+
+// Snapshot mounts the snapshots of the source path as read-only trees, at
+// /<created>/<path in the source>
+type Snapshot struct {
+	model.Storage
+	Addition
+
+	mu       sync.Mutex
+	taking   bool
+	lastTake *takeResult
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+type takeResult struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Files int       `json:"files"`
+	Error string    `json:"error"`
+}
+
+func (d *Snapshot) Config() driver.Config {
+	return config
+}
+
+func (d *Snapshot) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Snapshot) Init(ctx context.Context) error {
+	d.SourcePath = utils.FixAndCleanPath(d.SourcePath)
+	if utils.IsSubPath(d.MountPath, d.SourcePath) || utils.IsSubPath(d.SourcePath, d.MountPath) {
+		return errors.New("the source path and the storage can't contain each other")
+	}
+	if d.Keep <= 0 {
+		d.Keep = 1
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if d.Interval > 0 {
+		go d.background(d.ctx)
+	}
+	return nil
+}
+
+func (d *Snapshot) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+	return nil
+}
+
+func (d *Snapshot) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{
+		ID:       "0",
+		Name:     "root",
+		Path:     "/",
+		IsFolder: true,
+		Modified: d.Modified,
+	}, nil
+}
+
+func snapshotName(s *model.Snapshot) string {
+	return s.Created.Format(nameFormat)
+}
+
+func snapshotObj(s *model.Snapshot) model.Obj {
+	return &model.Object{
+		ID:       "s" + strconv.FormatUint(uint64(s.ID), 10),
+		Path:     "/" + snapshotName(s),
+		Name:     snapshotName(s),
+		Size:     s.Size,
+		Modified: s.Created,
+		Ctime:    s.Created,
+		IsFolder: true,
+	}
+}
+
+func entryObj(e *model.SnapshotEntry, dir string) model.Obj {
+	return &model.Object{
+		ID:       strconv.FormatUint(uint64(e.ID), 10),
+		Path:     stdpath.Join(dir, e.Name),
+		Name:     e.Name,
+		Size:     e.Size,
+		Modified: e.Modified,
+		IsFolder: e.IsDir,
+		HashInfo: utils.FromString(e.Hash),
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.ObjectNotFound
+	}
+	return err
+}
+
+// snapshots returns the snapshots done, the newest first
+func (d *Snapshot) snapshots() ([]model.Snapshot, error) {
+	all, err := op.GetSnapshots(d.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	done := all[:0]
+	for _, s := range all {
+		if s.Done {
+			done = append(done, s)
+		}
+	}
+	return done, nil
+}
+
+// split returns the snapshot of the path and the path relative to the source
+func (d *Snapshot) split(path string) (*model.Snapshot, string, error) {
+	path = utils.FixAndCleanPath(path)
+	name, rel, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	snapshots, err := d.snapshots()
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range snapshots {
+		if snapshotName(&snapshots[i]) == name {
+			return &snapshots[i], "/" + rel, nil
+		}
+	}
+	return nil, "", errs.ObjectNotFound
+}
+
+func (d *Snapshot) getEntry(path string) (*model.Snapshot, *model.SnapshotEntry, error) {
+	s, rel, err := d.split(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rel == "/" {
+		return s, nil, nil
+	}
+	e, err := op.GetSnapshotEntry(s.ID, stdpath.Dir(rel), stdpath.Base(rel))
+	return s, e, notFound(err)
+}
+
+func (d *Snapshot) Get(ctx context.Context, path string) (model.Obj, error) {
+	path = utils.FixAndCleanPath(path)
+	if path == "/" {
+		return d.GetRoot(ctx)
+	}
+	s, e, err := d.getEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return snapshotObj(s), nil
+	}
+	return entryObj(e, stdpath.Dir(path)), nil
+}
+
+func (d *Snapshot) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	if dir.GetPath() == "/" {
+		snapshots, err := d.snapshots()
+		if err != nil {
+			return nil, err
+		}
+		objs := make([]model.Obj, 0, len(snapshots))
+		for i := range snapshots {
+			objs = append(objs, snapshotObj(&snapshots[i]))
+		}
+		return objs, nil
+	}
+	s, rel, err := d.split(dir.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := op.GetSnapshotChildren(s.ID, rel)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]model.Obj, 0, len(entries))
+	for i := range entries {
+		objs = append(objs, entryObj(&entries[i], dir.GetPath()))
+	}
+	return objs, nil
+}
+
+// Link resolves the file to the live one if it is unchanged, or else to a
+// version of it kept
+func (d *Snapshot) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	s, e, err := d.getEntry(file.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.IsDir {
+		return nil, errs.NotFile
+	}
+	path, state := snap.Resolve(ctx, s, e)
+	if state == snap.StateLost {
+		return nil, fmt.Errorf("%s is no longer recoverable", file.GetPath())
+	}
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return nil, err
+	}
+	l, _, err := op.Link(ctx, storage, actualPath, args)
+	if err != nil {
+		return nil, err
+	}
+	link := l.Clone()
+	if link.ContentLength == 0 {
+		link.ContentLength = e.Size
+	}
+	return link, nil
+}
+
+type report struct {
+	Live    int      `json:"live"`
+	Version int      `json:"version"`
+	Lost    []string `json:"lost"`
+}
+
+// Other takes a snapshot with "take", reports the last one with "status",
+// deletes the snapshot of the obj with "delete", and reports which files
+// under the obj are still recoverable with "report". Only the admin may take
+// or delete the snapshots.
+func (d *Snapshot) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "take", "delete":
+		if user, ok := ctx.Value(conf.UserKey).(*model.User); !ok || !user.IsAdmin() {
+			return nil, errs.PermissionDenied
+		}
+	}
+	switch args.Method {
+	case "take":
+		if !d.tryTake() {
+			return nil, errors.New("a snapshot is already being taken")
+		}
+		go d.take(d.ctx)
+		return "snapshot started", nil
+	case "status":
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return map[string]any{
+			"taking":    d.taking,
+			"last_take": d.lastTake,
+		}, nil
+	case "delete":
+		s, e, err := d.getEntry(args.Obj.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			return nil, errors.New("only a whole snapshot can be deleted")
+		}
+		return "snapshot deleted", op.DeleteSnapshot(s.ID)
+	case "report":
+		s, e, err := d.getEntry(args.Obj.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		var r report
+		var files []model.SnapshotEntry
+		if e != nil && !e.IsDir {
+			files = []model.SnapshotEntry{*e}
+		} else {
+			dir := "/"
+			if e != nil {
+				dir = stdpath.Join(e.Parent, e.Name)
+			}
+			if files, err = op.GetSnapshotFilesUnder(s.ID, dir); err != nil {
+				return nil, err
+			}
+		}
+		for i := range files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			switch _, state := snap.Resolve(ctx, s, &files[i]); state {
+			case snap.StateLive:
+				r.Live++
+			case snap.StateVersion:
+				r.Version++
+			default:
+				r.Lost = append(r.Lost, stdpath.Join(files[i].Parent, files[i].Name))
+			}
+		}
+		return r, nil
+	}
+	return nil, errs.NotSupport
+}
+
+// tryTake marks a snapshot being taken, it fails if one already is
+func (d *Snapshot) tryTake() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.taking {
+		return false
+	}
+	d.taking = true
+	return true
+}
+
+func (d *Snapshot) take(ctx context.Context) {
+	res := &takeResult{Start: time.Now()}
+	s, err := snap.Take(ctx, d.SourcePath)
+	if err == nil {
+		res.Files = s.Files
+		err = snap.Prune(d.SourcePath, d.Keep)
+	}
+	if err != nil {
+		log.Errorf("snapshot %s: failed take the snapshot of %s: %+v", d.MountPath, d.SourcePath, err)
+		res.Error = err.Error()
+	}
+	res.End = time.Now()
+	d.mu.Lock()
+	d.taking = false
+	d.lastTake = res
+	d.mu.Unlock()
+}
+
+// background takes a snapshot once the interval since the latest one passes
+func (d *Snapshot) background(ctx context.Context) {
+	interval := time.Duration(d.Interval) * time.Hour
+	for {
+		wait := time.Duration(0)
+		if snapshots, err := d.snapshots(); err != nil {
+			log.Warnf("snapshot %s: failed get the snapshots: %+v", d.MountPath, err)
+			wait = interval
+		} else if len(snapshots) > 0 {
+			wait = time.Until(snapshots[0].Created.Add(interval))
+		}
+		timer := time.NewTimer(max(wait, time.Minute))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if d.tryTake() {
+				d.take(ctx)
+			} else {
+				log.Warnf("snapshot %s: skipped the snapshot as the last one is still being taken", d.MountPath)
+			}
+		}
+	}
+}
+
+var _ driver.Driver = (*Snapshot)(nil)
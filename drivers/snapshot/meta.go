@@ -0,0 +1,28 @@
+package snapshot
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	SourcePath string `json:"source_path" required:"true" help:"The mount path to take the snapshots of"`
+	Interval   int    `json:"interval" type:"number" default:"24" help:"Hours between the snapshots, 0 to take them only on demand"`
+	Keep       int    `json:"keep" type:"number" default:"7" help:"The number of the latest snapshots to keep"`
+}
+
+var config = driver.Config{
+	Name:        "Snapshot",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	NoUpload:    true,
+	DefaultRoot: "/",
+	NoLinkURL:   true,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Snapshot{}
+	})
+}
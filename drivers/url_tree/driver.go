@@ -3,6 +3,7 @@ package url_tree
 import (
 	"context"
 	"errors"
+	"io"
 	stdpath "path"
 	"strings"
 	"sync"
@@ -20,6 +21,12 @@ type Urls struct {
 	Addition
 	root  *Node
 	mutex sync.RWMutex
+
+	checkMu   sync.Mutex
+	checking  bool
+	lastCheck *checkResult
+	ctx       context.Context
+	cancel    context.CancelFunc
 }
 
 func (d *Urls) Config() driver.Config {
@@ -37,10 +44,21 @@ func (d *Urls) Init(ctx context.Context) error {
 	}
 	node.calSize()
 	d.root = node
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if d.CheckInterval > 0 {
+		go d.background(d.ctx)
+	}
 	return nil
 }
 
 func (d *Urls) Drop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
 	return nil
 }
 
@@ -250,6 +268,21 @@ func (d *Urls) Put(ctx context.Context, dstDir model.Obj, stream model.FileStrea
 	if !d.Writable {
 		return errs.PermissionDenied
 	}
+	// a manifest uploaded is imported into the folder
+	if format := FormatByName(stream.GetName()); format != "" && !isUrl(stream.GetName()) {
+		if stream.GetSize() > maxManifestSize {
+			return errors.New("the manifest is too large")
+		}
+		content, err := io.ReadAll(io.LimitReader(stream, maxManifestSize+1))
+		if err != nil {
+			return err
+		}
+		if len(content) > maxManifestSize {
+			return errors.New("the manifest is too large")
+		}
+		_, err = d.importNodes(ctx, dstDir.GetPath(), format, content)
+		return err
+	}
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	node := GetNodeFromRootByPath(d.root, dstDir.GetPath()) // parent
@@ -273,8 +306,4 @@ func (d *Urls) updateStorage() {
 	op.MustSaveDriverStorage(d)
 }
 
-//func (d *Template) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
-//	return nil, errs.NotSupport
-//}
-
 var _ driver.Driver = (*Urls)(nil)
@@ -0,0 +1,313 @@
+package url_tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdpath "path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the formats of the manifests which can be imported and exported besides
+// the tree text
+const (
+	FormatTree = "tree"
+	FormatM3U  = "m3u"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// FormatByName returns the format of a manifest file by its extension, it
+// returns "" if the file isn't a manifest
+func FormatByName(name string) string {
+	switch strings.ToLower(stdpath.Ext(name)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	}
+	return ""
+}
+
+// jsonNode is a node of the JSON tree, it's a file if Url is set
+type jsonNode struct {
+	Name     string     `json:"name"`
+	Url      string     `json:"url,omitempty"`
+	Size     int64      `json:"size,omitempty"`
+	Modified int64      `json:"modified,omitempty"`
+	Children []jsonNode `json:"children,omitempty"`
+}
+
+// ParseManifest parses a manifest into the nodes to be put into a folder,
+// the levels of the nodes are relative to the folder
+func ParseManifest(format string, content []byte, headSize bool) ([]*Node, error) {
+	dir := &Node{Level: -1}
+	var err error
+	switch format {
+	case FormatTree:
+		var root *Node
+		if root, err = BuildTree(string(content), headSize); err == nil {
+			dir = root
+		}
+	case FormatM3U:
+		err = parseM3U(dir, content, headSize)
+	case FormatJSON:
+		err = parseJSON(dir, content)
+	case FormatCSV:
+		err = parseCSV(dir, content, headSize)
+	default:
+		return nil, fmt.Errorf("unknown manifest format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dir.Children, nil
+}
+
+// the names in the tree text can't contain ':' or line breaks, nor '/' as a
+// path element
+var nameReplacer = strings.NewReplacer(":", "_", "/", "_", "\r", "", "\n", " ")
+
+func cleanName(name string) string {
+	return strings.TrimSpace(nameReplacer.Replace(name))
+}
+
+func isUrl(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// mkdirAll returns the folder of the path under dir, creating the missing ones
+func mkdirAll(dir *Node, path string) *Node {
+	for _, name := range strings.Split(path, "/") {
+		name = cleanName(name)
+		if name == "" {
+			continue
+		}
+		var next *Node
+		for _, child := range dir.Children {
+			if !child.isFile() && child.Name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			next = &Node{Name: name, Level: dir.Level + 1}
+			dir.Children = append(dir.Children, next)
+		}
+		dir = next
+	}
+	return dir
+}
+
+func addFile(dir *Node, name, url string, size, modified int64, headSize bool) error {
+	if !isUrl(url) {
+		return fmt.Errorf("invalid url: %s", url)
+	}
+	if name = cleanName(name); name == "" {
+		name = cleanName(stdpath.Base(url))
+	}
+	node := &Node{
+		Name:     name,
+		Url:      url,
+		Level:    dir.Level + 1,
+		Size:     size,
+		Modified: modified,
+	}
+	if size == 0 && headSize {
+		if size, modified, err := getInfoFromUrl(url); err == nil {
+			node.Size = max(size, 0)
+			if node.Modified == 0 {
+				node.Modified = modified
+			}
+		}
+	}
+	dir.Children = append(dir.Children, node)
+	return nil
+}
+
+var groupTitleReg = regexp.MustCompile(`group-title="([^"]*)"`)
+
+// parseM3U parses a playlist, the entries are named by the titles of
+// #EXTINF and put into the folders named by their group-title or #EXTGRP
+func parseM3U(dir *Node, content []byte, headSize bool) error {
+	var title, group, lastGroup string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			// the title is after the first comma which isn't in the quotes
+			inQuote := false
+			for i, c := range info {
+				if c == '"' {
+					inQuote = !inQuote
+				} else if c == ',' && !inQuote {
+					title = info[i+1:]
+					break
+				}
+			}
+			if m := groupTitleReg.FindStringSubmatch(info); m != nil {
+				group = m[1]
+			}
+		case strings.HasPrefix(line, "#EXTGRP:"):
+			lastGroup = strings.TrimPrefix(line, "#EXTGRP:")
+		case strings.HasPrefix(line, "#"):
+		default:
+			if group == "" {
+				group = lastGroup
+			}
+			if err := addFile(mkdirAll(dir, group), title, line, 0, 0, headSize); err != nil {
+				return err
+			}
+			title, group = "", ""
+		}
+	}
+	return scanner.Err()
+}
+
+func parseJSON(dir *Node, content []byte) error {
+	var nodes []jsonNode
+	if err := json.Unmarshal(content, &nodes); err != nil {
+		// a single root folder is accepted too
+		var root jsonNode
+		if json.Unmarshal(content, &root) != nil {
+			return fmt.Errorf("invalid json tree: %w", err)
+		}
+		nodes = root.Children
+	}
+	return addJSONNodes(dir, nodes)
+}
+
+func addJSONNodes(dir *Node, nodes []jsonNode) error {
+	for _, n := range nodes {
+		if n.Url != "" {
+			if err := addFile(dir, n.Name, n.Url, n.Size, n.Modified, false); err != nil {
+				return err
+			}
+			continue
+		}
+		if cleanName(n.Name) == "" {
+			return fmt.Errorf("a folder of the json tree has no name")
+		}
+		if err := addJSONNodes(mkdirAll(dir, n.Name), n.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCSV parses the rows of path,size,modified,url, the header row is
+// optional and the modified time is either a unix timestamp or RFC 3339
+func parseCSV(dir *Node, content []byte, headSize bool) error {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\ufeff"))))
+	r.FieldsPerRecord = 4
+	r.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == 1 && strings.EqualFold(record[0], "path") {
+			continue
+		}
+		var size, modified int64
+		if record[1] != "" {
+			if size, err = strconv.ParseInt(record[1], 10, 64); err != nil {
+				return fmt.Errorf("invalid size at line %d: %s", line, record[1])
+			}
+		}
+		if record[2] != "" {
+			if modified, err = strconv.ParseInt(record[2], 10, 64); err != nil {
+				t, err := time.Parse(time.RFC3339, record[2])
+				if err != nil {
+					return fmt.Errorf("invalid modified at line %d: %s", line, record[2])
+				}
+				modified = t.Unix()
+			}
+		}
+		path := strings.Trim(record[0], "/")
+		parent, name := stdpath.Split(path)
+		if name == "" {
+			name = stdpath.Base(record[3])
+		}
+		if err := addFile(mkdirAll(dir, parent), name, record[3], size, modified, headSize); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// Export produces the manifest of a folder in the format given
+func Export(dir *Node, format string) ([]byte, error) {
+	switch format {
+	case FormatTree:
+		root := dir.deepCopy(-1)
+		return []byte(StringifyTree(root)), nil
+	case FormatM3U:
+		var buf bytes.Buffer
+		buf.WriteString("#EXTM3U\n")
+		walkFiles(dir, "", func(parent string, node *Node) {
+			if parent != "" {
+				fmt.Fprintf(&buf, "#EXTINF:-1 group-title=\"%s\",%s\n", strings.ReplaceAll(parent, `"`, "'"), node.Name)
+			} else {
+				fmt.Fprintf(&buf, "#EXTINF:-1,%s\n", node.Name)
+			}
+			buf.WriteString(node.Url)
+			buf.WriteString("\n")
+		})
+		return buf.Bytes(), nil
+	case FormatJSON:
+		return json.MarshalIndent(toJSONNodes(dir.Children), "", "  ")
+	case FormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"path", "size", "modified", "url"})
+		walkFiles(dir, "", func(parent string, node *Node) {
+			modified := ""
+			if node.Modified != 0 {
+				modified = strconv.FormatInt(node.Modified, 10)
+			}
+			_ = w.Write([]string{stdpath.Join(parent, node.Name), strconv.FormatInt(node.Size, 10), modified, node.Url})
+		})
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+	return nil, fmt.Errorf("unknown manifest format: %s", format)
+}
+
+func walkFiles(dir *Node, parent string, fn func(parent string, node *Node)) {
+	for _, child := range dir.Children {
+		if child.isFile() {
+			fn(parent, child)
+		} else {
+			walkFiles(child, stdpath.Join(parent, child.Name), fn)
+		}
+	}
+}
+
+func toJSONNodes(nodes []*Node) []jsonNode {
+	ret := make([]jsonNode, 0, len(nodes))
+	for _, node := range nodes {
+		n := jsonNode{Name: node.Name, Url: node.Url}
+		if node.isFile() {
+			n.Size, n.Modified = node.Size, node.Modified
+		} else {
+			n.Children = toJSONNodes(node.Children)
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
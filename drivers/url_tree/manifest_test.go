@@ -0,0 +1,70 @@
+package url_tree_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/url_tree"
+)
+
+func TestParseM3U(t *testing.T) {
+	text := `#EXTM3U
+#EXTINF:-1 group-title="News, Live",Channel: One
+http://host/one.m3u8
+#EXTINF:120,Song
+https://host/music/song.mp3
+#EXTGRP:Misc
+http://host/misc/a.ts`
+	nodes, err := url_tree.ParseManifest(url_tree.FormatM3U, []byte(text), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("got %d nodes", len(nodes))
+	}
+	if nodes[0].Name != "News, Live" || nodes[0].Children[0].Name != "Channel_ One" {
+		t.Errorf("got wrong group: %+v", nodes[0].Children[0])
+	}
+	if nodes[1].Name != "Song" || nodes[1].Url != "https://host/music/song.mp3" {
+		t.Errorf("got wrong file: %+v", nodes[1])
+	}
+	if nodes[2].Name != "Misc" || nodes[2].Children[0].Name != "a.ts" {
+		t.Errorf("got wrong group: %+v", nodes[2])
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	csv := `path,size,modified,url
+a/b/1.txt,10,1700000000,http://host/1
+a/2.txt,,2024-01-02T03:04:05Z,http://host/2
+3.txt,30,,https://host/3`
+	nodes, err := url_tree.ParseManifest(url_tree.FormatCSV, []byte(csv), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &url_tree.Node{Name: "root", Level: -1, Children: nodes}
+	for _, format := range []string{url_tree.FormatTree, url_tree.FormatJSON, url_tree.FormatCSV} {
+		content, err := url_tree.Export(root, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := url_tree.ParseManifest(format, content, false)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		again, err := url_tree.Export(&url_tree.Node{Name: "root", Level: -1, Children: parsed}, url_tree.FormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, _ := url_tree.Export(root, url_tree.FormatCSV); string(again) != string(want) {
+			t.Errorf("%s: got %s, want %s", format, again, want)
+		}
+	}
+	m3u, err := url_tree.Export(root, url_tree.FormatM3U)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(m3u), "#EXTINF:-1 group-title=\"a/b\",1.txt\nhttp://host/1\n") {
+		t.Errorf("got m3u %s", m3u)
+	}
+}
@@ -0,0 +1,264 @@
+package url_tree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// the manifests larger than it are refused
+const maxManifestSize = 16 * 1024 * 1024
+
+type importArgs struct {
+	Format  string `json:"format"`
+	Content string `json:"content"`
+}
+
+type exportArgs struct {
+	Format string `json:"format"`
+}
+
+type checkResult struct {
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	Checked int               `json:"checked"`
+	Updated int               `json:"updated"`
+	Dead    map[string]string `json:"dead"`
+}
+
+// importNodes puts the nodes parsed from a manifest into the folder, the
+// sizes are got by head only if the admin imports it, so that the others
+// can't make the server request the urls they like
+func (d *Urls) importNodes(ctx context.Context, dirPath string, format string, content []byte) (int, error) {
+	user, _ := ctx.Value(conf.UserKey).(*model.User)
+	nodes, err := ParseManifest(format, content, d.HeadSize && user != nil && user.IsAdmin())
+	if err != nil {
+		return 0, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	dir := GetNodeFromRootByPath(d.root, dirPath)
+	if dir == nil {
+		return 0, errs.ObjectNotFound
+	}
+	if dir.isFile() {
+		return 0, errs.NotFolder
+	}
+	count := 0
+	for _, node := range nodes {
+		count += merge(dir, node)
+	}
+	d.root.calSize()
+	d.updateStorage()
+	return count, nil
+}
+
+// merge puts the node into the folder, the folders of the same name are
+// merged, it returns the number of the files put
+func merge(dir, node *Node) int {
+	if !node.isFile() {
+		for _, child := range dir.Children {
+			if !child.isFile() && child.Name == node.Name {
+				count := 0
+				for _, n := range node.Children {
+					count += merge(child, n)
+				}
+				return count
+			}
+		}
+	}
+	node.setLevel(dir.Level + 1)
+	dir.Children = append(dir.Children, node)
+	if node.isFile() {
+		return 1
+	}
+	count := 0
+	walkFiles(node, "", func(string, *Node) { count++ })
+	return count
+}
+
+// Other imports a manifest into the folder of the obj with "import", exports
+// the folder with "export", and starts the reachability check with "check"
+// whose result is reported by "status"
+func (d *Urls) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "import":
+		user, _ := ctx.Value(conf.UserKey).(*model.User)
+		if !d.Writable || user == nil || !(user.IsAdmin() || user.CanWriteContent()) {
+			return nil, errs.PermissionDenied
+		}
+		var ia importArgs
+		if err := utils.JsonConvert(args.Data, &ia); err != nil {
+			return nil, err
+		}
+		if len(ia.Content) > maxManifestSize {
+			return nil, errors.New("the manifest is too large")
+		}
+		count, err := d.importNodes(ctx, args.Obj.GetPath(), ia.Format, []byte(ia.Content))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"imported": count}, nil
+	case "export":
+		ea := exportArgs{Format: FormatTree}
+		if err := utils.JsonConvert(args.Data, &ea); err != nil {
+			return nil, err
+		}
+		d.mutex.RLock()
+		defer d.mutex.RUnlock()
+		node := GetNodeFromRootByPath(d.root, args.Obj.GetPath())
+		if node == nil {
+			return nil, errs.ObjectNotFound
+		}
+		if node.isFile() {
+			return nil, errs.NotFolder
+		}
+		content, err := Export(node, ea.Format)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"name":    fmt.Sprintf("%s.%s", node.Name, ea.Format),
+			"content": string(content),
+		}, nil
+	case "check":
+		// the check sends requests to every url, so only the admin may start it
+		if user, ok := ctx.Value(conf.UserKey).(*model.User); !ok || !user.IsAdmin() {
+			return nil, errs.PermissionDenied
+		}
+		if !d.startCheck() {
+			return nil, errors.New("the check is already running")
+		}
+		return "check started", nil
+	case "status":
+		d.checkMu.Lock()
+		defer d.checkMu.Unlock()
+		return map[string]any{
+			"checking":   d.checking,
+			"last_check": d.lastCheck,
+		}, nil
+	}
+	return nil, errs.NotSupport
+}
+
+func (d *Urls) startCheck() bool {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+	if d.checking {
+		return false
+	}
+	d.checking = true
+	go d.check(d.ctx)
+	return true
+}
+
+type checkItem struct {
+	path string
+	node *Node
+}
+
+// check heads the url of every file to update its size and modified time,
+// and records the ones unreachable
+func (d *Urls) check(ctx context.Context) {
+	res := &checkResult{Start: time.Now(), Dead: map[string]string{}}
+	defer func() {
+		res.End = time.Now()
+		d.checkMu.Lock()
+		d.checking = false
+		d.lastCheck = res
+		d.checkMu.Unlock()
+	}()
+	var items []checkItem
+	d.mutex.RLock()
+	walkFiles(d.root, "/", func(parent string, node *Node) {
+		items = append(items, checkItem{path: stdpath.Join(parent, node.Name), node: node})
+	})
+	d.mutex.RUnlock()
+
+	type info struct {
+		size, modified int64
+	}
+	infos := make([]*info, len(items))
+	var deadMu sync.Mutex
+	sem := make(chan struct{}, 4)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		d.mutex.RLock()
+		url := item.node.Url
+		d.mutex.RUnlock()
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			size, modified, err := getInfoFromUrl(url)
+			if err != nil {
+				deadMu.Lock()
+				res.Dead[item.path] = err.Error()
+				deadMu.Unlock()
+				return
+			}
+			infos[i] = &info{size: size, modified: modified}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+	res.Checked = len(items)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, item := range items {
+		in := infos[i]
+		if in == nil {
+			continue
+		}
+		// the size or the modified time the server doesn't tell is kept
+		if (in.size >= 0 && item.node.Size != in.size) || (in.modified != 0 && item.node.Modified != in.modified) {
+			if in.size >= 0 {
+				item.node.Size = in.size
+			}
+			if in.modified != 0 {
+				item.node.Modified = in.modified
+			}
+			res.Updated++
+		}
+	}
+	if res.Updated > 0 {
+		d.root.calSize()
+		d.updateStorage()
+	}
+	if len(res.Dead) > 0 {
+		log.Warnf("url tree %s: %d of %d urls are unreachable", d.MountPath, len(res.Dead), len(items))
+	}
+}
+
+func (d *Urls) background(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(d.CheckInterval) * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !d.startCheck() {
+				log.Warnf("url tree %s: skipped the check as the last one is still running", d.MountPath)
+			}
+		}
+	}
+}
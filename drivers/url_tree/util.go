@@ -2,6 +2,7 @@ package url_tree
 
 import (
 	"fmt"
+	"net/http"
 	stdpath "path"
 	"strconv"
 	"strings"
@@ -176,19 +177,52 @@ func nodeToObj(node *Node, path string) (model.Obj, error) {
 }
 
 func getSizeFromUrl(url string) (int64, error) {
+	size, _, err := getInfoFromUrl(url)
+	if err == nil && size < 0 {
+		err = fmt.Errorf("get size from url %s failed, the size is unknown", url)
+	}
+	return size, err
+}
+
+// getInfoFromUrl returns the size and the modified time of the url by the
+// head method, or by a get of the first byte if the server refuses head.
+// The size is -1 and the modified time is 0 if the server doesn't tell.
+func getInfoFromUrl(url string) (int64, int64, error) {
 	res, err := base.RestyClient.R().SetDoNotParseResponse(true).Head(url)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
+	}
+	_ = res.RawResponse.Body.Close()
+	ranged := false
+	if res.StatusCode() == http.StatusMethodNotAllowed || res.StatusCode() == http.StatusNotImplemented ||
+		res.StatusCode() == http.StatusForbidden {
+		res, err = base.RestyClient.R().SetDoNotParseResponse(true).SetHeader("Range", "bytes=0-0").Get(url)
+		if err != nil {
+			return 0, 0, err
+		}
+		_ = res.RawResponse.Body.Close()
+		ranged = res.StatusCode() == http.StatusPartialContent
 	}
-	defer res.RawResponse.Body.Close()
 	if res.StatusCode() >= 300 {
-		return 0, fmt.Errorf("get size from url %s failed, status code: %d", url, res.StatusCode())
+		return 0, 0, fmt.Errorf("get size from url %s failed, status code: %d", url, res.StatusCode())
 	}
-	size, err := strconv.ParseInt(res.Header().Get("Content-Length"), 10, 64)
-	if err != nil {
-		return 0, err
+	size := int64(-1)
+	if ranged {
+		// Content-Range: bytes 0-0/<size>
+		cr := res.Header().Get("Content-Range")
+		if i := strings.LastIndexByte(cr, '/'); i != -1 {
+			if s, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				size = s
+			}
+		}
+	} else if s, err := strconv.ParseInt(res.Header().Get("Content-Length"), 10, 64); err == nil {
+		size = s
+	}
+	var modified int64
+	if t, err := http.ParseTime(res.Header().Get("Last-Modified")); err == nil {
+		modified = t.Unix()
 	}
-	return size, nil
+	return size, modified, nil
 }
 
 func StringifyTree(node *Node) string {
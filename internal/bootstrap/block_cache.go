@@ -0,0 +1,20 @@
+package bootstrap
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+func InitBlockCache() {
+	if !conf.Conf.BlockCache.Enable {
+		return
+	}
+	c := conf.Conf.BlockCache
+	err := stream.InitBlockCache(c.Dir, int64(c.MaxSize)<<20, int64(c.BlockSize)<<10)
+	if err != nil {
+		log.Errorf("failed init block cache: %+v", err)
+		return
+	}
+	log.Infof("block cache enabled at %s, max size: %dMB, block size: %dKB", c.Dir, c.MaxSize, c.BlockSize)
+}
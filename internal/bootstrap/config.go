@@ -155,6 +155,9 @@ func InitConfig() {
 	convertAbsPath(&conf.Conf.TempDir)
 	convertAbsPath(&conf.Conf.BleveDir)
 	convertAbsPath(&conf.Conf.DistDir)
+	convertAbsPath(&conf.Conf.BlockCache.Dir)
+	convertAbsPath(&conf.Conf.Thumbnail.Dir)
+	convertAbsPath(&conf.Conf.HLS.Dir)
 
 	err := os.MkdirAll(conf.Conf.TempDir, 0o777)
 	if err != nil {
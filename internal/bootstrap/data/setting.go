@@ -162,6 +162,11 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.ReadMeAutoRender, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW},
 		{Key: conf.FilterReadMeScripts, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW}, // frontend
 		{Key: conf.NonEFSZipEncoding, Value: "IBM437", Type: conf.TypeString, Group: model.PREVIEW},
+		{Key: conf.MediaMeta, Value: "false", Type: conf.TypeBool, Group: model.PREVIEW, Help: `return the metadata of images, audios and videos in fs/get, they are parsed in the background on the first request`},
+		{Key: conf.SubtitleEncoding, Value: "GB18030", Type: conf.TypeString, Group: model.PREVIEW, Help: `encoding of the subtitles which are neither UTF-8 nor UTF-16, used when converting them to WebVTT`},
+		{Key: conf.TextHistoryCount, Value: "10", Type: conf.TypeNumber, Group: model.PREVIEW, Help: `previous versions kept of each text file saved by the editor, 0 to keep none`},
+		{Key: conf.WOPIEditorURL, Value: "", Type: conf.TypeString, Group: model.PREVIEW, Help: `url of the office editor, e.g. the url of Collabora or OnlyOffice for the action of the file from its WOPI discovery, with $wopi_src in place of the url of the file`},
+		{Key: conf.WOPITokenExpiration, Value: "60", Type: conf.TypeNumber, Group: model.PREVIEW, Flag: model.PRIVATE, Help: `minutes the access tokens of the office editor are valid for`},
 		// global settings
 		{Key: conf.HideFiles, Value: "/\\/README.md/i", Type: conf.TypeText, Group: model.GLOBAL},
 		{Key: "package_download", Value: "true", Type: conf.TypeBool, Group: model.GLOBAL},
@@ -186,6 +191,9 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.ShareSummaryContent, Value: "@{{creator}} shared {{#each files}}{{#if @first}}\"{{filename this}}\"{{/if}}{{#if @last}}{{#unless (eq @index 0)}} and {{@index}} more files{{/unless}}{{/if}}{{/each}} from {{site_title}}: {{base_url}}/@s/{{id}}{{#if pwd}} , the share code is {{pwd}}{{/if}}{{#if expires}}, please access before {{dateLocaleString expires}}.{{/if}}", Type: conf.TypeText, Group: model.GLOBAL, Flag: model.PUBLIC},
 		{Key: conf.HandleHookAfterWriting, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE},
 		{Key: conf.HandleHookRateLimit, Value: "0", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE},
+		{Key: conf.ChangeJournalRetention, Value: "7", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `days to keep the change journal used by WebDAV sync-collection, 0 to disable`},
+		{Key: conf.VersionsKeep, Value: "10", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `versions kept of each file replaced in the storages or paths with versioning enabled, 0 for no limit`},
+		{Key: conf.VersionsMaxAge, Value: "30", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `days to keep the versions of replaced files, 0 for no limit`},
 		{Key: conf.IgnoreSystemFiles, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `When enabled, ignores common system files during upload (.DS_Store, desktop.ini, Thumbs.db, and files starting with ._)`},
 
 		// single settings
@@ -194,6 +202,7 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.AutoUpdateIndex, Value: "false", Type: conf.TypeBool, Group: model.INDEX},
 		{Key: conf.IgnorePaths, Value: "", Type: conf.TypeText, Group: model.INDEX, Flag: model.PRIVATE, Help: `one path per line`},
 		{Key: conf.MaxIndexDepth, Value: "20", Type: conf.TypeNumber, Group: model.INDEX, Flag: model.PRIVATE, Help: `max depth of index`},
+		{Key: conf.MediaMetaIndex, Value: "false", Type: conf.TypeBool, Group: model.INDEX, Flag: model.PRIVATE, Help: `parse the metadata of media files while building index, so that they can be searched by it and the images appear in the photo timeline, which lists only the images of which the metadata are cached`},
 		{Key: conf.IndexProgress, Value: "{}", Type: conf.TypeText, Group: model.SINGLE, Flag: model.PRIVATE},
 
 		// SSO settings
@@ -236,6 +245,7 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.FTPImplicitTLS, Value: "false", Type: conf.TypeBool, Group: model.FTP, Flag: model.PRIVATE},
 		{Key: conf.FTPTLSPrivateKeyPath, Value: "", Type: conf.TypeString, Group: model.FTP, Flag: model.PRIVATE},
 		{Key: conf.FTPTLSPublicCertPath, Value: "", Type: conf.TypeString, Group: model.FTP, Flag: model.PRIVATE},
+		{Key: conf.FTPAllowedIPs, Value: "", Type: conf.TypeText, Group: model.FTP, Flag: model.PRIVATE, Help: `IPs or CIDRs allowed to connect, one per line, empty to allow all`},
 		{Key: conf.SFTPDisablePasswordLogin, Value: "false", Type: conf.TypeBool, Group: model.FTP, Flag: model.PRIVATE},
 
 		// traffic settings
@@ -0,0 +1,20 @@
+package bootstrap
+
+import (
+	"context"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitFileVersionPrune removes the expired versions hourly, the versions of
+// a file are pruned as well when it is replaced or its versions are listed
+func InitFileVersionPrune() {
+	cron.NewCron(time.Hour).Do(func() {
+		if err := op.PruneExpiredFileVersions(context.Background()); err != nil {
+			log.Warnf("failed prune expired file versions: %+v", err)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/hls"
+	log "github.com/sirupsen/logrus"
+)
+
+func InitHLS() {
+	if !conf.Conf.HLS.Enable {
+		return
+	}
+	c := conf.Conf.HLS
+	err := hls.Init(hls.Config{
+		Dir:         c.Dir,
+		MaxSize:     int64(c.MaxSize) << 20,
+		FFmpegPath:  c.FFmpegPath,
+		Transcode:   c.Transcode,
+		MaxJobs:     c.MaxJobs,
+		SegmentTime: c.SegmentTime,
+		Timeout:     time.Duration(c.Timeout) * time.Minute,
+	})
+	if err != nil {
+		log.Errorf("failed init hls: %+v", err)
+		return
+	}
+	log.Infof("hls enabled at %s, max size: %dMB, transcode: %s", c.Dir, c.MaxSize, c.Transcode)
+}
@@ -35,6 +35,9 @@ func Init() {
 	InitDB()
 	data.InitData()
 	InitStreamLimit()
+	InitBlockCache()
+	InitThumbnail()
+	InitHLS()
 	InitIndex()
 	InitUpgradePatch()
 }
@@ -61,6 +64,8 @@ var (
 	sftpDriver   *server.SftpDriver
 	sftpServer   *sftpd.SftpServer
 	sftpRunning  bool
+	nfsServer    *server.NfsServer
+	nfsRunning   bool
 )
 
 // Called by OpenList-Mobile
@@ -80,6 +85,8 @@ func IsRunning(t string) bool {
 		return sftpRunning
 	case "ftp":
 		return ftpRunning
+	case "nfs":
+		return nfsRunning
 	}
 	return running
 }
@@ -92,6 +99,7 @@ func Start() {
 	InitOfflineDownloadTools()
 	LoadStorages()
 	InitTaskManager()
+	InitFileVersionPrune()
 	if !flags.Debug && !flags.Dev {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -268,6 +276,28 @@ func Start() {
 			}()
 		}
 	}
+	if conf.Conf.NFS.Listen != "" && conf.Conf.NFS.Enable {
+		var err error
+		nfsServer, err = server.NewNfsServer()
+		if err != nil {
+			handleEndpointStartFailedHooks("nfs", err)
+			utils.Log.Errorf("failed to start nfs server: %s", err.Error())
+		} else {
+			fmt.Printf("start nfs server on %s\n", conf.Conf.NFS.Listen)
+			utils.Log.Infof("start nfs server on %s", conf.Conf.NFS.Listen)
+			go func() {
+				nfsRunning = true
+				err := nfsServer.Serve()
+				nfsRunning = false
+				if err != nil {
+					handleEndpointStartFailedHooks("nfs", err)
+					utils.Log.Errorf("problem nfs server listening: %s", err.Error())
+				} else {
+					handleEndpointShutdownHooks("nfs")
+				}
+			}()
+		}
+	}
 	running = true
 }
 
@@ -352,6 +382,16 @@ func Shutdown(timeout time.Duration) {
 			sftpDriver = nil
 		}()
 	}
+	if conf.Conf.NFS.Listen != "" && conf.Conf.NFS.Enable && nfsServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := nfsServer.Close(); err != nil {
+				utils.Log.Error("NFS server shutdown err: ", err)
+			}
+			nfsServer = nil
+		}()
+	}
 	wg.Wait()
 	utils.Log.Println("Server exit")
 	running = false
@@ -49,4 +49,5 @@ func InitTaskManager() {
 	op.RegisterSettingChangingCallback(func() {
 		fs.ArchiveContentUploadTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskDecompressUploadThreadsNum, conf.Conf.Tasks.DecompressUpload.Workers)))
 	})
+	fs.StorageJobTaskManager = tache.NewManager[*fs.StorageJobTask](tache.WithWorks(conf.Conf.Tasks.StorageJob.Workers), tache.WithMaxRetry(conf.Conf.Tasks.StorageJob.MaxRetry)) //storage job will not support persist
 }
@@ -0,0 +1,28 @@
+package bootstrap
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/thumbnail"
+	log "github.com/sirupsen/logrus"
+)
+
+func InitThumbnail() {
+	if !conf.Conf.Thumbnail.Enable {
+		return
+	}
+	c := conf.Conf.Thumbnail
+	err := thumbnail.Init(thumbnail.Config{
+		Dir:           c.Dir,
+		MaxSize:       int64(c.MaxSize) << 20,
+		Width:         c.Width,
+		MaxSourceSize: int64(c.MaxSourceSize) << 20,
+		Video:         c.Video,
+		FFmpegPath:    c.FFmpegPath,
+		VideoPos:      c.VideoPos,
+	})
+	if err != nil {
+		log.Errorf("failed init thumbnail: %+v", err)
+		return
+	}
+	log.Infof("thumbnail enabled at %s, max size: %dMB", c.Dir, c.MaxSize)
+}
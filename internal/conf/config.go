@@ -73,6 +73,7 @@ type TasksConfig struct {
 	Move               TaskConfig `json:"move" envPrefix:"MOVE_"`
 	Decompress         TaskConfig `json:"decompress" envPrefix:"DECOMPRESS_"`
 	DecompressUpload   TaskConfig `json:"decompress_upload" envPrefix:"DECOMPRESS_UPLOAD_"`
+	StorageJob         TaskConfig `json:"storage_job" envPrefix:"STORAGE_JOB_"`
 	AllowRetryCanceled bool       `json:"allow_retry_canceled" env:"ALLOW_RETRY_CANCELED"`
 }
 
@@ -106,6 +107,43 @@ type SFTP struct {
 	Listen string `json:"listen" env:"LISTEN"`
 }
 
+type NFS struct {
+	Enable       bool   `json:"enable" env:"ENABLE"`
+	Listen       string `json:"listen" env:"LISTEN"`
+	User         string `json:"user" env:"USER"`
+	AllowedIPs   string `json:"allowed_ips" env:"ALLOWED_IPS"`
+	HandleExpiry int    `json:"handle_expiry" env:"HANDLE_EXPIRY"` // days unused after which a handle is pruned
+}
+
+type BlockCache struct {
+	Enable    bool   `json:"enable" env:"ENABLE"`
+	Dir       string `json:"dir" env:"DIR"`
+	MaxSize   int    `json:"max_size" env:"MAX_SIZE"`     // MB
+	BlockSize int    `json:"block_size" env:"BLOCK_SIZE"` // KB
+}
+
+type Thumbnail struct {
+	Enable        bool    `json:"enable" env:"ENABLE"`
+	Dir           string  `json:"dir" env:"DIR"`
+	MaxSize       int     `json:"max_size" env:"MAX_SIZE"`               // MB
+	Width         int     `json:"width" env:"WIDTH"`                     // px
+	MaxSourceSize int     `json:"max_source_size" env:"MAX_SOURCE_SIZE"` // MB
+	Video         bool    `json:"video" env:"VIDEO"`
+	FFmpegPath    string  `json:"ffmpeg_path" env:"FFMPEG_PATH"`
+	VideoPos      float64 `json:"video_pos" env:"VIDEO_POS"` // seconds
+}
+
+type HLS struct {
+	Enable      bool   `json:"enable" env:"ENABLE"`
+	Dir         string `json:"dir" env:"DIR"`
+	MaxSize     int    `json:"max_size" env:"MAX_SIZE"` // MB
+	FFmpegPath  string `json:"ffmpeg_path" env:"FFMPEG_PATH"`
+	Transcode   string `json:"transcode" env:"TRANSCODE"` // never, auto or always
+	MaxJobs     int    `json:"max_jobs" env:"MAX_JOBS"`
+	SegmentTime int    `json:"segment_time" env:"SEGMENT_TIME"` // seconds
+	Timeout     int    `json:"timeout" env:"TIMEOUT"`           // minutes
+}
+
 type MCP struct {
 	Enable bool `json:"enable" env:"ENABLE"`
 }
@@ -135,6 +173,10 @@ type Config struct {
 	S3                    S3          `json:"s3" envPrefix:"S3_"`
 	FTP                   FTP         `json:"ftp" envPrefix:"FTP_"`
 	SFTP                  SFTP        `json:"sftp" envPrefix:"SFTP_"`
+	NFS                   NFS         `json:"nfs" envPrefix:"NFS_"`
+	BlockCache            BlockCache  `json:"block_cache" envPrefix:"BLOCK_CACHE_"`
+	Thumbnail             Thumbnail   `json:"thumbnail" envPrefix:"THUMBNAIL_"`
+	HLS                   HLS         `json:"hls" envPrefix:"HLS_"`
 	MCP                   MCP         `json:"mcp" envPrefix:"MCP_"`
 	LastLaunchedVersion   string      `json:"last_launched_version"`
 	ProxyAddress          string      `json:"proxy_address" env:"PROXY_ADDRESS"`
@@ -143,6 +185,9 @@ type Config struct {
 func DefaultConfig(dataDir string) *Config {
 	tempDir := filepath.Join(dataDir, "temp")
 	indexDir := filepath.Join(dataDir, "bleve")
+	blockCacheDir := filepath.Join(dataDir, "block_cache")
+	thumbnailDir := filepath.Join(dataDir, "thumbnail")
+	hlsDir := filepath.Join(dataDir, "hls")
 	logPath := filepath.Join(dataDir, "log/log.log")
 	dbPath := filepath.Join(dataDir, "data.db")
 	return &Config{
@@ -221,6 +266,9 @@ func DefaultConfig(dataDir string) *Config {
 				Workers:  5,
 				MaxRetry: 2,
 			},
+			StorageJob: TaskConfig{
+				Workers: 2,
+			},
 			AllowRetryCanceled: false,
 		},
 		Cors: Cors{
@@ -249,6 +297,39 @@ func DefaultConfig(dataDir string) *Config {
 			Enable: false,
 			Listen: ":5222",
 		},
+		NFS: NFS{
+			Enable:       false,
+			Listen:       ":5223",
+			User:         "guest",
+			AllowedIPs:   "127.0.0.1,::1",
+			HandleExpiry: 30,
+		},
+		BlockCache: BlockCache{
+			Enable:    false,
+			Dir:       blockCacheDir,
+			MaxSize:   10240,
+			BlockSize: 1024,
+		},
+		Thumbnail: Thumbnail{
+			Enable:        false,
+			Dir:           thumbnailDir,
+			MaxSize:       1024,
+			Width:         256,
+			MaxSourceSize: 50,
+			Video:         false,
+			FFmpegPath:    "ffmpeg",
+			VideoPos:      10,
+		},
+		HLS: HLS{
+			Enable:      false,
+			Dir:         hlsDir,
+			MaxSize:     10240,
+			FFmpegPath:  "ffmpeg",
+			Transcode:   "auto",
+			MaxJobs:     2,
+			SegmentTime: 6,
+			Timeout:     240,
+		},
 		MCP: MCP{
 			Enable: false,
 		},
@@ -39,6 +39,13 @@ const (
 	ReadMeAutoRender              = "readme_autorender"
 	FilterReadMeScripts           = "filter_readme_scripts"
 	NonEFSZipEncoding             = "non_efs_zip_encoding"
+	MediaMeta                     = "media_meta"
+	SubtitleEncoding              = "subtitle_encoding"
+	TextHistoryCount              = "text_history_count"
+	WOPIEditorURL                 = "wopi_editor_url"
+	WOPITokenExpiration           = "wopi_token_expiration"
+	VersionsKeep                  = "versions_keep"
+	VersionsMaxAge                = "versions_max_age"
 
 	// global
 	HideFiles               = "hide_files"
@@ -58,6 +65,7 @@ const (
 	ShareSummaryContent     = "share_summary_content"
 	HandleHookAfterWriting  = "handle_hook_after_writing"
 	HandleHookRateLimit     = "handle_hook_rate_limit"
+	ChangeJournalRetention  = "change_journal_retention"
 	IgnoreSystemFiles       = "ignore_system_files"
 
 	// index
@@ -65,6 +73,7 @@ const (
 	AutoUpdateIndex = "auto_update_index"
 	IgnorePaths     = "ignore_paths"
 	MaxIndexDepth   = "max_index_depth"
+	MediaMetaIndex  = "media_meta_index"
 
 	// aria2
 	Aria2Uri    = "aria2_uri"
@@ -150,6 +159,7 @@ const (
 	FTPImplicitTLS           = "ftp_implicit_tls"
 	FTPTLSPrivateKeyPath     = "ftp_tls_private_key_path"
 	FTPTLSPublicCertPath     = "ftp_tls_public_cert_path"
+	FTPAllowedIPs            = "ftp_allowed_ips"
 	SFTPDisablePasswordLogin = "sftp_disable_password_login"
 
 	// traffic
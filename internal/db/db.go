@@ -12,7 +12,7 @@ var db *gorm.DB
 
 func Init(d *gorm.DB) {
 	db = d
-	err := AutoMigrate(new(model.Storage), new(model.User), new(model.Meta), new(model.SettingItem), new(model.SearchNode), new(model.TaskItem), new(model.SSHPublicKey), new(model.SharingDB))
+	err := AutoMigrate(new(model.Storage), new(model.User), new(model.Meta), new(model.SettingItem), new(model.SearchNode), new(model.TaskItem), new(model.SSHPublicKey), new(model.SharingDB), new(model.DeadProp), new(model.FsChange), new(model.NfsHandle), new(model.SharingAccess), new(model.MediaMeta), new(model.PhotoAlbum), new(model.TextVersion), new(model.FileVersion), new(model.DedupNode), new(model.DBStorageNode), new(model.DBStorageBlob), new(model.DBStorageChunk), new(model.Snapshot), new(model.SnapshotEntry), new(model.MirrorRepair))
 	if err != nil {
 		log.Fatalf("failed migrate database: %s", err.Error())
 	}
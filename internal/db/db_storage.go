@@ -0,0 +1,180 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetDBStorageNode(storageID, parentID uint, name string) (*model.DBStorageNode, error) {
+	var n model.DBStorageNode
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ? AND %s = ?",
+		columnName("storage_id"), columnName("parent_id"), columnName("name")),
+		storageID, parentID, name).First(&n).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get db storage node")
+	}
+	return &n, nil
+}
+
+func GetDBStorageNodeById(id uint) (*model.DBStorageNode, error) {
+	var n model.DBStorageNode
+	if err := db.First(&n, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get db storage node")
+	}
+	return &n, nil
+}
+
+func GetDBStorageChildren(storageID, parentID uint) ([]model.DBStorageNode, error) {
+	var nodes []model.DBStorageNode
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("storage_id"), columnName("parent_id")),
+		storageID, parentID).Find(&nodes).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get db storage children")
+	}
+	return nodes, nil
+}
+
+func CreateDBStorageNode(n *model.DBStorageNode) error {
+	return errors.WithStack(db.Create(n).Error)
+}
+
+func UpdateDBStorageNode(n *model.DBStorageNode) error {
+	return errors.WithStack(db.Save(n).Error)
+}
+
+// SaveDBStorageFile creates or updates a file, the blob it referred to
+// before is deleted
+func SaveDBStorageFile(n *model.DBStorageNode) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		if n.ID == 0 {
+			return tx.Create(n).Error
+		}
+		var old model.DBStorageNode
+		if err := tx.First(&old, n.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(n).Error; err != nil {
+			return err
+		}
+		if old.BlobID != 0 && old.BlobID != n.BlobID {
+			return deleteDBStorageBlobs(tx, []uint{old.BlobID})
+		}
+		return nil
+	}))
+}
+
+// DeleteDBStorageNodes deletes the nodes with the blobs of them
+func DeleteDBStorageNodes(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		var blobIDs []uint
+		err := tx.Model(&model.DBStorageNode{}).Where(fmt.Sprintf("%s IN ? AND %s <> 0",
+			columnName("id"), columnName("blob_id")), ids).Pluck(columnName("blob_id"), &blobIDs).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.DBStorageNode{}, ids).Error; err != nil {
+			return err
+		}
+		return deleteDBStorageBlobs(tx, blobIDs)
+	}))
+}
+
+func CreateDBStorageBlob(b *model.DBStorageBlob) error {
+	return errors.WithStack(db.Create(b).Error)
+}
+
+func CreateDBStorageChunk(c *model.DBStorageChunk) error {
+	return errors.WithStack(db.Create(c).Error)
+}
+
+func GetDBStorageBlob(id uint) (*model.DBStorageBlob, error) {
+	var b model.DBStorageBlob
+	if err := db.First(&b, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get db storage blob")
+	}
+	return &b, nil
+}
+
+func GetDBStorageChunk(blobID uint, seq int) (*model.DBStorageChunk, error) {
+	var c model.DBStorageChunk
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("blob_id"), columnName("seq")),
+		blobID, seq).First(&c).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get db storage chunk")
+	}
+	return &c, nil
+}
+
+func DeleteDBStorageBlobs(ids []uint) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		return deleteDBStorageBlobs(tx, ids)
+	}))
+}
+
+func deleteDBStorageBlobs(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := tx.Where(fmt.Sprintf("%s IN ?", columnName("blob_id")), ids).Delete(&model.DBStorageChunk{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Delete(&model.DBStorageBlob{}, ids).Error
+}
+
+// deleteDBStorageDataByStorage deletes the files of a db storage with their
+// blobs and chunks
+func deleteDBStorageDataByStorage(tx *gorm.DB, storageID uint) error {
+	where := fmt.Sprintf("%s = ?", columnName("storage_id"))
+	if err := tx.Where(where, storageID).Delete(&model.DBStorageNode{}).Error; err != nil {
+		return err
+	}
+	err := tx.Where(fmt.Sprintf("%s IN (?)", columnName("blob_id")),
+		tx.Model(&model.DBStorageBlob{}).Where(where, storageID).Select(columnName("id"))).
+		Delete(&model.DBStorageChunk{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where(where, storageID).Delete(&model.DBStorageBlob{}).Error
+}
+
+// GetDBStorageUsed returns the total size of the files of a db storage
+func GetDBStorageUsed(storageID uint) (int64, error) {
+	var used int64
+	err := db.Model(&model.DBStorageNode{}).Where(fmt.Sprintf("%s = ?", columnName("storage_id")), storageID).
+		Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", columnName("size"))).Scan(&used).Error
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed get db storage used space")
+	}
+	return used, nil
+}
+
+// DeleteOrphanDBStorageData deletes the data of the db storages deleted, and
+// the blobs no file refers to which are created before the time given
+func DeleteOrphanDBStorageData(before time.Time) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		storages := tx.Model(&model.Storage{}).Select(columnName("id"))
+		err := tx.Where(fmt.Sprintf("%s NOT IN (?)", columnName("storage_id")), storages).
+			Delete(&model.DBStorageNode{}).Error
+		if err != nil {
+			return err
+		}
+		var blobIDs []uint
+		err = tx.Model(&model.DBStorageBlob{}).
+			Where(fmt.Sprintf("%s NOT IN (?)", columnName("storage_id")), storages).
+			Or(fmt.Sprintf("%s < ? AND %s NOT IN (?)", columnName("created"), columnName("id")),
+				before, tx.Model(&model.DBStorageNode{}).Select(columnName("blob_id"))).
+			Pluck(columnName("id"), &blobIDs).Error
+		if err != nil {
+			return err
+		}
+		return deleteDBStorageBlobs(tx, blobIDs)
+	}))
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"slices"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetDeadPropsByPath(path string) ([]model.DeadProp, error) {
+	var props []model.DeadProp
+	if err := db.Where(model.DeadProp{Path: path}).Order(columnName("id")).Find(&props).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get dead props")
+	}
+	return props, nil
+}
+
+// GetDeadPropsByPaths returns the dead props of paths, in batches to keep
+// the queries under the limit of variables of sqlite
+func GetDeadPropsByPaths(paths []string) ([]model.DeadProp, error) {
+	var props []model.DeadProp
+	for batch := range slices.Chunk(paths, 500) {
+		var ps []model.DeadProp
+		if err := db.Where(columnName("path")+" IN (?)", batch).Order(columnName("id")).Find(&ps).Error; err != nil {
+			return nil, errors.Wrapf(err, "failed get dead props")
+		}
+		props = append(props, ps...)
+	}
+	return props, nil
+}
+
+// PatchDeadProps sets and removes the dead props of path in a single transaction
+func PatchDeadProps(path string, set []model.DeadProp, remove []model.DeadProp) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range append(remove, set...) {
+			cond := model.DeadProp{Path: path, Space: p.Space, Local: p.Local}
+			if err := tx.Where(cond).Delete(&model.DeadProp{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range set {
+			set[i].ID = 0
+			set[i].Path = path
+			if err := tx.Create(&set[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// MoveDeadProps moves the dead props of srcPath and its descendants to dstPath,
+// the dead props of the objects replaced at dstPath are dropped
+func MoveDeadProps(srcPath, dstPath string) error {
+	return movePathRows[model.DeadProp](srcPath, dstPath, nil)
+}
+
+// DeleteDeadProps deletes the dead props of path and its descendants
+func DeleteDeadProps(path string) error {
+	return deletePathRows[model.DeadProp](path)
+}
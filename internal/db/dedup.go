@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetDedupNode(storageID, parentID uint, name string) (*model.DedupNode, error) {
+	var n model.DedupNode
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ? AND %s = ?",
+		columnName("storage_id"), columnName("parent_id"), columnName("name")),
+		storageID, parentID, name).First(&n).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get dedup node")
+	}
+	return &n, nil
+}
+
+func GetDedupNodeById(id uint) (*model.DedupNode, error) {
+	var n model.DedupNode
+	if err := db.First(&n, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get dedup node")
+	}
+	return &n, nil
+}
+
+func GetDedupChildren(storageID, parentID uint) ([]model.DedupNode, error) {
+	var nodes []model.DedupNode
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("storage_id"), columnName("parent_id")),
+		storageID, parentID).Find(&nodes).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get dedup children")
+	}
+	return nodes, nil
+}
+
+func CreateDedupNode(n *model.DedupNode) error {
+	return errors.WithStack(db.Create(n).Error)
+}
+
+func UpdateDedupNode(n *model.DedupNode) error {
+	return errors.WithStack(db.Save(n).Error)
+}
+
+func DeleteDedupNodes(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return errors.WithStack(db.Delete(&model.DedupNode{}, ids).Error)
+}
+
+func deleteDedupNodesByStorage(tx *gorm.DB, storageID uint) error {
+	return tx.Where(fmt.Sprintf("%s = ?", columnName("storage_id")), storageID).
+		Delete(&model.DedupNode{}).Error
+}
+
+// GetDedupHashes returns the hashes referenced by the files of all the
+// dedup storages
+func GetDedupHashes() ([]string, error) {
+	var hashes []string
+	err := db.Model(&model.DedupNode{}).Where(fmt.Sprintf("%s = ?", columnName("is_dir")), false).
+		Distinct(columnName("hash")).Pluck(columnName("hash"), &hashes).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get dedup hashes")
+	}
+	return hashes, nil
+}
+
+// DeleteOrphanDedupNodes deletes the nodes of the storages deleted
+func DeleteOrphanDedupNodes() error {
+	return errors.WithStack(db.Where(fmt.Sprintf("%s NOT IN (?)", columnName("storage_id")),
+		db.Model(&model.Storage{}).Select(columnName("id"))).Delete(&model.DedupNode{}).Error)
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func GetFileVersionById(id uint) (*model.FileVersion, error) {
+	var v model.FileVersion
+	if err := db.First(&v, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get file version")
+	}
+	return &v, nil
+}
+
+// GetFileVersions returns the versions of path, the newest first
+func GetFileVersions(path string) ([]model.FileVersion, error) {
+	var versions []model.FileVersion
+	if err := db.Where(model.FileVersion{Path: path}).Order(columnName("id") + " DESC").Find(&versions).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get file versions")
+	}
+	return versions, nil
+}
+
+// GetFileVersionsCreatedBefore returns the versions of all the files created
+// before the time given
+func GetFileVersionsCreatedBefore(t time.Time) ([]model.FileVersion, error) {
+	var versions []model.FileVersion
+	if err := db.Where(fmt.Sprintf("%s < ?", columnName("created")), t).Find(&versions).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get expired file versions")
+	}
+	return versions, nil
+}
+
+func CreateFileVersion(v *model.FileVersion) error {
+	return errors.WithStack(db.Create(v).Error)
+}
+
+func DeleteFileVersionById(id uint) error {
+	return errors.WithStack(db.Delete(&model.FileVersion{}, id).Error)
+}
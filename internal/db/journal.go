@@ -0,0 +1,50 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func CreateFsChanges(changes []model.FsChange) error {
+	return errors.WithStack(db.Create(&changes).Error)
+}
+
+// GetFsChangeIdRange returns the smallest and the largest id in the journal
+func GetFsChangeIdRange() (minId, maxId uint, err error) {
+	var ret struct {
+		MinId uint
+		MaxId uint
+	}
+	err = db.Model(&model.FsChange{}).
+		Select("COALESCE(MIN(" + columnName("id") + "), 0) AS min_id, COALESCE(MAX(" + columnName("id") + "), 0) AS max_id").
+		Scan(&ret).Error
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed get journal id range")
+	}
+	return ret.MinId, ret.MaxId, nil
+}
+
+// GetFsChangesUnder returns the changes after since of the objects under the dir, in order of id
+func GetFsChangesUnder(dir string, since uint) ([]model.FsChange, error) {
+	var changes []model.FsChange
+	cond, arg := likePrefix("path", strings.TrimSuffix(dir, "/")+"/")
+	if err := db.Where(columnName("id")+" > ?", since).Where(cond, arg).
+		Order(columnName("id")).Find(&changes).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get journal")
+	}
+	return changes, nil
+}
+
+// DeleteFsChangesBefore deletes the changes older than t, but always keeps
+// the latest one so that the id keeps increasing
+func DeleteFsChangesBefore(t time.Time) error {
+	_, maxId, err := GetFsChangeIdRange()
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(db.Where(columnName("time")+" < ? AND "+columnName("id")+" < ?", t, maxId).
+		Delete(&model.FsChange{}).Error)
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	stdpath "path"
+	"slices"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetMediaMeta(path string) (*model.MediaMeta, error) {
+	var m model.MediaMeta
+	if err := db.Where(model.MediaMeta{Path: path}).First(&m).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media meta")
+	}
+	return &m, nil
+}
+
+// SaveMediaMeta creates or replaces the media meta of m.Path
+func SaveMediaMeta(m *model.MediaMeta) error {
+	m.Dir = stdpath.Dir(m.Path)
+	taken := time.Unix(0, m.Modified).UTC()
+	if m.TakenAt != nil {
+		taken = m.TakenAt.UTC()
+	}
+	m.Taken, m.Month = taken.UnixNano(), taken.Format("2006-01")
+	var old model.MediaMeta
+	if err := db.Where(model.MediaMeta{Path: m.Path}).Limit(1).Find(&old).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	m.ID = old.ID
+	return errors.WithStack(db.Save(m).Error)
+}
+
+// MoveMediaMetas moves the media meta of srcPath and its descendants to
+// dstPath, the media meta of the files replaced at dstPath are dropped
+func MoveMediaMetas(srcPath, dstPath string) error {
+	return movePathRows[model.MediaMeta](srcPath, dstPath, func(newPath string) map[string]any {
+		return map[string]any{"path": newPath, "dir": stdpath.Dir(newPath)}
+	})
+}
+
+// DeleteMediaMetas deletes the media meta of path and its descendants
+func DeleteMediaMetas(path string) error {
+	return deletePathRows[model.MediaMeta](path)
+}
+
+func mediaMetaQuery(filter model.MediaMetaFilter) *gorm.DB {
+	tx := db.Model(&model.MediaMeta{})
+	if filter.Type != "" {
+		tx = tx.Where(columnName("type")+" = ?", filter.Type)
+	}
+	if filter.TakenFrom != nil {
+		tx = tx.Where(columnName("taken_at")+" >= ?", *filter.TakenFrom)
+	}
+	if filter.TakenTo != nil {
+		tx = tx.Where(columnName("taken_at")+" < ?", *filter.TakenTo)
+	}
+	if filter.Year != 0 {
+		tx = tx.Where(columnName("year")+" = ?", filter.Year)
+	}
+	if filter.Artist != "" {
+		tx = tx.Where("("+columnName("artist")+" LIKE ? OR "+columnName("album_artist")+" LIKE ?)",
+			"%"+filter.Artist+"%", "%"+filter.Artist+"%")
+	}
+	if filter.Album != "" {
+		tx = tx.Where(columnName("album")+" LIKE ?", "%"+filter.Album+"%")
+	}
+	if filter.Camera != "" {
+		tx = tx.Where("("+columnName("camera_make")+" LIKE ? OR "+columnName("camera_model")+" LIKE ?)",
+			"%"+filter.Camera+"%", "%"+filter.Camera+"%")
+	}
+	return tx
+}
+
+// GetMediaMetaPaths returns the paths of the files matching filter
+func GetMediaMetaPaths(filter model.MediaMetaFilter) ([]string, error) {
+	var paths []string
+	if err := mediaMetaQuery(filter).Pluck(columnName("path"), &paths).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get media meta paths")
+	}
+	return paths, nil
+}
+
+// photoQuery selects the images under dirs matching filter, by their capture
+// time or modified time if they have no EXIF
+func photoQuery(dirs []string, filter model.MediaMetaFilter) *gorm.DB {
+	from, to := filter.TakenFrom, filter.TakenTo
+	filter.Type, filter.TakenFrom, filter.TakenTo = "image", nil, nil
+	tx := mediaMetaQuery(filter)
+	if from != nil {
+		tx = tx.Where(columnName("taken")+" >= ?", from.UnixNano())
+	}
+	if to != nil {
+		tx = tx.Where(columnName("taken")+" < ?", to.UnixNano())
+	}
+	if !slices.Contains(dirs, "/") {
+		tx = whereUnder(tx, "dir", dirs...)
+	}
+	return tx
+}
+
+// GetPhotoCounts counts the images under dirs matching filter by their
+// directory and month
+func GetPhotoCounts(dirs []string, filter model.MediaMetaFilter) ([]model.PhotoCount, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	var counts []model.PhotoCount
+	err := photoQuery(dirs, filter).
+		Select(columnName("dir") + ", " + columnName("month") + ", COUNT(*) AS " + columnName("count")).
+		Group(columnName("dir") + ", " + columnName("month")).Scan(&counts).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed count photos")
+	}
+	return counts, nil
+}
+
+// GetPhotos returns a page of the images under dirs matching filter, except
+// the ones directly in the excluded dirs, of the month given if not empty,
+// the newest first
+func GetPhotos(dirs []string, filter model.MediaMetaFilter, excluded []string, month string, offset, limit int) ([]model.MediaMeta, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	tx := photoQuery(dirs, filter)
+	if len(excluded) > 0 {
+		tx = tx.Where(columnName("dir")+" NOT IN (?)", excluded)
+	}
+	if month != "" {
+		tx = tx.Where(columnName("month")+" = ?", month)
+	}
+	var metas []model.MediaMeta
+	err := tx.Order(columnName("taken") + " DESC, " + columnName("id") + " DESC").
+		Offset(offset).Limit(limit).Find(&metas).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get photos")
+	}
+	return metas, nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetMirrorRepairs(storageID uint) ([]model.MirrorRepair, error) {
+	var repairs []model.MirrorRepair
+	err := db.Where(fmt.Sprintf("%s = ?", columnName("storage_id")), storageID).
+		Order(columnName("id")).Find(&repairs).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get mirror repairs")
+	}
+	return repairs, nil
+}
+
+func SaveMirrorRepair(r *model.MirrorRepair) error {
+	return errors.WithStack(db.Save(r).Error)
+}
+
+func DeleteMirrorRepair(id uint) error {
+	return errors.WithStack(db.Delete(&model.MirrorRepair{}, id).Error)
+}
+
+func deleteMirrorRepairsByStorage(tx *gorm.DB, storageID uint) error {
+	return tx.Where(fmt.Sprintf("%s = ?", columnName("storage_id")), storageID).
+		Delete(&model.MirrorRepair{}).Error
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"slices"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// nfsHandleTouchInterval is how often the last use of a handle is refreshed
+const nfsHandleTouchInterval = 24 * time.Hour
+
+// nfsHandleBatch keeps the queries under the limit of variables of sqlite
+const nfsHandleBatch = 500
+
+func GetNfsHandleById(id uint64) (*model.NfsHandle, error) {
+	var h model.NfsHandle
+	if err := db.First(&h, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get nfs handle")
+	}
+	if time.Since(h.Used) >= nfsHandleTouchInterval {
+		h.Used = time.Now()
+		if err := db.Model(&model.NfsHandle{}).Where("id = ?", h.ID).Update("used", h.Used).Error; err != nil {
+			return nil, errors.Wrapf(err, "failed touch nfs handle")
+		}
+	}
+	return &h, nil
+}
+
+// GetOrCreateNfsHandle returns the handle of path, which is created if missing
+func GetOrCreateNfsHandle(path string) (*model.NfsHandle, error) {
+	handles, err := GetOrCreateNfsHandles([]string{path})
+	if err != nil {
+		return nil, err
+	}
+	return &handles[0], nil
+}
+
+// GetOrCreateNfsHandles returns the handles of paths in their order, the
+// missing ones are created, all in one transaction
+func GetOrCreateNfsHandles(paths []string) ([]model.NfsHandle, error) {
+	byPath := make(map[string]model.NfsHandle, len(paths))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var stale []uint64
+		for batch := range slices.Chunk(paths, nfsHandleBatch) {
+			var handles []model.NfsHandle
+			if err := tx.Where(columnName("path")+" IN (?)", batch).Order(columnName("id")).Find(&handles).Error; err != nil {
+				return err
+			}
+			for _, h := range handles {
+				if _, ok := byPath[h.Path]; ok {
+					continue
+				}
+				if now.Sub(h.Used) >= nfsHandleTouchInterval {
+					h.Used = now
+					stale = append(stale, h.ID)
+				}
+				byPath[h.Path] = h
+			}
+		}
+		for batch := range slices.Chunk(stale, nfsHandleBatch) {
+			if err := tx.Model(&model.NfsHandle{}).Where("id IN (?)", batch).Update("used", now).Error; err != nil {
+				return err
+			}
+		}
+		var missing []model.NfsHandle
+		for _, path := range paths {
+			if _, ok := byPath[path]; !ok {
+				byPath[path] = model.NfsHandle{}
+				missing = append(missing, model.NfsHandle{Path: path, Used: now})
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(missing, 100).Error; err != nil {
+			return err
+		}
+		for _, h := range missing {
+			byPath[h.Path] = h
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get or create nfs handles")
+	}
+	handles := make([]model.NfsHandle, len(paths))
+	for i, path := range paths {
+		handles[i] = byPath[path]
+	}
+	return handles, nil
+}
+
+// DeleteNfsHandlesUnusedSince deletes the handles not used since t
+func DeleteNfsHandlesUnusedSince(t time.Time) (int64, error) {
+	res := db.Where(columnName("used")+" < ?", t).Delete(&model.NfsHandle{})
+	return res.RowsAffected, errors.WithStack(res.Error)
+}
+
+// MoveNfsHandles points the handles of srcPath and its descendants to dstPath,
+// the handles previously given to dstPath are dropped
+func MoveNfsHandles(srcPath, dstPath string) error {
+	return movePathRows[model.NfsHandle](srcPath, dstPath, nil)
+}
+
+// DeleteNfsHandles deletes the handles of path and its descendants
+func DeleteNfsHandles(path string) error {
+	return deletePathRows[model.NfsHandle](path)
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func GetPhotoAlbumById(id uint) (*model.PhotoAlbum, error) {
+	var a model.PhotoAlbum
+	if err := db.First(&a, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get photo album")
+	}
+	return &a, nil
+}
+
+func GetPhotoAlbumsByCreatorId(creator uint) ([]model.PhotoAlbum, error) {
+	var albums []model.PhotoAlbum
+	if err := db.Where(model.PhotoAlbum{CreatorId: creator}).Order(columnName("id")).Find(&albums).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get photo albums")
+	}
+	return albums, nil
+}
+
+func CreatePhotoAlbum(a *model.PhotoAlbum) error {
+	return errors.WithStack(db.Create(a).Error)
+}
+
+func UpdatePhotoAlbum(a *model.PhotoAlbum) error {
+	return errors.WithStack(db.Save(a).Error)
+}
+
+func DeletePhotoAlbumById(id uint) error {
+	return errors.WithStack(db.Delete(&model.PhotoAlbum{}, id).Error)
+}
+
+func DeletePhotoAlbumsByCreatorId(creator uint) error {
+	return errors.WithStack(db.Where(model.PhotoAlbum{CreatorId: creator}).Delete(&model.PhotoAlbum{}).Error)
+}
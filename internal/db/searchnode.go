@@ -16,9 +16,7 @@ func whereInParent(parent string) *gorm.DB {
 	if parent == "/" {
 		return db.Where("1 = 1")
 	}
-	return db.Where(fmt.Sprintf("%s LIKE ?", columnName("parent")),
-		fmt.Sprintf("%s/%%", parent)).
-		Or(fmt.Sprintf("%s = ?", columnName("parent")), parent)
+	return whereUnder(db, "parent", parent)
 }
 
 func CreateSearchNode(node *model.SearchNode) error {
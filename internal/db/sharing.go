@@ -78,5 +78,8 @@ func DeleteSharingById(id string) error {
 }
 
 func DeleteSharingsByCreatorId(creatorId uint) error {
+	if err := deleteSharingAccessesByCreatorId(creatorId); err != nil {
+		return err
+	}
 	return errors.WithStack(db.Where("creator_id = ?", creatorId).Delete(&model.SharingDB{}).Error)
 }
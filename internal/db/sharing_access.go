@@ -0,0 +1,85 @@
+package db
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func CreateSharingAccess(a *model.SharingAccess) error {
+	return errors.WithStack(db.Create(a).Error)
+}
+
+func GetSharingAccesses(sid string, pageIndex, pageSize int) (accesses []model.SharingAccess, count int64, err error) {
+	accessDB := db.Model(&model.SharingAccess{}).Where(columnName("sharing_id")+" = ?", sid)
+	if err := accessDB.Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get sharing accesses count")
+	}
+	if err := accessDB.Order(columnName("id") + " DESC").Offset((pageIndex - 1) * pageSize).Limit(pageSize).Find(&accesses).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed find sharing accesses")
+	}
+	return accesses, count, nil
+}
+
+func GetSharingAccessStats(sid string) (*model.SharingAccessStats, error) {
+	var stats model.SharingAccessStats
+	accessDB := func() *gorm.DB {
+		return db.Model(&model.SharingAccess{}).Where(columnName("sharing_id")+" = ?", sid)
+	}
+	if err := accessDB().Where(columnName("upload")+" = ? AND "+columnName("partial")+" = ?", false, false).Count(&stats.Downloads).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := accessDB().Where(columnName("upload")+" = ?", true).Count(&stats.Uploads).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := accessDB().Select("COALESCE(SUM(" + columnName("bytes") + "), 0)").Scan(&stats.Bytes).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := accessDB().Select("COUNT(DISTINCT " + columnName("ip") + ")").Scan(&stats.IPs).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &stats, nil
+}
+
+// CountSharingDownloads counts the files downloaded by ip through a share,
+// a file downloaded several times is counted once
+func CountSharingDownloads(sid, ip string) (int64, error) {
+	var count int64
+	err := db.Model(&model.SharingAccess{}).
+		Where(columnName("sharing_id")+" = ? AND "+columnName("ip")+" = ?", sid, ip).
+		Where(columnName("upload")+" = ?", false).
+		Select("COUNT(DISTINCT " + columnName("path") + ")").Scan(&count).Error
+	return count, errors.WithStack(err)
+}
+
+// HasSharingDownload reports whether ip has downloaded the file at path
+// through a share
+func HasSharingDownload(sid, ip, path string) (bool, error) {
+	var count int64
+	err := db.Model(&model.SharingAccess{}).
+		Where(columnName("sharing_id")+" = ? AND "+columnName("ip")+" = ?", sid, ip).
+		Where(columnName("upload")+" = ? AND "+columnName("path")+" = ?", false, path).
+		Limit(1).Count(&count).Error
+	return count > 0, errors.WithStack(err)
+}
+
+func UpdateSharingAccessBytes(id uint, bytes int64) error {
+	return errors.WithStack(db.Model(&model.SharingAccess{}).Where("id = ?", id).Update("bytes", bytes).Error)
+}
+
+func DeleteSharingAccess(id uint) error {
+	return errors.WithStack(db.Delete(&model.SharingAccess{}, id).Error)
+}
+
+func UpdateSharingAccessesId(oldId, newId string) error {
+	return errors.WithStack(db.Model(&model.SharingAccess{}).Where(columnName("sharing_id")+" = ?", oldId).Update("sharing_id", newId).Error)
+}
+
+func deleteSharingAccessesByCreatorId(creatorId uint) error {
+	sids := db.Model(&model.SharingDB{}).Select("id").Where("creator_id = ?", creatorId)
+	return errors.WithStack(db.Where(columnName("sharing_id")+" IN (?)", sids).Delete(&model.SharingAccess{}).Error)
+}
+
+func DeleteSharingAccesses(sid string) error {
+	return errors.WithStack(db.Where(columnName("sharing_id")+" = ?", sid).Delete(&model.SharingAccess{}).Error)
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func CreateSnapshot(s *model.Snapshot) error {
+	return errors.WithStack(db.Create(s).Error)
+}
+
+func UpdateSnapshot(s *model.Snapshot) error {
+	return errors.WithStack(db.Save(s).Error)
+}
+
+// GetSnapshots returns the snapshots of the source path, the newest first
+func GetSnapshots(sourcePath string) ([]model.Snapshot, error) {
+	var snapshots []model.Snapshot
+	err := db.Where(fmt.Sprintf("%s = ?", columnName("source_path")), sourcePath).
+		Order(fmt.Sprintf("%s DESC, %s DESC", columnName("created"), columnName("id"))).Find(&snapshots).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get snapshots")
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot deletes a snapshot with its entries
+func DeleteSnapshot(id uint) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(fmt.Sprintf("%s = ?", columnName("snapshot_id")), id).Delete(&model.SnapshotEntry{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&model.Snapshot{}, id).Error
+	}))
+}
+
+func CreateSnapshotEntries(entries []model.SnapshotEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return errors.WithStack(db.CreateInBatches(entries, 100).Error)
+}
+
+func GetSnapshotEntry(snapshotID uint, parent, name string) (*model.SnapshotEntry, error) {
+	var e model.SnapshotEntry
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ? AND %s = ?",
+		columnName("snapshot_id"), columnName("parent"), columnName("name")),
+		snapshotID, parent, name).First(&e).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get snapshot entry")
+	}
+	return &e, nil
+}
+
+func GetSnapshotChildren(snapshotID uint, parent string) ([]model.SnapshotEntry, error) {
+	var entries []model.SnapshotEntry
+	err := db.Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("snapshot_id"), columnName("parent")),
+		snapshotID, parent).Find(&entries).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get snapshot children")
+	}
+	return entries, nil
+}
+
+// GetSnapshotFilesUnder returns the files recorded by a snapshot under the dir
+func GetSnapshotFilesUnder(snapshotID uint, dir string) ([]model.SnapshotEntry, error) {
+	var entries []model.SnapshotEntry
+	err := whereUnder(db.Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("snapshot_id"), columnName("is_dir")),
+		snapshotID, false), "parent", dir).Find(&entries).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get snapshot files")
+	}
+	return entries, nil
+}
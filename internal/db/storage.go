@@ -5,6 +5,7 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 // why don't need `cache` for storage?
@@ -22,9 +23,21 @@ func UpdateStorage(storage *model.Storage) error {
 	return errors.WithStack(db.Save(storage).Error)
 }
 
-// DeleteStorageById just delete storage from database by id
+// DeleteStorageById delete storage from database by id, with the data the
+// drivers keep in the database for it
 func DeleteStorageById(id uint) error {
-	return errors.WithStack(db.Delete(&model.Storage{}, id).Error)
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteDedupNodesByStorage(tx, id); err != nil {
+			return err
+		}
+		if err := deleteMirrorRepairsByStorage(tx, id); err != nil {
+			return err
+		}
+		if err := deleteDBStorageDataByStorage(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Storage{}, id).Error
+	}))
 }
 
 // GetStorages Get all storages from database order by index
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func GetTextVersionById(id uint) (*model.TextVersion, error) {
+	var v model.TextVersion
+	if err := db.First(&v, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get text version")
+	}
+	return &v, nil
+}
+
+// GetTextVersions returns the versions of path without their content, the
+// newest first
+func GetTextVersions(path string) ([]model.TextVersion, error) {
+	var versions []model.TextVersion
+	if err := db.Omit("content").Where(model.TextVersion{Path: path}).
+		Order(columnName("id") + " DESC").Find(&versions).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get text versions")
+	}
+	return versions, nil
+}
+
+func CreateTextVersion(v *model.TextVersion) error {
+	return errors.WithStack(db.Create(v).Error)
+}
+
+// PruneTextVersions keeps the newest keep versions of path
+func PruneTextVersions(path string, keep int) error {
+	var ids []uint
+	err := db.Model(&model.TextVersion{}).Where(model.TextVersion{Path: path}).
+		Order(columnName("id")+" DESC").Pluck("id", &ids).Error
+	if err != nil || len(ids) <= keep {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(db.Delete(&model.TextVersion{}, ids[keep:]).Error)
+}
+
+// MoveTextVersions moves the versions of srcPath and its descendants to
+// dstPath, the versions of the files replaced at dstPath are dropped
+func MoveTextVersions(srcPath, dstPath string) error {
+	return movePathRows[model.TextVersion](srcPath, dstPath, nil)
+}
+
+// DeleteTextVersions deletes the versions of path and its descendants
+func DeleteTextVersions(path string) error {
+	return deletePathRows[model.TextVersion](path)
+}
@@ -2,8 +2,11 @@ package db
 
 import (
 	"fmt"
+	stdpath "path"
+	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
@@ -17,3 +20,65 @@ func columnName(name string) string {
 func addStorageOrder(db *gorm.DB) *gorm.DB {
 	return db.Order(fmt.Sprintf("%s, %s", columnName("order"), columnName("id")))
 }
+
+// likeEscaper escapes the wildcards of LIKE with '!', which unlike '\' is
+// taken literally in the strings of all the databases
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// likePrefix returns the condition matching the values of column starting
+// with prefix, and its argument
+func likePrefix(column, prefix string) (string, string) {
+	return columnName(column) + " LIKE ? ESCAPE '!'", likeEscaper.Replace(prefix) + "%"
+}
+
+// whereUnder selects the rows of which the path in column is one of paths or
+// under one of them, paths must not be empty
+func whereUnder(tx *gorm.DB, column string, paths ...string) *gorm.DB {
+	conds := make([]string, 0, len(paths))
+	args := make([]any, 0, 2*len(paths))
+	for _, path := range paths {
+		cond, arg := likePrefix(column, strings.TrimSuffix(path, "/")+"/")
+		conds = append(conds, columnName(column)+" = ? OR "+cond)
+		args = append(args, path, arg)
+	}
+	return tx.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+// pathRow is the id and the path of a row kept by the path of an object
+type pathRow struct {
+	ID   uint64
+	Path string
+}
+
+// movePathRows moves the rows of T of srcPath and its descendants to dstPath,
+// the rows left at dstPath by the objects replaced are deleted first. set
+// returns the columns to update for a new path, only the path if it's nil
+func movePathRows[T any](srcPath, dstPath string, set func(newPath string) map[string]any) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		if !strings.HasPrefix(srcPath, strings.TrimSuffix(dstPath, "/")+"/") {
+			if err := whereUnder(tx, "path", dstPath).Delete(new(T)).Error; err != nil {
+				return err
+			}
+		}
+		var rows []pathRow
+		if err := whereUnder(tx.Model(new(T)), "path", srcPath).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			newPath := stdpath.Join(dstPath, strings.TrimPrefix(r.Path, srcPath))
+			values := map[string]any{"path": newPath}
+			if set != nil {
+				values = set(newPath)
+			}
+			if err := tx.Model(new(T)).Where("id = ?", r.ID).Updates(values).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// deletePathRows deletes the rows of T of path and its descendants
+func deletePathRows[T any](path string) error {
+	return errors.WithStack(whereUnder(db, "path", path).Delete(new(T)).Error)
+}
@@ -23,9 +23,12 @@ var (
 	WrongArchivePassword      = errors.New("wrong archive password")
 	DriverExtractNotSupported = errors.New("driver extraction not supported")
 
-	WrongShareCode  = errors.New("wrong share code")
-	InvalidSharing  = errors.New("invalid sharing")
-	SharingNotFound = errors.New("sharing not found")
+	WrongShareCode   = errors.New("wrong share code")
+	InvalidSharing   = errors.New("invalid sharing")
+	SharingNotFound  = errors.New("sharing not found")
+	UploadOnlyShare  = errors.New("the share only accepts uploads")
+	SharingIPDenied  = errors.New("the share can't be accessed from your IP")
+	SharingDownLimit = errors.New("the download limit of the share has been reached")
 )
 
 // NewErr wrap constant error with an extra message
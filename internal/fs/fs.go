@@ -3,11 +3,13 @@ package fs
 import (
 	"context"
 	"io"
+	stdpath "path"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/journal"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
@@ -196,7 +198,11 @@ func PutURL(ctx context.Context, path, dstName, urlStr string) error {
 	if !ok && !okResult {
 		return errs.NotImplement
 	}
-	return op.PutURL(ctx, storage, dstDirActualPath, dstName, urlStr)
+	err = op.PutURL(ctx, storage, dstDirActualPath, dstName, urlStr)
+	if err == nil {
+		journal.Record(false, stdpath.Join(path, dstName))
+	}
+	return err
 }
 
 func GetDirectUploadInfo(ctx context.Context, tool, path, dstName string, fileSize int64, overwrite bool) (any, error) {
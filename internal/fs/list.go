@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"path"
+	"slices"
 )
 
 // List files
@@ -37,6 +38,11 @@ func list(ctx context.Context, path string, args *ListArgs) ([]model.Obj, error)
 				return nil, errors.WithMessage(err, "failed get objs")
 			}
 		}
+		if actualPath == "/" {
+			_objs = slices.DeleteFunc(slices.Clone(_objs), func(obj model.Obj) bool {
+				return obj.GetName() == op.VersionsDir
+			})
+		}
 	}
 
 	om := model.NewObjMerge()
@@ -2,9 +2,11 @@ package fs
 
 import (
 	"context"
+	stdpath "path"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/journal"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
@@ -17,7 +19,11 @@ func makeDir(ctx context.Context, path string) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed get storage")
 	}
-	return op.MakeDir(ctx, storage, actualPath)
+	err = op.MakeDir(ctx, storage, actualPath)
+	if err == nil {
+		journal.Record(false, path)
+	}
+	return err
 }
 
 func rename(ctx context.Context, srcPath, dstName string, skipHook ...bool) error {
@@ -28,7 +34,13 @@ func rename(ctx context.Context, srcPath, dstName string, skipHook ...bool) erro
 	if utils.IsBool(skipHook...) {
 		ctx = context.WithValue(ctx, conf.SkipHookKey, struct{}{})
 	}
-	return op.Rename(ctx, storage, srcActualPath, dstName)
+	err = op.Rename(ctx, storage, srcActualPath, dstName)
+	if err == nil {
+		journal.Record(true, srcPath)
+		journal.Record(false, stdpath.Join(stdpath.Dir(srcPath), dstName))
+		op.MovePathData(srcPath, stdpath.Join(stdpath.Dir(srcPath), dstName))
+	}
+	return err
 }
 
 func remove(ctx context.Context, path string) error {
@@ -36,7 +48,12 @@ func remove(ctx context.Context, path string) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed get storage")
 	}
-	return op.Remove(ctx, storage, actualPath)
+	err = op.Remove(ctx, storage, actualPath)
+	if err == nil {
+		journal.Record(true, path)
+		op.DeletePathData(path)
+	}
+	return err
 }
 
 func other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
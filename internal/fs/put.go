@@ -12,6 +12,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/journal"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
@@ -43,6 +44,7 @@ func (t *UploadTask) Run() error {
 }
 
 func (t *UploadTask) OnSucceeded() {
+	journal.Record(false, stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath, t.file.GetName()))
 	task_group.TransferCoordinator.Done(context.WithoutCancel(t.Ctx()), stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath), true)
 }
 
@@ -107,7 +109,11 @@ func putDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer
 	if utils.IsBool(skipHook...) {
 		ctx = context.WithValue(ctx, conf.SkipHookKey, struct{}{})
 	}
-	return op.Put(ctx, storage, dstDirActualPath, file, nil)
+	err = op.Put(ctx, storage, dstDirActualPath, file, nil)
+	if err == nil {
+		journal.Record(false, stdpath.Join(dstDirPath, file.GetName()))
+	}
+	return err
 }
 
 func getDirectUploadInfo(ctx context.Context, tool, dstDirPath, dstName string, fileSize int64, overwrite bool) (any, error) {
@@ -0,0 +1,63 @@
+package fs
+
+import (
+	"context"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/tache"
+)
+
+// StorageJobTask runs a long job of a storage, e.g. the verification of its
+// files, so that its progress is shown and it can be canceled as a task. It
+// isn't persisted, as the job is a function of the storage.
+type StorageJobTask struct {
+	task.TaskExtension
+	Name   string
+	Job    func(ctx context.Context) error
+	Status func() string
+}
+
+func (t *StorageJobTask) GetName() string {
+	return t.Name
+}
+
+func (t *StorageJobTask) GetStatus() string {
+	if t.Status == nil {
+		return "running"
+	}
+	return t.Status()
+}
+
+func (t *StorageJobTask) Run() error {
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	return t.Job(t.Ctx())
+}
+
+// Finished tells if the task has succeeded, failed or been canceled
+func (t *StorageJobTask) Finished() bool {
+	switch t.GetState() {
+	case tache.StateSucceeded, tache.StateFailed, tache.StateCanceled:
+		return true
+	}
+	return false
+}
+
+var StorageJobTaskManager *tache.Manager[*StorageJobTask]
+
+// AddStorageJob runs job as a task created by the user of ctx
+func AddStorageJob(ctx context.Context, name string, job func(ctx context.Context) error, status func() string) *StorageJobTask {
+	creator, _ := ctx.Value(conf.UserKey).(*model.User)
+	t := &StorageJobTask{
+		TaskExtension: task.TaskExtension{Creator: creator},
+		Name:          name,
+		Job:           job,
+		Status:        status,
+	}
+	StorageJobTaskManager.Add(t)
+	return t
+}
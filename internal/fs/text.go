@@ -0,0 +1,218 @@
+package fs
+
+import (
+	"context"
+	"io"
+	stdpath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxTextSize bounds the size of the text files edited online
+const MaxTextSize = 5 * 1024 * 1024
+
+type TextState struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Hash     string    `json:"hash"` // sha1
+}
+
+// TextBase is the version of a text file which an edit is based on, it is
+// identified by its hash, or by its size and modified time. The base of an
+// edit creating a file is empty.
+type TextBase struct {
+	Size     *int64     `json:"size"`
+	Modified *time.Time `json:"modified"`
+	Hash     string     `json:"hash"`
+}
+
+func (b *TextBase) empty() bool {
+	return b == nil || (b.Hash == "" && b.Size == nil && b.Modified == nil)
+}
+
+// match reports whether the base is the current version, the modified
+// times are compared in seconds as some storages don't keep the rest
+func (b *TextBase) match(cur *TextState) bool {
+	if b.Hash != "" {
+		return strings.EqualFold(b.Hash, cur.Hash)
+	}
+	if b.Size != nil && *b.Size != cur.Size {
+		return false
+	}
+	return b.Modified == nil || b.Modified.Unix() == cur.Modified.Unix()
+}
+
+// TextConflict is returned when the file has been changed since the version
+// an edit is based on, with the current content to diff with
+type TextConflict struct {
+	// Current is nil if the file has been removed
+	Current *TextState `json:"current"`
+	Content string     `json:"content"`
+}
+
+func (e *TextConflict) Error() string {
+	if e.Current == nil {
+		return "the file has been removed since it was opened"
+	}
+	return "the file has been changed since it was opened"
+}
+
+// textLocks serializes the saves of a path, so that the conflict check and
+// the write of one save are not interleaved with another
+var textLocks = struct {
+	sync.Mutex
+	m map[string]*textLock
+}{m: make(map[string]*textLock)}
+
+type textLock struct {
+	sync.Mutex
+	refs int
+}
+
+func lockText(path string) func() {
+	textLocks.Lock()
+	l, ok := textLocks.m[path]
+	if !ok {
+		l = &textLock{}
+		textLocks.m[path] = l
+	}
+	l.refs++
+	textLocks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		textLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(textLocks.m, path)
+		}
+		textLocks.Unlock()
+	}
+}
+
+// checkText refuses to edit the files which are not of the text types
+func checkText(path string) error {
+	if utils.GetFileType(path) != conf.TEXT {
+		return errors.WithMessage(errs.NotSupport, "not a text file, see text_types")
+	}
+	return nil
+}
+
+// ReadText reads a text file with the state of the version read
+func ReadText(ctx context.Context, path string) (string, *TextState, error) {
+	if err := checkText(path); err != nil {
+		return "", nil, err
+	}
+	obj, err := Get(ctx, path, &GetArgs{NoLog: true})
+	if err != nil {
+		return "", nil, err
+	}
+	if obj.IsDir() {
+		return "", nil, errs.NotFile
+	}
+	if obj.GetSize() > MaxTextSize {
+		return "", nil, errors.WithMessage(errs.NotSupport, "the file is too large to be edited online")
+	}
+	link, _, err := Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return "", nil, err
+	}
+	defer link.Close()
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return "", nil, err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, MaxTextSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	return string(data), &TextState{
+		Size:     int64(len(data)),
+		Modified: obj.ModTime(),
+		Hash:     utils.HashData(utils.SHA1, data),
+	}, nil
+}
+
+// SaveText writes the content of a text file edited from base, the replaced
+// version is kept in the history. It fails with a *TextConflict if the file
+// has been changed since base, unless force is set.
+func SaveText(ctx context.Context, path, content string, base *TextBase, force bool, user *model.User) (*TextState, error) {
+	if err := checkText(path); err != nil {
+		return nil, err
+	}
+	if len(content) > MaxTextSize {
+		return nil, errors.WithMessage(errs.NotSupport, "the content is too large to be edited online")
+	}
+	unlock := lockText(path)
+	defer unlock()
+	old, cur, err := ReadText(ctx, path)
+	if err != nil && !errs.IsObjectNotFound(err) {
+		return nil, err
+	}
+	if !force {
+		if cur == nil && !base.empty() {
+			return nil, &TextConflict{}
+		}
+		if cur != nil && (base.empty() || !base.match(cur)) {
+			return nil, &TextConflict{Current: cur, Content: old}
+		}
+	}
+	if cur != nil && old == content {
+		return cur, nil
+	}
+	if keep := setting.GetInt(conf.TextHistoryCount, 10); cur != nil && keep > 0 {
+		err = op.CreateTextVersion(&model.TextVersion{
+			Path:     path,
+			Content:  []byte(old),
+			Size:     cur.Size,
+			Hash:     cur.Hash,
+			Modified: cur.Modified,
+			Created:  time.Now(),
+			UserId:   user.ID,
+		})
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed save the version to be replaced")
+		}
+		if err := op.PruneTextVersions(path, keep); err != nil {
+			log.Warnf("failed prune text versions of %s: %+v", path, err)
+		}
+	}
+	dir, name := stdpath.Split(path)
+	err = PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     int64(len(content)),
+			Modified: time.Now(),
+		},
+		Reader:   strings.NewReader(content),
+		Mimetype: utils.GetMimeType(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	state := &TextState{
+		Size:     int64(len(content)),
+		Modified: time.Now(),
+		Hash:     utils.HashData(utils.SHA1, []byte(content)),
+	}
+	if obj, err := Get(ctx, path, &GetArgs{NoLog: true}); err == nil {
+		state.Modified = obj.ModTime()
+	}
+	return state, nil
+}
@@ -0,0 +1,33 @@
+package fs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTextBaseMatch(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	cur := &TextState{Size: 10, Modified: modified, Hash: "ABC"}
+	size, other := int64(10), int64(11)
+	truncated := modified.Truncate(time.Second)
+	later := modified.Add(time.Second)
+	tests := []struct {
+		name string
+		base *TextBase
+		want bool
+	}{
+		{"hash", &TextBase{Hash: "abc"}, true},
+		{"hash changed", &TextBase{Hash: "abd", Size: &size}, false},
+		{"size and modified in seconds", &TextBase{Size: &size, Modified: &truncated}, true},
+		{"size changed", &TextBase{Size: &other, Modified: &modified}, false},
+		{"modified changed", &TextBase{Size: &size, Modified: &later}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.base.match(cur); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(*TextBase)(nil).empty() || !(&TextBase{}).empty() || (&TextBase{Hash: "a"}).empty() {
+		t.Error("unexpected empty base")
+	}
+}
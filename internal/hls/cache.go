@@ -0,0 +1,87 @@
+package hls
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type packaged struct {
+	key  string
+	size int64
+	used time.Time
+}
+
+func dirSize(d string) int64 {
+	var size int64
+	_ = filepath.WalkDir(d, func(_ string, e fs.DirEntry, err error) error {
+		if err == nil && !e.IsDir() {
+			if info, err := e.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// evict removes the least recently played videos once the cache exceeds the
+// max size, and returns the size left. The ones being packaged count in the
+// size but are kept.
+func evict() int64 {
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		log.Warnf("failed read hls cache dir: %+v", err)
+		return 0
+	}
+	var (
+		all   []packaged
+		total int64
+	)
+	for _, e := range entries {
+		size := dirSize(dir(e.Name()))
+		total += size
+		info, err := os.Stat(filepath.Join(dir(e.Name()), doneName))
+		if err != nil {
+			continue
+		}
+		all = append(all, packaged{key: e.Name(), size: size, used: info.ModTime()})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].used.Before(all[j].used)
+	})
+	for _, p := range all {
+		if total <= cfg.MaxSize {
+			break
+		}
+		if err := os.RemoveAll(dir(p.key)); err != nil {
+			log.Warnf("failed remove hls cache %s: %+v", p.key, err)
+			continue
+		}
+		total -= p.size
+	}
+	return total
+}
+
+// Clear removes all the packaged videos except the ones being packaged
+func Clear() error {
+	if !enabled {
+		return nil
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if finished(e.Name()) {
+			if err := os.RemoveAll(dir(e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
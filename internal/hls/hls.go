@@ -0,0 +1,342 @@
+package hls
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	gonet "net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/media"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/net"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// the transcode modes
+const (
+	TranscodeNever  = "never"
+	TranscodeAuto   = "auto"
+	TranscodeAlways = "always"
+)
+
+const (
+	playlistName = "index.m3u8"
+	// doneName marks a directory of which the packaging has finished
+	doneName = ".done"
+)
+
+var ErrBusy = errors.New("too many videos are being packaged, try again later")
+
+type Config struct {
+	Dir        string
+	MaxSize    int64 // bytes of the disk cache
+	FFmpegPath string
+	// Transcode is one of the transcode modes, auto transcodes the videos
+	// which are not H.264
+	Transcode   string
+	MaxJobs     int
+	SegmentTime int           // seconds
+	Timeout     time.Duration // of packaging a video
+}
+
+type job struct {
+	done chan struct{}
+	err  error
+}
+
+var (
+	cfg     Config
+	enabled bool
+	mu      sync.Mutex
+	jobs    = make(map[string]*job)
+)
+
+// Init enables the HLS packaging, which requires ffmpeg
+func Init(c Config) error {
+	path, err := exec.LookPath(c.FFmpegPath)
+	if err != nil {
+		return errors.WithMessage(err, "ffmpeg not found")
+	}
+	c.FFmpegPath = path
+	switch c.Transcode {
+	case TranscodeNever, TranscodeAuto, TranscodeAlways:
+	default:
+		return fmt.Errorf("invalid transcode mode %s", c.Transcode)
+	}
+	if c.MaxJobs <= 0 || c.SegmentTime <= 0 || c.MaxSize <= 0 {
+		return fmt.Errorf("invalid hls config %+v", c)
+	}
+	if err := os.MkdirAll(c.Dir, 0o777); err != nil {
+		return err
+	}
+	// the ones without a done marker are left by the packaging interrupted
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !utils.Exists(filepath.Join(c.Dir, e.Name(), doneName)) {
+			_ = os.RemoveAll(filepath.Join(c.Dir, e.Name()))
+		}
+	}
+	cfg, enabled = c, true
+	return nil
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+func Supported(name string) bool {
+	return enabled && utils.GetFileType(name) == conf.VIDEO
+}
+
+func dir(key string) string {
+	return filepath.Join(cfg.Dir, key)
+}
+
+func finished(key string) bool {
+	return utils.Exists(filepath.Join(dir(key), doneName))
+}
+
+// transcode decides whether the video at path is transcoded to H.264, which
+// may parse the media meta of the video, so it's called by the job only
+func transcode(ctx context.Context, path string) bool {
+	switch cfg.Transcode {
+	case TranscodeAlways:
+		return true
+	case TranscodeAuto:
+		m, err := media.Get(ctx, path)
+		if err != nil || m.VideoCodec == "" {
+			return false
+		}
+		return !utils.SliceContains([]string{"avc1", "avc3", "V_MPEG4/ISO/AVC"}, m.VideoCodec)
+	}
+	return false
+}
+
+// Start packages the video at path unless it is cached or being packaged,
+// and returns the key of its playlist
+func Start(ctx context.Context, path string) (string, error) {
+	if !enabled {
+		return "", errors.WithMessage(errs.NotSupport, "hls is disabled")
+	}
+	obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return "", err
+	}
+	if obj.IsDir() {
+		return "", errs.NotFile
+	}
+	if !Supported(obj.GetName()) {
+		return "", errors.WithMessage(errs.NotSupport, "not a video")
+	}
+	h := sha1.Sum([]byte(path + "\x00" + strconv.FormatInt(obj.GetSize(), 10) + "\x00" +
+		strconv.FormatInt(obj.ModTime().UnixNano(), 10) + "\x00" + cfg.Transcode))
+	key := hex.EncodeToString(h[:])
+	if finished(key) {
+		now := time.Now()
+		_ = os.Chtimes(filepath.Join(dir(key), doneName), now, now)
+		return key, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := jobs[key]; ok {
+		return key, nil
+	}
+	if len(jobs) >= cfg.MaxJobs {
+		return "", ErrBusy
+	}
+	j := &job{done: make(chan struct{})}
+	jobs[key] = j
+	go func() {
+		j.err = run(key, path, obj)
+		if j.err != nil {
+			log.Errorf("failed package hls of %s: %+v", path, j.err)
+			_ = os.RemoveAll(dir(key))
+		}
+		// the waiting playlists get the error from the job they hold, and
+		// the failed one is packaged again on the next request
+		mu.Lock()
+		delete(jobs, key)
+		mu.Unlock()
+		close(j.done)
+		if j.err == nil {
+			evict()
+		}
+	}()
+	return key, nil
+}
+
+// serve exposes the content of a link on the loopback interface, so that
+// ffmpeg seeks in it with range requests as it does in a local file
+func serve(rr model.RangeReaderIF, obj model.Obj) (string, func(), error) {
+	ln, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	token := "/" + random.String(16)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != token {
+			http.NotFound(w, r)
+			return
+		}
+		_ = net.ServeHTTP(w, r, obj.GetName(), obj.ModTime(), obj.GetSize(), &model.RangeReadCloser{RangeReader: rr})
+	})}
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	return "http://" + ln.Addr().String() + token, func() { _ = srv.Close() }, nil
+}
+
+// watch stops the packaging into d once the cache can't hold it, the videos
+// least recently played are evicted to make room as it grows
+func watch(ctx context.Context, cancel context.CancelCauseFunc, d string) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if size := dirSize(d); size > cfg.MaxSize {
+			cancel(fmt.Errorf("the video packaged exceeds the max size of the cache: %d > %d", size, cfg.MaxSize))
+			return
+		}
+		if total := evict(); total > cfg.MaxSize {
+			cancel(fmt.Errorf("the cache is full of the videos being packaged: %d > %d", total, cfg.MaxSize))
+			return
+		}
+	}
+}
+
+func run(key, path string, obj model.Obj) error {
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	tc := transcode(ctx, path)
+	link, _, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return err
+	}
+	defer link.Close()
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return err
+	}
+	input, stop, err := serve(rr, obj)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	d := dir(key)
+	if err := os.MkdirAll(d, 0o777); err != nil {
+		return err
+	}
+	seg := strconv.Itoa(cfg.SegmentTime)
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?"}
+	if tc {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p",
+			"-force_key_frames", "expr:gte(t,n_forced*"+seg+")")
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+	// browsers can't play AC3 or DTS, encoding the audio costs little
+	args = append(args, "-c:a", "aac", "-ac", "2",
+		"-f", "hls", "-hls_time", seg, "-hls_playlist_type", "event",
+		"-hls_flags", "independent_segments+temp_file",
+		"-hls_segment_filename", filepath.Join(d, "seg%05d.ts"),
+		filepath.Join(d, playlistName))
+	go watch(ctx, cancel, d)
+	out, err := exec.CommandContext(ctx, cfg.FFmpegPath, args...).CombinedOutput()
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	if err != nil {
+		return errors.Wrapf(err, "ffmpeg: %s", strings.TrimSpace(string(out)))
+	}
+	f, err := os.Create(filepath.Join(d, doneName))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Playlist waits until the first segments of key are packaged, and returns
+// its playlist with the segment names mapped by segURL
+func Playlist(ctx context.Context, key string, segURL func(name string) string) ([]byte, error) {
+	if !enabled {
+		return nil, errors.WithMessage(errs.NotSupport, "hls is disabled")
+	}
+	if strings.ContainsAny(key, `/\.`) {
+		return nil, errs.ObjectNotFound
+	}
+	mu.Lock()
+	j := jobs[key]
+	mu.Unlock()
+	p := filepath.Join(dir(key), playlistName)
+	if j != nil {
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		for !utils.Exists(p) {
+			select {
+			case <-j.done:
+				if j.err != nil {
+					return nil, j.err
+				}
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-timer.C:
+				return nil, errors.New("timeout waiting for the first segment")
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+	} else if !finished(key) {
+		return nil, errors.WithMessage(errs.ObjectNotFound, "the playlist is not packaged, request it again")
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines[i] = segURL(line)
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// Segment opens a packaged segment of key
+func Segment(key, name string) (*os.File, error) {
+	if !enabled {
+		return nil, errors.WithMessage(errs.NotSupport, "hls is disabled")
+	}
+	if strings.ContainsAny(key, `/\.`) || !strings.HasSuffix(name, ".ts") || strings.ContainsAny(name, `/\`) {
+		return nil, errs.ObjectNotFound
+	}
+	f, err := os.Open(filepath.Join(dir(key), name))
+	if os.IsNotExist(err) {
+		return nil, errs.ObjectNotFound
+	}
+	return f, err
+}
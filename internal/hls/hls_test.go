@@ -0,0 +1,76 @@
+package hls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func TestPlaylistAndEvict(t *testing.T) {
+	cfg, enabled = Config{Dir: t.TempDir(), MaxSize: 150}, true
+	defer func() { enabled = false }()
+	write := func(key string, used time.Time) {
+		d := dir(key)
+		if err := os.MkdirAll(d, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		playlist := "#EXTM3U\n#EXTINF:6.0,\nseg00000.ts\n#EXT-X-ENDLIST\n"
+		_ = os.WriteFile(filepath.Join(d, playlistName), []byte(playlist), 0o666)
+		_ = os.WriteFile(filepath.Join(d, "seg00000.ts"), make([]byte, 64), 0o666)
+		_ = os.WriteFile(filepath.Join(d, doneName), nil, 0o666)
+		_ = os.Chtimes(filepath.Join(d, doneName), used, used)
+	}
+	now := time.Now()
+	write("old", now.Add(-time.Hour))
+	write("new", now)
+
+	data, err := Playlist(context.Background(), "new", func(name string) string {
+		return name + "?sign=x"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#EXTM3U\n#EXTINF:6.0,\nseg00000.ts?sign=x\n#EXT-X-ENDLIST\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	if _, err := Playlist(context.Background(), "missing", nil); err == nil {
+		t.Error("a playlist not packaged should not be found")
+	}
+	if _, err := Segment("new", "../old/seg00000.ts"); err == nil {
+		t.Error("segments out of the directory should not be opened")
+	}
+
+	evict()
+	if finished("old") || !finished("new") {
+		t.Error("the least recently played video should be evicted")
+	}
+}
+
+func TestEvictCountsPackaging(t *testing.T) {
+	cfg, enabled = Config{Dir: t.TempDir(), MaxSize: 100}, true
+	defer func() { enabled = false }()
+	packaged := dir("packaged")
+	packaging := dir("packaging")
+	for _, d := range []string{packaged, packaging} {
+		if err := os.MkdirAll(d, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.WriteFile(filepath.Join(d, "seg00000.ts"), make([]byte, 64), 0o666)
+	}
+	_ = os.WriteFile(filepath.Join(packaged, doneName), nil, 0o666)
+
+	// the video being packaged makes room by evicting the packaged one
+	if total := evict(); total != 64 {
+		t.Errorf("%d bytes are left, want 64", total)
+	}
+	if finished("packaged") {
+		t.Error("the packaged video should be evicted")
+	}
+	if !utils.Exists(packaging) {
+		t.Error("the video being packaged should be kept")
+	}
+}
@@ -0,0 +1,162 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cache"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// the journal records which paths have changed, so that clients such as WebDAV
+// sync-collection can ask for the changes since a token instead of walking the
+// whole tree again. It is fed by the write paths of internal/fs and by
+// diffing the listings passed to the objs update hook.
+
+const pruneInterval = time.Hour
+
+var (
+	// snapshots keeps the fingerprints of the children of recently listed dirs
+	snapshots  = cache.NewKeyedCache[map[string]string](24 * time.Hour)
+	snapshotMu sync.Mutex
+
+	lastPrune time.Time
+	pruneMu   sync.Mutex
+)
+
+func retention() int {
+	return setting.GetInt(conf.ChangeJournalRetention, 7)
+}
+
+// Enabled reports whether the change journal is recording
+func Enabled() bool {
+	return retention() > 0
+}
+
+// Record appends changes of the objects at paths to the journal
+func Record(deleted bool, paths ...string) {
+	if !Enabled() || len(paths) == 0 {
+		return
+	}
+	now := time.Now()
+	changes := make([]model.FsChange, 0, len(paths))
+	for _, p := range paths {
+		changes = append(changes, model.FsChange{
+			Path:    utils.FixAndCleanPath(p),
+			Deleted: deleted,
+			Time:    now,
+		})
+	}
+	if err := db.CreateFsChanges(changes); err != nil {
+		log.Warnf("failed record changes of %v: %+v", paths, err)
+		return
+	}
+	go prune()
+}
+
+// Latest returns the id of the latest change, which is the current sync token
+func Latest() (uint, error) {
+	_, maxId, err := db.GetFsChangeIdRange()
+	return maxId, err
+}
+
+// Valid reports whether the changes since token are still in the journal
+func Valid(token uint) (bool, error) {
+	minId, maxId, err := db.GetFsChangeIdRange()
+	if err != nil {
+		return false, err
+	}
+	if maxId == 0 {
+		return token == 0, nil
+	}
+	return token+1 >= minId && token <= maxId, nil
+}
+
+// Changes returns the latest change of each object under dir since token.
+// If depth is 1, only the direct children of dir are returned.
+func Changes(dir string, token uint, depth int) ([]model.FsChange, error) {
+	dir = utils.FixAndCleanPath(dir)
+	changes, err := db.GetFsChangesUnder(dir, token)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]int, len(changes))
+	ret := make([]model.FsChange, 0, len(changes))
+	for _, c := range changes {
+		if depth == 1 && stdpath.Dir(c.Path) != dir {
+			continue
+		}
+		if i, ok := latest[c.Path]; ok {
+			ret[i] = c
+			continue
+		}
+		latest[c.Path] = len(ret)
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+func prune() {
+	pruneMu.Lock()
+	defer pruneMu.Unlock()
+	if time.Since(lastPrune) < pruneInterval {
+		return
+	}
+	lastPrune = time.Now()
+	days := retention()
+	if days <= 0 {
+		return
+	}
+	if err := db.DeleteFsChangesBefore(time.Now().AddDate(0, 0, -days)); err != nil {
+		log.Warnf("failed prune change journal: %+v", err)
+	}
+}
+
+func fingerprint(obj model.Obj) string {
+	return fmt.Sprintf("%t-%x-%x", obj.IsDir(), obj.ModTime().Unix(), obj.GetSize())
+}
+
+// Update compares the listing of parent with the last one seen and records
+// the differences, which catches changes made outside of OpenList
+func Update(ctx context.Context, parent string, objs []model.Obj) {
+	if !Enabled() {
+		return
+	}
+	now := make(map[string]string, len(objs))
+	for _, obj := range objs {
+		now[obj.GetName()] = fingerprint(obj)
+	}
+	snapshotMu.Lock()
+	old, ok := snapshots.Get(parent)
+	snapshots.Set(parent, now)
+	snapshotMu.Unlock()
+	if !ok {
+		return
+	}
+	var changed, deleted []string
+	for name, fp := range now {
+		if old[name] != fp {
+			changed = append(changed, stdpath.Join(parent, name))
+		}
+	}
+	for name := range old {
+		if _, ok := now[name]; !ok {
+			deleted = append(deleted, stdpath.Join(parent, name))
+		}
+	}
+	Record(false, changed...)
+	Record(true, deleted...)
+}
+
+func init() {
+	op.RegisterObjsUpdateHook(Update)
+}
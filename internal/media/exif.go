@@ -0,0 +1,213 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+// readJPEGExif returns the TIFF structure of the APP1 Exif segment of a JPEG
+func readJPEGExif(r io.ReaderAt, size int64) ([]byte, error) {
+	var marker [4]byte
+	if _, err := r.ReadAt(marker[:2], 0); err != nil {
+		return nil, err
+	}
+	if marker[0] != 0xFF || marker[1] != 0xD8 {
+		return nil, errors.New("not a jpeg")
+	}
+	off := int64(2)
+	for off+4 <= size {
+		if _, err := r.ReadAt(marker[:], off); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("invalid jpeg marker")
+		}
+		typ := marker[1]
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		// start of scan, the metadata segments are over
+		if typ == 0xDA || typ == 0xD9 {
+			break
+		}
+		if typ == 0xE1 && length > 8 {
+			seg := make([]byte, length-2)
+			if _, err := r.ReadAt(seg, off+4); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(string(seg), "Exif\x00\x00") {
+				return seg[6:], nil
+			}
+		}
+		off += 2 + length
+	}
+	return nil, nil
+}
+
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagPixelXDimension  = 0xA002
+	tagPixelYDimension  = 0xA003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+var tiffTypeSize = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+func (t *tiff) ifd(off uint32) map[uint16]ifdEntry {
+	entries := make(map[uint16]ifdEntry)
+	if uint64(off)+2 > uint64(len(t.data)) {
+		return entries
+	}
+	n := uint32(t.order.Uint16(t.data[off:]))
+	for i := uint32(0); i < n; i++ {
+		e := off + 2 + i*12
+		if uint64(e)+12 > uint64(len(t.data)) {
+			break
+		}
+		tag := t.order.Uint16(t.data[e:])
+		typ := t.order.Uint16(t.data[e+2:])
+		count := t.order.Uint32(t.data[e+4:])
+		size, ok := tiffTypeSize[typ]
+		if !ok || uint64(size)*uint64(count) > uint64(len(t.data)) {
+			continue
+		}
+		total := size * count
+		var value []byte
+		if total <= 4 {
+			value = t.data[e+8 : e+8+total]
+		} else {
+			p := t.order.Uint32(t.data[e+8:])
+			if uint64(p)+uint64(total) > uint64(len(t.data)) {
+				continue
+			}
+			value = t.data[p : p+total]
+		}
+		entries[tag] = ifdEntry{typ: typ, count: count, value: value}
+	}
+	return entries
+}
+
+func (t *tiff) str(e ifdEntry) string {
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+func (t *tiff) uint(e ifdEntry) uint32 {
+	switch e.typ {
+	case 3:
+		return uint32(t.order.Uint16(e.value))
+	case 4:
+		return t.order.Uint32(e.value)
+	case 1:
+		return uint32(e.value[0])
+	}
+	return 0
+}
+
+// degrees converts 3 rationals of degrees, minutes and seconds
+func (t *tiff) degrees(e ifdEntry) (float64, bool) {
+	if e.typ != 5 || e.count != 3 {
+		return 0, false
+	}
+	var v [3]float64
+	for i := range v {
+		num := t.order.Uint32(e.value[i*8:])
+		den := t.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		v[i] = float64(num) / float64(den)
+	}
+	return v[0] + v[1]/60 + v[2]/3600, true
+}
+
+// parseExif fills m with the EXIF in the TIFF structure data
+func parseExif(data []byte, m *model.MediaMeta) {
+	if len(data) < 8 {
+		return
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+	ifd0 := t.ifd(t.order.Uint32(data[4:]))
+	if e, ok := ifd0[tagMake]; ok {
+		m.CameraMake = t.str(e)
+	}
+	if e, ok := ifd0[tagModel]; ok {
+		m.CameraModel = t.str(e)
+	}
+	if e, ok := ifd0[tagOrientation]; ok {
+		m.Orientation = int(t.uint(e))
+	}
+	var taken, offset string
+	if e, ok := ifd0[tagDateTime]; ok {
+		taken = t.str(e)
+	}
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif := t.ifd(t.uint(e))
+		if e, ok := exif[tagDateTimeOriginal]; ok {
+			taken = t.str(e)
+		}
+		if e, ok := exif[tagOffsetTimeOrig]; ok {
+			offset = t.str(e)
+		}
+		if e, ok := exif[tagPixelXDimension]; ok && m.Width == 0 {
+			m.Width = int(t.uint(e))
+		}
+		if e, ok := exif[tagPixelYDimension]; ok && m.Height == 0 {
+			m.Height = int(t.uint(e))
+		}
+	}
+	if taken != "" {
+		// EXIF times have no zone unless the offset is given, take them as UTC
+		if tm, err := time.Parse("2006:01:02 15:04:05-07:00", taken+offset); err == nil {
+			m.TakenAt = &tm
+		} else if tm, err := time.Parse("2006:01:02 15:04:05", taken); err == nil {
+			m.TakenAt = &tm
+		}
+	}
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		gps := t.ifd(t.uint(e))
+		lat, ok1 := t.degrees(gps[tagGPSLatitude])
+		lon, ok2 := t.degrees(gps[tagGPSLongitude])
+		if ok1 && ok2 && !math.IsNaN(lat) && !math.IsNaN(lon) {
+			if strings.HasPrefix(t.str(gps[tagGPSLatitudeRef]), "S") {
+				lat = -lat
+			}
+			if strings.HasPrefix(t.str(gps[tagGPSLongitudeRef]), "W") {
+				lon = -lon
+			}
+			m.Latitude, m.Longitude = &lat, &lon
+		}
+	}
+}
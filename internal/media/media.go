@@ -0,0 +1,241 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/dhowden/tag"
+	pkgerrors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+var errReadLimit = errors.New("too much data read for the metadata")
+
+// readBudget bounds the bytes read from a file for its metadata, which is
+// enough for large cover arts and moov boxes
+const readBudget = 48 * 1024 * 1024
+
+var (
+	imageExts = []string{"jpg", "jpeg", "png", "gif", "webp"}
+	audioExts = []string{"mp3", "flac", "ogg", "m4a", "m4b", "aac", "alac"}
+	videoExts = map[string]string{
+		"mp4": "mp4", "m4v": "mp4", "mov": "mp4", "3gp": "mp4",
+		"mkv": "mkv", "webm": "mkv",
+	}
+)
+
+// Type returns the media type of the metadata of a file named name, or an
+// empty string if its metadata can't be parsed
+func Type(name string) string {
+	ext := strings.ToLower(utils.Ext(name))
+	switch {
+	case utils.SliceContains(imageExts, ext):
+		return "image"
+	case utils.SliceContains(audioExts, ext):
+		return "audio"
+	case videoExts[ext] != "":
+		return "video"
+	}
+	return ""
+}
+
+// Extract parses the metadata of a file from its content
+func Extract(r io.ReaderAt, size int64, name string) (*model.MediaMeta, error) {
+	m := &model.MediaMeta{Type: Type(name)}
+	ext := strings.ToLower(utils.Ext(name))
+	sr := io.NewSectionReader(r, 0, size)
+	switch m.Type {
+	case "image":
+		if cfg, _, err := image.DecodeConfig(bufio.NewReader(sr)); err == nil {
+			m.Width, m.Height = cfg.Width, cfg.Height
+		}
+		if ext == "jpg" || ext == "jpeg" {
+			exif, err := readJPEGExif(r, size)
+			if err != nil {
+				return nil, err
+			}
+			parseExif(exif, m)
+			// the pixels are stored rotated by 90 degrees
+			if m.Orientation >= 5 && m.Orientation <= 8 {
+				m.Width, m.Height = m.Height, m.Width
+			}
+		}
+	case "audio":
+		if md, err := tag.ReadFrom(sr); err == nil {
+			m.Title, m.Artist, m.Album = md.Title(), md.Artist(), md.Album()
+			m.AlbumArtist, m.Genre, m.Year = md.AlbumArtist(), md.Genre(), md.Year()
+			m.Track, _ = md.Track()
+			m.HasCover = md.Picture() != nil
+			m.AudioCodec = strings.ToLower(string(md.FileType()))
+		} else if errors.Is(err, errReadLimit) {
+			return nil, err
+		}
+		switch ext {
+		case "flac":
+			m.Duration = flacDuration(r)
+		case "m4a", "m4b", "alac":
+			codec := m.AudioCodec
+			_ = parseMP4(r, size, m)
+			if codec != "" {
+				m.AudioCodec = codec
+			}
+		}
+	case "video":
+		var err error
+		if videoExts[ext] == "mkv" {
+			err = parseMKV(r, size, m)
+		} else {
+			err = parseMP4(r, size, m)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, pkgerrors.WithMessage(errs.NotSupport, "no metadata for this type of file")
+	}
+	return m, nil
+}
+
+// flacDuration reads the duration from the STREAMINFO block, which is
+// always the first one
+func flacDuration(r io.ReaderAt) float64 {
+	var b [42]byte
+	if _, err := r.ReadAt(b[:], 0); err != nil || string(b[:4]) != "fLaC" || b[4]&0x7F != 0 {
+		return 0
+	}
+	info := b[8:]
+	v := binary.BigEndian.Uint64(info[10:18])
+	sampleRate := v >> 44
+	samples := v & (1<<36 - 1)
+	if sampleRate == 0 {
+		return 0
+	}
+	return float64(samples) / float64(sampleRate)
+}
+
+// Cover returns the cover art embedded in the audio file at path
+func Cover(ctx context.Context, path string) ([]byte, error) {
+	r, obj, closer, err := open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	if Type(obj.GetName()) != "audio" {
+		return nil, errs.NotSupport
+	}
+	md, err := tag.ReadFrom(io.NewSectionReader(r, 0, obj.GetSize()))
+	if err != nil {
+		return nil, err
+	}
+	if md.Picture() == nil {
+		return nil, pkgerrors.WithMessage(errs.ObjectNotFound, "no cover art")
+	}
+	return md.Picture().Data, nil
+}
+
+func open(ctx context.Context, path string) (io.ReaderAt, model.Obj, func(), error) {
+	link, obj, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		_ = link.Close()
+		return nil, nil, nil, err
+	}
+	return newRangeReaderAt(ctx, rr, obj.GetSize(), readBudget), obj, func() { _ = link.Close() }, nil
+}
+
+var group singleflight.Group[*model.MediaMeta]
+
+// cached returns the metadata cached of obj at path, or nil if it's missing
+// or stale
+func cached(path string, obj model.Obj) (*model.MediaMeta, error) {
+	m, err := op.GetMediaMeta(path)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Size != obj.GetSize() || m.Modified != obj.ModTime().UnixNano() {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// Get returns the metadata of the file at path, it is parsed on the first
+// request and cached until the file changes
+func Get(ctx context.Context, path string) (*model.MediaMeta, error) {
+	path = utils.FixAndCleanPath(path)
+	obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return nil, err
+	}
+	if obj.IsDir() || Type(obj.GetName()) == "" {
+		return nil, pkgerrors.WithMessage(errs.NotSupport, "no metadata for this type of file")
+	}
+	if m, err := cached(path, obj); m != nil || err != nil {
+		return m, err
+	}
+	m, err, _ := group.Do(path, func() (*model.MediaMeta, error) {
+		r, obj, closer, err := open(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		defer closer()
+		m, err := Extract(r, obj.GetSize(), obj.GetName())
+		if err != nil {
+			return nil, err
+		}
+		m.Path, m.Size, m.Modified = path, obj.GetSize(), obj.ModTime().UnixNano()
+		return m, op.SaveMediaMeta(m)
+	})
+	return m, err
+}
+
+// parsing bounds the files parsed in the background at once
+var parsing = make(chan struct{}, 4)
+
+// GetCached returns the metadata cached of obj at path without reading the
+// file. If it's missing or stale, the file is parsed in the background so that
+// it is cached for the next requests, and nil is returned.
+func GetCached(ctx context.Context, path string, obj model.Obj) (*model.MediaMeta, error) {
+	path = utils.FixAndCleanPath(path)
+	if obj.IsDir() || Type(obj.GetName()) == "" {
+		return nil, nil
+	}
+	if m, err := cached(path, obj); m != nil || err != nil {
+		return m, err
+	}
+	select {
+	case parsing <- struct{}{}:
+	default:
+		// the files are parsed again on their next requests
+		return nil, nil
+	}
+	go func() {
+		defer func() { <-parsing }()
+		if _, err := Get(context.WithoutCancel(ctx), path); err != nil {
+			log.Warnf("failed get media meta of %s: %+v", path, err)
+		}
+	}()
+	return nil, nil
+}
@@ -0,0 +1,181 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+)
+
+// tiffBuilder writes little endian IFDs with their values after them
+type tiffBuilder struct {
+	buf bytes.Buffer
+}
+
+type ifdField struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+func (b *tiffBuilder) ifd(fields []ifdField) uint32 {
+	off := uint32(b.buf.Len())
+	data := off + 2 + uint32(len(fields))*12 + 4
+	var extra bytes.Buffer
+	le := binary.LittleEndian
+	_ = binary.Write(&b.buf, le, uint16(len(fields)))
+	for _, f := range fields {
+		_ = binary.Write(&b.buf, le, f.tag)
+		_ = binary.Write(&b.buf, le, f.typ)
+		_ = binary.Write(&b.buf, le, f.count)
+		if len(f.value) <= 4 {
+			v := make([]byte, 4)
+			copy(v, f.value)
+			b.buf.Write(v)
+		} else {
+			_ = binary.Write(&b.buf, le, data+uint32(extra.Len()))
+			extra.Write(f.value)
+		}
+	}
+	_ = binary.Write(&b.buf, le, uint32(0))
+	b.buf.Write(extra.Bytes())
+	return off
+}
+
+func u32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+func rationals(v ...uint32) []byte {
+	var ret []byte
+	for _, x := range v {
+		ret = append(ret, u32(x)...)
+		ret = append(ret, u32(1)...)
+	}
+	return ret
+}
+
+func TestExtractJPEG(t *testing.T) {
+	b := &tiffBuilder{}
+	b.buf.WriteString("II*\x00")
+	b.buf.Write(u32(8))
+	// IFD0 is written after the sub IFDs it points to, its offset is patched below
+	exifIFD := b.ifd([]ifdField{
+		{tagDateTimeOriginal, 2, 20, []byte("2023:05:06 07:08:09\x00")},
+		{tagOffsetTimeOrig, 2, 7, []byte("+02:00\x00")},
+	})
+	gpsIFD := b.ifd([]ifdField{
+		{tagGPSLatitudeRef, 2, 2, []byte("S\x00")},
+		{tagGPSLatitude, 5, 3, rationals(33, 30, 0)},
+		{tagGPSLongitudeRef, 2, 2, []byte("E\x00")},
+		{tagGPSLongitude, 5, 3, rationals(151, 15, 0)},
+	})
+	ifd0 := b.ifd([]ifdField{
+		{tagMake, 2, 6, []byte("Canon\x00")},
+		{tagOrientation, 3, 1, []byte{6, 0}},
+		{tagExifIFD, 4, 1, u32(exifIFD)},
+		{tagGPSIFD, 4, 1, u32(gpsIFD)},
+	})
+	tiff := b.buf.Bytes()
+	binary.LittleEndian.PutUint32(tiff[4:], ifd0)
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	var file bytes.Buffer
+	file.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	_ = binary.Write(&file, binary.BigEndian, uint16(len(app1)+2))
+	file.Write(app1)
+	file.Write(img.Bytes()[2:])
+
+	m, err := Extract(bytes.NewReader(file.Bytes()), int64(file.Len()), "a.JPG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != "image" || m.CameraMake != "Canon" || m.Orientation != 6 {
+		t.Errorf("unexpected meta %+v", m)
+	}
+	if m.Width != 30 || m.Height != 40 {
+		t.Errorf("size of a rotated image should be swapped, got %dx%d", m.Width, m.Height)
+	}
+	want := time.Date(2023, 5, 6, 5, 8, 9, 0, time.UTC)
+	if m.TakenAt == nil || !m.TakenAt.Equal(want) {
+		t.Errorf("taken at %v, want %v", m.TakenAt, want)
+	}
+	if m.Latitude == nil || m.Longitude == nil || math.Abs(*m.Latitude+33.5) > 1e-9 || math.Abs(*m.Longitude-151.25) > 1e-9 {
+		t.Errorf("unexpected gps %v %v", m.Latitude, m.Longitude)
+	}
+}
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(body)+8)), append([]byte(typ), body...)...)
+}
+
+func TestExtractMP4(t *testing.T) {
+	be := binary.BigEndian
+	mvhd := make([]byte, 100)
+	be.PutUint32(mvhd[12:], 1000)
+	be.PutUint32(mvhd[16:], 90500)
+	tkhd := make([]byte, 84)
+	be.PutUint32(tkhd[76:], 1920<<16)
+	be.PutUint32(tkhd[80:], 1080<<16)
+	trak := func(handler, codec string, tkhd []byte) []byte {
+		hdlr := make([]byte, 24)
+		copy(hdlr[8:], handler)
+		stsd := make([]byte, 16)
+		copy(stsd[12:], codec)
+		return mp4Box("trak", mp4Box("tkhd", tkhd), mp4Box("mdia",
+			mp4Box("hdlr", hdlr),
+			mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))))
+	}
+	// moov at the end of the file, after the media data
+	file := bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom")),
+		mp4Box("mdat", make([]byte, 200000)),
+		mp4Box("moov", mp4Box("mvhd", mvhd), trak("soun", "mp4a", make([]byte, 84)), trak("vide", "avc1", tkhd)),
+	}, nil)
+	m, err := Extract(bytes.NewReader(file), int64(len(file)), "a.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != 90.5 || m.Width != 1920 || m.Height != 1080 || m.VideoCodec != "avc1" || m.AudioCodec != "mp4a" {
+		t.Errorf("unexpected meta %+v", m)
+	}
+}
+
+func ebml(id uint64, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	var ret []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(ret) > 0 {
+			ret = append(ret, b)
+		}
+	}
+	return append(append(ret, 0x01, 0, 0, 0, 0, 0, 0, byte(len(body))), body...)
+}
+
+func TestExtractMKV(t *testing.T) {
+	duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(5000))
+	file := bytes.Join([][]byte{
+		ebml(ebmlHeader, ebml(0x4282, []byte("webm"))),
+		ebml(mkvSegment,
+			ebml(mkvInfo, ebml(mkvTimescale, []byte{0x0F, 0x42, 0x40}), ebml(mkvDuration, duration)),
+			ebml(mkvTracks,
+				ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{1}), ebml(mkvCodecID, []byte("V_VP9")),
+					ebml(mkvVideo, ebml(mkvWidth, []byte{0x05, 0x00}), ebml(mkvHeight, []byte{0x02, 0xD0}))),
+				ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{2}), ebml(mkvCodecID, []byte("A_OPUS"))))),
+	}, nil)
+	m, err := Extract(bytes.NewReader(file), int64(len(file)), "a.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != 5 || m.Width != 1280 || m.Height != 720 || m.VideoCodec != "V_VP9" || m.AudioCodec != "A_OPUS" {
+		t.Errorf("unexpected meta %+v", m)
+	}
+}
@@ -0,0 +1,180 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+// the Matroska elements read for the metadata
+const (
+	ebmlHeader    = 0x1A45DFA3
+	mkvSegment    = 0x18538067
+	mkvInfo       = 0x1549A966
+	mkvTimescale  = 0x2AD7B1
+	mkvDuration   = 0x4489
+	mkvTracks     = 0x1654AE6B
+	mkvTrackEntry = 0xAE
+	mkvTrackType  = 0x83
+	mkvCodecID    = 0x86
+	mkvVideo      = 0xE0
+	mkvWidth      = 0xB0
+	mkvHeight     = 0xBA
+	mkvCluster    = 0x1F43B675
+)
+
+// maxMkvHead bounds the bytes searched for the info and tracks, which come
+// before the clusters in files written by common muxers
+const maxMkvHead = 4 * 1024 * 1024
+
+// readVint reads an EBML variable size integer, the marker bit is kept for
+// element ids and dropped for sizes
+func readVint(data []byte, keepMarker bool) (uint64, int, error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, errors.New("invalid vint")
+	}
+	n := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 || len(data) < n {
+		return 0, 0, errors.New("invalid vint")
+	}
+	v := uint64(data[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> n)
+	}
+	unknown := v == uint64(0xFF>>n)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(data[i])
+		unknown = unknown && data[i] == 0xFF
+	}
+	if !keepMarker && unknown {
+		return math.MaxUint64, n, nil
+	}
+	return v, n, nil
+}
+
+type element struct {
+	id   uint64
+	data []byte
+}
+
+// elements parses the elements in data, an element whose data is cut at the
+// end of data is returned truncated
+func elements(data []byte) []element {
+	var ret []element
+	for len(data) > 0 {
+		id, n, err := readVint(data, true)
+		if err != nil {
+			break
+		}
+		size, m, err := readVint(data[n:], false)
+		if err != nil {
+			break
+		}
+		data = data[n+m:]
+		if size > uint64(len(data)) {
+			size = uint64(len(data))
+		}
+		ret = append(ret, element{id: id, data: data[:size]})
+		data = data[size:]
+	}
+	return ret
+}
+
+func ebmlUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+// parseMKV reads the duration, the resolution and the codecs of a Matroska
+// or WebM file
+func parseMKV(r io.ReaderAt, size int64, m *model.MediaMeta) error {
+	head := make([]byte, min(size, maxMkvHead))
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	top := elements(head[:n])
+	if len(top) == 0 || top[0].id != ebmlHeader {
+		return errors.New("not a matroska file")
+	}
+	var segment []byte
+	for _, e := range top {
+		if e.id == mkvSegment {
+			segment = e.data
+		}
+	}
+	if segment == nil {
+		return errors.New("matroska segment not found")
+	}
+	for _, e := range elements(segment) {
+		switch e.id {
+		case mkvInfo:
+			timescale, duration := uint64(1000000), 0.0
+			for _, info := range elements(e.data) {
+				switch info.id {
+				case mkvTimescale:
+					timescale = ebmlUint(info.data)
+				case mkvDuration:
+					duration = ebmlFloat(info.data)
+				}
+			}
+			m.Duration = duration * float64(timescale) / 1e9
+		case mkvTracks:
+			for _, entry := range elements(e.data) {
+				if entry.id != mkvTrackEntry {
+					continue
+				}
+				var (
+					typ           uint64
+					codec         string
+					width, height int
+				)
+				for _, t := range elements(entry.data) {
+					switch t.id {
+					case mkvTrackType:
+						typ = ebmlUint(t.data)
+					case mkvCodecID:
+						codec = strings.TrimRight(string(t.data), "\x00")
+					case mkvVideo:
+						for _, v := range elements(t.data) {
+							switch v.id {
+							case mkvWidth:
+								width = int(ebmlUint(v.data))
+							case mkvHeight:
+								height = int(ebmlUint(v.data))
+							}
+						}
+					}
+				}
+				if typ == 1 && m.VideoCodec == "" {
+					m.VideoCodec, m.Width, m.Height = codec, width, height
+				} else if typ == 2 && m.AudioCodec == "" {
+					m.AudioCodec = codec
+				}
+			}
+		case mkvCluster:
+			return nil
+		}
+	}
+	return nil
+}
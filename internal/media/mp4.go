@@ -0,0 +1,146 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+const maxMoovSize = 32 * 1024 * 1024
+
+type box struct {
+	typ    string
+	offset int64 // of the payload
+	size   int64 // of the payload
+}
+
+// readBoxHeader reads the header of the box at off of an ISO BMFF file
+func readBoxHeader(r io.ReaderAt, off, end int64) (box, error) {
+	var h [16]byte
+	if _, err := r.ReadAt(h[:8], off); err != nil {
+		return box{}, err
+	}
+	size := int64(binary.BigEndian.Uint32(h[:4]))
+	b := box{typ: string(h[4:8]), offset: off + 8}
+	switch size {
+	case 0:
+		size = end - off
+	case 1:
+		if _, err := r.ReadAt(h[8:16], off+8); err != nil {
+			return box{}, err
+		}
+		size = int64(binary.BigEndian.Uint64(h[8:16]))
+		b.offset += 8
+	}
+	if size < b.offset-off || off+size > end {
+		return box{}, errors.New("invalid box size")
+	}
+	b.size = off + size - b.offset
+	return b, nil
+}
+
+// children parses the boxes in data, a box payload already in memory
+func children(data []byte) map[string][][]byte {
+	boxes := make(map[string][][]byte)
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		head := uint64(8)
+		if size == 1 && len(data) >= 16 {
+			size = binary.BigEndian.Uint64(data[8:])
+			head = 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+		if size < head || size > uint64(len(data)) {
+			break
+		}
+		boxes[typ] = append(boxes[typ], data[head:size])
+		data = data[size:]
+	}
+	return boxes
+}
+
+func first(boxes map[string][][]byte, typ string) []byte {
+	if b := boxes[typ]; len(b) > 0 {
+		return b[0]
+	}
+	return nil
+}
+
+// parseMP4 reads the duration, the resolution and the codecs from the moov
+// box of an MP4 or QuickTime file, which may be at its end
+func parseMP4(r io.ReaderAt, size int64, m *model.MediaMeta) error {
+	var moov []byte
+	for off := int64(0); off+8 <= size; {
+		b, err := readBoxHeader(r, off, size)
+		if err != nil {
+			return err
+		}
+		if b.typ == "moov" {
+			if b.size > maxMoovSize {
+				return errors.New("moov box is too large")
+			}
+			moov = make([]byte, b.size)
+			if _, err := r.ReadAt(moov, b.offset); err != nil {
+				return err
+			}
+			break
+		}
+		off = b.offset + b.size
+	}
+	if moov == nil {
+		return errors.New("moov box not found")
+	}
+	boxes := children(moov)
+	if mvhd := first(boxes, "mvhd"); len(mvhd) >= 20 {
+		var timescale, duration uint64
+		if mvhd[0] == 1 && len(mvhd) >= 32 {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[20:]))
+			duration = binary.BigEndian.Uint64(mvhd[24:])
+		} else {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[12:]))
+			duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+		}
+		if timescale > 0 {
+			m.Duration = float64(duration) / float64(timescale)
+		}
+	}
+	for _, trak := range boxes["trak"] {
+		trakBoxes := children(trak)
+		mdia := children(first(trakBoxes, "mdia"))
+		hdlr := first(mdia, "hdlr")
+		if len(hdlr) < 12 {
+			continue
+		}
+		handler := string(hdlr[8:12])
+		var codec string
+		stbl := children(first(children(first(mdia, "minf")), "stbl"))
+		if stsd := first(stbl, "stsd"); len(stsd) >= 16 {
+			codec = strings.TrimSpace(string(stsd[12:16]))
+		}
+		switch handler {
+		case "vide":
+			if m.VideoCodec != "" {
+				continue
+			}
+			m.VideoCodec = codec
+			if tkhd := first(trakBoxes, "tkhd"); len(tkhd) >= 84 {
+				end := 84
+				if tkhd[0] == 1 && len(tkhd) >= 96 {
+					end = 96
+				}
+				m.Width = int(binary.BigEndian.Uint32(tkhd[end-8:]) >> 16)
+				m.Height = int(binary.BigEndian.Uint32(tkhd[end-4:]) >> 16)
+			}
+		case "soun":
+			if m.AudioCodec == "" {
+				m.AudioCodec = codec
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package media
+
+import (
+	"context"
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+)
+
+const chunkSize = 64 * 1024
+
+// rangeReaderAt reads a remote file in chunks which are kept in memory,
+// metadata parsers jump between a few small regions of a file so the same
+// chunk is often read many times
+type rangeReaderAt struct {
+	ctx    context.Context
+	rr     model.RangeReaderIF
+	size   int64
+	chunks map[int64][]byte
+	// limit of bytes read from the upstream, as a malformed file could make
+	// a parser read the whole of it
+	budget int64
+}
+
+func newRangeReaderAt(ctx context.Context, rr model.RangeReaderIF, size, budget int64) *rangeReaderAt {
+	return &rangeReaderAt{ctx: ctx, rr: rr, size: size, chunks: make(map[int64][]byte), budget: budget}
+}
+
+func (r *rangeReaderAt) chunk(index int64) ([]byte, error) {
+	if c, ok := r.chunks[index]; ok {
+		return c, nil
+	}
+	start := index * chunkSize
+	length := min(chunkSize, r.size-start)
+	if r.budget < length {
+		return nil, errReadLimit
+	}
+	r.budget -= length
+	rc, err := r.rr.RangeRead(r.ctx, http_range.Range{Start: start, Length: length})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	c := make([]byte, length)
+	if _, err = io.ReadFull(rc, c); err != nil {
+		return nil, err
+	}
+	r.chunks[index] = c
+	return c, nil
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		c, err := r.chunk(pos / chunkSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], c[pos%chunkSize:])
+	}
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	stdpath "path"
+	"strconv"
+	"time"
+)
+
+// DBStorageNode is a file or a directory of a db storage, the body of a file
+// is its blob
+type DBStorageNode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StorageID uint      `json:"storage_id" gorm:"uniqueIndex:idx_db_storage_node"`
+	ParentID  uint      `json:"parent_id" gorm:"uniqueIndex:idx_db_storage_node"` // 0 for the root
+	Name      string    `json:"name" gorm:"type:varchar(255);uniqueIndex:idx_db_storage_node"`
+	IsDir     bool      `json:"is_dir"`
+	Size      int64     `json:"size"`
+	BlobID    uint      `json:"blob_id" gorm:"index"`
+	Modified  time.Time `json:"modified"`
+	Created   time.Time `json:"created"`
+}
+
+// DBStorageBlob is the body of a file of a db storage, which is written in
+// chunks before the file refers to it
+type DBStorageBlob struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StorageID uint      `json:"storage_id" gorm:"index"`
+	ChunkSize int64     `json:"chunk_size"` // of all the chunks but the last one
+	Created   time.Time `json:"created"`
+}
+
+type DBStorageChunk struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	BlobID uint   `json:"blob_id" gorm:"uniqueIndex:idx_db_storage_chunk"`
+	Seq    int    `json:"seq" gorm:"uniqueIndex:idx_db_storage_chunk"`
+	Data   []byte `json:"data"`
+}
+
+func (n *DBStorageNode) NodeID() uint {
+	return n.ID
+}
+
+func (n *DBStorageNode) NodeName() string {
+	return n.Name
+}
+
+func (n *DBStorageNode) NodeIsDir() bool {
+	return n.IsDir
+}
+
+func (n *DBStorageNode) NodeObj(dir string) Obj {
+	return &Object{
+		ID:       strconv.FormatUint(uint64(n.ID), 10),
+		Path:     stdpath.Join(dir, n.Name),
+		Name:     n.Name,
+		Size:     n.Size,
+		Modified: n.Modified,
+		Ctime:    n.Created,
+		IsFolder: n.IsDir,
+	}
+}
+
+func (n *DBStorageNode) Place(parentID uint, name string) {
+	n.ParentID = parentID
+	n.Name = name
+}
+
+func (n *DBStorageNode) Renew(id uint, created time.Time) {
+	n.ID = id
+	n.Created = created
+}
@@ -0,0 +1,12 @@
+package model
+
+// DeadProp is a WebDAV dead property (RFC 4918) set by a client via PROPPATCH.
+// It is keyed by the full mount path of the resource.
+type DeadProp struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Path     string `json:"path" gorm:"index"`
+	Space    string `json:"space"`
+	Local    string `json:"local"`
+	Lang     string `json:"lang"`
+	InnerXML string `json:"inner_xml" gorm:"type:text"`
+}
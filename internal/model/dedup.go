@@ -0,0 +1,61 @@
+package model
+
+import (
+	stdpath "path"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+// DedupNode is a file or a directory of a dedup storage, the body of a file
+// is the blob of its hash in the backing path of the storage
+type DedupNode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StorageID uint      `json:"storage_id" gorm:"uniqueIndex:idx_dedup_node"`
+	ParentID  uint      `json:"parent_id" gorm:"uniqueIndex:idx_dedup_node"` // 0 for the root
+	Name      string    `json:"name" gorm:"type:varchar(255);uniqueIndex:idx_dedup_node"`
+	IsDir     bool      `json:"is_dir"`
+	Size      int64     `json:"size"`
+	Hash      string    `json:"hash" gorm:"type:varchar(64);index"` // sha256
+	Modified  time.Time `json:"modified"`
+	Created   time.Time `json:"created"`
+}
+
+func (n *DedupNode) NodeID() uint {
+	return n.ID
+}
+
+func (n *DedupNode) NodeName() string {
+	return n.Name
+}
+
+func (n *DedupNode) NodeIsDir() bool {
+	return n.IsDir
+}
+
+func (n *DedupNode) NodeObj(dir string) Obj {
+	obj := &Object{
+		ID:       strconv.FormatUint(uint64(n.ID), 10),
+		Path:     stdpath.Join(dir, n.Name),
+		Name:     n.Name,
+		Size:     n.Size,
+		Modified: n.Modified,
+		Ctime:    n.Created,
+		IsFolder: n.IsDir,
+	}
+	if !n.IsDir {
+		obj.HashInfo = utils.NewHashInfo(utils.SHA256, n.Hash)
+	}
+	return obj
+}
+
+func (n *DedupNode) Place(parentID uint, name string) {
+	n.ParentID = parentID
+	n.Name = name
+}
+
+func (n *DedupNode) Renew(id uint, created time.Time) {
+	n.ID = id
+	n.Created = created
+}
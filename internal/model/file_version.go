@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// FileVersion is a file replaced by an upload, a move or a rename, which is
+// kept in the versions directory of its storage
+type FileVersion struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// Path is the path of the file replaced
+	Path string `json:"path" gorm:"index"`
+	// VersionPath is where the file is kept, its name is the same as Path's
+	VersionPath string    `json:"-"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	// Created is the time when the file was replaced
+	Created time.Time `json:"created"`
+	// Reason is the operation which replaced the file, put, move or rename
+	Reason string `json:"reason"`
+}
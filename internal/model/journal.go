@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// FsChange is an entry of the change journal, recording that the object at
+// Path has been created, modified or deleted. ID increases monotonically and
+// is used as the sync token of WebDAV sync-collection reports.
+type FsChange struct {
+	ID      uint      `json:"id" gorm:"primaryKey"`
+	Path    string    `json:"path" gorm:"index"`
+	Deleted bool      `json:"deleted"`
+	Time    time.Time `json:"time" gorm:"index"`
+}
@@ -0,0 +1,64 @@
+package model
+
+import "time"
+
+// MediaMeta is the metadata parsed from the content of an image, audio or
+// video file. It is cached by the full path of the file and is stale once
+// the size or modified time of the file change.
+type MediaMeta struct {
+	ID       uint   `json:"-" gorm:"primaryKey"`
+	Path     string `json:"-" gorm:"unique"`
+	Size     int64  `json:"-"`
+	Modified int64  `json:"-"` // unix nano
+	// Dir, Taken and Month are the columns the photos are grouped and sorted
+	// by, Taken is TakenAt or the modified time of the files without EXIF in
+	// unix nano, and Month is its month in UTC
+	Dir   string `json:"-" gorm:"index"`
+	Taken int64  `json:"-" gorm:"index"`
+	Month string `json:"-" gorm:"index"`
+
+	Type   string `json:"type"` // image, audio or video
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+
+	// EXIF of images
+	TakenAt     *time.Time `json:"taken_at,omitempty" gorm:"index"`
+	CameraMake  string     `json:"camera_make,omitempty"`
+	CameraModel string     `json:"camera_model,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	Latitude    *float64   `json:"latitude,omitempty"`
+	Longitude   *float64   `json:"longitude,omitempty"`
+
+	Duration   float64 `json:"duration,omitempty"` // seconds
+	VideoCodec string  `json:"video_codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+
+	// tags of audio files
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Year        int    `json:"year,omitempty" gorm:"index"`
+	Track       int    `json:"track,omitempty"`
+	HasCover    bool   `json:"has_cover,omitempty"`
+}
+
+// MediaMetaFilter selects files by their cached media metadata,
+// zero fields match everything
+type MediaMetaFilter struct {
+	Type      string     `json:"type"`
+	TakenFrom *time.Time `json:"taken_from"`
+	TakenTo   *time.Time `json:"taken_to"`
+	Year      int        `json:"year"`
+	Artist    string     `json:"artist"`
+	Album     string     `json:"album"`
+	Camera    string     `json:"camera"`
+}
+
+// PhotoCount is the number of the photos of a month in a directory
+type PhotoCount struct {
+	Dir   string
+	Month string
+	Count int64
+}
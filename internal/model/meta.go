@@ -17,4 +17,6 @@ type Meta struct {
 	RSub          bool   `json:"r_sub"`
 	Header        string `json:"header"`
 	HeaderSub     bool   `json:"header_sub"`
+	Versioning    bool   `json:"versioning"`
+	VSub          bool   `json:"v_sub"`
 }
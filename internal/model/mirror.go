@@ -0,0 +1,14 @@
+package model
+
+// MirrorRepair is a write failed on a replica of a mirror storage, to be
+// retried by copying the path from a replica it succeeded on. Src and Dst
+// are the mount paths of the replicas.
+type MirrorRepair struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	StorageID uint   `json:"storage_id" gorm:"index"`
+	Src       string `json:"src"`
+	Dst       string `json:"dst"`
+	Path      string `json:"path"`
+	Attempts  int    `json:"attempts"`
+	LastErr   string `json:"last_err"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// NfsHandle maps an NFS file handle to the full mount path of an object,
+// so that handles given to NFS clients stay valid across restarts. Used is
+// refreshed at most daily, the handles unused for long are pruned.
+type NfsHandle struct {
+	ID   uint64    `json:"id" gorm:"primaryKey"`
+	Path string    `json:"path" gorm:"index"`
+	Used time.Time `json:"used" gorm:"index"`
+}
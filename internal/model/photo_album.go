@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PhotoAlbum is a virtual album of the photos under Paths whose media meta
+// match Filter, it doesn't hold the photos themselves
+type PhotoAlbum struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	Name      string          `json:"name" binding:"required"`
+	Paths     []string        `json:"paths" gorm:"serializer:json"`
+	Filter    MediaMetaFilter `json:"filter" gorm:"serializer:json"`
+	CreatorId uint            `json:"-" gorm:"index"`
+	Created   time.Time       `json:"created"`
+}
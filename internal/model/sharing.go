@@ -1,6 +1,11 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
 
 type SharingDB struct {
 	ID          string     `json:"id" gorm:"type:varchar(64);primaryKey"`
@@ -15,6 +20,17 @@ type SharingDB struct {
 	Readme      string     `json:"readme" gorm:"type:text"`
 	Header      string     `json:"header" gorm:"type:text"`
 	Sort
+	// Upload turns the share into a file request: visitors can upload into
+	// the shared folder but can't list or download its content
+	Upload       bool   `json:"upload"`
+	MaxFileSize  int64  `json:"max_file_size"`
+	MaxFileCount int    `json:"max_file_count"`
+	Uploaded     int    `json:"uploaded"`
+	AllowedExts  string `json:"allowed_exts"`
+	// comma or newline separated IPs or CIDRs, see utils.IPAllowed
+	AllowedIPs        string `json:"allowed_ips" gorm:"type:text"`
+	DeniedIPs         string `json:"denied_ips" gorm:"type:text"`
+	MaxDownloadsPerIP int    `json:"max_downloads_per_ip"`
 }
 
 type Sharing struct {
@@ -45,3 +61,26 @@ func (s *Sharing) Valid() bool {
 func (s *Sharing) Verify(pwd string) bool {
 	return s.Pwd == "" || s.Pwd == pwd
 }
+
+// IPAllowed reports whether the share can be accessed from ip
+func (s *Sharing) IPAllowed(ip string) bool {
+	if strings.TrimSpace(s.DeniedIPs) != "" && utils.IPAllowed(ip, s.DeniedIPs) {
+		return false
+	}
+	return utils.IPAllowed(ip, s.AllowedIPs)
+}
+
+// ExtAllowed reports whether a file named name may be uploaded to the share,
+// AllowedExts is a comma separated list of extensions, empty allows all
+func (s *Sharing) ExtAllowed(name string) bool {
+	if strings.TrimSpace(s.AllowedExts) == "" {
+		return true
+	}
+	ext := utils.Ext(name)
+	for _, allowed := range strings.Split(s.AllowedExts, ",") {
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
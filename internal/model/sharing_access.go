@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// SharingAccess is a download, archive extraction or upload made through a share
+type SharingAccess struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	SharingID string    `json:"sharing_id" gorm:"type:varchar(64);index"`
+	Time      time.Time `json:"time" gorm:"index"`
+	IP        string    `json:"ip" gorm:"index"`
+	UserAgent string    `json:"user_agent"`
+	Path      string    `json:"path" gorm:"type:text"`
+	Bytes     int64     `json:"bytes"`
+	Archive   bool      `json:"archive"`
+	Upload    bool      `json:"upload"`
+	// Partial is set for the downloads of a file the ip has downloaded
+	// before, such as the resumed or ranged ones, they count as one download
+	Partial bool `json:"partial"`
+}
+
+type SharingAccessStats struct {
+	Downloads int64 `json:"downloads"`
+	Uploads   int64 `json:"uploads"`
+	Bytes     int64 `json:"bytes"`
+	IPs       int64 `json:"ips"`
+}
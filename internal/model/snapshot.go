@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Snapshot is the listing of the tree of a mount path at a point in time
+type Snapshot struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	SourcePath string    `json:"source_path" gorm:"index"`
+	Created    time.Time `json:"created"`
+	Dirs       int       `json:"dirs"`
+	Files      int       `json:"files"`
+	Size       int64     `json:"size"`
+	// Errors is the number of the dirs failed to list
+	Errors int  `json:"errors"`
+	Done   bool `json:"done"`
+}
+
+// SnapshotEntry is an object recorded by a snapshot, Parent is the path of
+// its dir relative to the source path
+type SnapshotEntry struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	SnapshotID uint      `json:"snapshot_id" gorm:"index:idx_snapshot_entry"`
+	Parent     string    `json:"parent" gorm:"index:idx_snapshot_entry"`
+	Name       string    `json:"name"`
+	IsDir      bool      `json:"is_dir"`
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	ObjID      string    `json:"obj_id"`
+	Hash       string    `json:"hash"` // HashInfo.String()
+}
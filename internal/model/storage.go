@@ -19,6 +19,7 @@ type Storage struct {
 	Disabled            bool      `json:"disabled"` // if disabled
 	DisableIndex        bool      `json:"disable_index"`
 	EnableSign          bool      `json:"enable_sign"`
+	Versioning          bool      `json:"versioning"` // keep the files replaced by uploads, moves and renames
 	Sort
 	Proxy
 }
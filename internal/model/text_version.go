@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// TextVersion is a previous content of a text file saved by the editor
+type TextVersion struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	Path    string `json:"path" gorm:"index"`
+	Content []byte `json:"-"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"` // sha1
+	// Modified is the modified time of the file of this version
+	Modified time.Time `json:"modified"`
+	// Created is the time when the version was replaced
+	Created  time.Time `json:"created"`
+	UserId   uint      `json:"-"`
+	Username string    `json:"username" gorm:"-"`
+}
@@ -69,6 +69,9 @@ type User struct {
 	SsoID      string `json:"sso_id"` // unique by sso platform
 	Authn      string `gorm:"type:text" json:"-"`
 	AllowLdap  bool   `json:"allow_ldap" gorm:"default:true"`
+	// FTP login restrictions of the user
+	FtpRequireTLS bool   `json:"ftp_require_tls"`
+	FtpAllowedIPs string `json:"ftp_allowed_ips" gorm:"type:text"` // IPs or CIDRs, one per line
 }
 
 func (u *User) IsGuest() bool {
@@ -272,6 +272,21 @@ func checkPreconditions(w http.ResponseWriter, r *http.Request, modtime time.Tim
 	return false, rangeHeader
 }
 
+// CheckPreconditions sets the Last-Modified header and evaluates the conditional
+// headers of r against it and the Etag already set on w. It reports whether
+// StatusNotModified or StatusPreconditionFailed has been written to w.
+// The evaluated headers are removed from r, so a later ServeHTTP does not check them again,
+// and so is the Range header if If-Range fails.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
+	setLastModified(w, modtime)
+	done, rangeHeader := checkPreconditions(w, r, modtime)
+	if rangeHeader == "" {
+		// the If-Range has failed, so the whole content is served
+		r.Header.Del("Range")
+	}
+	return done
+}
+
 func sumRangesSize(ranges []http_range.Range) (size int64) {
 	for _, ra := range ranges {
 		size += ra.Length
@@ -0,0 +1,40 @@
+package net
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditionsIfRange(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name      string
+		ifRange   string
+		wantRange string
+	}{
+		{name: "etag matched", ifRange: `"v1"`, wantRange: "bytes=1-"},
+		{name: "etag changed", ifRange: `"v0"`, wantRange: ""},
+		{name: "date matched", ifRange: modtime.Format(http.TimeFormat), wantRange: "bytes=1-"},
+		{name: "date changed", ifRange: modtime.Add(-time.Hour).Format(http.TimeFormat), wantRange: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Range", "bytes=1-")
+			r.Header.Set("If-Range", tt.ifRange)
+			w := httptest.NewRecorder()
+			w.Header().Set("Etag", `"v1"`)
+			if CheckPreconditions(w, r, modtime) {
+				t.Fatalf("the request is answered with %d", w.Code)
+			}
+			if got := r.Header.Get("Range"); got != tt.wantRange {
+				t.Errorf("the range left is %q, want %q", got, tt.wantRange)
+			}
+			if r.Header.Get("If-Range") != "" {
+				t.Errorf("the If-Range is left to be evaluated again")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Package node_tree keeps the trees of the files of the storages whose nodes
+// are kept in the database, such as db storage and dedup
+package node_tree
+
+import (
+	"context"
+	"errors"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Node is a file or a directory of a tree, 0 is the id of the root
+type Node interface {
+	NodeID() uint
+	NodeName() string
+	NodeIsDir() bool
+	// NodeObj returns the node as an object in the directory
+	NodeObj(dir string) model.Obj
+	// Place puts the node into the parent with the name
+	Place(parentID uint, name string)
+	// Renew makes the node the one of id, or a new one if id is 0
+	Renew(id uint, created time.Time)
+}
+
+// Store keeps the nodes of a tree of a storage
+type Store[N any] interface {
+	GetNode(parentID uint, name string) (*N, error)
+	GetNodeById(id uint) (*N, error)
+	GetChildren(parentID uint) ([]N, error)
+	CreateNode(n *N) error
+	UpdateNode(n *N) error
+	DeleteNodes(ids []uint) error
+}
+
+type Tree[N any, P interface {
+	*N
+	Node
+}] struct {
+	Store Store[N]
+}
+
+func Root(modified time.Time) model.Obj {
+	return &model.Object{
+		ID:       "0",
+		Name:     "root",
+		Path:     "/",
+		IsFolder: true,
+		Modified: modified,
+	}
+}
+
+func ID(obj model.Obj) uint {
+	id, _ := strconv.ParseUint(obj.GetID(), 10, 64)
+	return uint(id)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.ObjectNotFound
+	}
+	return err
+}
+
+func (t *Tree[N, P]) getNode(parentID uint, name string) (P, error) {
+	n, err := t.Store.GetNode(parentID, name)
+	return P(n), notFound(err)
+}
+
+// GetNodeById returns the node of id, errs.ObjectNotFound if it doesn't exist
+func (t *Tree[N, P]) GetNodeById(id uint) (P, error) {
+	n, err := t.Store.GetNodeById(id)
+	return P(n), notFound(err)
+}
+
+// Get returns the object of the path, which isn't the root
+func (t *Tree[N, P]) Get(path string) (model.Obj, error) {
+	var n P
+	var parentID uint
+	for _, name := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		var err error
+		if n, err = t.getNode(parentID, name); err != nil {
+			return nil, err
+		}
+		parentID = n.NodeID()
+	}
+	return n.NodeObj(stdpath.Dir(path)), nil
+}
+
+func (t *Tree[N, P]) List(dir model.Obj) ([]model.Obj, error) {
+	nodes, err := t.Store.GetChildren(ID(dir))
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]model.Obj, 0, len(nodes))
+	for i := range nodes {
+		objs = append(objs, P(&nodes[i]).NodeObj(dir.GetPath()))
+	}
+	return objs, nil
+}
+
+// CheckName returns the node named name in the directory, which is replaced
+// only if both of them are files
+func (t *Tree[N, P]) CheckName(parentID uint, name string, isDir bool) (P, error) {
+	n, err := t.getNode(parentID, name)
+	if errs.IsObjectNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.NodeIsDir() || isDir {
+		return nil, errs.ObjectAlreadyExists
+	}
+	return n, nil
+}
+
+// MakeDir creates the directory, n is filled with the other fields
+func (t *Tree[N, P]) MakeDir(parentID uint, name string, n P) error {
+	if _, err := t.CheckName(parentID, name, true); err != nil {
+		return err
+	}
+	n.Place(parentID, name)
+	return t.Store.CreateNode(n)
+}
+
+func (t *Tree[N, P]) rename(srcObj model.Obj, parentID uint, name string) error {
+	replaced, err := t.CheckName(parentID, name, srcObj.IsDir())
+	if err != nil {
+		return err
+	}
+	if replaced != nil {
+		if replaced.NodeID() == ID(srcObj) {
+			return nil
+		}
+		if err := t.Store.DeleteNodes([]uint{replaced.NodeID()}); err != nil {
+			return err
+		}
+	}
+	n, err := t.GetNodeById(ID(srcObj))
+	if err != nil {
+		return err
+	}
+	n.Place(parentID, name)
+	return t.Store.UpdateNode(n)
+}
+
+func (t *Tree[N, P]) Move(srcObj, dstDir model.Obj) error {
+	if srcObj.IsDir() && utils.IsSubPath(srcObj.GetPath(), dstDir.GetPath()) {
+		return errors.New("can't move a directory into itself")
+	}
+	return t.rename(srcObj, ID(dstDir), srcObj.GetName())
+}
+
+func (t *Tree[N, P]) Rename(srcObj model.Obj, newName string) error {
+	parentID, err := t.parentID(srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	return t.rename(srcObj, parentID, newName)
+}
+
+func (t *Tree[N, P]) parentID(path string) (uint, error) {
+	dir := stdpath.Dir(path)
+	if dir == "/" {
+		return 0, nil
+	}
+	obj, err := t.Get(dir)
+	if err != nil {
+		return 0, err
+	}
+	return ID(obj), nil
+}
+
+// Copy copies the tree of srcObj into dstDir, replacing the file of the same
+// name, the files are saved by saveFile
+func (t *Tree[N, P]) Copy(ctx context.Context, srcObj, dstDir model.Obj, saveFile func(P) error) error {
+	if srcObj.IsDir() && utils.IsSubPath(srcObj.GetPath(), dstDir.GetPath()) {
+		return errors.New("can't copy a directory into itself")
+	}
+	replaced, err := t.CheckName(ID(dstDir), srcObj.GetName(), srcObj.IsDir())
+	if err != nil {
+		return err
+	}
+	n, err := t.GetNodeById(ID(srcObj))
+	if err != nil {
+		return err
+	}
+	srcID := n.NodeID()
+	n.Place(ID(dstDir), srcObj.GetName())
+	if replaced != nil {
+		n.Renew(replaced.NodeID(), replaced.NodeObj("").CreateTime())
+	} else {
+		n.Renew(0, time.Now())
+	}
+	return t.copyNode(ctx, srcID, n, saveFile)
+}
+
+func (t *Tree[N, P]) copyNode(ctx context.Context, srcID uint, n P, saveFile func(P) error) error {
+	if !n.NodeIsDir() {
+		return saveFile(n)
+	}
+	if err := t.Store.CreateNode(n); err != nil {
+		return err
+	}
+	children, err := t.Store.GetChildren(srcID)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		if utils.IsCanceled(ctx) {
+			return ctx.Err()
+		}
+		c := P(&children[i])
+		srcID := c.NodeID()
+		c.Place(n.NodeID(), c.NodeName())
+		c.Renew(0, time.Now())
+		if err := t.copyNode(ctx, srcID, c, saveFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes the node of obj with all of its descendants
+func (t *Tree[N, P]) Remove(obj model.Obj) error {
+	ids := []uint{ID(obj)}
+	if obj.IsDir() {
+		for i := 0; i < len(ids); i++ {
+			children, err := t.Store.GetChildren(ids[i])
+			if err != nil {
+				return err
+			}
+			for j := range children {
+				ids = append(ids, P(&children[j]).NodeID())
+			}
+		}
+	}
+	return t.Store.DeleteNodes(ids)
+}
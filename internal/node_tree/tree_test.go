@@ -0,0 +1,142 @@
+package node_tree
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"gorm.io/gorm"
+)
+
+// memStore keeps the nodes in memory
+type memStore struct {
+	nodes  map[uint]model.DBStorageNode
+	lastID uint
+}
+
+func (s *memStore) GetNode(parentID uint, name string) (*model.DBStorageNode, error) {
+	for _, n := range s.nodes {
+		if n.ParentID == parentID && n.Name == name {
+			return &n, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *memStore) GetNodeById(id uint) (*model.DBStorageNode, error) {
+	n, ok := s.nodes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &n, nil
+}
+
+func (s *memStore) GetChildren(parentID uint) ([]model.DBStorageNode, error) {
+	var children []model.DBStorageNode
+	for _, n := range s.nodes {
+		if n.ParentID == parentID {
+			children = append(children, n)
+		}
+	}
+	return children, nil
+}
+
+func (s *memStore) CreateNode(n *model.DBStorageNode) error {
+	s.lastID++
+	n.ID = s.lastID
+	s.nodes[n.ID] = *n
+	return nil
+}
+
+func (s *memStore) UpdateNode(n *model.DBStorageNode) error {
+	s.nodes[n.ID] = *n
+	return nil
+}
+
+func (s *memStore) DeleteNodes(ids []uint) error {
+	for _, id := range ids {
+		delete(s.nodes, id)
+	}
+	return nil
+}
+
+func saveFile(s *memStore) func(n *model.DBStorageNode) error {
+	return func(n *model.DBStorageNode) error {
+		if n.ID != 0 {
+			return s.UpdateNode(n)
+		}
+		return s.CreateNode(n)
+	}
+}
+
+func TestTree(t *testing.T) {
+	s := &memStore{nodes: make(map[uint]model.DBStorageNode)}
+	tree := &Tree[model.DBStorageNode, *model.DBStorageNode]{Store: s}
+	ctx := context.Background()
+	mustGet := func(path string) model.Obj {
+		obj, err := tree.Get(path)
+		if err != nil {
+			t.Fatalf("failed get %s: %+v", path, err)
+		}
+		return obj
+	}
+
+	if err := tree.MakeDir(0, "a", &model.DBStorageNode{IsDir: true}); err != nil {
+		t.Fatal(err)
+	}
+	a := mustGet("/a")
+	if err := tree.MakeDir(ID(a), "b", &model.DBStorageNode{IsDir: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateNode(&model.DBStorageNode{ParentID: ID(mustGet("/a/b")), Name: "f", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.MakeDir(ID(a), "b", &model.DBStorageNode{IsDir: true}); err != errs.ObjectAlreadyExists {
+		t.Errorf("made the dir which exists: %v", err)
+	}
+	if obj := mustGet("/a/b/f"); obj.GetPath() != "/a/b/f" || obj.GetSize() != 1 {
+		t.Errorf("got %s of size %d", obj.GetPath(), obj.GetSize())
+	}
+
+	if err := tree.Copy(ctx, a, mustGet("/a/b"), saveFile(s)); err == nil {
+		t.Errorf("copied a dir into itself")
+	}
+	if err := tree.Copy(ctx, a, Root(time.Now()), saveFile(s)); err == nil {
+		t.Errorf("copied a dir onto itself")
+	}
+	if err := tree.Rename(a, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.MakeDir(0, "a", &model.DBStorageNode{IsDir: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Copy(ctx, mustGet("/c/b"), mustGet("/a"), saveFile(s)); err != nil {
+		t.Fatal(err)
+	}
+	copied := mustGet("/a/b/f")
+	if ID(copied) == ID(mustGet("/c/b/f")) || copied.GetSize() != 1 {
+		t.Errorf("the file isn't copied")
+	}
+
+	if err := tree.Move(mustGet("/c/b/f"), mustGet("/a/b")); err != nil {
+		t.Fatal(err)
+	}
+	if ID(mustGet("/a/b/f")) == ID(copied) {
+		t.Errorf("the file moved doesn't replace the one there")
+	}
+	if objs, _ := tree.List(mustGet("/c/b")); len(objs) != 0 {
+		t.Errorf("the file moved is still listed in the source")
+	}
+
+	if err := tree.Remove(mustGet("/a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Get("/a/b/f"); !errs.IsObjectNotFound(err) {
+		t.Errorf("got the file removed: %v", err)
+	}
+	if len(s.nodes) != 2 {
+		t.Errorf("%d nodes are left, want /c and /c/b", len(s.nodes))
+	}
+}
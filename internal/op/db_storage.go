@@ -0,0 +1,64 @@
+package op
+
+import (
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetDBStorageNode(storageID, parentID uint, name string) (*model.DBStorageNode, error) {
+	return db.GetDBStorageNode(storageID, parentID, name)
+}
+
+func GetDBStorageNodeById(id uint) (*model.DBStorageNode, error) {
+	return db.GetDBStorageNodeById(id)
+}
+
+func GetDBStorageChildren(storageID, parentID uint) ([]model.DBStorageNode, error) {
+	return db.GetDBStorageChildren(storageID, parentID)
+}
+
+func CreateDBStorageNode(n *model.DBStorageNode) error {
+	return db.CreateDBStorageNode(n)
+}
+
+func UpdateDBStorageNode(n *model.DBStorageNode) error {
+	return db.UpdateDBStorageNode(n)
+}
+
+func SaveDBStorageFile(n *model.DBStorageNode) error {
+	return db.SaveDBStorageFile(n)
+}
+
+func DeleteDBStorageNodes(ids []uint) error {
+	return db.DeleteDBStorageNodes(ids)
+}
+
+func CreateDBStorageBlob(b *model.DBStorageBlob) error {
+	return db.CreateDBStorageBlob(b)
+}
+
+func CreateDBStorageChunk(c *model.DBStorageChunk) error {
+	return db.CreateDBStorageChunk(c)
+}
+
+func GetDBStorageBlob(id uint) (*model.DBStorageBlob, error) {
+	return db.GetDBStorageBlob(id)
+}
+
+func GetDBStorageChunk(blobID uint, seq int) (*model.DBStorageChunk, error) {
+	return db.GetDBStorageChunk(blobID, seq)
+}
+
+func DeleteDBStorageBlobs(ids []uint) error {
+	return db.DeleteDBStorageBlobs(ids)
+}
+
+func GetDBStorageUsed(storageID uint) (int64, error) {
+	return db.GetDBStorageUsed(storageID)
+}
+
+func DeleteOrphanDBStorageData(before time.Time) error {
+	return db.DeleteOrphanDBStorageData(before)
+}
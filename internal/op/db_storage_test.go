@@ -0,0 +1,55 @@
+package op_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestDBStorageBlobs(t *testing.T) {
+	const storageID = 9999
+	newBlob := func(data ...string) uint {
+		b := &model.DBStorageBlob{StorageID: storageID, ChunkSize: 4, Created: time.Now()}
+		if err := op.CreateDBStorageBlob(b); err != nil {
+			t.Fatal(err)
+		}
+		for i, d := range data {
+			if err := op.CreateDBStorageChunk(&model.DBStorageChunk{BlobID: b.ID, Seq: i, Data: []byte(d)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return b.ID
+	}
+	first := newBlob("abcd", "ef")
+	n := &model.DBStorageNode{StorageID: storageID, Name: "a.txt", Size: 6, BlobID: first}
+	if err := op.SaveDBStorageFile(n); err != nil {
+		t.Fatal(err)
+	}
+	n.BlobID = newBlob("xyz")
+	n.Size = 3
+	if err := op.SaveDBStorageFile(n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.GetDBStorageChunk(first, 0); err == nil {
+		t.Errorf("the chunks of the blob replaced are kept")
+	}
+	if used, err := op.GetDBStorageUsed(storageID); err != nil || used != 3 {
+		t.Errorf("got used %d, %v", used, err)
+	}
+	if c, err := op.GetDBStorageChunk(n.BlobID, 0); err != nil || string(c.Data) != "xyz" {
+		t.Errorf("got chunk %+v, %v", c, err)
+	}
+
+	// the storage doesn't exist, so all of its data are orphans
+	if err := op.DeleteOrphanDBStorageData(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.GetDBStorageNodeById(n.ID); err == nil {
+		t.Errorf("the orphan node is kept")
+	}
+	if _, err := op.GetDBStorageBlob(n.BlobID); err == nil {
+		t.Errorf("the orphan blob is kept")
+	}
+}
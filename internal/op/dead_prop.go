@@ -0,0 +1,35 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+func GetDeadProps(path string) ([]model.DeadProp, error) {
+	return db.GetDeadPropsByPath(utils.FixAndCleanPath(path))
+}
+
+// GetDeadPropsOfPaths returns the dead props of paths by path in one query
+func GetDeadPropsOfPaths(paths []string) (map[string][]model.DeadProp, error) {
+	cleaned := make([]string, len(paths))
+	for i, path := range paths {
+		cleaned[i] = utils.FixAndCleanPath(path)
+	}
+	props, err := db.GetDeadPropsByPaths(cleaned)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]model.DeadProp, len(paths))
+	for _, path := range cleaned {
+		ret[path] = nil
+	}
+	for _, p := range props {
+		ret[p.Path] = append(ret[p.Path], p)
+	}
+	return ret, nil
+}
+
+func PatchDeadProps(path string, set []model.DeadProp, remove []model.DeadProp) error {
+	return db.PatchDeadProps(utils.FixAndCleanPath(path), set, remove)
+}
@@ -0,0 +1,50 @@
+package op_test
+
+import (
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestPatchDeadProps(t *testing.T) {
+	props := []model.DeadProp{{Space: "urn:test", Local: "color", InnerXML: "red"}}
+	if err := op.PatchDeadProps("/dp/a", props, nil); err != nil {
+		t.Fatalf("failed patch dead props: %+v", err)
+	}
+	props = []model.DeadProp{{Space: "urn:test", Local: "color", InnerXML: "blue"}}
+	if err := op.PatchDeadProps("/dp/a", props, nil); err != nil {
+		t.Fatalf("failed patch dead props: %+v", err)
+	}
+	got, err := op.GetDeadProps("/dp/a")
+	if err != nil || len(got) != 1 || got[0].InnerXML != "blue" {
+		t.Errorf("a set dead prop should replace the old one, got %+v, %v", got, err)
+	}
+
+	err = op.PatchDeadProps("/dp/a", nil, []model.DeadProp{{Space: "urn:test", Local: "color"}})
+	if err != nil {
+		t.Fatalf("failed remove dead prop: %+v", err)
+	}
+	if got, _ := op.GetDeadProps("/dp/a"); len(got) != 0 {
+		t.Errorf("dead prop is not removed")
+	}
+}
+
+func TestDeadPropsOfPaths(t *testing.T) {
+	for _, path := range []string{"/dps/a", "/dps/b", "/dps/b"} {
+		err := op.PatchDeadProps(path, []model.DeadProp{{Space: "urn:test", Local: path}}, nil)
+		if err != nil {
+			t.Fatalf("failed patch dead props of %s: %+v", path, err)
+		}
+	}
+	props, err := op.GetDeadPropsOfPaths([]string{"/dps/a", "/dps/b/", "/dps/c"})
+	if err != nil {
+		t.Fatalf("failed get dead props: %+v", err)
+	}
+	if len(props) != 3 || len(props["/dps/a"]) != 1 || len(props["/dps/b"]) != 1 {
+		t.Errorf("got the dead props %+v", props)
+	}
+	if ps, ok := props["/dps/c"]; !ok || len(ps) != 0 {
+		t.Errorf("the path without dead props should be loaded empty")
+	}
+}
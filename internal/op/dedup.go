@@ -0,0 +1,38 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetDedupNode(storageID, parentID uint, name string) (*model.DedupNode, error) {
+	return db.GetDedupNode(storageID, parentID, name)
+}
+
+func GetDedupNodeById(id uint) (*model.DedupNode, error) {
+	return db.GetDedupNodeById(id)
+}
+
+func GetDedupChildren(storageID, parentID uint) ([]model.DedupNode, error) {
+	return db.GetDedupChildren(storageID, parentID)
+}
+
+func CreateDedupNode(n *model.DedupNode) error {
+	return db.CreateDedupNode(n)
+}
+
+func UpdateDedupNode(n *model.DedupNode) error {
+	return db.UpdateDedupNode(n)
+}
+
+func DeleteDedupNodes(ids []uint) error {
+	return db.DeleteDedupNodes(ids)
+}
+
+func GetDedupHashes() ([]string, error) {
+	return db.GetDedupHashes()
+}
+
+func DeleteOrphanDedupNodes() error {
+	return db.DeleteOrphanDedupNodes()
+}
@@ -0,0 +1,223 @@
+package op
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// VersionsDir is the directory at the root of a storage keeping the versions
+// of its files, each version is in a directory of its own
+const VersionsDir = ".openlist_versions"
+
+// the reasons of the versions
+const (
+	VersionPut    = "put"
+	VersionMove   = "move"
+	VersionRename = "rename"
+)
+
+func isVersionPath(path string) bool {
+	return utils.IsSubPath("/"+VersionsDir, path)
+}
+
+// canKeepVersion reports whether the storage can keep the versions, which
+// are moved into directories of their own
+func canKeepVersion(storage driver.Driver) bool {
+	switch storage.(type) {
+	case driver.Mkdir, driver.MkdirResult:
+	default:
+		return false
+	}
+	switch storage.(type) {
+	case driver.Move, driver.MoveResult:
+		return true
+	}
+	return false
+}
+
+// versioningEnabled reports whether the file at the actual path is kept as
+// a version when it is replaced, by the storage or the meta of its directory.
+// The files are replaced as usual on the storages which can't keep versions.
+func versioningEnabled(storage driver.Driver, path string) bool {
+	if isVersionPath(path) || !canKeepVersion(storage) {
+		return false
+	}
+	if storage.GetStorage().Versioning {
+		return true
+	}
+	dir := stdpath.Dir(stdpath.Join(storage.GetStorage().MountPath, path))
+	meta, err := GetNearestMeta(dir)
+	if err != nil || !meta.Versioning {
+		return false
+	}
+	return utils.PathEqual(meta.Path, dir) || (meta.VSub && utils.IsSubPath(meta.Path, dir))
+}
+
+// keepVersion moves the file at the actual path, which is about to be
+// replaced, to the versions directory. It returns nil if versioning is not
+// enabled for the file.
+func keepVersion(ctx context.Context, storage driver.Driver, path string, obj model.Obj, reason string) (*model.FileVersion, error) {
+	if obj.IsDir() || !versioningEnabled(storage, path) {
+		return nil, nil
+	}
+	ctx = context.WithValue(ctx, conf.SkipHookKey, struct{}{})
+	dir := stdpath.Join("/", VersionsDir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), random.String(8)))
+	if err := MakeDir(ctx, storage, dir); err != nil {
+		return nil, errors.WithMessage(err, "failed make the version dir")
+	}
+	if err := Move(ctx, storage, path, dir); err != nil {
+		_ = Remove(ctx, storage, dir)
+		return nil, errors.WithMessage(err, "failed keep the version")
+	}
+	mountPath := storage.GetStorage().MountPath
+	v := &model.FileVersion{
+		Path:        stdpath.Join(mountPath, path),
+		VersionPath: stdpath.Join(mountPath, dir, stdpath.Base(path)),
+		Size:        obj.GetSize(),
+		Modified:    obj.ModTime(),
+		Created:     time.Now(),
+		Reason:      reason,
+	}
+	if err := db.CreateFileVersion(v); err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := pruneFileVersions(context.WithoutCancel(ctx), v.Path); err != nil {
+			log.Warnf("failed prune versions of %s: %+v", v.Path, err)
+		}
+	}()
+	return v, nil
+}
+
+// recoverVersion moves a version kept by a failed write back, anything
+// left by the write is removed
+func recoverVersion(ctx context.Context, v *model.FileVersion) {
+	if err := restoreFileVersion(ctx, v, false, true); err != nil {
+		log.Errorf("failed recover %s from its version: %+v", v.Path, err)
+	}
+}
+
+// pruneFileVersions removes the versions of path exceeding the count or
+// the age to keep
+func pruneFileVersions(ctx context.Context, path string) error {
+	versions, err := db.GetFileVersions(path)
+	if err != nil {
+		return err
+	}
+	keep := GetSettingInt(conf.VersionsKeep, 10)
+	maxAge := time.Duration(GetSettingInt(conf.VersionsMaxAge, 30)) * 24 * time.Hour
+	for i := range versions {
+		v := &versions[i]
+		if (keep > 0 && i >= keep) || (maxAge > 0 && time.Since(v.Created) > maxAge) {
+			if err := DeleteFileVersion(ctx, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneExpiredFileVersions removes the versions older than the age to keep,
+// including the ones of the files never replaced or listed again
+func PruneExpiredFileVersions(ctx context.Context) error {
+	maxAge := time.Duration(GetSettingInt(conf.VersionsMaxAge, 30)) * 24 * time.Hour
+	if maxAge <= 0 {
+		return nil
+	}
+	versions, err := db.GetFileVersionsCreatedBefore(time.Now().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+	for i := range versions {
+		if err := DeleteFileVersion(ctx, &versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetFileVersionById(id uint) (*model.FileVersion, error) {
+	return db.GetFileVersionById(id)
+}
+
+// GetFileVersions returns the versions of the file at path, the newest first
+func GetFileVersions(ctx context.Context, path string) ([]model.FileVersion, error) {
+	path = utils.FixAndCleanPath(path)
+	if err := pruneFileVersions(ctx, path); err != nil {
+		log.Warnf("failed prune versions of %s: %+v", path, err)
+	}
+	return db.GetFileVersions(path)
+}
+
+// RestoreFileVersion moves a version back to the path of the file, the
+// current file is kept as a version as well if versioning is still enabled,
+// and removed otherwise if canRemove is set
+func RestoreFileVersion(ctx context.Context, v *model.FileVersion, canRemove bool) error {
+	return restoreFileVersion(ctx, v, true, canRemove)
+}
+
+func restoreFileVersion(ctx context.Context, v *model.FileVersion, keepCurrent, canRemove bool) error {
+	storage, actualPath, err := GetStorageAndActualPath(v.VersionPath)
+	if err != nil {
+		return err
+	}
+	dstStorage, dstPath, err := GetStorageAndActualPath(v.Path)
+	if err != nil {
+		return err
+	}
+	if storage.GetStorage().MountPath != dstStorage.GetStorage().MountPath {
+		return errors.New("the version is not in the storage of the file")
+	}
+	ctx = context.WithValue(ctx, conf.SkipHookKey, struct{}{})
+	if cur, err := GetUnwrap(ctx, storage, dstPath); err == nil {
+		var kept *model.FileVersion
+		if keepCurrent {
+			if kept, err = keepVersion(ctx, storage, dstPath, cur, VersionMove); err != nil {
+				return err
+			}
+		}
+		if kept == nil {
+			if !canRemove {
+				return errs.PermissionDenied
+			}
+			if err := Remove(ctx, storage, dstPath); err != nil {
+				return errors.WithMessage(err, "failed remove the current file")
+			}
+		}
+	}
+	if err := MakeDir(ctx, storage, stdpath.Dir(dstPath)); err != nil && !errs.IsObjectAlreadyExists(err) {
+		return err
+	}
+	if err := Move(ctx, storage, actualPath, stdpath.Dir(dstPath)); err != nil {
+		return err
+	}
+	if err := Remove(ctx, storage, stdpath.Dir(actualPath)); err != nil && !errs.IsObjectNotFound(err) {
+		log.Warnf("failed remove the version dir of %s: %+v", v.VersionPath, err)
+	}
+	return db.DeleteFileVersionById(v.ID)
+}
+
+// DeleteFileVersion removes a version with its content
+func DeleteFileVersion(ctx context.Context, v *model.FileVersion) error {
+	storage, actualPath, err := GetStorageAndActualPath(v.VersionPath)
+	if err == nil {
+		ctx = context.WithValue(ctx, conf.SkipHookKey, struct{}{})
+		err = Remove(ctx, storage, stdpath.Dir(actualPath))
+	}
+	if err != nil && !errs.IsNotFoundError(err) {
+		return err
+	}
+	return db.DeleteFileVersionById(v.ID)
+}
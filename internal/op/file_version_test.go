@@ -0,0 +1,181 @@
+package op_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+func versioningStorage(t *testing.T, mountPath string) (driver.Driver, string) {
+	dir := t.TempDir()
+	_, err := op.CreateStorage(context.Background(), model.Storage{
+		Driver:     "Local",
+		MountPath:  mountPath,
+		Versioning: true,
+		Addition:   fmt.Sprintf(`{"root_folder_path":%q}`, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed create storage: %+v", err)
+	}
+	storage, err := op.GetStorageByMountPath(mountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage, dir
+}
+
+func putText(storage driver.Driver, name string, r io.Reader, size int64) error {
+	return op.Put(context.Background(), storage, "/", &stream.FileStream{
+		Obj:    &model.Object{Name: name, Size: size, Modified: time.Now()},
+		Reader: r,
+	}, nil)
+}
+
+func readText(t *testing.T, path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFileVersionKeepAndRestore(t *testing.T) {
+	storage, dir := versioningStorage(t, "/versions_keep")
+	for _, content := range []string{"first", "second"} {
+		if err := putText(storage, "a.txt", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := op.GetFileVersions(context.Background(), "/versions_keep/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("got %d versions, want 1", len(versions))
+	}
+	if got := readText(t, filepath.Join(dir, "a.txt")); got != "second" {
+		t.Errorf("the file is %q, want %q", got, "second")
+	}
+
+	if err := op.RestoreFileVersion(context.Background(), &versions[0], false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readText(t, filepath.Join(dir, "a.txt")); got != "first" {
+		t.Errorf("the file restored is %q, want %q", got, "first")
+	}
+	// the file replaced by the restoration is kept in turn
+	versions, err = op.GetFileVersions(context.Background(), "/versions_keep/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Size != int64(len("second")) {
+		t.Errorf("got the versions %+v after the restoration", versions)
+	}
+}
+
+func TestFileVersionRestoreRemovesOnlyIfAllowed(t *testing.T) {
+	storage, dir := versioningStorage(t, "/versions_remove")
+	for _, content := range []string{"first", "second"} {
+		if err := putText(storage, "a.txt", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := op.GetFileVersions(context.Background(), "/versions_remove/a.txt")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("got the versions %+v, %v", versions, err)
+	}
+	// the current file is removed by the restoration once versioning is off
+	storage.GetStorage().Versioning = false
+
+	err = op.RestoreFileVersion(context.Background(), &versions[0], false)
+	if !errors.Is(err, errs.PermissionDenied) {
+		t.Errorf("the restoration removing the file is %v, want permission denied", err)
+	}
+	if got := readText(t, filepath.Join(dir, "a.txt")); got != "second" {
+		t.Errorf("the file is %q after the denied restoration, want %q", got, "second")
+	}
+	if err := op.RestoreFileVersion(context.Background(), &versions[0], true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readText(t, filepath.Join(dir, "a.txt")); got != "first" {
+		t.Errorf("the file restored is %q, want %q", got, "first")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("the upload is broken")
+}
+
+func TestFileVersionRecoveredOnFailedPut(t *testing.T) {
+	storage, dir := versioningStorage(t, "/versions_recover")
+	if err := putText(storage, "b.txt", strings.NewReader("kept"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := putText(storage, "b.txt", failingReader{}, 10); err == nil {
+		t.Fatal("the broken upload succeeds")
+	}
+	if got := readText(t, filepath.Join(dir, "b.txt")); got != "kept" {
+		t.Errorf("the file is %q after the failed upload, want %q", got, "kept")
+	}
+	versions, err := op.GetFileVersions(context.Background(), "/versions_recover/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 0 {
+		t.Errorf("got %d versions after the recovery, want 0", len(versions))
+	}
+}
+
+func TestFileVersionPrune(t *testing.T) {
+	storage, _ := versioningStorage(t, "/versions_prune")
+	// 10 versions are kept by default
+	for i := range 13 {
+		content := strings.Repeat("x", i+1)
+		if err := putText(storage, "c.txt", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := op.GetFileVersions(context.Background(), "/versions_prune/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 10 {
+		t.Errorf("got %d versions, want 10", len(versions))
+	}
+
+	// the versions expired are removed even if the file isn't touched again
+	old := &model.FileVersion{
+		Path:        "/versions_prune/d.txt",
+		VersionPath: "/versions_prune/.openlist_versions/0-expired/d.txt",
+		Size:        1,
+		Created:     time.Now().Add(-31 * 24 * time.Hour),
+		Reason:      op.VersionPut,
+	}
+	if err := db.CreateFileVersion(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := op.PruneExpiredFileVersions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.GetFileVersionById(old.ID); err == nil {
+		t.Errorf("the expired version is kept")
+	}
+	if versions, err = db.GetFileVersions("/versions_prune/c.txt"); err != nil || len(versions) != 10 {
+		t.Errorf("got %d versions not expired, %v", len(versions), err)
+	}
+}
@@ -397,6 +397,13 @@ func Move(ctx context.Context, storage driver.Driver, srcPath, dstDirPath string
 	if model.ObjHasMask(dstDir, model.NoWrite) {
 		return errors.WithStack(errs.PermissionDenied)
 	}
+	var version *model.FileVersion
+	dstPath := stdpath.Join(dstDirPath, srcRawObj.GetName())
+	if dstObj, err := GetUnwrap(ctx, storage, dstPath); err == nil {
+		if version, err = keepVersion(ctx, storage, dstPath, dstObj, VersionMove); err != nil {
+			return err
+		}
+	}
 
 	var newObj model.Obj
 	switch s := storage.(type) {
@@ -408,6 +415,9 @@ func Move(ctx context.Context, storage driver.Driver, srcPath, dstDirPath string
 		err = errs.NotImplement
 	}
 	if err != nil {
+		if version != nil {
+			recoverVersion(ctx, version)
+		}
 		return errors.WithStack(err)
 	}
 
@@ -462,6 +472,15 @@ func Rename(ctx context.Context, storage driver.Driver, srcPath, dstName string)
 	}
 	oldName := srcRawObj.GetName()
 	srcObj := model.UnwrapObjName(srcRawObj)
+	var version *model.FileVersion
+	if dstName != oldName {
+		dstPath := stdpath.Join(stdpath.Dir(srcPath), dstName)
+		if dstObj, err := GetUnwrap(ctx, storage, dstPath); err == nil {
+			if version, err = keepVersion(ctx, storage, dstPath, dstObj, VersionRename); err != nil {
+				return err
+			}
+		}
+	}
 
 	var newObj model.Obj
 	switch s := storage.(type) {
@@ -470,9 +489,12 @@ func Rename(ctx context.Context, storage driver.Driver, srcPath, dstName string)
 	case driver.Rename:
 		err = s.Rename(ctx, srcObj, dstName)
 	default:
-		return errs.NotImplement
+		err = errs.NotImplement
 	}
 	if err != nil {
+		if version != nil {
+			recoverVersion(ctx, version)
+		}
 		return errors.WithStack(err)
 	}
 
@@ -605,7 +627,7 @@ func Remove(ctx context.Context, storage driver.Driver, path string) error {
 	return errors.WithStack(err)
 }
 
-func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file model.FileStreamer, up driver.UpdateProgress) error {
+func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file model.FileStreamer, up driver.UpdateProgress) (err error) {
 	defer func() {
 		if err := file.Close(); err != nil {
 			log.Errorf("failed to close file streamer, %v", err)
@@ -626,6 +648,22 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 	tempName := file.GetName() + ".openlist_to_delete"
 	tempPath := stdpath.Join(dstDirPath, tempName)
 	fi, err := GetUnwrap(ctx, storage, dstPath)
+	var version *model.FileVersion
+	if err == nil && fi.GetSize() > 0 {
+		// the existing file is kept as a version instead of being overwritten
+		if version, err = keepVersion(ctx, storage, dstPath, fi, VersionPut); err != nil {
+			return err
+		}
+		if version != nil {
+			fi, err = nil, errs.ObjectNotFound
+			defer func() {
+				if err != nil {
+					// upload failed, recover the replaced file
+					recoverVersion(ctx, version)
+				}
+			}()
+		}
+	}
 	if err == nil {
 		if fi.GetSize() == 0 {
 			err = Remove(ctx, storage, dstPath)
@@ -0,0 +1,30 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetMediaMeta(path string) (*model.MediaMeta, error) {
+	return db.GetMediaMeta(path)
+}
+
+func SaveMediaMeta(m *model.MediaMeta) error {
+	return db.SaveMediaMeta(m)
+}
+
+// GetMediaMetaPaths returns the paths of the files whose cached media meta match filter
+func GetMediaMetaPaths(filter model.MediaMetaFilter) ([]string, error) {
+	return db.GetMediaMetaPaths(filter)
+}
+
+// GetPhotoCounts counts the cached images under dirs matching filter by their directory and month
+func GetPhotoCounts(dirs []string, filter model.MediaMetaFilter) ([]model.PhotoCount, error) {
+	return db.GetPhotoCounts(dirs, filter)
+}
+
+// GetPhotos returns a page of the cached images under dirs matching filter, except the
+// ones directly in the excluded dirs, of month if it's given, the newest first
+func GetPhotos(dirs []string, filter model.MediaMetaFilter, excluded []string, month string, offset, limit int) ([]model.MediaMeta, error) {
+	return db.GetPhotos(dirs, filter, excluded, month, offset, limit)
+}
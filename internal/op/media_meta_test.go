@@ -0,0 +1,82 @@
+package op_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestMediaMetasDirFollowsMove(t *testing.T) {
+	if err := op.SaveMediaMeta(&model.MediaMeta{Path: "/mm/a/b.jpg", Type: "image"}); err != nil {
+		t.Fatalf("failed save media meta: %+v", err)
+	}
+	op.MovePathData("/mm/a", "/mm/c")
+	photos, err := op.GetPhotos([]string{"/mm/c"}, model.MediaMetaFilter{}, nil, "", 0, 10)
+	if err != nil {
+		t.Fatalf("failed get photos: %+v", err)
+	}
+	if len(photos) != 1 || photos[0].Dir != "/mm/c" {
+		t.Errorf("the dir of the moved media meta is not updated, got %+v", photos)
+	}
+}
+
+func TestPhotosPagedInSQL(t *testing.T) {
+	day := func(d int) int64 {
+		return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC).UnixNano()
+	}
+	taken := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	for _, m := range []model.MediaMeta{
+		{Path: "/photos/a_b/1.jpg", Type: "image", Modified: day(1)},
+		{Path: "/photos/a_b/2.jpg", Type: "image", Modified: day(2)},
+		{Path: "/photos/a_b/sub/3.jpg", Type: "image", Modified: day(3)},
+		{Path: "/photos/a_b/4.jpg", Type: "image", Modified: day(4), TakenAt: &taken},
+		{Path: "/photos/a_b/5.mp3", Type: "audio", Modified: day(5)},
+		// '_' of the dir is not a wildcard
+		{Path: "/photos/axb/6.jpg", Type: "image", Modified: day(6)},
+	} {
+		if err := op.SaveMediaMeta(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts, err := op.GetPhotoCounts([]string{"/photos/a_b"}, model.MediaMetaFilter{})
+	if err != nil {
+		t.Fatalf("failed count photos: %+v", err)
+	}
+	got := make(map[string]int64)
+	for _, c := range counts {
+		got[c.Dir+" "+c.Month] = c.Count
+	}
+	want := map[string]int64{"/photos/a_b 2024-03": 2, "/photos/a_b/sub 2024-03": 1, "/photos/a_b 2024-04": 1}
+	if len(got) != len(want) {
+		t.Errorf("got the counts %v, want %v", got, want)
+	}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("got %d photos of %s, want %d", got[k], k, n)
+		}
+	}
+
+	paths := func(metas []model.MediaMeta) []string {
+		var ret []string
+		for _, m := range metas {
+			ret = append(ret, m.Path)
+		}
+		return ret
+	}
+	page, err := op.GetPhotos([]string{"/photos/a_b"}, model.MediaMetaFilter{}, nil, "2024-03", 1, 1)
+	if err != nil {
+		t.Fatalf("failed get photos: %+v", err)
+	}
+	if p := paths(page); len(p) != 1 || p[0] != "/photos/a_b/2.jpg" {
+		t.Errorf("got the page %v, want [/photos/a_b/2.jpg]", p)
+	}
+	page, err = op.GetPhotos([]string{"/photos/a_b"}, model.MediaMetaFilter{}, []string{"/photos/a_b/sub"}, "", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := paths(page); len(p) != 3 || p[0] != "/photos/a_b/4.jpg" {
+		t.Errorf("got the photos %v, want the 3 out of sub the newest first", p)
+	}
+}
@@ -0,0 +1,18 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetMirrorRepairs(storageID uint) ([]model.MirrorRepair, error) {
+	return db.GetMirrorRepairs(storageID)
+}
+
+func SaveMirrorRepair(r *model.MirrorRepair) error {
+	return db.SaveMirrorRepair(r)
+}
+
+func DeleteMirrorRepair(id uint) error {
+	return db.DeleteMirrorRepair(id)
+}
@@ -0,0 +1,95 @@
+package op
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cache"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+var (
+	nfsHandleIdCache   = cache.NewKeyedCache[uint64](time.Hour)
+	nfsHandlePathCache = cache.NewKeyedCache[string](time.Hour)
+)
+
+// GetNfsHandle returns the persisted NFS handle id of path
+func GetNfsHandle(path string) (uint64, error) {
+	path = utils.FixAndCleanPath(path)
+	if id, ok := nfsHandleIdCache.Get(path); ok {
+		return id, nil
+	}
+	h, err := db.GetOrCreateNfsHandle(path)
+	if err != nil {
+		return 0, err
+	}
+	nfsHandleIdCache.Set(path, h.ID)
+	nfsHandlePathCache.Set(strconv.FormatUint(h.ID, 10), path)
+	return h.ID, nil
+}
+
+// GetNfsHandles returns the persisted NFS handle ids of paths in their order,
+// the ones not cached are fetched or created in one batch
+func GetNfsHandles(paths []string) ([]uint64, error) {
+	ids := make([]uint64, len(paths))
+	cleaned := make([]string, len(paths))
+	var missing []string
+	for i, path := range paths {
+		cleaned[i] = utils.FixAndCleanPath(path)
+		if id, ok := nfsHandleIdCache.Get(cleaned[i]); ok {
+			ids[i] = id
+		} else {
+			missing = append(missing, cleaned[i])
+		}
+	}
+	if len(missing) == 0 {
+		return ids, nil
+	}
+	handles, err := db.GetOrCreateNfsHandles(missing)
+	if err != nil {
+		return nil, err
+	}
+	fetched := make(map[string]uint64, len(handles))
+	for _, h := range handles {
+		fetched[h.Path] = h.ID
+		nfsHandleIdCache.Set(h.Path, h.ID)
+		nfsHandlePathCache.Set(strconv.FormatUint(h.ID, 10), h.Path)
+	}
+	for i, path := range cleaned {
+		if ids[i] == 0 {
+			ids[i] = fetched[path]
+		}
+	}
+	return ids, nil
+}
+
+// GetNfsHandlePath returns the path an NFS handle id points to
+func GetNfsHandlePath(id uint64) (string, error) {
+	key := strconv.FormatUint(id, 10)
+	if path, ok := nfsHandlePathCache.Get(key); ok {
+		return path, nil
+	}
+	h, err := db.GetNfsHandleById(id)
+	if err != nil {
+		return "", err
+	}
+	nfsHandlePathCache.Set(key, h.Path)
+	nfsHandleIdCache.Set(h.Path, h.ID)
+	return h.Path, nil
+}
+
+func clearNfsHandleCache() {
+	nfsHandleIdCache.Clear()
+	nfsHandlePathCache.Clear()
+}
+
+// PruneNfsHandles deletes the NFS handles unused for the duration given, the
+// clients holding them get stale handles and look the paths up again
+func PruneNfsHandles(unused time.Duration) error {
+	n, err := db.DeleteNfsHandlesUnusedSince(time.Now().Add(-unused))
+	if n > 0 {
+		clearNfsHandleCache()
+	}
+	return err
+}
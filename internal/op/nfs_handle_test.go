@@ -0,0 +1,63 @@
+package op_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestNfsHandlesKeptOnMove(t *testing.T) {
+	handle := func(path string) uint64 {
+		id, err := op.GetNfsHandle(path)
+		if err != nil {
+			t.Fatalf("failed get nfs handle of %s: %+v", path, err)
+		}
+		return id
+	}
+	dir := handle("/nfs/a")
+	if handle("/nfs/a/") != dir {
+		t.Errorf("the same path should get the same handle")
+	}
+
+	// the cached handles must not outlive the move
+	op.MovePathData("/nfs/a", "/nfs/c")
+	if handle("/nfs/c") != dir {
+		t.Errorf("handle of the moved dir should be kept")
+	}
+	if handle("/nfs/a") == dir {
+		t.Errorf("a new object at the source path should get a new handle")
+	}
+
+	op.DeletePathData("/nfs/c")
+	if handle("/nfs/c") == dir {
+		t.Errorf("a new object at a removed path should get a new handle")
+	}
+}
+
+func TestNfsHandlesBatchAndPrune(t *testing.T) {
+	single, err := op.GetNfsHandle("/nfs_batch/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := op.GetNfsHandles([]string{"/nfs_batch/b", "/nfs_batch/a/", "/nfs_batch/b"})
+	if err != nil {
+		t.Fatalf("failed get nfs handles: %+v", err)
+	}
+	if ids[1] != single || ids[0] != ids[2] || ids[0] == 0 || ids[0] == single {
+		t.Errorf("got the handles %v, want [b %d b]", ids, single)
+	}
+
+	if err := op.PruneNfsHandles(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := op.GetNfsHandlePath(single); err != nil || path != "/nfs_batch/a" {
+		t.Errorf("the handle used recently is pruned: %s, %v", path, err)
+	}
+	if err := op.PruneNfsHandles(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.GetNfsHandlePath(single); err == nil {
+		t.Errorf("the handle unused is kept")
+	}
+}
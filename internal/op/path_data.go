@@ -0,0 +1,51 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// pathData is the data kept by the path of an object, which follows the
+// object when it's moved or renamed and goes with it when it's removed
+var pathData = []struct {
+	name   string
+	move   func(srcPath, dstPath string) error
+	delete func(path string) error
+}{
+	{"dead props", db.MoveDeadProps, db.DeleteDeadProps},
+	{"nfs handles", func(srcPath, dstPath string) error {
+		defer clearNfsHandleCache()
+		return db.MoveNfsHandles(srcPath, dstPath)
+	}, func(path string) error {
+		defer clearNfsHandleCache()
+		return db.DeleteNfsHandles(path)
+	}},
+	{"media meta", db.MoveMediaMetas, db.DeleteMediaMetas},
+	{"text versions", db.MoveTextVersions, db.DeleteTextVersions},
+}
+
+// MovePathData makes the data kept by the path of an object follow it after
+// it has been moved or renamed to dstPath, the failures are only logged
+func MovePathData(srcPath, dstPath string) {
+	srcPath, dstPath = utils.FixAndCleanPath(srcPath), utils.FixAndCleanPath(dstPath)
+	if srcPath == dstPath {
+		return
+	}
+	for _, d := range pathData {
+		if err := d.move(srcPath, dstPath); err != nil {
+			log.Warnf("failed move %s of %s: %+v", d.name, srcPath, err)
+		}
+	}
+}
+
+// DeletePathData deletes the data kept by the path of a removed object and
+// of its children, the failures are only logged
+func DeletePathData(path string) {
+	path = utils.FixAndCleanPath(path)
+	for _, d := range pathData {
+		if err := d.delete(path); err != nil {
+			log.Warnf("failed delete %s of %s: %+v", d.name, path, err)
+		}
+	}
+}
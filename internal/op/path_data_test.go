@@ -0,0 +1,94 @@
+package op_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestPathDataFollowPath(t *testing.T) {
+	var handles []uint64
+	tests := []struct {
+		name  string
+		put   func(path string) error
+		count func(path string) int
+	}{
+		{
+			name: "dead props",
+			put: func(path string) error {
+				return op.PatchDeadProps(path, []model.DeadProp{{Space: "urn:test", Local: "color"}}, nil)
+			},
+			count: func(path string) int {
+				props, _ := op.GetDeadProps(path)
+				return len(props)
+			},
+		},
+		{
+			name: "nfs handles",
+			put: func(path string) error {
+				id, err := op.GetNfsHandle(path)
+				handles = append(handles, id)
+				return err
+			},
+			// the handles given before pointing to path
+			count: func(path string) int {
+				n := 0
+				for _, id := range handles {
+					if p, err := op.GetNfsHandlePath(id); err == nil && p == path {
+						n++
+					}
+				}
+				return n
+			},
+		},
+		{
+			name: "media meta",
+			put: func(path string) error {
+				return op.SaveMediaMeta(&model.MediaMeta{Path: path, Type: "image"})
+			},
+			count: func(path string) int {
+				if _, err := op.GetMediaMeta(path); err != nil {
+					return 0
+				}
+				return 1
+			},
+		},
+		{
+			name: "text versions",
+			put: func(path string) error {
+				return op.CreateTextVersion(&model.TextVersion{Path: path, Content: []byte("old"), Size: 3, Created: time.Now()})
+			},
+			count: func(path string) int {
+				versions, _ := op.GetTextVersions(path)
+				return len(versions)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := "/path_data/" + tt.name
+			// c is replaced by the move of a
+			for _, p := range []string{"/a", "/a/b.txt", "/a_b", "/c/b.txt", "/c/d.txt"} {
+				if err := tt.put(base + p); err != nil {
+					t.Fatalf("failed put %s of %s: %+v", tt.name, base+p, err)
+				}
+			}
+			check := func(want map[string]int) {
+				t.Helper()
+				for p, n := range want {
+					if got := tt.count(base + p); got != n {
+						t.Errorf("got %d %s at %s, want %d", got, tt.name, p, n)
+					}
+				}
+			}
+
+			op.MovePathData(base+"/a", base+"/c")
+			check(map[string]int{"/a": 0, "/a/b.txt": 0, "/c": 1, "/c/b.txt": 1, "/c/d.txt": 0, "/a_b": 1})
+
+			op.DeletePathData(base + "/c")
+			check(map[string]int{"/c": 0, "/c/b.txt": 0, "/a_b": 1})
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetPhotoAlbumById(id uint) (*model.PhotoAlbum, error) {
+	return db.GetPhotoAlbumById(id)
+}
+
+func GetPhotoAlbumsByCreatorId(creator uint) ([]model.PhotoAlbum, error) {
+	return db.GetPhotoAlbumsByCreatorId(creator)
+}
+
+func CreatePhotoAlbum(a *model.PhotoAlbum) error {
+	return db.CreatePhotoAlbum(a)
+}
+
+func UpdatePhotoAlbum(a *model.PhotoAlbum) error {
+	return db.UpdatePhotoAlbum(a)
+}
+
+func DeletePhotoAlbum(id uint) error {
+	return db.DeletePhotoAlbumById(id)
+}
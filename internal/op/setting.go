@@ -83,6 +83,20 @@ func GetPublicSettingItems() ([]model.SettingItem, error) {
 	return items, err
 }
 
+// GetSettingInt returns the int value of the setting of key, or defaultVal
+// if it's not set or not an int
+func GetSettingInt(key string, defaultVal int) int {
+	item, _ := GetSettingItemByKey(key)
+	if item == nil {
+		return defaultVal
+	}
+	i, err := strconv.Atoi(item.Value)
+	if err != nil {
+		return defaultVal
+	}
+	return i
+}
+
 func GetSettingItemByKey(key string) (*model.SettingItem, error) {
 	if item, exists := Cache.GetSetting(key); exists {
 		return item, nil
@@ -148,12 +148,18 @@ func UpdateSharingId(sharing *model.Sharing, newId string) error {
 	if err := db.UpdateSharingId(sharing.ID, newId); err != nil {
 		return err
 	}
+	if err := db.UpdateSharingAccessesId(sharing.ID, newId); err != nil {
+		return err
+	}
 	sharing.ID = newId
 	return nil
 }
 
 func DeleteSharing(sid string) error {
 	sharingCache.Del(sid)
+	if err := db.DeleteSharingAccesses(sid); err != nil {
+		return err
+	}
 	return db.DeleteSharingById(sid)
 }
 
@@ -0,0 +1,74 @@
+package op
+
+import (
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+// sharingDownloadLock makes the check of the per-IP download limit of the
+// shares and the record of the download a single step
+var sharingDownloadLock sync.Mutex
+
+func CreateSharingAccess(a *model.SharingAccess) error {
+	return db.CreateSharingAccess(a)
+}
+
+func GetSharingAccesses(sid string, pageIndex, pageSize int) ([]model.SharingAccess, int64, error) {
+	return db.GetSharingAccesses(sid, pageIndex, pageSize)
+}
+
+func GetSharingAccessStats(sid string) (*model.SharingAccessStats, error) {
+	return db.GetSharingAccessStats(sid)
+}
+
+// CountSharingDownloads counts the files downloaded by ip through a share
+func CountSharingDownloads(sid, ip string) (int64, error) {
+	return db.CountSharingDownloads(sid, ip)
+}
+
+func HasSharingDownload(sid, ip, path string) (bool, error) {
+	return db.HasSharingDownload(sid, ip, path)
+}
+
+// CheckSharingDownload checks the download of path by ip through a share
+// against its per-IP limit of files, a file the ip has downloaded before is
+// let through, which it reports
+func CheckSharingDownload(sid, ip, path string, limit int) (bool, error) {
+	downloaded, err := db.HasSharingDownload(sid, ip, path)
+	if err != nil || downloaded || limit <= 0 {
+		return downloaded, err
+	}
+	count, err := db.CountSharingDownloads(sid, ip)
+	if err != nil {
+		return false, err
+	}
+	if count >= int64(limit) {
+		return false, errs.SharingDownLimit
+	}
+	return false, nil
+}
+
+// CreateSharingDownload records the download a through a share once it is
+// checked against the per-IP limit of the share, so that the downloads
+// started together can't pass the limit
+func CreateSharingDownload(a *model.SharingAccess, limit int) error {
+	sharingDownloadLock.Lock()
+	defer sharingDownloadLock.Unlock()
+	downloaded, err := CheckSharingDownload(a.SharingID, a.IP, a.Path, limit)
+	if err != nil {
+		return err
+	}
+	a.Partial = downloaded
+	return db.CreateSharingAccess(a)
+}
+
+func UpdateSharingAccessBytes(id uint, bytes int64) error {
+	return db.UpdateSharingAccessBytes(id, bytes)
+}
+
+func DeleteSharingAccess(id uint) error {
+	return db.DeleteSharingAccess(id)
+}
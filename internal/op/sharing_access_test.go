@@ -0,0 +1,78 @@
+package op_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestCountSharingDownloads(t *testing.T) {
+	for _, a := range []model.SharingAccess{
+		{SharingID: "count", IP: "1.1.1.1", Path: "/a"},
+		{SharingID: "count", IP: "1.1.1.1", Path: "/a", Partial: true},
+		{SharingID: "count", IP: "1.1.1.1", Path: "/b"},
+		{SharingID: "count", IP: "1.1.1.1", Path: "/c", Upload: true},
+		{SharingID: "count", IP: "2.2.2.2", Path: "/d"},
+	} {
+		a.Time = time.Now()
+		if err := op.CreateSharingAccess(&a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, err := op.CountSharingDownloads("count", "1.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("counted %d files downloaded, want 2", count)
+	}
+	for path, want := range map[string]bool{"/a": true, "/c": false, "/d": false} {
+		got, err := op.HasSharingDownload("count", "1.1.1.1", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("the download of %s is %t, want %t", path, got, want)
+		}
+	}
+}
+
+func TestCreateSharingDownloadUnderLimit(t *testing.T) {
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := &model.SharingAccess{SharingID: "limit", IP: "1.1.1.1", Path: fmt.Sprintf("/%d", i), Time: time.Now()}
+			err := op.CreateSharingDownload(a, 3)
+			if err == nil {
+				created.Add(1)
+			} else if !errors.Is(err, errs.SharingDownLimit) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := created.Load(); n != 3 {
+		t.Errorf("%d downloads started together passed the limit of 3", n)
+	}
+	for i := range 10 {
+		path := fmt.Sprintf("/%d", i)
+		if downloaded, _ := op.HasSharingDownload("limit", "1.1.1.1", path); !downloaded {
+			continue
+		}
+		a := &model.SharingAccess{SharingID: "limit", IP: "1.1.1.1", Path: path, Time: time.Now()}
+		if err := op.CreateSharingDownload(a, 3); err != nil || !a.Partial {
+			t.Errorf("a file downloaded again should pass the limit as partial, got %v, %t", err, a.Partial)
+		}
+		break
+	}
+}
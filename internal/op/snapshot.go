@@ -0,0 +1,26 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetSnapshots(sourcePath string) ([]model.Snapshot, error) {
+	return db.GetSnapshots(sourcePath)
+}
+
+func DeleteSnapshot(id uint) error {
+	return db.DeleteSnapshot(id)
+}
+
+func GetSnapshotEntry(snapshotID uint, parent, name string) (*model.SnapshotEntry, error) {
+	return db.GetSnapshotEntry(snapshotID, parent, name)
+}
+
+func GetSnapshotChildren(snapshotID uint, parent string) ([]model.SnapshotEntry, error) {
+	return db.GetSnapshotChildren(snapshotID, parent)
+}
+
+func GetSnapshotFilesUnder(snapshotID uint, dir string) ([]model.SnapshotEntry, error) {
+	return db.GetSnapshotFilesUnder(snapshotID, dir)
+}
@@ -0,0 +1,43 @@
+package op_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+func TestSnapshotFilesUnder(t *testing.T) {
+	s := &model.Snapshot{SourcePath: "/src", Created: time.Now(), Done: true}
+	if err := db.CreateSnapshot(s); err != nil {
+		t.Fatal(err)
+	}
+	entries := []model.SnapshotEntry{
+		{SnapshotID: s.ID, Parent: "/", Name: "a_b", IsDir: true},
+		{SnapshotID: s.ID, Parent: "/", Name: "axb", IsDir: true},
+		{SnapshotID: s.ID, Parent: "/a_b", Name: "1.txt", Size: 1},
+		{SnapshotID: s.ID, Parent: "/a_b/c", Name: "2.txt", Size: 2},
+		{SnapshotID: s.ID, Parent: "/axb", Name: "3.txt", Size: 3},
+	}
+	if err := db.CreateSnapshotEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	files, err := op.GetSnapshotFilesUnder(s.ID, "/a_b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("got %d files under /a_b", len(files))
+	}
+	if files, err = op.GetSnapshotFilesUnder(s.ID, "/"); err != nil || len(files) != 3 {
+		t.Errorf("got %d files under /, %v", len(files), err)
+	}
+	if err := op.DeleteSnapshot(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := op.GetSnapshotChildren(s.ID, "/"); err != nil || len(children) != 0 {
+		t.Errorf("the entries of the snapshot deleted are kept: %d, %v", len(children), err)
+	}
+}
@@ -0,0 +1,22 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func GetTextVersionById(id uint) (*model.TextVersion, error) {
+	return db.GetTextVersionById(id)
+}
+
+func GetTextVersions(path string) ([]model.TextVersion, error) {
+	return db.GetTextVersions(path)
+}
+
+func CreateTextVersion(v *model.TextVersion) error {
+	return db.CreateTextVersion(v)
+}
+
+func PruneTextVersions(path string, keep int) error {
+	return db.PruneTextVersions(path, keep)
+}
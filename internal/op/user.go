@@ -82,6 +82,9 @@ func DeleteUserById(id uint) error {
 	if err := DeleteSharingsByCreatorId(id); err != nil {
 		return errors.WithMessage(err, "failed to delete user's sharings")
 	}
+	if err := db.DeletePhotoAlbumsByCreatorId(id); err != nil {
+		return errors.WithMessage(err, "failed to delete user's photo albums")
+	}
 	return db.DeleteUserById(id)
 }
 
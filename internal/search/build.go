@@ -12,6 +12,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/media"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/search/searcher"
@@ -147,6 +148,8 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 			IsDone:   false,
 		})
 	}
+	walkCtx := context.WithValue(ctx, conf.UserKey, admin)
+	withMedia := setting.GetBool(conf.MediaMetaIndex)
 	for _, indexPath := range indexPaths {
 		walkFn := func(indexPath string, info model.Obj) error {
 			if !running.Load() {
@@ -166,6 +169,11 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 			if indexPath == "/" {
 				return nil
 			}
+			if withMedia && !info.IsDir() && media.Type(info.GetName()) != "" {
+				if _, err := media.Get(walkCtx, indexPath); err != nil {
+					log.Debugf("failed get media meta of %s: %+v", indexPath, err)
+				}
+			}
 			indexMQ.Publish(mq.Message[ObjWithParent]{
 				Content: ObjWithParent{
 					Obj:    info,
@@ -179,7 +187,7 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 			return err
 		}
 		// TODO: run walkFS concurrently
-		err = fs.WalkFS(context.WithValue(ctx, conf.UserKey, admin), maxDepth, indexPath, fi, walkFn)
+		err = fs.WalkFS(walkCtx, maxDepth, indexPath, fi, walkFn)
 		if err != nil {
 			return err
 		}
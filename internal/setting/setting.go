@@ -18,11 +18,7 @@ func GetStr(key string, defaultValue ...string) string {
 }
 
 func GetInt(key string, defaultVal int) int {
-	i, err := strconv.Atoi(GetStr(key))
-	if err != nil {
-		return defaultVal
-	}
-	return i
+	return op.GetSettingInt(key, defaultVal)
 }
 
 func GetBool(key string) bool {
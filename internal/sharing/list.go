@@ -23,6 +23,13 @@ func list(ctx context.Context, sid, path string, args model.SharingListArgs) (*m
 		return sharing, nil, errors.WithStack(errs.WrongShareCode)
 	}
 	path = utils.FixAndCleanPath(path)
+	if sharing.Upload {
+		// the content of an upload share is not disclosed
+		if path != "/" {
+			return sharing, nil, errors.WithStack(errs.UploadOnlyShare)
+		}
+		return sharing, []model.Obj{}, nil
+	}
 	if len(sharing.Files) == 1 || path != "/" {
 		unwrapPath, err := op.GetSharingUnwrapPath(sharing, path)
 		if err != nil {
@@ -0,0 +1,41 @@
+package sign
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/sign"
+)
+
+var onceHLS sync.Once
+var instanceHLS sign.Sign
+
+func SignHLS(data string) string {
+	expire := setting.GetInt(conf.LinkExpiration, 0)
+	if expire == 0 {
+		return NotExpiredHLS(data)
+	} else {
+		return WithDurationHLS(data, time.Duration(expire)*time.Hour)
+	}
+}
+
+func WithDurationHLS(data string, d time.Duration) string {
+	onceHLS.Do(InstanceHLS)
+	return instanceHLS.Sign(data, time.Now().Add(d).Unix())
+}
+
+func NotExpiredHLS(data string) string {
+	onceHLS.Do(InstanceHLS)
+	return instanceHLS.Sign(data, 0)
+}
+
+func VerifyHLS(data string, sign string) error {
+	onceHLS.Do(InstanceHLS)
+	return instanceHLS.Verify(data, sign)
+}
+
+func InstanceHLS() {
+	instanceHLS = sign.NewHMACSign([]byte(setting.GetStr(conf.Token) + "-hls"))
+}
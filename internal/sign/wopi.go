@@ -0,0 +1,41 @@
+package sign
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/sign"
+)
+
+var onceWOPI sync.Once
+var instanceWOPI sign.Sign
+
+// WOPIExpiration is how long the access tokens of the office editors are
+// valid for, they always expire
+func WOPIExpiration() time.Duration {
+	expire := setting.GetInt(conf.WOPITokenExpiration, 60)
+	if expire <= 0 {
+		expire = 60
+	}
+	return time.Duration(expire) * time.Minute
+}
+
+func SignWOPI(data string) string {
+	return WithDurationWOPI(data, WOPIExpiration())
+}
+
+func WithDurationWOPI(data string, d time.Duration) string {
+	onceWOPI.Do(InstanceWOPI)
+	return instanceWOPI.Sign(data, time.Now().Add(d).Unix())
+}
+
+func VerifyWOPI(data string, sign string) error {
+	onceWOPI.Do(InstanceWOPI)
+	return instanceWOPI.Verify(data, sign)
+}
+
+func InstanceWOPI() {
+	instanceWOPI = sign.NewHMACSign([]byte(setting.GetStr(conf.Token) + "-wopi"))
+}
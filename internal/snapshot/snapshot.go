@@ -0,0 +1,189 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// a snapshot records the listing of the tree of a source path, so that the
+// files can be found as they were, either live if they are unchanged, or in
+// the versions kept when they were replaced
+
+const batchSize = 1000
+
+// the states of the files recorded by a snapshot
+const (
+	StateLive    = "live"
+	StateVersion = "version"
+	StateLost    = "lost"
+)
+
+// taking keeps the source paths being taken, so that a source path is taken
+// once at a time
+var (
+	taking   = make(map[string]struct{})
+	takingMu sync.Mutex
+)
+
+type taker struct {
+	ctx     context.Context
+	s       *model.Snapshot
+	entries []model.SnapshotEntry
+}
+
+// Take records the tree of the source path as a new snapshot
+func Take(ctx context.Context, sourcePath string) (*model.Snapshot, error) {
+	sourcePath = utils.FixAndCleanPath(sourcePath)
+	takingMu.Lock()
+	if _, ok := taking[sourcePath]; ok {
+		takingMu.Unlock()
+		return nil, fmt.Errorf("a snapshot of %s is being taken", sourcePath)
+	}
+	taking[sourcePath] = struct{}{}
+	takingMu.Unlock()
+	defer func() {
+		takingMu.Lock()
+		delete(taking, sourcePath)
+		takingMu.Unlock()
+	}()
+
+	t := &taker{ctx: ctx, s: &model.Snapshot{SourcePath: sourcePath, Created: time.Now()}}
+	if err := db.CreateSnapshot(t.s); err != nil {
+		return nil, err
+	}
+	err := t.walk(sourcePath, "/")
+	if err == nil {
+		err = t.flush()
+	}
+	if err == nil {
+		t.s.Done = true
+		err = db.UpdateSnapshot(t.s)
+	}
+	if err != nil {
+		if e := db.DeleteSnapshot(t.s.ID); e != nil {
+			log.Errorf("failed delete the snapshot %d: %+v", t.s.ID, e)
+		}
+		return nil, err
+	}
+	return t.s, nil
+}
+
+func (t *taker) walk(path, rel string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	objs, err := fs.List(t.ctx, path, &fs.ListArgs{NoLog: true, Refresh: true})
+	if err != nil {
+		log.Warnf("snapshot of %s: failed list %s: %+v", t.s.SourcePath, path, err)
+		t.s.Errors++
+		return nil
+	}
+	var dirs []string
+	for _, obj := range objs {
+		if rel == "/" && obj.GetName() == op.VersionsDir {
+			continue
+		}
+		t.entries = append(t.entries, model.SnapshotEntry{
+			SnapshotID: t.s.ID,
+			Parent:     rel,
+			Name:       obj.GetName(),
+			IsDir:      obj.IsDir(),
+			Size:       obj.GetSize(),
+			Modified:   obj.ModTime(),
+			ObjID:      obj.GetID(),
+			Hash:       obj.GetHash().String(),
+		})
+		if obj.IsDir() {
+			t.s.Dirs++
+			dirs = append(dirs, obj.GetName())
+		} else {
+			t.s.Files++
+			t.s.Size += obj.GetSize()
+		}
+	}
+	if len(t.entries) >= batchSize {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+	for _, name := range dirs {
+		if err := t.walk(stdpath.Join(path, name), stdpath.Join(rel, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *taker) flush() error {
+	err := db.CreateSnapshotEntries(t.entries)
+	t.entries = t.entries[:0]
+	return err
+}
+
+// Prune deletes the snapshots of the source path but the latest keep ones,
+// and the ones left unfinished before the latest
+func Prune(sourcePath string, keep int) error {
+	snapshots, err := db.GetSnapshots(utils.FixAndCleanPath(sourcePath))
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, s := range snapshots {
+		if !s.Done && kept == 0 {
+			continue
+		}
+		if s.Done && kept < keep {
+			kept++
+			continue
+		}
+		if err := db.DeleteSnapshot(s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve finds the file recorded by a snapshot, it returns the path of the
+// live file if it is unchanged, or of a version of it with the same size and
+// modified time. The path is empty if the file is lost.
+func Resolve(ctx context.Context, s *model.Snapshot, e *model.SnapshotEntry) (string, string) {
+	path := stdpath.Join(s.SourcePath, e.Parent, e.Name)
+	if obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true}); err == nil && unchanged(obj, e) {
+		return path, StateLive
+	}
+	// op.GetFileVersions would prune the versions, so they are read directly
+	versions, err := db.GetFileVersions(path)
+	if err != nil {
+		log.Warnf("failed get the versions of %s: %+v", path, err)
+	}
+	for _, v := range versions {
+		if v.Size == e.Size && v.Modified.Unix() == e.Modified.Unix() {
+			return v.VersionPath, StateVersion
+		}
+	}
+	return "", StateLost
+}
+
+func unchanged(obj model.Obj, e *model.SnapshotEntry) bool {
+	if obj.IsDir() || obj.GetSize() != e.Size || obj.ModTime().Unix() != e.Modified.Unix() {
+		return false
+	}
+	// the hashes are compared only if both of them are known
+	recorded := utils.FromString(e.Hash)
+	for ht, h := range obj.GetHash().All() {
+		if r := recorded.GetHash(ht); r != "" && h != "" && r != h {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,414 @@
+package stream
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlockCache caches fixed-size blocks of remote files on the local disk,
+// so that seeking in a proxied file doesn't hit the upstream again.
+// Blocks are evicted in least recently used order once the total size
+// exceeds maxSize.
+type BlockCache struct {
+	dir       string
+	maxSize   int64
+	blockSize int64
+
+	mu     sync.Mutex
+	lru    *list.List // of *cachedBlock, most recently used at the front
+	blocks map[string]*list.Element
+	size   int64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	hitBytes  atomic.Int64
+	missBytes atomic.Int64
+	evictions atomic.Int64
+}
+
+type cachedBlock struct {
+	name string
+	size int64
+}
+
+type BlockCacheStats struct {
+	Dir       string `json:"dir"`
+	BlockSize int64  `json:"block_size"`
+	MaxSize   int64  `json:"max_size"`
+	Size      int64  `json:"size"`
+	Blocks    int    `json:"blocks"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	HitBytes  int64  `json:"hit_bytes"`
+	MissBytes int64  `json:"miss_bytes"`
+	Evictions int64  `json:"evictions"`
+}
+
+// NewBlockCache opens the block cache in dir, picking up the blocks
+// cached by a previous run
+func NewBlockCache(dir string, maxSize, blockSize int64) (*BlockCache, error) {
+	if maxSize <= 0 || blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block cache size %d/%d", maxSize, blockSize)
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	c := &BlockCache{
+		dir:       dir,
+		maxSize:   maxSize,
+		blockSize: blockSize,
+		lru:       list.New(),
+		blocks:    make(map[string]*list.Element),
+	}
+	type found struct {
+		cachedBlock
+		modTime time.Time
+	}
+	var blocks []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			_ = os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		blocks = append(blocks, found{cachedBlock{name: filepath.ToSlash(name), size: info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].modTime.After(blocks[j].modTime)
+	})
+	for i := range blocks {
+		b := blocks[i].cachedBlock
+		c.blocks[b.name] = c.lru.PushBack(&b)
+		c.size += b.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// BlockCacheKey identifies the content of a file by its path, which
+// includes the mount path of its storage, its size and modified time
+func BlockCacheKey(path string, size int64, modified time.Time) string {
+	h := sha1.Sum([]byte(path + "\x00" + strconv.FormatInt(size, 10) + "\x00" + strconv.FormatInt(modified.UnixNano(), 10)))
+	return hex.EncodeToString(h[:])
+}
+
+func (c *BlockCache) blockName(key string, index int64) string {
+	return key[:2] + "/" + key + "-" + strconv.FormatInt(index, 10)
+}
+
+func (c *BlockCache) load(name string, length int64, buf []byte) bool {
+	c.mu.Lock()
+	_, ok := c.blocks[name]
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	path := filepath.Join(c.dir, filepath.FromSlash(name))
+	f, err := os.Open(path)
+	if err == nil {
+		_, err = io.ReadFull(f, buf[:length])
+		_ = f.Close()
+	}
+	if err != nil {
+		log.Warnf("failed read cached block %s: %+v", name, err)
+		c.remove(name)
+		return false
+	}
+	c.mu.Lock()
+	if e, ok := c.blocks[name]; ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+func (c *BlockCache) store(name string, data []byte) {
+	c.mu.Lock()
+	_, ok := c.blocks[name]
+	c.mu.Unlock()
+	if ok {
+		return
+	}
+	path := filepath.Join(c.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		log.Warnf("failed create block cache dir: %+v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		log.Warnf("failed create cached block %s: %+v", name, err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		log.Warnf("failed write cached block %s: %+v", name, err)
+		return
+	}
+	c.mu.Lock()
+	if _, ok := c.blocks[name]; !ok {
+		c.blocks[name] = c.lru.PushFront(&cachedBlock{name: name, size: int64(len(data))})
+		c.size += int64(len(data))
+	}
+	c.mu.Unlock()
+	c.evict()
+}
+
+func (c *BlockCache) remove(name string) {
+	c.mu.Lock()
+	if e, ok := c.blocks[name]; ok {
+		c.size -= e.Value.(*cachedBlock).size
+		c.lru.Remove(e)
+		delete(c.blocks, name)
+	}
+	c.mu.Unlock()
+	_ = os.Remove(filepath.Join(c.dir, filepath.FromSlash(name)))
+}
+
+func (c *BlockCache) evict() {
+	var evicted []string
+	c.mu.Lock()
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		b := c.lru.Remove(c.lru.Back()).(*cachedBlock)
+		delete(c.blocks, b.name)
+		c.size -= b.size
+		evicted = append(evicted, b.name)
+	}
+	c.mu.Unlock()
+	for _, name := range evicted {
+		_ = os.Remove(filepath.Join(c.dir, filepath.FromSlash(name)))
+	}
+	c.evictions.Add(int64(len(evicted)))
+}
+
+// Clear removes all the cached blocks. The blocks are dropped from the index
+// at once and their files are removed afterwards, so that the reads and
+// writes of the cache aren't blocked meanwhile.
+func (c *BlockCache) Clear() error {
+	c.mu.Lock()
+	blocks := c.blocks
+	c.lru.Init()
+	c.blocks = make(map[string]*list.Element)
+	c.size = 0
+	c.mu.Unlock()
+	var err error
+	for name := range blocks {
+		e := os.Remove(filepath.Join(c.dir, filepath.FromSlash(name)))
+		if e != nil && !errors.Is(e, os.ErrNotExist) && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *BlockCache) Stats() BlockCacheStats {
+	c.mu.Lock()
+	size, blocks := c.size, c.lru.Len()
+	c.mu.Unlock()
+	return BlockCacheStats{
+		Dir:       c.dir,
+		BlockSize: c.blockSize,
+		MaxSize:   c.maxSize,
+		Size:      size,
+		Blocks:    blocks,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		HitBytes:  c.hitBytes.Load(),
+		MissBytes: c.missBytes.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// CacheLink returns a link reading the file identified by key through the
+// cache. The link is returned as is if it reads a local file. The original
+// link must still be closed by the caller.
+func (c *BlockCache) CacheLink(key string, size int64, link *model.Link) *model.Link {
+	if size <= 0 {
+		return link
+	}
+	rr, err := GetRangeReaderFromLink(size, link)
+	if err != nil {
+		return link
+	}
+	if _, ok := rr.(*model.FileRangeReader); ok {
+		return link
+	}
+	return &model.Link{
+		Header: link.Header,
+		RangeReader: RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+			if httpRange.Length < 0 || httpRange.Start+httpRange.Length > size {
+				httpRange.Length = size - httpRange.Start
+			}
+			if httpRange.Start < 0 || httpRange.Length < 0 {
+				return nil, fmt.Errorf("invalid range %d-%d of size %d", httpRange.Start, httpRange.Length, size)
+			}
+			return &blockReader{
+				c:    c,
+				ctx:  ctx,
+				key:  key,
+				size: size,
+				rr:   rr,
+				pos:  httpRange.Start,
+				end:  httpRange.Start + httpRange.Length,
+			}, nil
+		}),
+		ContentLength: size,
+	}
+}
+
+// blockReader reads a range block by block, from the cache when a block is
+// cached and otherwise from a single upstream request which lasts until the
+// next cached block
+type blockReader struct {
+	c    *BlockCache
+	ctx  context.Context
+	key  string
+	size int64
+	rr   model.RangeReaderIF
+
+	pos, end int64
+	buf      []byte
+	data     []byte // the unread part of the current block
+
+	upstream io.ReadCloser
+	upPos    int64
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		if r.pos >= r.end {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *blockReader) next() error {
+	bs := r.c.blockSize
+	index := r.pos / bs
+	start := index * bs
+	length := min(bs, r.size-start)
+	if r.buf == nil {
+		r.buf = make([]byte, bs)
+	}
+	name := r.c.blockName(r.key, index)
+	if r.c.load(name, length, r.buf) {
+		r.c.hits.Add(1)
+		r.c.hitBytes.Add(length)
+		r.closeUpstream()
+	} else {
+		if r.upstream == nil || r.upPos != start {
+			r.closeUpstream()
+			end := min(r.size, ((r.end-1)/bs+1)*bs)
+			rc, err := r.rr.RangeRead(r.ctx, http_range.Range{Start: start, Length: end - start})
+			if err != nil {
+				return err
+			}
+			r.upstream, r.upPos = rc, start
+		}
+		if _, err := io.ReadFull(r.upstream, r.buf[:length]); err != nil {
+			r.closeUpstream()
+			return err
+		}
+		r.upPos += length
+		r.c.misses.Add(1)
+		r.c.missBytes.Add(length)
+		r.c.store(name, r.buf[:length])
+	}
+	r.data = r.buf[r.pos-start : min(length, r.end-start)]
+	return nil
+}
+
+func (r *blockReader) closeUpstream() {
+	if r.upstream != nil {
+		_ = r.upstream.Close()
+		r.upstream = nil
+	}
+}
+
+func (r *blockReader) Close() error {
+	r.closeUpstream()
+	return nil
+}
+
+var blockCache *BlockCache
+
+// InitBlockCache enables the block cache used by CacheLink
+func InitBlockCache(dir string, maxSize, blockSize int64) error {
+	c, err := NewBlockCache(dir, maxSize, blockSize)
+	if err != nil {
+		return err
+	}
+	blockCache = c
+	return nil
+}
+
+// CacheLink makes link read through the block cache if it is enabled.
+// path, size and modified identify the content of the file.
+func CacheLink(path string, size int64, modified time.Time, link *model.Link) *model.Link {
+	if blockCache == nil {
+		return link
+	}
+	return blockCache.CacheLink(BlockCacheKey(path, size, modified), size, link)
+}
+
+// GetBlockCacheStats returns false if the block cache is disabled
+func GetBlockCacheStats() (BlockCacheStats, bool) {
+	if blockCache == nil {
+		return BlockCacheStats{}, false
+	}
+	return blockCache.Stats(), true
+}
+
+func ClearBlockCache() error {
+	if blockCache == nil {
+		return nil
+	}
+	return blockCache.Clear()
+}
@@ -0,0 +1,91 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+)
+
+func TestBlockCache(t *testing.T) {
+	buf := []byte("github.com/OpenListTeam/OpenList")
+	size := int64(len(buf))
+	requests := 0
+	link := &model.Link{
+		RangeReader: stream.RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+			requests++
+			return io.NopCloser(bytes.NewReader(buf[httpRange.Start : httpRange.Start+httpRange.Length])), nil
+		}),
+	}
+	dir := t.TempDir()
+	c, err := stream.NewBlockCache(dir, 16, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := c.CacheLink(stream.BlockCacheKey("/test", size, time.Unix(0, 0)), size, link)
+	read := func(start, length int64) {
+		t.Helper()
+		rc, err := cached.RangeReader.RangeRead(context.Background(), http_range.Range{Start: start, Length: length})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length < 0 {
+			length = size - start
+		}
+		if want := buf[start : start+length]; !bytes.Equal(got, want) {
+			t.Errorf("range %d-%d = %s, want %s", start, length, got, want)
+		}
+	}
+
+	read(5, 6)
+	if requests != 1 {
+		t.Errorf("a range spanning missing blocks should be one request, got %d", requests)
+	}
+	read(6, 3)
+	if requests != 1 {
+		t.Errorf("a cached range should not hit the upstream, got %d requests", requests)
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Size != 8 {
+		t.Errorf("unexpected stats after caching: %+v", stats)
+	}
+
+	read(0, -1)
+	stats = c.Stats()
+	if stats.Size > 16 || stats.Evictions == 0 {
+		t.Errorf("cache should be evicted to its max size: %+v", stats)
+	}
+	requests = 0
+	read(size-4, 4)
+	if requests != 0 {
+		t.Errorf("the most recently used block should be kept")
+	}
+
+	if err = c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if stats = c.Stats(); stats.Size != 0 || stats.Blocks != 0 {
+		t.Errorf("cache should be empty after clear: %+v", stats)
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("the block %s is left after clear", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package subtitle
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+)
+
+var (
+	subtitleExts = []string{"srt", "ass", "ssa", "vtt", "sub"}
+	audioExts    = []string{"mka", "aac", "ac3", "eac3", "dts", "flac", "mp3", "m4a", "opus", "ogg", "wav"}
+	posterExts   = []string{"jpg", "jpeg", "png", "webp"}
+	// posterNames are the posters of all the videos in a directory
+	posterNames = []string{"poster", "folder", "cover"}
+	// posterWords mark an image named after a video as its poster
+	posterWords = []string{"poster", "cover", "folder", "thumb", "fanart"}
+	// flagWords are the parts of a name which are not a language
+	flagWords = []string{"forced", "sdh", "cc", "hi", "default", "full"}
+)
+
+var langRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,4})?$`)
+
+// langAliases maps the language names seen in subtitle names but not in
+// BCP 47 to their tags
+var langAliases = map[string]string{
+	"chs": "zh-Hans", "sc": "zh-Hans", "gb": "zh-Hans",
+	"cht": "zh-Hant", "tc": "zh-Hant", "big5": "zh-Hant",
+	"chi": "zh", "zho": "zh", "eng": "en", "jpn": "ja", "jp": "ja",
+	"kor": "ko", "fre": "fr", "fra": "fr", "ger": "de", "deu": "de",
+	"spa": "es", "rus": "ru", "ita": "it", "por": "pt",
+}
+
+// Track is a file played along with a video
+type Track struct {
+	Obj model.Obj
+	// Format is the lower case extension of the file
+	Format string
+	// Lang is the language tag in the name of the file, e.g. zh of a.zh.srt
+	Lang string
+	// Label is the part of the name between the name of the video and the
+	// extension, e.g. zh.forced of a.zh.forced.srt
+	Label string
+}
+
+type Related struct {
+	Subtitles []Track
+	Audios    []Track
+	Posters   []Track
+}
+
+// Convertible reports whether a subtitle of format can be converted to WebVTT
+func Convertible(format string) bool {
+	return format != "sub" && utils.SliceContains(subtitleExts, format)
+}
+
+// label returns the part of name after the name of the video without its
+// extension, ok is false if name is not named after the video
+func label(name, base string) (string, bool) {
+	name = strings.TrimSuffix(name, "."+utils.SourceExt(name))
+	if len(name) < len(base) || !strings.EqualFold(name[:len(base)], base) {
+		return "", false
+	}
+	rest := name[len(base):]
+	if rest != "" && !strings.ContainsRune(".-_ ", rune(rest[0])) {
+		return "", false
+	}
+	return strings.Trim(rest, ".-_ "), true
+}
+
+// lang finds the language in the label of a subtitle or an audio track
+func lang(label string) string {
+	for _, part := range strings.FieldsFunc(label, func(r rune) bool { return r == '.' || r == ' ' }) {
+		l := strings.ToLower(part)
+		if alias, ok := langAliases[l]; ok {
+			return alias
+		}
+		if utils.SliceContains(flagWords, l) || !langRegexp.MatchString(part) {
+			continue
+		}
+		return strings.ReplaceAll(part, "_", "-")
+	}
+	return ""
+}
+
+// Resolve finds the subtitles, the external audio tracks and the posters of
+// a video among the files in its directory
+func Resolve(video model.Obj, siblings []model.Obj) *Related {
+	ret := &Related{Subtitles: []Track{}, Audios: []Track{}, Posters: []Track{}}
+	name := video.GetName()
+	base := strings.TrimSuffix(name, "."+utils.SourceExt(name))
+	var dirPosters []Track
+	for _, o := range siblings {
+		if o.IsDir() || o.GetName() == name {
+			continue
+		}
+		format := utils.Ext(o.GetName())
+		l, ok := label(o.GetName(), base)
+		if !ok {
+			stem := strings.ToLower(strings.TrimSuffix(o.GetName(), "."+utils.SourceExt(o.GetName())))
+			if utils.SliceContains(posterExts, format) && utils.SliceContains(posterNames, stem) {
+				dirPosters = append(dirPosters, Track{Obj: o, Format: format})
+			}
+			continue
+		}
+		t := Track{Obj: o, Format: format, Label: l}
+		switch {
+		case utils.SliceContains(subtitleExts, format):
+			t.Lang = lang(l)
+			ret.Subtitles = append(ret.Subtitles, t)
+		case utils.SliceContains(audioExts, format):
+			t.Lang = lang(l)
+			ret.Audios = append(ret.Audios, t)
+		case utils.SliceContains(posterExts, format):
+			lower := strings.ToLower(l)
+			if l == "" || utils.SliceMeet(posterWords, lower, func(w, l string) bool {
+				return strings.Contains(l, w)
+			}) {
+				ret.Posters = append(ret.Posters, t)
+			}
+		}
+	}
+	// the ones named after the video exactly come first
+	for _, tracks := range [][]Track{ret.Subtitles, ret.Audios, ret.Posters} {
+		sort.SliceStable(tracks, func(i, j int) bool {
+			return tracks[i].Label == "" && tracks[j].Label != ""
+		})
+	}
+	ret.Posters = append(ret.Posters, dirPosters...)
+	return ret
+}
@@ -0,0 +1,88 @@
+package subtitle
+
+import (
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestSRTToVTT(t *testing.T) {
+	srt := "1\r\n00:00:01,5 --> 00:00:03,250\r\n{\\an8}<font color=\"red\">Hello</font>\r\n\r\n" +
+		"2\r\n00:01:02,000 --> 01:00:00,000\r\n<i>World</i>\r\nagain\r\n"
+	got, err := ToVTT(srt, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:01.500 --> 00:00:03.250 line:0\nHello\n\n00:01:02.000 --> 01:00:00.000\n<i>World</i>\nagain\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestASSToVTT(t *testing.T) {
+	ass := `[Script Info]
+Title: test
+
+[V4+ Styles]
+Format: Name, Fontname
+Style: Default,Arial
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:05.10,0:00:06.00,Default,,0,0,0,,Second, with comma
+Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,hidden
+Dialogue: 0,0:00:01.00,0:00:02.50,Default,,0,0,0,,{\i1}First{\i0}\Nline
+`
+	got, err := ToVTT(ass, "ass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nFirst\nline\n\n00:00:05.100 --> 00:00:06.000\nSecond, with comma\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	objs := []model.Obj{
+		&model.Object{Name: "Movie.mkv"},
+		&model.Object{Name: "Movie.srt"},
+		&model.Object{Name: "movie.zh-CN.forced.ass"},
+		&model.Object{Name: "Movie.chs.srt"},
+		&model.Object{Name: "Movie 2.srt"},
+		&model.Object{Name: "Movie2.srt"},
+		&model.Object{Name: "Movie.eng.mka"},
+		&model.Object{Name: "Movie-poster.jpg"},
+		&model.Object{Name: "Movie.screenshot.jpg"},
+		&model.Object{Name: "folder.jpg"},
+		&model.Object{Name: "Other.srt"},
+	}
+	r := Resolve(objs[0], objs)
+	langs := map[string]string{}
+	for _, s := range r.Subtitles {
+		langs[s.Obj.GetName()] = s.Lang
+	}
+	want := map[string]string{
+		"Movie.srt":              "",
+		"movie.zh-CN.forced.ass": "zh-CN",
+		"Movie.chs.srt":          "zh-Hans",
+		"Movie 2.srt":            "",
+	}
+	if len(langs) != len(want) {
+		t.Fatalf("got subtitles %v, want %v", langs, want)
+	}
+	for name, lang := range want {
+		if l, ok := langs[name]; !ok || l != lang {
+			t.Errorf("subtitle %s: got %q, want %q", name, l, lang)
+		}
+	}
+	if r.Subtitles[0].Obj.GetName() != "Movie.srt" {
+		t.Errorf("the subtitle named after the video should be the first, got %s", r.Subtitles[0].Obj.GetName())
+	}
+	if len(r.Audios) != 1 || r.Audios[0].Lang != "en" {
+		t.Errorf("unexpected audios %+v", r.Audios)
+	}
+	if len(r.Posters) != 2 || r.Posters[0].Obj.GetName() != "Movie-poster.jpg" || r.Posters[1].Obj.GetName() != "folder.jpg" {
+		t.Errorf("unexpected posters %+v", r.Posters)
+	}
+}
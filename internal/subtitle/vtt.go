@@ -0,0 +1,246 @@
+package subtitle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// MaxSize bounds the size of a subtitle to be converted
+const MaxSize = 20 * 1024 * 1024
+
+type cue struct {
+	start, end time.Duration
+	settings   string
+	text       string
+}
+
+// VTT reads the subtitle of link and converts it to WebVTT
+func VTT(ctx context.Context, link *model.Link, obj model.Obj) ([]byte, error) {
+	format := utils.Ext(obj.GetName())
+	if !Convertible(format) {
+		return nil, errors.WithMessage(errs.NotSupport, "the subtitle can't be converted to webvtt")
+	}
+	if obj.GetSize() > MaxSize {
+		return nil, errors.WithMessage(errs.NotSupport, "the subtitle is too large")
+	}
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, MaxSize))
+	if err != nil {
+		return nil, err
+	}
+	return ToVTT(decode(data), format)
+}
+
+// decode converts a subtitle to UTF-8, the ones without a BOM which are not
+// valid UTF-8 are decoded by subtitle_encoding
+func decode(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		if s, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data); err == nil {
+			return string(s)
+		}
+	case utf8.Valid(data):
+		return string(data)
+	}
+	enc, err := ianaindex.IANA.Encoding(setting.GetStr(conf.SubtitleEncoding))
+	if err != nil || enc == nil {
+		return string(data)
+	}
+	s, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(s)
+}
+
+// ToVTT converts a subtitle of format, which is srt, ass, ssa or vtt, to WebVTT
+func ToVTT(s string, format string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+	var cues []cue
+	switch format {
+	case "vtt":
+		if !strings.HasPrefix(s, "WEBVTT") {
+			return nil, errors.New("invalid webvtt file")
+		}
+		return []byte(s), nil
+	case "srt":
+		cues = parseSRT(s)
+	case "ass", "ssa":
+		cues = parseASS(s)
+	default:
+		return nil, errors.WithMessagef(errs.NotSupport, "subtitles of %s", format)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		buf.WriteString("\n" + vttTime(c.start) + " --> " + vttTime(c.end))
+		if c.settings != "" {
+			buf.WriteString(" " + c.settings)
+		}
+		buf.WriteString("\n" + c.text + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+var (
+	srtTimeRegexp = regexp.MustCompile(`(\d+):(\d{1,2}):(\d{1,2})(?:[,.](\d{1,3}))?`)
+	// the ASS override tags, which are also seen in some SRT files
+	overrideRegexp = regexp.MustCompile(`\{\\[^}]*\}`)
+	fontTagRegexp  = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	// the blank lines would end a cue early
+	blankLineRegexp = regexp.MustCompile(`\n\s*\n`)
+)
+
+// parseTime parses h:mm:ss,mmm of SRT and h:mm:ss.cc of ASS
+func parseTime(s string) (time.Duration, bool) {
+	m := srtTimeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	h, _ := strconv.Atoi(m[1])
+	mins, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	d := time.Duration(h)*time.Hour + time.Duration(mins)*time.Minute + time.Duration(sec)*time.Second
+	if frac := m[4]; frac != "" {
+		f, _ := strconv.Atoi(frac)
+		for i := len(frac); i < 3; i++ {
+			f *= 10
+		}
+		d += time.Duration(f) * time.Millisecond
+	}
+	return d, true
+}
+
+func cueText(s string) string {
+	s = overrideRegexp.ReplaceAllString(s, "")
+	s = fontTagRegexp.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "-->", "->")
+	return strings.TrimSpace(blankLineRegexp.ReplaceAllString(s, "\n"))
+}
+
+func parseSRT(s string) []cue {
+	var cues []cue
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		start, end, ok := strings.Cut(lines[i], "-->")
+		if !ok {
+			continue
+		}
+		var c cue
+		var ok1, ok2 bool
+		c.start, ok1 = parseTime(start)
+		c.end, ok2 = parseTime(end)
+		if !ok1 || !ok2 {
+			continue
+		}
+		var text []string
+		for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			text = append(text, lines[i])
+		}
+		joined := strings.Join(text, "\n")
+		if strings.Contains(joined, `\an8`) {
+			c.settings = "line:0"
+		}
+		if c.text = cueText(joined); c.text != "" {
+			cues = append(cues, c)
+		}
+	}
+	return cues
+}
+
+// parseASS reads the dialogues of the events section, the styles are dropped
+// except for the alignment override
+func parseASS(s string) []cue {
+	var (
+		cues    []cue
+		inEvent bool
+		format  = []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvent = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvent {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			format = format[:0]
+			for _, f := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			fields := strings.SplitN(strings.TrimSpace(value), ",", len(format))
+			if len(fields) < len(format) {
+				continue
+			}
+			var c cue
+			var text string
+			ok1, ok2 := true, true
+			for i, f := range format {
+				switch f {
+				case "start":
+					c.start, ok1 = parseTime(fields[i])
+				case "end":
+					c.end, ok2 = parseTime(fields[i])
+				case "text":
+					text = fields[i]
+				}
+			}
+			if !ok1 || !ok2 {
+				continue
+			}
+			if strings.Contains(text, `\an8`) {
+				c.settings = "line:0"
+			}
+			text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+			if c.text = cueText(text); c.text != "" {
+				cues = append(cues, c)
+			}
+		}
+	}
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].start < cues[j].start
+	})
+	return cues
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/journal"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
@@ -67,7 +68,10 @@ func HookAndRemove(ctx context.Context, dstPath string, payloads ...any) {
 			err = verifyAndRemove(ctx, srcStorage, dstStorage, srcActualPath, dstActualPath)
 			if err != nil {
 				log.Error(err)
+				continue
 			}
+			journal.Record(true, string(p))
+			op.MovePathData(string(p), path.Join(dstPath, path.Base(string(p))))
 		}
 	}
 }
@@ -0,0 +1,180 @@
+package thumbnail
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskCache keeps generated thumbnails on the local disk and evicts them
+// in least recently used order once the total size exceeds maxSize
+type diskCache struct {
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	lru   *list.List // of *cachedThumb, most recently used at the front
+	items map[string]*list.Element
+	size  int64
+}
+
+type cachedThumb struct {
+	key  string
+	size int64
+}
+
+func newDiskCache(dir string, maxSize int64) (*diskCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail cache size %d", maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	c := &diskCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+	}
+	type found struct {
+		cachedThumb
+		modTime time.Time
+	}
+	var thumbs []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			_ = os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		thumbs = append(thumbs, found{cachedThumb{key: d.Name(), size: info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(thumbs, func(i, j int) bool {
+		return thumbs[i].modTime.After(thumbs[j].modTime)
+	})
+	for i := range thumbs {
+		t := thumbs[i].cachedThumb
+		c.items[t.key] = c.lru.PushBack(&t)
+		c.size += t.size
+	}
+	c.evict()
+	return c, nil
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// open returns the cached thumbnail of key, the file stays readable even
+// if the thumbnail is evicted meanwhile
+func (c *diskCache) open(key string) (*os.File, bool) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		c.remove(key)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(f.Name(), now, now)
+	return f, true
+}
+
+func (c *diskCache) put(key string, data []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.size -= e.Value.(*cachedThumb).size
+		c.lru.Remove(e)
+	}
+	c.items[key] = c.lru.PushFront(&cachedThumb{key: key, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.mu.Unlock()
+	c.evict()
+	return nil
+}
+
+func (c *diskCache) remove(key string) {
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.size -= e.Value.(*cachedThumb).size
+		c.lru.Remove(e)
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+	_ = os.Remove(c.path(key))
+}
+
+func (c *diskCache) evict() {
+	var evicted []string
+	c.mu.Lock()
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		t := c.lru.Remove(c.lru.Back()).(*cachedThumb)
+		delete(c.items, t.key)
+		c.size -= t.size
+		evicted = append(evicted, t.key)
+	}
+	c.mu.Unlock()
+	for _, key := range evicted {
+		_ = os.Remove(c.path(key))
+	}
+}
+
+func (c *diskCache) clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(c.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+	return nil
+}
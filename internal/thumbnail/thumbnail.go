@@ -0,0 +1,302 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/media"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+	_ "golang.org/x/image/webp"
+)
+
+type Config struct {
+	Dir           string
+	MaxSize       int64 // bytes of the disk cache
+	Width         int
+	MaxSourceSize int64 // bytes of the largest image to decode
+	Video         bool
+	FFmpegPath    string
+	VideoPos      float64 // seconds
+}
+
+var imageExts = []string{"jpg", "jpeg", "png", "gif", "webp"}
+
+// generateTimeout bounds the generation of a thumbnail, which outlives the
+// request starting it for the others waiting for it
+const generateTimeout = 2 * time.Minute
+
+var (
+	cfg   Config
+	cache *diskCache
+	group singleflight.Group[[]byte]
+)
+
+// Init enables the thumbnail service, video thumbnails are disabled if
+// ffmpeg can't be found
+func Init(c Config) error {
+	if c.Width <= 0 {
+		return fmt.Errorf("invalid thumbnail width %d", c.Width)
+	}
+	dc, err := newDiskCache(c.Dir, c.MaxSize)
+	if err != nil {
+		return err
+	}
+	if c.Video {
+		if path, err := exec.LookPath(c.FFmpegPath); err != nil {
+			log.Warnf("video thumbnails are disabled, ffmpeg not found: %+v", err)
+			c.Video = false
+		} else {
+			c.FFmpegPath = path
+		}
+	}
+	cfg, cache = c, dc
+	return nil
+}
+
+func Enabled() bool {
+	return cache != nil
+}
+
+// Supported reports whether a thumbnail can be generated for a file named name
+func Supported(name string) bool {
+	if cache == nil {
+		return false
+	}
+	switch utils.GetFileType(name) {
+	case conf.IMAGE:
+		return utils.SliceContains(imageExts, strings.ToLower(utils.Ext(name)))
+	case conf.VIDEO:
+		return cfg.Video
+	}
+	return false
+}
+
+// Key identifies the thumbnail of a file by its path, which includes the
+// mount path of its storage, its size and modified time
+func Key(path string, size int64, modified time.Time) string {
+	h := sha1.Sum([]byte(path + "\x00" + strconv.FormatInt(size, 10) + "\x00" + strconv.FormatInt(modified.UnixNano(), 10) + "\x00" + strconv.Itoa(cfg.Width)))
+	return hex.EncodeToString(h[:])
+}
+
+type bytesReadCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadCloser) Close() error {
+	return nil
+}
+
+// Get returns the thumbnail of the file at path, generating it if it is not
+// cached yet. Concurrent requests of the same thumbnail generate it once.
+// The thumbnail of an audio file is its cover art.
+func Get(ctx context.Context, path string) (io.ReadSeekCloser, model.Obj, error) {
+	if cache == nil {
+		return nil, nil, errors.WithMessage(errs.NotSupport, "thumbnail is disabled")
+	}
+	obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if obj.IsDir() {
+		return nil, nil, errs.NotFile
+	}
+	if !Supported(obj.GetName()) && media.Type(obj.GetName()) != "audio" {
+		return nil, nil, errors.WithMessage(errs.NotSupport, "no thumbnail for this type of file")
+	}
+	key := Key(path, obj.GetSize(), obj.ModTime())
+	if f, ok := cache.open(key); ok {
+		return f, obj, nil
+	}
+	data, err, _ := group.Do(key, func() ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), generateTimeout)
+		defer cancel()
+		data, err := generate(ctx, path, obj)
+		if err != nil {
+			return nil, err
+		}
+		if err := cache.put(key, data); err != nil {
+			log.Warnf("failed cache thumbnail of %s: %+v", path, err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if f, ok := cache.open(key); ok {
+		return f, obj, nil
+	}
+	return bytesReadCloser{bytes.NewReader(data)}, obj, nil
+}
+
+func Clear() error {
+	if cache == nil {
+		return nil
+	}
+	return cache.clear()
+}
+
+func generate(ctx context.Context, path string, obj model.Obj) ([]byte, error) {
+	var (
+		img image.Image
+		err error
+	)
+	switch {
+	case media.Type(obj.GetName()) == "audio":
+		img, err = cover(ctx, path)
+	case utils.GetFileType(obj.GetName()) == conf.VIDEO:
+		img, err = snapshot(ctx, path)
+	default:
+		img, err = decode(ctx, path, obj)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Encode(img, cfg.Width)
+}
+
+// Encode scales img down to width and encodes it as JPEG, or as PNG if it
+// has transparent pixels
+func Encode(img image.Image, width int) ([]byte, error) {
+	if img.Bounds().Dx() > width {
+		img = imaging.Resize(img, width, 0, imaging.Lanczos)
+	}
+	var buf bytes.Buffer
+	var err error
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	} else {
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(80))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(ctx context.Context, path string, obj model.Obj) (image.Image, error) {
+	if cfg.MaxSourceSize > 0 && obj.GetSize() > cfg.MaxSourceSize {
+		return nil, errors.WithMessage(errs.NotSupport, "the image is too large for a thumbnail")
+	}
+	link, _, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	defer link.Close()
+	rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return decodeImage(rc)
+}
+
+// maxPixels bounds the pixels of the images decoded, as a small file can
+// claim dimensions which take gigabytes to decode
+const maxPixels = 64 * 1024 * 1024
+
+// decodeImage reads the dimensions of the image before decoding it, and
+// rejects the images of more than maxPixels pixels
+func decodeImage(r io.Reader) (image.Image, error) {
+	var head bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, errors.WithMessagef(errs.NotSupport, "the image of %dx%d is too large for a thumbnail", config.Width, config.Height)
+	}
+	return imaging.Decode(io.MultiReader(&head, r), imaging.AutoOrientation(true))
+}
+
+// cover decodes the cover art embedded in an audio file
+func cover(ctx context.Context, path string) (image.Image, error) {
+	data, err := media.Cover(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(bytes.NewReader(data))
+}
+
+// snapshot grabs a frame of a video with ffmpeg, which reads the link URL
+// itself when there is one and the piped content otherwise
+func snapshot(ctx context.Context, path string) (image.Image, error) {
+	link, obj, err := fs.Link(ctx, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	defer link.Close()
+	grab := func(ss float64) (image.Image, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		args := ffmpeg.KwArgs{"ss": strconv.FormatFloat(ss, 'f', -1, 64), "noaccurate_seek": ""}
+		input := "pipe:"
+		var stdin io.Reader
+		if link.URL != "" && link.RangeReader == nil {
+			input = link.URL
+			var headers strings.Builder
+			for k, vs := range link.Header {
+				for _, v := range vs {
+					headers.WriteString(k + ": " + v + "\r\n")
+				}
+			}
+			if headers.Len() > 0 {
+				args["headers"] = headers.String()
+			}
+		} else {
+			rr, err := stream.GetRangeReaderFromLink(obj.GetSize(), link)
+			if err != nil {
+				return nil, err
+			}
+			rc, err := rr.RangeRead(ctx, http_range.Range{Length: -1})
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			stdin = rc
+		}
+		out := bytes.NewBuffer(nil)
+		s := ffmpeg.OutputContext(ctx, []*ffmpeg.Stream{ffmpeg.Input(input, args)}, "pipe:",
+			ffmpeg.KwArgs{"vframes": 1, "format": "image2", "vcodec": "mjpeg"}).
+			GlobalArgs("-loglevel", "error").SetFfmpegPath(cfg.FFmpegPath).Silent(true).
+			WithOutput(out, os.Stdout)
+		if stdin != nil {
+			s = s.WithInput(stdin)
+		}
+		if err := s.Run(); err != nil {
+			return nil, err
+		}
+		return jpeg.Decode(out)
+	}
+	img, err := grab(cfg.VideoPos)
+	if err != nil && cfg.VideoPos > 0 {
+		// the video may be shorter than the position
+		img, err = grab(0)
+	}
+	return img, err
+}
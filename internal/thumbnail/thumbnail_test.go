@@ -0,0 +1,114 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDiskCacheEviction(t *testing.T) {
+	c, err := newDiskCache(t.TempDir(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"aa01", "aa02", "aa03"} {
+		if err := c.put(key, []byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+		if key == "aa02" {
+			// make aa01 the most recently used
+			f, ok := c.open("aa01")
+			if !ok {
+				t.Fatal("aa01 should be cached")
+			}
+			_ = f.Close()
+		}
+	}
+	if _, ok := c.open("aa02"); ok {
+		t.Errorf("the least recently used thumbnail should be evicted")
+	}
+	f, ok := c.open("aa01")
+	if !ok {
+		t.Fatal("aa01 should be kept")
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "1234" {
+		t.Errorf("unexpected cached data %q", data)
+	}
+
+	reopened, err := newDiskCache(c.dir, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.size != 8 || len(reopened.items) != 2 {
+		t.Errorf("cache should be restored from disk, got %d bytes in %d items", reopened.size, len(reopened.items))
+	}
+}
+
+func TestEncode(t *testing.T) {
+	opaque := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 0xff
+	}
+	data, err := Encode(opaque, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := http.DetectContentType(data); ct != "image/jpeg" {
+		t.Errorf("an opaque image should be encoded as JPEG, got %s", ct)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Errorf("thumbnail should keep the aspect ratio, got %v", b)
+	}
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	transparent.Set(0, 0, color.NRGBA{R: 0xff, A: 0x80})
+	data, err = Encode(transparent, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := http.DetectContentType(data); ct != "image/png" {
+		t.Errorf("a transparent image should be encoded as PNG, got %s", ct)
+	}
+}
+
+// pngHeader returns the signature and the IHDR chunk of a PNG, which is all
+// image.DecodeConfig reads
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	b := []byte("\x89PNG\r\n\x1a\n")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestDecodeImageLimit(t *testing.T) {
+	if _, err := decodeImage(bytes.NewReader(pngHeader(100000, 100000))); err == nil {
+		t.Errorf("the image of 100000x100000 is decoded")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeImage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Errorf("the image decoded is of %v, want 4x2", b)
+	}
+}
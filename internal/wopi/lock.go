@@ -0,0 +1,93 @@
+package wopi
+
+import (
+	"sync"
+	"time"
+)
+
+// LockExpiration is how long a lock lasts unless it is refreshed
+const LockExpiration = 30 * time.Minute
+
+type lock struct {
+	id      string
+	expires time.Time
+}
+
+var locks = struct {
+	sync.Mutex
+	m map[string]lock
+}{m: make(map[string]lock)}
+
+// current returns the lock of path, expired locks are removed. It must be
+// called with locks held.
+func current(path string) string {
+	l, ok := locks.m[path]
+	if !ok {
+		return ""
+	}
+	if time.Now().After(l.expires) {
+		delete(locks.m, path)
+		return ""
+	}
+	return l.id
+}
+
+func set(path, id string) {
+	locks.m[path] = lock{id: id, expires: time.Now().Add(LockExpiration)}
+}
+
+// GetLock returns the lock of path, empty if it is not locked
+func GetLock(path string) string {
+	locks.Lock()
+	defer locks.Unlock()
+	return current(path)
+}
+
+// Lock locks path with id, or refreshes the lock if it is already locked with
+// id. If oldID is not empty, the lock is replaced only if it is oldID. On
+// conflict it returns false with the current lock.
+func Lock(path, id, oldID string) (string, bool) {
+	locks.Lock()
+	defer locks.Unlock()
+	cur := current(path)
+	if oldID != "" {
+		if cur != oldID {
+			return cur, false
+		}
+	} else if cur != "" && cur != id {
+		return cur, false
+	}
+	set(path, id)
+	return id, true
+}
+
+// RefreshLock extends the lock of path if it is id
+func RefreshLock(path, id string) (string, bool) {
+	locks.Lock()
+	defer locks.Unlock()
+	if cur := current(path); cur != id {
+		return cur, false
+	}
+	set(path, id)
+	return id, true
+}
+
+// Unlock removes the lock of path if it is id
+func Unlock(path, id string) (string, bool) {
+	locks.Lock()
+	defer locks.Unlock()
+	if cur := current(path); cur != id {
+		return cur, false
+	}
+	delete(locks.m, path)
+	return "", true
+}
+
+// CheckLock reports whether path can be written with the lock id, which is
+// when it is locked with id or it is not locked at all
+func CheckLock(path, id string) (string, bool) {
+	locks.Lock()
+	defer locks.Unlock()
+	cur := current(path)
+	return cur, cur == "" || cur == id
+}
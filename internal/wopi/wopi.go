@@ -0,0 +1,80 @@
+// Package wopi keeps the state of the WOPI host, which lets an office editor
+// such as Collabora or OnlyOffice view and edit the files of the storages.
+package wopi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidToken = errors.New("invalid access token")
+
+// FileID returns the id of the file at path in the WOPI urls
+func FileID(path string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(utils.FixAndCleanPath(path)))
+}
+
+// FilePath returns the path of the file of the id
+func FilePath(id string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return utils.FixAndCleanPath(string(data)), nil
+}
+
+// Token is the access token granted to a user for a file, write is whether
+// the user could write the file when the token was granted
+type Token struct {
+	UserID uint
+	Write  bool
+}
+
+func (t Token) prefix() string {
+	w := "0"
+	if t.Write {
+		w = "1"
+	}
+	return fmt.Sprintf("%d.%s", t.UserID, w)
+}
+
+// NewToken returns a signed access token of the file of the id, password is
+// the one protecting the file if any, the token is invalid once it changes
+func NewToken(id, password string, t Token) string {
+	prefix := t.prefix()
+	return prefix + "." + sign.SignWOPI(signedData(id, password, prefix))
+}
+
+func signedData(id, password, prefix string) string {
+	data := id + ":" + prefix
+	if password != "" {
+		sum := sha256.Sum256([]byte(password))
+		data += ":" + hex.EncodeToString(sum[:])
+	}
+	return data
+}
+
+// ParseToken verifies an access token of the file of the id
+func ParseToken(id, password, token string) (*Token, error) {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 || (parts[1] != "0" && parts[1] != "1") {
+		return nil, ErrInvalidToken
+	}
+	uid, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	t := &Token{UserID: uint(uid), Write: parts[1] == "1"}
+	if err := sign.VerifyWOPI(signedData(id, password, t.prefix()), parts[2]); err != nil {
+		return nil, errors.WithMessage(ErrInvalidToken, err.Error())
+	}
+	return t, nil
+}
@@ -0,0 +1,41 @@
+package wopi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileID(t *testing.T) {
+	id := FileID("/docs/报告 1.docx")
+	path, err := FilePath(id)
+	if err != nil || path != "/docs/报告 1.docx" {
+		t.Fatalf("got %q, %v", path, err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	const path = "/a.docx"
+	if _, ok := Lock(path, "l1", ""); !ok {
+		t.Fatal("lock an unlocked file")
+	}
+	if cur, ok := Lock(path, "l2", ""); ok || cur != "l1" {
+		t.Fatalf("lock a locked file: %q, %v", cur, ok)
+	}
+	if _, ok := CheckLock(path, "l2"); ok {
+		t.Fatal("write with another lock")
+	}
+	if _, ok := Lock(path, "l2", "l1"); !ok {
+		t.Fatal("unlock and relock")
+	}
+	if cur, ok := Unlock(path, "l1"); ok || cur != "l2" {
+		t.Fatalf("unlock with the old lock: %q, %v", cur, ok)
+	}
+	if _, ok := Unlock(path, "l2"); !ok || GetLock(path) != "" {
+		t.Fatal("unlock")
+	}
+
+	locks.m[path] = lock{id: "l3", expires: time.Now().Add(-time.Second)}
+	if cur, ok := CheckLock(path, "l4"); !ok || cur != "" {
+		t.Fatalf("expired lock: %q, %v", cur, ok)
+	}
+}
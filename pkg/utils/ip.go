@@ -3,6 +3,7 @@ package utils
 import (
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
@@ -47,3 +48,32 @@ func IsLocalIP(ip net.IP) bool {
 		(ip4[0] == 169 && ip4[1] == 254) || // 169.254.0.0/16
 		(ip4[0] == 192 && ip4[1] == 168) // 192.168.0.0/16
 }
+
+// IPAllowed reports whether ip matches one of the IPs or CIDRs in list,
+// which are separated by commas or new lines. An empty list allows any ip.
+func IPAllowed(ip string, list string) bool {
+	entries := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+	allowed := true
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		allowed = false
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if a, err := netip.ParseAddr(entry); err == nil && a.Unmap() == addr {
+			return true
+		}
+	}
+	return allowed
+}
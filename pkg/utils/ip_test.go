@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestIPAllowed(t *testing.T) {
+	testCases := []struct {
+		ip   string
+		list string
+		want bool
+	}{
+		{"1.2.3.4", "", true},
+		{"1.2.3.4", "\n \n", true},
+		{"1.2.3.4", "1.2.3.4", true},
+		{"1.2.3.4", "10.0.0.0/8\n1.2.3.0/24", true},
+		{"1.2.3.4", "10.0.0.0/8, 192.168.1.1", false},
+		{"::ffff:1.2.3.4", "1.2.3.0/24", true},
+		{"2001:db8::1", "2001:db8::/32", true},
+		{"invalid", "1.2.3.4", false},
+	}
+	for _, tc := range testCases {
+		if got := IPAllowed(tc.ip, tc.list); got != tc.want {
+			t.Errorf("IPAllowed(%q, %q) = %t, want %t", tc.ip, tc.list, got, tc.want)
+		}
+	}
+}
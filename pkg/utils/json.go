@@ -27,3 +27,16 @@ func WriteJsonToFile(dst string, data interface{}, std ...bool) bool {
 	}
 	return true
 }
+
+// JsonConvert converts data, e.g. the data of model.OtherArgs decoded into
+// a map, to v by a round trip through json, nil data leaves v unchanged
+func JsonConvert(data interface{}, v interface{}) error {
+	if data == nil {
+		return nil
+	}
+	b, err := Json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return Json.Unmarshal(b, v)
+}
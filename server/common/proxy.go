@@ -117,6 +117,16 @@ func ProxyRange(ctx context.Context, link *model.Link, size int64) *model.Link {
 	return link
 }
 
+// CacheRange makes link read through the block cache of internal/stream,
+// path is the full path of file
+func CacheRange(path string, file model.Obj, link *model.Link) *model.Link {
+	size := link.ContentLength
+	if size <= 0 {
+		size = file.GetSize()
+	}
+	return stream.CacheLink(path, size, file.ModTime(), link)
+}
+
 type InterceptResponseWriter struct {
 	http.ResponseWriter
 	io.Writer
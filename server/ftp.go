@@ -73,7 +73,7 @@ func NewMainDriver() (*FtpMainDriver, error) {
 			DisableLISTArgs:          false,
 			DisableSite:              false,
 			DisableActiveMode:        conf.Conf.FTP.DisableActiveMode,
-			EnableHASH:               false,
+			EnableHASH:               true,
 			DisableSTAT:              false,
 			DisableSYST:              false,
 			EnableCOMB:               false,
@@ -100,6 +100,9 @@ func (d *FtpMainDriver) ClientConnected(cc ftpserver.ClientContext) (string, err
 		return "", errors.New("server has shutdown")
 	}
 	defer d.shutdownLock.RUnlock()
+	if !utils.IPAllowed(remoteIP(cc), setting.GetStr(conf.FTPAllowedIPs)) {
+		return "", errors.New("your IP is not allowed to connect")
+	}
 	d.clients[cc.ID()] = cc
 	return "OpenList FTP Endpoint", nil
 }
@@ -112,6 +115,31 @@ func (d *FtpMainDriver) ClientDisconnected(cc ftpserver.ClientContext) {
 	delete(d.clients, cc.ID())
 }
 
+// PreAuthUser applies the FTP restrictions of the user before the password is asked
+func (d *FtpMainDriver) PreAuthUser(cc ftpserver.ClientContext, user string) error {
+	var userObj *model.User
+	var err error
+	if user == "anonymous" || user == "guest" {
+		userObj, err = op.GetGuest()
+	} else {
+		userObj, err = op.GetUserByName(user)
+	}
+	if err != nil {
+		// unknown users are rejected or registered via LDAP by AuthUser
+		return cc.SetTLSRequirement(ftpserver.ClearOrEncrypted)
+	}
+	if !utils.IPAllowed(remoteIP(cc), userObj.FtpAllowedIPs) {
+		return errors.New("user is not allowed to login from your IP")
+	}
+	if !userObj.FtpRequireTLS {
+		return cc.SetTLSRequirement(ftpserver.ClearOrEncrypted)
+	}
+	if d.tlsConfig == nil {
+		return errors.New("TLS is required for the user, but the certificate is not provided")
+	}
+	return cc.SetTLSRequirement(ftpserver.MandatoryEncryption)
+}
+
 func (d *FtpMainDriver) AuthUser(cc ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
 	ip := cc.RemoteAddr().String()
 	count, ok := model.LoginCache.Get(ip)
@@ -175,6 +203,14 @@ func (d *FtpMainDriver) Stop() {
 	}
 }
 
+func remoteIP(cc ftpserver.ClientContext) string {
+	host, _, err := net.SplitHostPort(cc.RemoteAddr().String())
+	if err != nil {
+		return cc.RemoteAddr().String()
+	}
+	return host
+}
+
 func lookupIP(host string) string {
 	if host == "" || net.ParseIP(host) != nil {
 		return host
@@ -126,7 +126,10 @@ func (a *AferoAdapter) GetHandle(name string, flags int, offset int64) (ftpserve
 	}
 	if (flags & os.O_WRONLY) != 0 {
 		if offset != 0 {
-			return nil, errs.NotSupport
+			if !exists {
+				return nil, errs.ObjectNotFound
+			}
+			return OpenUploadAt(a.ctx, path, offset)
 		}
 		trunc := (flags & os.O_TRUNC) != 0
 		if fileSize > 0 {
@@ -154,6 +157,12 @@ func (a *AferoAdapter) Site(param string) *ftpserver.AnswerCommand {
 			Code:    code,
 			Message: msg,
 		}
+	case "MLST", "MLSD":
+		code, msg := HandleMLST(params, a, cmd == "MLSD")
+		return &ftpserver.AnswerCommand{
+			Code:    code,
+			Message: msg,
+		}
 	}
 	return nil
 }
@@ -42,11 +42,17 @@ func OpenDownload(ctx context.Context, reqPath string, offset int64) (*FileDownl
 	if err != nil {
 		return nil, err
 	}
-	ss, err := stream.NewSeekableStream(&stream.FileStream{
+	fileStream := &stream.FileStream{
 		Obj: obj,
 		Ctx: ctx,
-	}, link)
+	}
+	if cached := common.CacheRange(reqPath, obj, link); cached != link {
+		fileStream.Add(link)
+		link = cached
+	}
+	ss, err := stream.NewSeekableStream(fileStream, link)
 	if err != nil {
+		_ = fileStream.Close()
 		_ = link.Close()
 		return nil, err
 	}
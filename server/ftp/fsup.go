@@ -67,6 +67,34 @@ func OpenUpload(ctx context.Context, path string, trunc bool) (*FileUploadProxy,
 	return &FileUploadProxy{buffer: tmpFile, path: path, ctx: ctx, trunc: trunc}, nil
 }
 
+// OpenUploadAt resumes an upload interrupted at offset, which is REST before STOR.
+// The uploaded part of the existing file is copied into the buffer, so that the
+// rest of the data is appended to it before the whole file is put again.
+func OpenUploadAt(ctx context.Context, path string, offset int64) (*FileUploadProxy, error) {
+	f, err := OpenUpload(ctx, path, true)
+	if err != nil {
+		return nil, err
+	}
+	discard := func() {
+		_ = f.buffer.Close()
+		_ = os.Remove(f.buffer.Name())
+	}
+	down, err := OpenDownload(ctx, path, 0)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	defer down.Close()
+	if _, err = io.CopyN(f.buffer, down, offset); err != nil {
+		discard()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("offset %d is beyond the end of [%s]", offset, path)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
 func (f *FileUploadProxy) Read(p []byte) (n int, err error) {
 	return 0, errs.NotSupport
 }
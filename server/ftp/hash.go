@@ -0,0 +1,62 @@
+package ftp
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/pkg/errors"
+)
+
+var hashTypes = map[ftpserver.HASHAlgo]*utils.HashType{
+	ftpserver.HASHAlgoMD5:    utils.MD5,
+	ftpserver.HASHAlgoSHA1:   utils.SHA1,
+	ftpserver.HASHAlgoSHA256: utils.SHA256,
+}
+
+// ComputeHash answers HASH, XMD5, XSHA1 and the like. The hash known by the
+// storage is used if the whole file is requested, otherwise the requested
+// range is downloaded and hashed.
+func (a *AferoAdapter) ComputeHash(name string, algo ftpserver.HASHAlgo, startOffset, endOffset int64) (string, error) {
+	info, err := a.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	var newHash func() hash.Hash
+	ht, ok := hashTypes[algo]
+	if ok {
+		newHash = func() hash.Hash { return ht.NewFunc() }
+		if o, ok := info.(*OsFileInfoAdapter); ok && startOffset == 0 && endOffset == info.Size() {
+			if h := o.obj.GetHash().GetHash(ht); h != "" {
+				return strings.ToLower(h), nil
+			}
+		}
+	} else {
+		switch algo {
+		case ftpserver.HASHAlgoCRC32:
+			newHash = func() hash.Hash { return crc32.NewIEEE() }
+		case ftpserver.HASHAlgoSHA512:
+			newHash = sha512.New
+		default:
+			return "", errs.NotSupport
+		}
+	}
+	f, err := a.GetHandle(name, os.O_RDONLY, startOffset)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	_, err = io.CopyN(h, f, endOffset-startOffset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,143 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	stdpath "path"
+	"sort"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+)
+
+// the MLSD writer of ftpserverlib only emits the type, size and modify facts,
+// so the full RFC 3659 facts are answered by SITE MLST and SITE MLSD.
+
+// mlsxFacts builds the RFC 3659 facts of obj located at reqPath, and the hashes
+// known by the storage as x.<hash> facts.
+func mlsxFacts(ctx context.Context, reqPath string, obj model.Obj) string {
+	var b strings.Builder
+	typ := "file"
+	if obj.IsDir() {
+		typ = "dir"
+	}
+	fmt.Fprintf(&b, "modify=%s;", obj.ModTime().UTC().Format("20060102150405"))
+	fmt.Fprintf(&b, "perm=%s;", mlsxPerm(ctx, reqPath, obj))
+	if !obj.IsDir() {
+		fmt.Fprintf(&b, "size=%d;", obj.GetSize())
+	}
+	fmt.Fprintf(&b, "type=%s;", typ)
+	fmt.Fprintf(&b, "unique=%s;", mlsxUnique(reqPath, obj))
+	hashes := obj.GetHash().Export()
+	names := make([]string, 0, len(hashes))
+	values := make(map[string]string, len(hashes))
+	for ht, v := range hashes {
+		if v == "" {
+			continue
+		}
+		names = append(names, ht.Name)
+		values[ht.Name] = strings.ToLower(v)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "x.%s=%s;", name, values[name])
+	}
+	return b.String()
+}
+
+func mlsxPerm(ctx context.Context, reqPath string, obj model.Obj) string {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	parentPath := stdpath.Dir(reqPath)
+	parentMeta, _ := op.GetNearestMeta(parentPath)
+	manage := user.CanFTPManage() && common.CanWrite(user, parentMeta, parentPath)
+	var perm strings.Builder
+	if obj.IsDir() {
+		perm.WriteString("el")
+		meta, _ := op.GetNearestMeta(reqPath)
+		if user.CanFTPManage() && common.CanWrite(user, meta, reqPath) {
+			if user.CanWriteContent() || common.CanWriteContentBypassUserPerms(meta, reqPath) {
+				perm.WriteString("c")
+			}
+			perm.WriteString("m")
+		}
+	} else {
+		perm.WriteString("r")
+		if manage && (user.CanWriteContent() || common.CanWriteContentBypassUserPerms(parentMeta, parentPath)) {
+			perm.WriteString("w")
+		}
+	}
+	if manage && user.CanRemove() {
+		perm.WriteString("d")
+	}
+	if manage && user.CanRename() {
+		perm.WriteString("f")
+	}
+	return perm.String()
+}
+
+// mlsxUnique prefers the id given by the storage, which survives renames
+func mlsxUnique(reqPath string, obj model.Obj) string {
+	if id := obj.GetID(); id != "" {
+		if storage, err := fs.GetStorage(reqPath, &fs.GetStoragesArgs{}); err == nil {
+			return utils.GetMD5EncodeStr(storage.GetStorage().MountPath + ":" + id)[:16]
+		}
+	}
+	return utils.GetMD5EncodeStr(reqPath)[:16]
+}
+
+func objOf(info os.FileInfo) model.Obj {
+	if o, ok := info.(*OsFileInfoAdapter); ok {
+		return o.obj
+	}
+	return &model.Object{
+		Name:     info.Name(),
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+		IsFolder: info.IsDir(),
+	}
+}
+
+// HandleMLST answers SITE MLST <path> and SITE MLSD <dir> with the full facts,
+// the path is taken from the root of the user as SITE does not know the working dir
+func HandleMLST(param string, client ftpserver.ClientDriver, dir bool) (int, string) {
+	a, ok := client.(*AferoAdapter)
+	if !ok {
+		return ftpserver.StatusNotLoggedIn, "Unexpected exception (driver is nil)"
+	}
+	if param == "" {
+		param = "/"
+	}
+	user := a.ctx.Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(param)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, err.Error()
+	}
+	info, err := a.Stat(param)
+	if err != nil {
+		return ftpserver.StatusActionNotTaken, fmt.Sprintf("%s: %v", param, err)
+	}
+	var lines []string
+	if !dir {
+		lines = append(lines, " "+mlsxFacts(a.ctx, reqPath, objOf(info))+" "+param)
+	} else {
+		if !info.IsDir() {
+			return ftpserver.StatusActionNotTakenNoFile, fmt.Sprintf("%s is not a directory", param)
+		}
+		infos, err := a.ReadDir(param)
+		if err != nil {
+			return ftpserver.StatusActionNotTaken, fmt.Sprintf("%s: %v", param, err)
+		}
+		for _, info := range infos {
+			name := info.Name()
+			lines = append(lines, " "+mlsxFacts(a.ctx, stdpath.Join(reqPath, name), objOf(info))+" "+name)
+		}
+	}
+	return ftpserver.StatusFileOK, fmt.Sprintf("Listing %s\r\n%s\r\nEnd", param, strings.Join(lines, "\r\n"))
+}
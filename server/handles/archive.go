@@ -363,7 +363,7 @@ func ArchiveProxy(c *gin.Context) {
 			common.ErrorPage(c, err, 500)
 			return
 		}
-		proxy(c, link, file, storage.GetStorage().ProxyRange)
+		proxy(c, link, file, stdpath.Join(archiveRawPath, innerPath), storage.GetStorage().ProxyRange)
 	} else {
 		common.ErrorPage(c, errors.New("proxy not allowed"), 403)
 		return
@@ -0,0 +1,24 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+func GetBlockCacheStats(c *gin.Context) {
+	stats, ok := stream.GetBlockCacheStats()
+	if !ok {
+		common.ErrorStrResp(c, "block cache is disabled", 400)
+		return
+	}
+	common.SuccessResp(c, stats)
+}
+
+func ClearBlockCache(c *gin.Context) {
+	if err := stream.ClearBlockCache(); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
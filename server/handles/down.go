@@ -66,7 +66,7 @@ func Proxy(c *gin.Context) {
 			common.ErrorPage(c, err, 500)
 			return
 		}
-		proxy(c, link, file, storage.GetStorage().ProxyRange)
+		proxy(c, link, file, rawPath, storage.GetStorage().ProxyRange)
 	} else {
 		common.ErrorPage(c, errors.New("proxy not allowed"), 403)
 		return
@@ -92,7 +92,7 @@ func redirect(c *gin.Context, link *model.Link) {
 	c.Redirect(302, link.URL)
 }
 
-func proxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange bool) {
+func proxy(c *gin.Context, link *model.Link, file model.Obj, path string, proxyRange bool) {
 	defer link.Close()
 	var err error
 	if link.URL != "" && setting.GetBool(conf.ForwardDirectLinkParams) {
@@ -106,6 +106,9 @@ func proxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange bool) {
 			return
 		}
 	}
+	if c.Query("type") == "" {
+		link = common.CacheRange(path, file, link)
+	}
 	if proxyRange {
 		link = common.ProxyRange(c, link, file.GetSize())
 	}
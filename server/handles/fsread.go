@@ -9,14 +9,17 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/media"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/thumbnail"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 type ListReq struct {
@@ -115,7 +118,7 @@ func FsList(c *gin.Context, req *ListReq, user *model.User) {
 		}
 	}
 	common.SuccessResp(c, FsListResp{
-		Content:            toObjsResp(objs, reqPath, isEncrypt(meta, reqPath)),
+		Content:            toObjsResp(c, objs, reqPath, isEncrypt(meta, reqPath)),
 		Total:              int64(total),
 		Readme:             getReadme(meta, reqPath),
 		Header:             getHeader(meta, reqPath),
@@ -225,10 +228,10 @@ func pagination(objs []model.Obj, req *model.PageReq) (int, []model.Obj) {
 	return total, objs[start:end]
 }
 
-func toObjsResp(objs []model.Obj, parent string, encrypt bool) []ObjResp {
+func toObjsResp(c *gin.Context, objs []model.Obj, parent string, encrypt bool) []ObjResp {
 	var resp []ObjResp
 	for _, obj := range objs {
-		thumb, _ := model.GetThumb(obj)
+		objSign := common.Sign(obj, parent, encrypt)
 		mountDetails, _ := model.GetStorageDetails(obj)
 		resp = append(resp, ObjResp{
 			Name:         obj.GetName(),
@@ -238,8 +241,8 @@ func toObjsResp(objs []model.Obj, parent string, encrypt bool) []ObjResp {
 			Created:      obj.CreateTime(),
 			HashInfoStr:  obj.GetHash().String(),
 			HashInfo:     obj.GetHash().Export(),
-			Sign:         common.Sign(obj, parent, encrypt),
-			Thumb:        thumb,
+			Sign:         objSign,
+			Thumb:        getThumbURL(c, obj, parent, objSign),
 			Type:         utils.GetObjType(obj.GetName(), obj.IsDir()),
 			MountDetails: mountDetails,
 		})
@@ -257,8 +260,9 @@ type FsGetResp struct {
 	RawURL   string    `json:"raw_url"`
 	Readme   string    `json:"readme"`
 	Header   string    `json:"header"`
-	Provider string    `json:"provider"`
-	Related  []ObjResp `json:"related"`
+	Provider string           `json:"provider"`
+	Related  []ObjResp        `json:"related"`
+	Media    *model.MediaMeta `json:"media,omitempty"`
 }
 
 func FsGetSplit(c *gin.Context) {
@@ -354,7 +358,18 @@ func FsGet(c *gin.Context, req *FsGetReq, user *model.User) {
 		related = filterRelated(sameLevelFiles, obj)
 	}
 	parentMeta, _ := op.GetNearestMeta(parentPath)
-	thumb, _ := model.GetThumb(obj)
+	objSign := common.Sign(obj, parentPath, isEncrypt(meta, reqPath))
+	thumb := getThumbURL(c, obj, parentPath, objSign)
+	var mediaMeta *model.MediaMeta
+	if setting.GetBool(conf.MediaMeta) {
+		// only the metadata cached is returned, the file is parsed in the background
+		mediaMeta, err = media.GetCached(c.Request.Context(), reqPath, obj)
+		if err != nil {
+			log.Warnf("failed get media meta of %s: %+v", reqPath, err)
+		} else if mediaMeta != nil && thumb == "" && mediaMeta.HasCover && thumbnail.Enabled() {
+			thumb = thumbnailURL(c, reqPath, objSign)
+		}
+	}
 	mountDetails, _ := model.GetStorageDetails(obj)
 	common.SuccessResp(c, FsGetResp{
 		ObjResp: ObjResp{
@@ -365,7 +380,7 @@ func FsGet(c *gin.Context, req *FsGetReq, user *model.User) {
 			Created:      obj.CreateTime(),
 			HashInfoStr:  obj.GetHash().String(),
 			HashInfo:     obj.GetHash().Export(),
-			Sign:         common.Sign(obj, parentPath, isEncrypt(meta, reqPath)),
+			Sign:         objSign,
 			Type:         utils.GetFileType(obj.GetName()),
 			Thumb:        thumb,
 			MountDetails: mountDetails,
@@ -374,7 +389,8 @@ func FsGet(c *gin.Context, req *FsGetReq, user *model.User) {
 		Readme:   getReadme(meta, reqPath),
 		Header:   getHeader(meta, reqPath),
 		Provider: provider,
-		Related:  toObjsResp(related, parentPath, isEncrypt(parentMeta, parentPath)),
+		Related:  toObjsResp(c, related, parentPath, isEncrypt(parentMeta, parentPath)),
+		Media:    mediaMeta,
 	})
 }
 
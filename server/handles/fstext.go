@@ -0,0 +1,212 @@
+package handles
+
+import (
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type TextGetResp struct {
+	Content string        `json:"content"`
+	State   *fs.TextState `json:"state"`
+}
+
+// textPath joins the path of a request and checks the user can read it, or
+// write it if write is set
+func textPath(c *gin.Context, path, password string, write bool) (string, bool) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return "", false
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		common.ErrorResp(c, err, 500, true)
+		return "", false
+	}
+	if !common.CanAccess(user, meta, reqPath, password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return "", false
+	}
+	if write {
+		parentPath := stdpath.Dir(reqPath)
+		parentMeta, err := op.GetNearestMeta(parentPath)
+		if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			common.ErrorResp(c, err, 500, true)
+			return "", false
+		}
+		if (!user.CanWriteContent() && !common.CanWriteContentBypassUserPerms(parentMeta, parentPath)) ||
+			!common.CanWrite(user, parentMeta, parentPath) {
+			common.ErrorResp(c, errs.PermissionDenied, 403)
+			return "", false
+		}
+	}
+	return reqPath, true
+}
+
+func textError(c *gin.Context, err error) {
+	var conflict *fs.TextConflict
+	if errors.As(err, &conflict) {
+		common.ErrorWithDataResp(c, err, 409, conflict)
+	} else if errors.Is(err, errs.NotSupport) || errors.Is(err, errs.NotFile) {
+		common.ErrorResp(c, err, 400)
+	} else if errs.IsObjectNotFound(err) {
+		common.ErrorResp(c, err, 404)
+	} else {
+		common.ErrorResp(c, err, 500)
+	}
+}
+
+// FsTextGet returns the content of a text file with the state to be sent
+// back as the base of the edit
+func FsTextGet(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, false)
+	if !ok {
+		return
+	}
+	content, state, err := fs.ReadText(c.Request.Context(), reqPath)
+	if err != nil {
+		textError(c, err)
+		return
+	}
+	common.SuccessResp(c, TextGetResp{Content: content, State: state})
+}
+
+type TextSaveReq struct {
+	Path     string       `json:"path" binding:"required"`
+	Content  string       `json:"content"`
+	Base     *fs.TextBase `json:"base"`
+	Force    bool         `json:"force"`
+	Password string       `json:"password"`
+}
+
+// FsTextSave saves a text file, it responds 409 with the current content if
+// the file has been changed since the base of the edit
+func FsTextSave(c *gin.Context) {
+	var req TextSaveReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, true)
+	if !ok {
+		return
+	}
+	if shouldIgnoreSystemFile(stdpath.Base(reqPath)) {
+		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	state, err := fs.SaveText(c.Request.Context(), reqPath, req.Content, req.Base, req.Force, user)
+	if err != nil {
+		textError(c, err)
+		return
+	}
+	common.SuccessResp(c, state)
+}
+
+func FsTextHistory(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, false)
+	if !ok {
+		return
+	}
+	versions, err := op.GetTextVersions(reqPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	names := make(map[uint]string)
+	for i := range versions {
+		id := versions[i].UserId
+		if _, ok := names[id]; !ok {
+			if u, err := op.GetUserById(id); err == nil {
+				names[id] = u.Username
+			}
+		}
+		versions[i].Username = names[id]
+	}
+	common.SuccessResp(c, versions)
+}
+
+type TextVersionReq struct {
+	Path     string       `json:"path" binding:"required"`
+	ID       uint         `json:"id" binding:"required"`
+	Base     *fs.TextBase `json:"base"`
+	Force    bool         `json:"force"`
+	Password string       `json:"password"`
+}
+
+// getTextVersion returns the version of the id of the request if it is a
+// version of the path
+func getTextVersion(c *gin.Context, req *TextVersionReq, path string) (*model.TextVersion, bool) {
+	v, err := op.GetTextVersionById(req.ID)
+	if err != nil || v.Path != path {
+		common.ErrorStrResp(c, "version not found", 404)
+		return nil, false
+	}
+	return v, true
+}
+
+// FsTextVersion returns the content of a previous version
+func FsTextVersion(c *gin.Context) {
+	var req TextVersionReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, false)
+	if !ok {
+		return
+	}
+	v, ok := getTextVersion(c, &req, reqPath)
+	if !ok {
+		return
+	}
+	common.SuccessResp(c, TextGetResp{
+		Content: string(v.Content),
+		State:   &fs.TextState{Size: v.Size, Modified: v.Modified, Hash: v.Hash},
+	})
+}
+
+// FsTextRevert saves the content of a previous version as the current one,
+// which is kept in the history as well
+func FsTextRevert(c *gin.Context) {
+	var req TextVersionReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, true)
+	if !ok {
+		return
+	}
+	v, ok := getTextVersion(c, &req, reqPath)
+	if !ok {
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	state, err := fs.SaveText(c.Request.Context(), reqPath, string(v.Content), req.Base, req.Force, user)
+	if err != nil {
+		textError(c, err)
+		return
+	}
+	common.SuccessResp(c, state)
+}
@@ -0,0 +1,88 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// FsVersions lists the versions kept of a file replaced in the storages or
+// the paths with versioning enabled
+func FsVersions(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, false)
+	if !ok {
+		return
+	}
+	versions, err := op.GetFileVersions(c.Request.Context(), reqPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, versions)
+}
+
+type FsVersionReq struct {
+	Path     string `json:"path" binding:"required"`
+	ID       uint   `json:"id" binding:"required"`
+	Password string `json:"password"`
+}
+
+// getFileVersion binds the request and returns the version of its id if it
+// is a version of its path, which the user can write
+func getFileVersion(c *gin.Context) (*model.FileVersion, bool) {
+	var req FsVersionReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, true)
+	if !ok {
+		return nil, false
+	}
+	v, err := op.GetFileVersionById(req.ID)
+	if err != nil || v.Path != reqPath {
+		common.ErrorStrResp(c, "version not found", 404)
+		return nil, false
+	}
+	return v, true
+}
+
+// FsRestoreVersion moves a version back in place of the current file, which
+// is removed if it can't be kept as a version, for the users who can remove
+func FsRestoreVersion(c *gin.Context) {
+	v, ok := getFileVersion(c)
+	if !ok {
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if err := op.RestoreFileVersion(c.Request.Context(), v, user.CanRemove()); err != nil {
+		if errors.Is(err, errs.PermissionDenied) {
+			common.ErrorResp(c, err, 403)
+		} else {
+			common.ErrorResp(c, err, 500)
+		}
+		return
+	}
+	common.SuccessResp(c)
+}
+
+func FsDeleteVersion(c *gin.Context) {
+	v, ok := getFileVersion(c)
+	if !ok {
+		return
+	}
+	if err := op.DeleteFileVersion(c.Request.Context(), v); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
@@ -0,0 +1,115 @@
+package handles
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/hls"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type FsHLSResp struct {
+	URL string `json:"url"`
+}
+
+// FsHLS starts packaging a video to HLS and returns the signed url of its
+// playlist, which is available once the first segments are packaged
+func FsHLS(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if user.IsGuest() && user.Disabled {
+		common.ErrorStrResp(c, "Guest user is disabled, login please", 401)
+		return
+	}
+	reqPath, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	if !common.CanAccess(user, meta, reqPath, req.Password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return
+	}
+	key, err := hls.Start(c.Request.Context(), reqPath)
+	if err != nil {
+		if errors.Is(err, hls.ErrBusy) {
+			common.ErrorResp(c, err, 503)
+		} else if errors.Is(err, errs.NotSupport) || errors.Is(err, errs.NotFile) {
+			common.ErrorResp(c, err, 400)
+		} else {
+			common.ErrorResp(c, err, 500)
+		}
+		return
+	}
+	common.SuccessResp(c, FsHLSResp{
+		URL: fmt.Sprintf("%s/hls/%s/index.m3u8?sign=%s", common.GetApiUrl(c), key,
+			sign.SignHLS(key+"/index.m3u8")),
+	})
+}
+
+// HLS serves the playlists and the segments, each of them is signed
+func HLS(c *gin.Context) {
+	key, name := c.Param("key"), c.Param("name")
+	if err := sign.VerifyHLS(key+"/"+name, c.Query("sign")); err != nil {
+		common.ErrorPage(c, err, 401)
+		return
+	}
+	if name == "index.m3u8" {
+		data, err := hls.Playlist(c.Request.Context(), key, func(seg string) string {
+			return seg + "?sign=" + sign.SignHLS(key+"/"+seg)
+		})
+		if err != nil {
+			if errors.Is(err, errs.ObjectNotFound) {
+				common.ErrorPage(c, err, 404)
+			} else {
+				common.ErrorPage(c, err, 500)
+			}
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Data(200, "application/vnd.apple.mpegurl", data)
+		return
+	}
+	f, err := hls.Segment(key, name)
+	if err != nil {
+		if errors.Is(err, errs.ObjectNotFound) {
+			common.ErrorPage(c, err, 404)
+		} else {
+			common.ErrorPage(c, err, 500)
+		}
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		common.ErrorPage(c, err, 500)
+		return
+	}
+	c.Header("Content-Type", "video/mp2t")
+	c.Header("Cache-Control", "max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), f)
+}
+
+func ClearHLS(c *gin.Context) {
+	if err := hls.Clear(); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
@@ -0,0 +1,400 @@
+package handles
+
+import (
+	stdpath "path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/thumbnail"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// maxAlbumShareFiles bounds the photos of a shared album, as they are all
+// stored in the sharing
+const maxAlbumShareFiles = 1000
+
+type PhotoResp struct {
+	Path      string    `json:"path"`
+	Name      string    `json:"name"`
+	TakenAt   time.Time `json:"taken_at"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	Sign      string    `json:"sign"`
+	Thumb     string    `json:"thumb"`
+}
+
+type PhotoDayResp struct {
+	Date   string      `json:"date"`
+	Photos []PhotoResp `json:"photos"`
+}
+
+type PhotoMonthResp struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+type PhotoTimelineResp struct {
+	// all the months having photos, the newest first
+	Months []PhotoMonthResp `json:"months"`
+	Month  string           `json:"month"`
+	// Total is the number of the photos of the month, of which Days holds a page
+	Total int            `json:"total"`
+	Days  []PhotoDayResp `json:"days"`
+}
+
+// photoMonths counts by month the photos under dirs matching filter which
+// user can access, the newest month first, and returns the directories of
+// the photos the user can't access
+func photoMonths(user *model.User, dirs []string, filter model.MediaMetaFilter, password string) ([]PhotoMonthResp, []string, error) {
+	counts, err := op.GetPhotoCounts(dirs, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	access := make(map[string]bool)
+	byMonth := make(map[string]int)
+	var denied []string
+	for _, cnt := range counts {
+		ok, checked := access[cnt.Dir]
+		if !checked {
+			meta, err := op.GetNearestMeta(cnt.Dir)
+			if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+				return nil, nil, err
+			}
+			ok = common.CanAccess(user, meta, cnt.Dir, password)
+			access[cnt.Dir] = ok
+			if !ok {
+				denied = append(denied, cnt.Dir)
+			}
+		}
+		if ok {
+			byMonth[cnt.Month] += int(cnt.Count)
+		}
+	}
+	months := make([]PhotoMonthResp, 0, len(byMonth))
+	for m, n := range byMonth {
+		months = append(months, PhotoMonthResp{Month: m, Count: n})
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Month > months[j].Month
+	})
+	return months, denied, nil
+}
+
+func toPhotoResp(c *gin.Context, m *model.MediaMeta) PhotoResp {
+	var s string
+	meta, _ := op.GetNearestMeta(stdpath.Dir(m.Path))
+	if isEncrypt(meta, m.Path) || setting.GetBool(conf.SignAll) {
+		s = sign.Sign(m.Path)
+	}
+	var thumb string
+	if thumbnail.Supported(m.Path) {
+		thumb = thumbnailURL(c, m.Path, s)
+	}
+	return PhotoResp{
+		Path:      m.Path,
+		Name:      stdpath.Base(m.Path),
+		TakenAt:   time.Unix(0, m.Taken).UTC(),
+		Width:     m.Width,
+		Height:    m.Height,
+		Latitude:  m.Latitude,
+		Longitude: m.Longitude,
+		Sign:      s,
+		Thumb:     thumb,
+	}
+}
+
+// photoTimeline counts the photos by month and returns a page of the photos
+// of month grouped by day, month defaults to the newest one
+func photoTimeline(c *gin.Context, user *model.User, dirs []string, filter model.MediaMetaFilter, month, password string, page model.PageReq) {
+	if month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			common.ErrorStrResp(c, "month must be in the form of 2006-01", 400)
+			return
+		}
+	}
+	months, denied, err := photoMonths(user, dirs, filter, password)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	resp := PhotoTimelineResp{Months: months, Month: month, Days: []PhotoDayResp{}}
+	if resp.Month == "" && len(months) > 0 {
+		resp.Month = months[0].Month
+	}
+	for _, m := range months {
+		if m.Month == resp.Month {
+			resp.Total = m.Count
+		}
+	}
+	if resp.Total == 0 {
+		common.SuccessResp(c, resp)
+		return
+	}
+	page.Validate()
+	photos, err := op.GetPhotos(dirs, filter, denied, resp.Month, (page.Page-1)*page.PerPage, page.PerPage)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	for i := range photos {
+		p := toPhotoResp(c, &photos[i])
+		d := p.TakenAt.Format("2006-01-02")
+		if n := len(resp.Days); n == 0 || resp.Days[n-1].Date != d {
+			resp.Days = append(resp.Days, PhotoDayResp{Date: d})
+		}
+		day := &resp.Days[len(resp.Days)-1]
+		day.Photos = append(day.Photos, p)
+	}
+	common.SuccessResp(c, resp)
+}
+
+type PhotoTimelineReq struct {
+	model.PageReq
+	Paths    []string              `json:"paths"`
+	Month    string                `json:"month"`
+	Filter   model.MediaMetaFilter `json:"filter"`
+	Password string                `json:"password"`
+}
+
+// PhotoTimeline returns the photos grouped by their capture date. Only the
+// images of which the media meta are cached appear, which are parsed while
+// building the index with media_meta_index, or on their first fs/get with
+// media_meta.
+func PhotoTimeline(c *gin.Context) {
+	var req PhotoTimelineReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if len(req.Paths) == 0 {
+		req.Paths = []string{"/"}
+	}
+	dirs, err := joinUserPaths(user, req.Paths)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	photoTimeline(c, user, dirs, req.Filter, req.Month, req.Password, req.PageReq)
+}
+
+func joinUserPaths(user *model.User, paths []string) ([]string, error) {
+	ret := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p, err := user.JoinPath(p)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+func ListPhotoAlbums(c *gin.Context) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	albums, err := op.GetPhotoAlbumsByCreatorId(user.ID)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, albums)
+}
+
+// getOwnPhotoAlbum returns the album of id if it is created by the user
+func getOwnPhotoAlbum(c *gin.Context, id uint) (*model.PhotoAlbum, bool) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	a, err := op.GetPhotoAlbumById(id)
+	if err != nil || a.CreatorId != user.ID {
+		common.ErrorStrResp(c, "photo album not found", 404)
+		return nil, false
+	}
+	return a, true
+}
+
+func CreatePhotoAlbum(c *gin.Context) {
+	var req model.PhotoAlbum
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if len(req.Paths) == 0 {
+		common.ErrorStrResp(c, "must add at least 1 path", 400)
+		return
+	}
+	paths, err := joinUserPaths(user, req.Paths)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	a := &model.PhotoAlbum{
+		Name:      req.Name,
+		Paths:     paths,
+		Filter:    req.Filter,
+		CreatorId: user.ID,
+		Created:   time.Now(),
+	}
+	if err := op.CreatePhotoAlbum(a); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, a)
+}
+
+func UpdatePhotoAlbum(c *gin.Context) {
+	var req model.PhotoAlbum
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	a, ok := getOwnPhotoAlbum(c, req.ID)
+	if !ok {
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if len(req.Paths) == 0 {
+		common.ErrorStrResp(c, "must add at least 1 path", 400)
+		return
+	}
+	paths, err := joinUserPaths(user, req.Paths)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	a.Name, a.Paths, a.Filter = req.Name, paths, req.Filter
+	if err := op.UpdatePhotoAlbum(a); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, a)
+}
+
+func DeletePhotoAlbum(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if _, ok := getOwnPhotoAlbum(c, uint(id)); !ok {
+		return
+	}
+	if err := op.DeletePhotoAlbum(uint(id)); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+type PhotoAlbumTimelineReq struct {
+	model.PageReq
+	ID       uint   `json:"id"`
+	Month    string `json:"month"`
+	Password string `json:"password"`
+}
+
+func PhotoAlbumTimeline(c *gin.Context) {
+	var req PhotoAlbumTimelineReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	a, ok := getOwnPhotoAlbum(c, req.ID)
+	if !ok {
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	photoTimeline(c, user, a.Paths, a.Filter, req.Month, req.Password, req.PageReq)
+}
+
+type SharePhotoAlbumReq struct {
+	ID          uint       `json:"id"`
+	Expires     *time.Time `json:"expires"`
+	Pwd         string     `json:"pwd"`
+	MaxAccessed int        `json:"max_accessed"`
+	Remark      string     `json:"remark"`
+	Password    string     `json:"password"`
+}
+
+// SharePhotoAlbum shares the photos currently in an album, the sharing
+// doesn't follow the later changes of the album
+func SharePhotoAlbum(c *gin.Context) {
+	var req SharePhotoAlbumReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !user.CanShare() {
+		common.ErrorStrResp(c, "permission denied", 403)
+		return
+	}
+	a, ok := getOwnPhotoAlbum(c, req.ID)
+	if !ok {
+		return
+	}
+	months, denied, err := photoMonths(user, a.Paths, a.Filter, req.Password)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	total := 0
+	for _, m := range months {
+		total += m.Count
+	}
+	if total == 0 {
+		common.ErrorStrResp(c, "the album has no photo", 400)
+		return
+	}
+	if total > maxAlbumShareFiles {
+		common.ErrorStrResp(c, "the album has too many photos to be shared, narrow its filter", 400)
+		return
+	}
+	photos, err := op.GetPhotos(a.Paths, a.Filter, denied, "", 0, maxAlbumShareFiles)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	files := make([]string, 0, len(photos))
+	for _, p := range photos {
+		if utils.IsSubPath(user.BasePath, p.Path) {
+			files = append(files, p.Path)
+		}
+	}
+	remark := req.Remark
+	if remark == "" {
+		remark = a.Name
+	}
+	s := &model.Sharing{
+		SharingDB: &model.SharingDB{
+			Expires:     req.Expires,
+			Pwd:         req.Pwd,
+			MaxAccessed: req.MaxAccessed,
+			Remark:      remark,
+		},
+		Files:   files,
+		Creator: user,
+	}
+	id, err := op.CreateSharing(s)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	s.ID = id
+	common.SuccessResp(c, SharingResp{
+		Sharing:     s,
+		CreatorName: user.Username,
+		CreatorRole: user.Role,
+	})
+}
@@ -17,6 +17,8 @@ import (
 type SearchReq struct {
 	model.SearchReq
 	Password string `json:"password"`
+	// only keeps the files whose media meta match, see media_meta_index
+	Media *model.MediaMetaFilter `json:"media"`
 }
 
 type SearchResp struct {
@@ -43,10 +45,27 @@ func Search(c *gin.Context) {
 		common.ErrorResp(c, err, 400)
 		return
 	}
+	var mediaPaths map[string]struct{}
+	if req.Media != nil {
+		paths, err := op.GetMediaMetaPaths(*req.Media)
+		if err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+		mediaPaths = make(map[string]struct{}, len(paths))
+		for _, p := range paths {
+			mediaPaths[p] = struct{}{}
+		}
+	}
 	nodes, total, err := search.SearchFiltered(c, req.SearchReq, func(node model.SearchNode) bool {
 		if !utils.IsSubPath(user.BasePath, node.Parent) {
 			return false
 		}
+		if mediaPaths != nil {
+			if _, ok := mediaPaths[path.Join(node.Parent, node.Name)]; !ok {
+				return false
+			}
+		}
 		meta, err := op.GetNearestMeta(node.Parent)
 		if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
 			return false
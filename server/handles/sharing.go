@@ -31,7 +31,7 @@ func SharingGet(c *gin.Context, req *FsGetReq) {
 		Refresh: false,
 		Pwd:     req.Password,
 	})
-	if dealError(c, err) {
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
 		return
 	}
 	_ = countAccess(c.ClientIP(), s)
@@ -75,7 +75,7 @@ func SharingList(c *gin.Context, req *ListReq) {
 		Refresh: req.Refresh,
 		Pwd:     req.Password,
 	})
-	if dealError(c, err) {
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
 		return
 	}
 	_ = countAccess(c.ClientIP(), s)
@@ -99,7 +99,7 @@ func SharingList(c *gin.Context, req *ListReq) {
 		Total:    int64(total),
 		Readme:   s.Readme,
 		Header:   s.Header,
-		Write:    false,
+		Write:    s.Upload,
 		Provider: "unknown",
 	})
 }
@@ -128,7 +128,7 @@ func SharingArchiveMeta(c *gin.Context, req *ArchiveMetaReq) {
 		},
 		Pwd: req.Password,
 	})
-	if dealError(c, err) {
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
 		return
 	}
 	_ = countAccess(c.ClientIP(), s)
@@ -174,7 +174,7 @@ func SharingArchiveList(c *gin.Context, req *ArchiveListReq) {
 		},
 		Pwd: req.Password,
 	})
-	if dealError(c, err) {
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
 		return
 	}
 	_ = countAccess(c.ClientIP(), s)
@@ -199,6 +199,8 @@ func SharingDown(c *gin.Context) {
 			err = errs.InvalidSharing
 		} else if !s.Verify(pwd) {
 			err = errs.WrongShareCode
+		} else if s.Upload {
+			err = errs.UploadOnlyShare
 		} else if len(s.Files) != 1 && path == "/" {
 			err = errors.New("cannot get sharing root link")
 		}
@@ -206,6 +208,11 @@ func SharingDown(c *gin.Context) {
 	if dealErrorPage(c, err) {
 		return
 	}
+	access, err := startSharingDownload(c, s, path, false)
+	if dealErrorPage(c, err) {
+		return
+	}
+	defer finishSharingDownload(c, access)
 	unwrapPath, err := op.GetSharingUnwrapPath(s, path)
 	if err != nil {
 		common.ErrorPage(c, errors.New("failed get sharing unwrap path"), 500)
@@ -232,7 +239,7 @@ func SharingDown(c *gin.Context) {
 			return
 		}
 		_ = countAccess(c.ClientIP(), s)
-		proxy(c, link, obj, storage.GetStorage().ProxyRange)
+		proxy(c, link, obj, stdpath.Join(storage.GetStorage().MountPath, actualPath), storage.GetStorage().ProxyRange)
 	} else {
 		link, _, err := op.Link(c.Request.Context(), storage, actualPath, model.LinkArgs{
 			IP:       c.ClientIP(),
@@ -266,6 +273,8 @@ func SharingArchiveExtract(c *gin.Context) {
 			err = errs.InvalidSharing
 		} else if !s.Verify(pwd) {
 			err = errs.WrongShareCode
+		} else if s.Upload {
+			err = errs.UploadOnlyShare
 		} else if len(s.Files) != 1 && path == "/" {
 			err = errors.New("cannot extract sharing root")
 		}
@@ -273,6 +282,11 @@ func SharingArchiveExtract(c *gin.Context) {
 	if dealErrorPage(c, err) {
 		return
 	}
+	access, err := startSharingDownload(c, s, stdpath.Join(path, innerPath), true)
+	if dealErrorPage(c, err) {
+		return
+	}
+	defer finishSharingDownload(c, access)
 	unwrapPath, err := op.GetSharingUnwrapPath(s, path)
 	if err != nil {
 		common.ErrorPage(c, errors.New("failed get sharing unwrap path"), 500)
@@ -298,7 +312,7 @@ func SharingArchiveExtract(c *gin.Context) {
 			if dealErrorPage(c, err) {
 				return
 			}
-			proxy(c, link, obj, storage.GetStorage().ProxyRange)
+			proxy(c, link, obj, stdpath.Join(storage.GetStorage().MountPath, actualPath, innerPath), storage.GetStorage().ProxyRange)
 		} else {
 			args.Redirect = true
 			link, _, err := op.DriverExtract(c.Request.Context(), storage, actualPath, args)
@@ -324,7 +338,8 @@ func dealError(c *gin.Context, err error) bool {
 		common.ErrorStrResp(c, "the share does not exist", 500)
 	} else if errors.Is(err, errs.InvalidSharing) {
 		common.ErrorStrResp(c, "the share has expired or is no longer valid", 500)
-	} else if errors.Is(err, errs.WrongShareCode) {
+	} else if errors.Is(err, errs.WrongShareCode) || errors.Is(err, errs.UploadOnlyShare) ||
+		errors.Is(err, errs.SharingIPDenied) || errors.Is(err, errs.SharingDownLimit) {
 		common.ErrorResp(c, err, 403)
 	} else if errors.Is(err, errs.WrongArchivePassword) {
 		common.ErrorResp(c, err, 202)
@@ -341,7 +356,8 @@ func dealErrorPage(c *gin.Context, err error) bool {
 		common.ErrorPage(c, errors.New("the share does not exist"), 500)
 	} else if errors.Is(err, errs.InvalidSharing) {
 		common.ErrorPage(c, errors.New("the share has expired or is no longer valid"), 500)
-	} else if errors.Is(err, errs.WrongShareCode) {
+	} else if errors.Is(err, errs.WrongShareCode) || errors.Is(err, errs.UploadOnlyShare) ||
+		errors.Is(err, errs.SharingIPDenied) || errors.Is(err, errs.SharingDownLimit) {
 		common.ErrorPage(c, err, 403)
 	} else if errors.Is(err, errs.WrongArchivePassword) {
 		common.ErrorPage(c, err, 202)
@@ -414,10 +430,17 @@ type UpdateSharingReq struct {
 	Readme      string     `json:"readme"`
 	Header      string     `json:"header"`
 	model.Sort
-	CreatorName string `json:"creator"`
-	Accessed    int    `json:"accessed"`
-	ID          string `json:"id"`
-	NewID       string `json:"new_id"`
+	Upload            bool   `json:"upload"`
+	MaxFileSize       int64  `json:"max_file_size"`
+	MaxFileCount      int    `json:"max_file_count"`
+	AllowedExts       string `json:"allowed_exts"`
+	AllowedIPs        string `json:"allowed_ips"`
+	DeniedIPs         string `json:"denied_ips"`
+	MaxDownloadsPerIP int    `json:"max_downloads_per_ip"`
+	CreatorName       string `json:"creator"`
+	Accessed          int    `json:"accessed"`
+	ID                string `json:"id"`
+	NewID             string `json:"new_id"`
 }
 
 var validSharingID = regexp.MustCompile(`^[\w\p{Han}\-]+$`)
@@ -474,6 +497,12 @@ func UpdateSharing(c *gin.Context) {
 	if reqUser.IsAdmin() && req.CreatorName == "" {
 		user = s.Creator
 	}
+	if req.Upload {
+		if err = validateUploadSharing(c, user, req.Files); err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+	}
 	s.Files = req.Files
 	s.Expires = req.Expires
 	s.Pwd = req.Pwd
@@ -484,6 +513,13 @@ func UpdateSharing(c *gin.Context) {
 	s.Header = req.Header
 	s.Readme = req.Readme
 	s.Remark = req.Remark
+	s.Upload = req.Upload
+	s.MaxFileSize = req.MaxFileSize
+	s.MaxFileCount = req.MaxFileCount
+	s.AllowedExts = req.AllowedExts
+	s.AllowedIPs = req.AllowedIPs
+	s.DeniedIPs = req.DeniedIPs
+	s.MaxDownloadsPerIP = req.MaxDownloadsPerIP
 	s.Creator = user
 	if req.NewID != "" && req.NewID != req.ID {
 		if !reqUser.CanCustomizeShareID() {
@@ -550,18 +586,31 @@ func CreateSharing(c *gin.Context) {
 			return
 		}
 	}
+	if req.Upload {
+		if err = validateUploadSharing(c, user, req.Files); err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+	}
 	s := &model.Sharing{
 		SharingDB: &model.SharingDB{
-			ID:          req.ID,
-			Expires:     req.Expires,
-			Pwd:         req.Pwd,
-			Accessed:    req.Accessed,
-			MaxAccessed: req.MaxAccessed,
-			Disabled:    req.Disabled,
-			Sort:        req.Sort,
-			Remark:      req.Remark,
-			Readme:      req.Readme,
-			Header:      req.Header,
+			ID:                req.ID,
+			Expires:           req.Expires,
+			Pwd:               req.Pwd,
+			Accessed:          req.Accessed,
+			MaxAccessed:       req.MaxAccessed,
+			Disabled:          req.Disabled,
+			Sort:              req.Sort,
+			Remark:            req.Remark,
+			Readme:            req.Readme,
+			Header:            req.Header,
+			Upload:            req.Upload,
+			MaxFileSize:       req.MaxFileSize,
+			MaxFileCount:      req.MaxFileCount,
+			AllowedExts:       req.AllowedExts,
+			AllowedIPs:        req.AllowedIPs,
+			DeniedIPs:         req.DeniedIPs,
+			MaxDownloadsPerIP: req.MaxDownloadsPerIP,
 		},
 		Files:   req.Files,
 		Creator: user,
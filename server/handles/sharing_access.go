@@ -0,0 +1,126 @@
+package handles
+
+import (
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkSharingClient applies the IP lists of the share to the client
+func checkSharingClient(c *gin.Context, s *model.Sharing) error {
+	if !s.IPAllowed(c.ClientIP()) {
+		return errs.SharingIPDenied
+	}
+	return nil
+}
+
+// startSharingDownload applies the IP lists of the share to the client, and
+// its per-IP download limit, which counts the files downloaded so that every
+// request is checked while the ranges of a file downloaded are let through.
+// The download is recorded with the check, before it is served
+func startSharingDownload(c *gin.Context, s *model.Sharing, path string, archive bool) (*model.SharingAccess, error) {
+	if err := checkSharingClient(c, s); err != nil {
+		return nil, err
+	}
+	if c.Request.Method == "HEAD" {
+		_, err := op.CheckSharingDownload(s.ID, c.ClientIP(), path, s.MaxDownloadsPerIP)
+		return nil, err
+	}
+	a := &model.SharingAccess{
+		SharingID: s.ID,
+		Time:      time.Now(),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Path:      path,
+		Archive:   archive,
+	}
+	if err := op.CreateSharingDownload(a, s.MaxDownloadsPerIP); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// finishSharingDownload sets the bytes written to the response to the record
+// of a download, or drops the record if the download has failed
+func finishSharingDownload(c *gin.Context, a *model.SharingAccess) {
+	if a == nil {
+		return
+	}
+	var err error
+	if c.Writer.Status() >= 400 {
+		err = op.DeleteSharingAccess(a.ID)
+	} else {
+		err = op.UpdateSharingAccessBytes(a.ID, int64(max(c.Writer.Size(), 0)))
+	}
+	if err != nil {
+		log.Errorf("failed record access of sharing %s: %+v", a.SharingID, err)
+	}
+}
+
+// recordSharingUpload logs an upload made through a share
+func recordSharingUpload(c *gin.Context, s *model.Sharing, path string, bytes int64) {
+	err := op.CreateSharingAccess(&model.SharingAccess{
+		SharingID: s.ID,
+		Time:      time.Now(),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Path:      path,
+		Bytes:     bytes,
+		Upload:    true,
+	})
+	if err != nil {
+		log.Errorf("failed record access of sharing %s: %+v", s.ID, err)
+	}
+}
+
+// getOwnSharing returns the share of the id query if the user may manage it
+func getOwnSharing(c *gin.Context) (*model.Sharing, bool) {
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	s, err := op.GetSharingById(c.Query("id"))
+	if err != nil || (!user.IsAdmin() && s.CreatorId != user.ID) {
+		common.ErrorStrResp(c, "sharing not found", 404)
+		return nil, false
+	}
+	return s, true
+}
+
+func ListSharingAccesses(c *gin.Context) {
+	var req model.PageReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	req.Validate()
+	s, ok := getOwnSharing(c)
+	if !ok {
+		return
+	}
+	accesses, total, err := op.GetSharingAccesses(s.ID, req.Page, req.PerPage)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, common.PageResp{
+		Content: accesses,
+		Total:   total,
+	})
+}
+
+func GetSharingAccessStats(c *gin.Context) {
+	s, ok := getOwnSharing(c)
+	if !ok {
+		return
+	}
+	stats, err := op.GetSharingAccessStats(s.ID)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, stats)
+}
@@ -0,0 +1,286 @@
+package handles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// canUploadTo applies the checks of middlewares.FsUp to user and dir
+func canUploadTo(user *model.User, dir string) (bool, error) {
+	if user == nil || user.Disabled {
+		return false, nil
+	}
+	meta, err := op.GetNearestMeta(dir)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return false, err
+	}
+	if !user.CanWriteContent() && !common.CanWriteContentBypassUserPerms(meta, dir) {
+		return false, nil
+	}
+	return common.CanWrite(user, meta, dir), nil
+}
+
+// validateUploadSharing checks that an upload share targets a single folder
+// its creator can upload into
+func validateUploadSharing(c *gin.Context, user *model.User, files []string) error {
+	if len(files) != 1 {
+		return errors.New("an upload share must target exactly 1 folder")
+	}
+	ok, err := canUploadTo(user, files[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errs.PermissionDenied
+	}
+	ctx := context.WithValue(c.Request.Context(), conf.UserKey, user)
+	obj, err := fs.Get(ctx, files[0], &fs.GetArgs{NoLog: true})
+	if err != nil {
+		return err
+	}
+	if !obj.IsDir() {
+		return errs.NotFolder
+	}
+	return nil
+}
+
+var sharingUploadLock sync.Mutex
+
+// reserveSharingUpload counts an upload against the max file count of the
+// share, the upload must be released if it fails
+func reserveSharingUpload(sid string) (bool, error) {
+	sharingUploadLock.Lock()
+	defer sharingUploadLock.Unlock()
+	s, err := op.GetSharingById(sid)
+	if err != nil {
+		return false, err
+	}
+	if s.MaxFileCount > 0 && s.Uploaded >= s.MaxFileCount {
+		return false, nil
+	}
+	s.Uploaded += 1
+	return true, op.UpdateSharing(s, true)
+}
+
+func releaseSharingUpload(sid string) {
+	sharingUploadLock.Lock()
+	defer sharingUploadLock.Unlock()
+	if s, err := op.GetSharingById(sid); err == nil && s.Uploaded > 0 {
+		s.Uploaded -= 1
+		_ = op.UpdateSharing(s, true)
+	}
+}
+
+// uploadingNames keeps the paths being uploaded into upload shares, so that
+// concurrent uploads of the same name don't overwrite each other
+var (
+	uploadingNames   = make(map[string]struct{})
+	uploadingNamesMu sync.Mutex
+)
+
+// reserveName appends a counter to name if dir already has such an object
+// or one is being uploaded, so that visitors can neither overwrite nor probe
+// existing files. The name must be released with releaseName.
+func reserveName(ctx context.Context, dir, name string) (string, error) {
+	ext := stdpath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; i <= 100; i++ {
+		path := stdpath.Join(dir, candidate)
+		if res, _ := fs.Get(ctx, path, &fs.GetArgs{NoLog: true}); res == nil {
+			uploadingNamesMu.Lock()
+			_, uploading := uploadingNames[path]
+			if !uploading {
+				uploadingNames[path] = struct{}{}
+			}
+			uploadingNamesMu.Unlock()
+			if !uploading {
+				return candidate, nil
+			}
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return "", errs.ObjectAlreadyExists
+}
+
+func releaseName(dir, name string) {
+	uploadingNamesMu.Lock()
+	delete(uploadingNames, stdpath.Join(dir, name))
+	uploadingNamesMu.Unlock()
+}
+
+const maxSharingUploadDrain = 256 * 1024
+
+// maxMultipartOverhead is the room for the boundaries, the headers and the
+// other fields of a multipart upload beyond the max file size
+const maxMultipartOverhead = 1024 * 1024
+
+// SharingUpload receives a file for an upload share, either as a raw body
+// named by the File-Path header or as the file field of a multipart form.
+// The file is put into the shared folder with the permissions of the creator.
+func SharingUpload(c *gin.Context) {
+	defer func() {
+		// drain a little of the body left so that the connection may be
+		// reused, the rest isn't read as the visitor is anonymous
+		_, _ = io.CopyN(io.Discard, c.Request.Body, maxSharingUploadDrain)
+		_ = c.Request.Body.Close()
+	}()
+	sid := c.Request.Context().Value(conf.SharingIDKey).(string)
+	s, err := op.GetSharingById(sid)
+	if err == nil {
+		if !s.Valid() {
+			err = errs.InvalidSharing
+		} else if !s.Verify(c.Query("pwd")) {
+			err = errs.WrongShareCode
+		} else if !s.Upload {
+			err = errors.New("the share doesn't accept uploads")
+		}
+	}
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
+		return
+	}
+
+	var (
+		name     string
+		size     int64
+		reader   io.Reader
+		mimetype string
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		// the form is spooled to the disk before the size of the file is
+		// known, so the whole body is capped
+		if s.MaxFileSize > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.MaxFileSize+maxMultipartOverhead)
+		}
+		file, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				common.ErrorStrResp(c, "the file is too large", 413)
+				return
+			}
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+		defer f.Close()
+		name, size, reader = file.Filename, file.Size, f
+		mimetype = file.Header.Get("Content-Type")
+	} else {
+		name, err = url.PathUnescape(c.GetHeader("File-Path"))
+		if err != nil {
+			common.ErrorResp(c, err, 400)
+			return
+		}
+		size = c.Request.ContentLength
+		if size < 0 {
+			if sizeStr := c.GetHeader("X-File-Size"); sizeStr != "" {
+				if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+					common.ErrorResp(c, err, 400)
+					return
+				}
+			}
+		}
+		reader = c.Request.Body
+		// the size declared by the header isn't enforced by net/http, so the
+		// body is capped to it and to the max file size
+		limit := size
+		if s.MaxFileSize > 0 && (limit < 0 || limit > s.MaxFileSize) {
+			limit = s.MaxFileSize
+		}
+		if limit >= 0 {
+			reader = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		mimetype = c.GetHeader("Content-Type")
+	}
+	name = stdpath.Base(utils.FixAndCleanPath(name))
+	if name == "/" {
+		common.ErrorStrResp(c, "missing file name", 400)
+		return
+	}
+	if shouldIgnoreSystemFile(name) {
+		common.ErrorStrResp(c, errs.IgnoredSystemFile.Error(), 403)
+		return
+	}
+	if !s.ExtAllowed(name) {
+		common.ErrorStrResp(c, "the file type is not allowed", 403)
+		return
+	}
+	if s.MaxFileSize > 0 && (size < 0 || size > s.MaxFileSize) {
+		common.ErrorStrResp(c, "the file is too large", 413)
+		return
+	}
+	dir := s.Files[0]
+	if ok, err := canUploadTo(s.Creator, dir); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	} else if !ok {
+		common.ErrorResp(c, errs.PermissionDenied, 403)
+		return
+	}
+	ctx := context.WithValue(c.Request.Context(), conf.UserKey, s.Creator)
+	// the name given to the file isn't told to the visitor, whom it would
+	// tell that the name is taken
+	submitted := name
+	name, err = reserveName(ctx, dir, name)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	defer releaseName(dir, name)
+	if ok, err := reserveSharingUpload(sid); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	} else if !ok {
+		common.ErrorStrResp(c, "the share doesn't accept more files", 403)
+		return
+	}
+	if len(mimetype) == 0 {
+		mimetype = utils.GetMimeType(name)
+	}
+	err = fs.PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     size,
+			Modified: getLastModified(c),
+		},
+		Reader:   reader,
+		Mimetype: mimetype,
+	})
+	if err != nil {
+		releaseSharingUpload(sid)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			common.ErrorStrResp(c, "the file is too large", 413)
+			return
+		}
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	recordSharingUpload(c, s, stdpath.Join("/", name), size)
+	common.SuccessResp(c, gin.H{
+		"name": submitted,
+	})
+}
@@ -0,0 +1,211 @@
+package handles
+
+import (
+	"fmt"
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sharing"
+	"github.com/OpenListTeam/OpenList/v4/internal/subtitle"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type RelatedFileResp struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Format string `json:"format"`
+	Lang   string `json:"lang,omitempty"`
+	Label  string `json:"label,omitempty"`
+	URL    string `json:"url"`
+	// VttURL is the subtitle converted to WebVTT
+	VttURL string `json:"vtt_url,omitempty"`
+}
+
+type FsRelatedResp struct {
+	Subtitles []RelatedFileResp `json:"subtitles"`
+	Audios    []RelatedFileResp `json:"audios"`
+	Posters   []RelatedFileResp `json:"posters"`
+}
+
+// toRelatedResp builds the response with urlOf, which returns the url of the
+// file at the path under prefix, e.g. d or st
+func toRelatedResp(r *subtitle.Related, urlOf func(prefix string, obj model.Obj) string) FsRelatedResp {
+	convert := func(tracks []subtitle.Track, subtitles bool) []RelatedFileResp {
+		return utils.MustSliceConvert(tracks, func(t subtitle.Track) RelatedFileResp {
+			resp := RelatedFileResp{
+				Name:   t.Obj.GetName(),
+				Size:   t.Obj.GetSize(),
+				Format: t.Format,
+				Lang:   t.Lang,
+				Label:  t.Label,
+				URL:    urlOf("d", t.Obj),
+			}
+			if subtitles && subtitle.Convertible(t.Format) {
+				resp.VttURL = urlOf("st", t.Obj)
+			}
+			return resp
+		})
+	}
+	return FsRelatedResp{
+		Subtitles: convert(r.Subtitles, true),
+		Audios:    convert(r.Audios, false),
+		Posters:   convert(r.Posters, false),
+	}
+}
+
+func FsRelatedSplit(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if strings.HasPrefix(req.Path, "/@s") {
+		req.Path = strings.TrimPrefix(req.Path, "/@s")
+		SharingRelated(c, &req)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if user.IsGuest() && user.Disabled {
+		common.ErrorStrResp(c, "Guest user is disabled, login please", 401)
+		return
+	}
+	FsRelated(c, &req, user)
+}
+
+// FsRelated returns the subtitles, the external audio tracks and the posters
+// of a video
+func FsRelated(c *gin.Context, req *FsGetReq, user *model.User) {
+	reqPath, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	if !common.CanAccess(user, meta, reqPath, req.Password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return
+	}
+	obj, err := fs.Get(c.Request.Context(), reqPath, &fs.GetArgs{})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if obj.IsDir() {
+		common.ErrorResp(c, errs.NotFile, 400)
+		return
+	}
+	parent := stdpath.Dir(reqPath)
+	parentMeta, err := op.GetNearestMeta(parent)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	// the hidden files are left out as they are in the listing of the parent
+	common.GinAppendValues(c, conf.MetaKey, parentMeta)
+	objs, err := fs.List(c.Request.Context(), parent, &fs.ListArgs{})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	encrypt := isEncrypt(parentMeta, parent)
+	common.SuccessResp(c, toRelatedResp(subtitle.Resolve(obj, objs), func(prefix string, o model.Obj) string {
+		query := ""
+		if s := common.Sign(o, parent, encrypt); s != "" {
+			query = "?sign=" + s
+		}
+		return fmt.Sprintf("%s/%s%s%s", common.GetApiUrl(c), prefix,
+			utils.EncodePath(stdpath.Join(parent, o.GetName()), true), query)
+	}))
+}
+
+func SharingRelated(c *gin.Context, req *FsGetReq) {
+	sid, path, _ := strings.Cut(strings.TrimPrefix(req.Path, "/"), "/")
+	if sid == "" {
+		common.ErrorStrResp(c, "invalid share id", 400)
+		return
+	}
+	args := model.SharingListArgs{Pwd: req.Password}
+	s, obj, err := sharing.Get(c.Request.Context(), sid, path, args)
+	if dealError(c, err) || dealError(c, checkSharingClient(c, s)) {
+		return
+	}
+	if obj.IsDir() {
+		common.ErrorResp(c, errs.NotFile, 400)
+		return
+	}
+	parent := stdpath.Dir(utils.FixAndCleanPath(path))
+	_, objs, err := sharing.List(c.Request.Context(), sid, parent, args)
+	if dealError(c, err) {
+		return
+	}
+	common.SuccessResp(c, toRelatedResp(subtitle.Resolve(obj, objs), func(prefix string, o model.Obj) string {
+		fakePath := fmt.Sprintf("/%s%s", sid, stdpath.Join(parent, o.GetName()))
+		url := fmt.Sprintf("%s/s%s%s", common.GetApiUrl(c), prefix, utils.EncodePath(fakePath, true))
+		if s.Pwd != "" {
+			url += "?pwd=" + s.Pwd
+		}
+		return url
+	}))
+}
+
+func subtitleErrorPage(c *gin.Context, err error) {
+	if errors.Is(err, errs.NotSupport) {
+		common.ErrorPage(c, err, 400)
+	} else {
+		common.ErrorPage(c, err, 500)
+	}
+}
+
+// Subtitle serves a subtitle converted to WebVTT
+func Subtitle(c *gin.Context) {
+	rawPath := c.Request.Context().Value(conf.PathKey).(string)
+	link, obj, err := fs.Link(c.Request.Context(), rawPath, model.LinkArgs{
+		Header: c.Request.Header,
+		Type:   c.Query("type"),
+	})
+	if err != nil {
+		common.ErrorPage(c, err, 500)
+		return
+	}
+	defer link.Close()
+	data, err := subtitle.VTT(c.Request.Context(), link, obj)
+	if err != nil {
+		subtitleErrorPage(c, err)
+		return
+	}
+	c.Data(200, "text/vtt; charset=utf-8", data)
+}
+
+func SharingSubtitle(c *gin.Context) {
+	sid := c.Request.Context().Value(conf.SharingIDKey).(string)
+	path := c.Request.Context().Value(conf.PathKey).(string)
+	s, link, obj, err := sharing.Link(c.Request.Context(), sid, path, &sharing.LinkArgs{
+		SharingListArgs: model.SharingListArgs{Pwd: c.Query("pwd")},
+		LinkArgs: model.LinkArgs{
+			Header: c.Request.Header,
+			Type:   c.Query("type"),
+		},
+	})
+	if dealErrorPage(c, err) || dealErrorPage(c, checkSharingClient(c, s)) {
+		return
+	}
+	defer link.Close()
+	data, err := subtitle.VTT(c.Request.Context(), link, obj)
+	if err != nil {
+		subtitleErrorPage(c, err)
+		return
+	}
+	c.Data(200, "text/vtt; charset=utf-8", data)
+}
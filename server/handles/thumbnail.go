@@ -0,0 +1,60 @@
+package handles
+
+import (
+	"fmt"
+	"net/http"
+	stdpath "path"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/thumbnail"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// getThumbURL prefers the thumbnail provided by the driver and falls back
+// to the one generated by the thumbnail service
+func getThumbURL(c *gin.Context, obj model.Obj, parent, sign string) string {
+	if thumb, ok := model.GetThumb(obj); ok && thumb != "" {
+		return thumb
+	}
+	if obj.IsDir() || !thumbnail.Supported(obj.GetName()) {
+		return ""
+	}
+	return thumbnailURL(c, stdpath.Join(parent, obj.GetName()), sign)
+}
+
+func thumbnailURL(c *gin.Context, path, sign string) string {
+	query := ""
+	if sign != "" {
+		query = "?sign=" + sign
+	}
+	return fmt.Sprintf("%s/t%s%s", common.GetApiUrl(c), utils.EncodePath(path, true), query)
+}
+
+func Thumbnail(c *gin.Context) {
+	rawPath := c.Request.Context().Value(conf.PathKey).(string)
+	rc, obj, err := thumbnail.Get(c.Request.Context(), rawPath)
+	if err != nil {
+		if errors.Is(err, errs.NotSupport) || errors.Is(err, errs.NotFile) {
+			common.ErrorPage(c, err, 404)
+		} else {
+			common.ErrorPage(c, err, 500)
+		}
+		return
+	}
+	defer rc.Close()
+	c.Header("Cache-Control", "max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", obj.ModTime(), rc)
+}
+
+func ClearThumbnail(c *gin.Context) {
+	if err := thumbnail.Clear(); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
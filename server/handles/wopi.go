@@ -0,0 +1,325 @@
+package handles
+
+import (
+	"fmt"
+	"net/url"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/wopi"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type FsWOPIResp struct {
+	FileID         string `json:"file_id"`
+	WOPISrc        string `json:"wopi_src"`
+	AccessToken    string `json:"access_token"`
+	AccessTokenTTL int64  `json:"access_token_ttl"`
+	Write          bool   `json:"write"`
+	URL            string `json:"url,omitempty"`
+}
+
+// FsWOPI grants the user an access token of a file for the office editor,
+// the editor can write the file if the user can
+func FsWOPI(c *gin.Context) {
+	var req FsGetReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	reqPath, ok := textPath(c, req.Path, req.Password, false)
+	if !ok {
+		return
+	}
+	obj, err := fs.Get(c.Request.Context(), reqPath, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	if obj.IsDir() {
+		common.ErrorResp(c, errs.NotFile, 400)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	write, err := canWritePath(user, reqPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	_, password, err := wopiPassword(reqPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	id := wopi.FileID(reqPath)
+	resp := FsWOPIResp{
+		FileID:         id,
+		WOPISrc:        common.GetApiUrl(c) + "/wopi/files/" + id,
+		AccessToken:    wopi.NewToken(id, password, wopi.Token{UserID: user.ID, Write: write}),
+		AccessTokenTTL: time.Now().Add(sign.WOPIExpiration()).UnixMilli(),
+		Write:          write,
+	}
+	if editor := setting.GetStr(conf.WOPIEditorURL); editor != "" {
+		resp.URL = strings.ReplaceAll(editor, "$wopi_src", url.QueryEscape(resp.WOPISrc))
+	}
+	common.SuccessResp(c, resp)
+}
+
+// canWritePath reports whether the user can write the content of the file
+// at path
+func canWritePath(user *model.User, path string) (bool, error) {
+	parentPath := stdpath.Dir(path)
+	parentMeta, err := op.GetNearestMeta(parentPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return false, err
+	}
+	return (user.CanWriteContent() || common.CanWriteContentBypassUserPerms(parentMeta, parentPath)) &&
+		common.CanWrite(user, parentMeta, parentPath), nil
+}
+
+// wopiPassword returns the password of the meta protecting the file at
+// path, the tokens are bound to it so that they are revoked when it changes
+func wopiPassword(path string) (*model.Meta, string, error) {
+	meta, err := op.GetNearestMeta(path)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return nil, "", err
+	}
+	if meta != nil && common.MetaCoversPath(meta.Path, path, meta.PSub) {
+		return meta, meta.Password, nil
+	}
+	return meta, "", nil
+}
+
+// wopiFile verifies the access token of a WOPI request and returns the path
+// of its file with the token, the user is put in the context of the request.
+// The permissions of the user are checked again on every request, so the
+// token can't write the file once the user no longer can.
+func wopiFile(c *gin.Context) (string, *wopi.Token, bool) {
+	id := c.Param("id")
+	path, err := wopi.FilePath(id)
+	if err != nil {
+		c.AbortWithStatus(404)
+		return "", nil, false
+	}
+	meta, password, err := wopiPassword(path)
+	if err != nil {
+		wopiError(c, err)
+		return "", nil, false
+	}
+	token, err := wopi.ParseToken(id, password, c.Query("access_token"))
+	if err != nil {
+		c.AbortWithStatus(401)
+		return "", nil, false
+	}
+	user, err := op.GetUserById(token.UserID)
+	if err != nil || user.Disabled {
+		c.AbortWithStatus(401)
+		return "", nil, false
+	}
+	if !utils.IsSubPath(user.BasePath, path) || !common.CanAccess(user, meta, path, password) {
+		c.AbortWithStatus(401)
+		return "", nil, false
+	}
+	if token.Write {
+		if token.Write, err = canWritePath(user, path); err != nil {
+			wopiError(c, err)
+			return "", nil, false
+		}
+	}
+	common.GinAppendValues(c, conf.UserKey, user)
+	return path, token, true
+}
+
+func wopiVersion(obj model.Obj) string {
+	return fmt.Sprintf("%d-%d", obj.ModTime().UnixNano(), obj.GetSize())
+}
+
+func wopiError(c *gin.Context, err error) {
+	if errs.IsObjectNotFound(err) {
+		c.AbortWithStatus(404)
+	} else {
+		log.Errorf("wopi %s %s: %+v", c.Request.Method, c.Request.URL.Path, err)
+		c.AbortWithStatus(500)
+	}
+}
+
+// WOPICheckFileInfo returns the properties of the file and the permissions
+// of the user on it
+func WOPICheckFileInfo(c *gin.Context) {
+	path, token, ok := wopiFile(c)
+	if !ok {
+		return
+	}
+	obj, err := fs.Get(c.Request.Context(), path, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		wopiError(c, err)
+		return
+	}
+	if obj.IsDir() {
+		c.AbortWithStatus(404)
+		return
+	}
+	user := c.Request.Context().Value(conf.UserKey).(*model.User)
+	c.JSON(200, gin.H{
+		"BaseFileName":            obj.GetName(),
+		"OwnerId":                 strconv.FormatUint(uint64(user.ID), 10),
+		"UserId":                  strconv.FormatUint(uint64(user.ID), 10),
+		"UserFriendlyName":        user.Username,
+		"Size":                    obj.GetSize(),
+		"Version":                 wopiVersion(obj),
+		"LastModifiedTime":        obj.ModTime().UTC().Format(time.RFC3339),
+		"ReadOnly":                !token.Write,
+		"UserCanWrite":            token.Write,
+		"UserCanNotWriteRelative": true,
+		"SupportsLocks":           true,
+		"SupportsGetLock":         true,
+		"SupportsUpdate":          true,
+		"SupportsRename":          false,
+	})
+}
+
+// WOPIGetFile returns the content of the file
+func WOPIGetFile(c *gin.Context) {
+	path, _, ok := wopiFile(c)
+	if !ok {
+		return
+	}
+	link, obj, err := fs.Link(c.Request.Context(), path, model.LinkArgs{})
+	if err != nil {
+		wopiError(c, err)
+		return
+	}
+	defer link.Close()
+	c.Header("X-WOPI-ItemVersion", wopiVersion(obj))
+	link = common.ProxyRange(c, link, obj.GetSize())
+	Writer := &common.WrittenResponseWriter{ResponseWriter: c.Writer}
+	if err := common.Proxy(Writer, c.Request, link, obj); err != nil {
+		if Writer.IsWritten() {
+			log.Errorf("wopi get file %s: %+v", path, err)
+		} else {
+			wopiError(c, err)
+		}
+	}
+}
+
+// wopiConflict responds the current lock of a lock mismatch
+func wopiConflict(c *gin.Context, lock, reason string) {
+	c.Header("X-WOPI-Lock", lock)
+	c.Header("X-WOPI-LockFailureReason", reason)
+	c.AbortWithStatus(409)
+}
+
+// WOPIPutFile writes the file, which must be locked by the editor unless it
+// is empty
+func WOPIPutFile(c *gin.Context) {
+	defer func() { _ = c.Request.Body.Close() }()
+	path, token, ok := wopiFile(c)
+	if !ok {
+		return
+	}
+	if c.GetHeader("X-WOPI-Override") != "PUT" {
+		c.AbortWithStatus(501)
+		return
+	}
+	if !token.Write {
+		c.AbortWithStatus(401)
+		return
+	}
+	ctx := c.Request.Context()
+	lock := c.GetHeader("X-WOPI-Lock")
+	cur, ok := wopi.CheckLock(path, lock)
+	if !ok {
+		wopiConflict(c, cur, "locked by another editor")
+		return
+	}
+	if cur == "" {
+		obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+		if err != nil && !errs.IsObjectNotFound(err) {
+			wopiError(c, err)
+			return
+		}
+		if obj != nil && obj.GetSize() > 0 {
+			wopiConflict(c, "", "the file is not locked")
+			return
+		}
+	}
+	dir, name := stdpath.Split(path)
+	err := fs.PutDirectly(ctx, dir, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     c.Request.ContentLength,
+			Modified: time.Now(),
+		},
+		Reader:   c.Request.Body,
+		Mimetype: utils.GetMimeType(name),
+	})
+	if err != nil {
+		wopiError(c, err)
+		return
+	}
+	if obj, err := fs.Get(ctx, path, &fs.GetArgs{NoLog: true}); err == nil {
+		c.Header("X-WOPI-ItemVersion", wopiVersion(obj))
+	}
+	c.Status(200)
+}
+
+// WOPIFileOperation handles the lock operations on the file
+func WOPIFileOperation(c *gin.Context) {
+	path, token, ok := wopiFile(c)
+	if !ok {
+		return
+	}
+	override := c.GetHeader("X-WOPI-Override")
+	if override == "GET_LOCK" {
+		c.Header("X-WOPI-Lock", wopi.GetLock(path))
+		c.Status(200)
+		return
+	}
+	var lockFunc func(path, id string) (string, bool)
+	switch override {
+	case "LOCK":
+		oldLock := c.GetHeader("X-WOPI-OldLock")
+		lockFunc = func(path, id string) (string, bool) {
+			return wopi.Lock(path, id, oldLock)
+		}
+	case "REFRESH_LOCK":
+		lockFunc = wopi.RefreshLock
+	case "UNLOCK":
+		lockFunc = wopi.Unlock
+	default:
+		c.AbortWithStatus(501)
+		return
+	}
+	if !token.Write {
+		c.AbortWithStatus(401)
+		return
+	}
+	lock := c.GetHeader("X-WOPI-Lock")
+	if lock == "" {
+		c.AbortWithStatus(400)
+		return
+	}
+	if _, err := fs.Get(c.Request.Context(), path, &fs.GetArgs{NoLog: true}); err != nil {
+		wopiError(c, err)
+		return
+	}
+	if cur, ok := lockFunc(path, lock); !ok {
+		wopiConflict(c, cur, "lock mismatch")
+		return
+	}
+	c.Status(200)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/base"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/OpenListTeam/OpenList/v4/server/nfs"
+	gonfs "github.com/willscott/go-nfs"
+)
+
+type NfsServer struct {
+	handler  *nfs.Handler
+	listener net.Listener
+	prune    *cron.Cron
+}
+
+func NewNfsServer() (*NfsServer, error) {
+	ftp.InitStage()
+	listener, err := net.Listen("tcp", conf.Conf.NFS.Listen)
+	if err != nil {
+		return nil, err
+	}
+	s := &NfsServer{
+		handler: nfs.NewHandler(conf.Conf.NFS.User, http.Header{
+			"User-Agent": {base.UserAgent},
+		}),
+		listener: &nfsListener{Listener: listener},
+	}
+	if conf.Conf.NFS.HandleExpiry > 0 {
+		expiry := time.Duration(conf.Conf.NFS.HandleExpiry) * 24 * time.Hour
+		s.prune = cron.NewCron(24 * time.Hour)
+		s.prune.Do(func() {
+			if err := op.PruneNfsHandles(expiry); err != nil {
+				utils.Log.Warnf("failed prune nfs handles: %+v", err)
+			}
+		})
+	}
+	return s, nil
+}
+
+func (s *NfsServer) Serve() error {
+	err := gonfs.Serve(s.listener, s.handler)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *NfsServer) Close() error {
+	if s.prune != nil {
+		s.prune.Stop()
+	}
+	s.handler.Close()
+	return s.listener.Close()
+}
+
+// nfsListener drops the connections of clients outside of the allowed IPs
+type nfsListener struct {
+	net.Listener
+}
+
+func (l *nfsListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		if utils.IPAllowed(ip, conf.Conf.NFS.AllowedIPs) {
+			return conn, nil
+		}
+		utils.Log.Warnf("nfs connection from %s is not allowed", ip)
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,177 @@
+package nfs
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/go-git/go-billy/v5"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	nfs "github.com/willscott/go-nfs"
+)
+
+// Handler exports the virtual filesystem over NFS with the permissions of a
+// single user. File handles are the ids of the paths persisted by
+// op.GetNfsHandle, so they survive restarts and follow renames.
+type Handler struct {
+	userName    string
+	proxyHeader http.Header
+	root        *VFS
+	readers     *readerCache
+}
+
+func NewHandler(userName string, proxyHeader http.Header) *Handler {
+	h := &Handler{
+		userName:    userName,
+		proxyHeader: proxyHeader,
+		readers:     newReaderCache(),
+	}
+	h.root = &VFS{h: h, root: "/"}
+	return h
+}
+
+// context looks the user up on every call so that disabling the user or
+// revoking its permissions takes effect immediately
+func (h *Handler) context() (context.Context, error) {
+	user, err := op.GetUserByName(h.userName)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled || !user.CanFTPAccess() {
+		return nil, errs.PermissionDenied
+	}
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, conf.UserKey, user)
+	ctx = context.WithValue(ctx, conf.MetaPassKey, "")
+	ctx = context.WithValue(ctx, conf.ProxyHeaderKey, h.proxyHeader)
+	return ctx, nil
+}
+
+func (h *Handler) reqPath(ctx context.Context, path string) (string, error) {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	return user.JoinPath(path)
+}
+
+func (h *Handler) handleId(ctx context.Context, path string) (uint64, error) {
+	reqPath, err := h.reqPath(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	return op.GetNfsHandle(reqPath)
+}
+
+func (h *Handler) Mount(_ context.Context, _ net.Conn, req nfs.MountRequest) (nfs.MountStatus, billy.Filesystem, []nfs.AuthFlavor) {
+	ctx, err := h.context()
+	if err != nil {
+		return nfs.MountStatusErrAcces, nil, nil
+	}
+	dirPath := utils.FixAndCleanPath(string(req.Dirpath))
+	info, err := ftp.Stat(ctx, dirPath)
+	if err != nil {
+		if errs.IsNotFoundError(err) {
+			return nfs.MountStatusErrNoEnt, nil, nil
+		}
+		return nfs.MountStatusErrAcces, nil, nil
+	}
+	if !info.IsDir() {
+		return nfs.MountStatusErrNotDir, nil, nil
+	}
+	root, _ := h.root.Chroot(dirPath)
+	return nfs.MountStatusOk, root, []nfs.AuthFlavor{nfs.AuthFlavorNull}
+}
+
+// Change returns nil as the export is read-only
+func (h *Handler) Change(_ billy.Filesystem) billy.Change {
+	return nil
+}
+
+func (h *Handler) FSStat(_ context.Context, f billy.Filesystem, stat *nfs.FSStat) error {
+	ctx, err := h.context()
+	if err != nil {
+		return err
+	}
+	user := ctx.Value(conf.UserKey).(*model.User)
+	if user.IsGuest() || setting.GetBool(conf.HideStorageDetails) {
+		return nil
+	}
+	reqPath, err := h.reqPath(ctx, f.Root())
+	if err != nil {
+		return err
+	}
+	storage, err := fs.GetStorage(reqPath, &fs.GetStoragesArgs{})
+	if err != nil {
+		return nil
+	}
+	details, err := op.GetStorageDetails(ctx, storage)
+	if err != nil || details == nil {
+		return nil
+	}
+	stat.TotalSize = uint64(details.TotalSpace)
+	stat.FreeSize = uint64(details.FreeSpace())
+	stat.AvailableSize = stat.FreeSize
+	return nil
+}
+
+func (h *Handler) ToHandle(f billy.Filesystem, path []string) []byte {
+	ctx, err := h.context()
+	if err != nil {
+		return nil
+	}
+	id, err := h.handleId(ctx, f.Join(append([]string{f.Root()}, path...)...))
+	if err != nil {
+		log.Errorf("failed get nfs handle of %v: %+v", path, err)
+		return nil
+	}
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+func (h *Handler) FromHandle(fh []byte) (billy.Filesystem, []string, error) {
+	if len(fh) != 8 {
+		return nil, nil, errors.New("invalid nfs handle")
+	}
+	ctx, err := h.context()
+	if err != nil {
+		return nil, nil, err
+	}
+	reqPath, err := op.GetNfsHandlePath(binary.BigEndian.Uint64(fh))
+	if err != nil {
+		return nil, nil, err
+	}
+	basePath := utils.FixAndCleanPath(ctx.Value(conf.UserKey).(*model.User).BasePath)
+	if !utils.IsSubPath(basePath, reqPath) {
+		return nil, nil, errs.PermissionDenied
+	}
+	rel := strings.Trim(strings.TrimPrefix(reqPath, basePath), "/")
+	if rel == "" {
+		return h.root, []string{}, nil
+	}
+	return h.root, strings.Split(rel, "/"), nil
+}
+
+// InvalidateHandle does nothing, handles are dropped when their object is
+// removed through internal/fs, or pruned once unused for the handle expiry
+func (h *Handler) InvalidateHandle(_ billy.Filesystem, _ []byte) error {
+	return nil
+}
+
+func (h *Handler) HandleLimit() int {
+	return math.MaxInt32
+}
+
+func (h *Handler) Close() {
+	h.readers.Close()
+}
+
+var _ nfs.Handler = (*Handler)(nil)
@@ -0,0 +1,128 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/glebarez/sqlite"
+	nfs "github.com/willscott/go-nfs"
+	nfsc "github.com/willscott/go-nfs-client/nfs"
+	"github.com/willscott/go-nfs-client/nfs/rpc"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dB, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	db.Init(dB)
+	ftp.InitStage()
+	stream.ClientDownloadLimit = rate.NewLimiter(rate.Inf, 0)
+}
+
+// mount serves a local storage over NFS on the loopback interface, and
+// mounts its root with the client
+func mount(t *testing.T) (*nfsc.Target, string) {
+	dir := t.TempDir()
+	_, err := op.CreateStorage(context.Background(), model.Storage{
+		Driver:    "Local",
+		MountPath: "/nfs_local",
+		Addition:  fmt.Sprintf(`{"root_folder_path":%q}`, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed create storage: %+v", err)
+	}
+	err = op.CreateUser(&model.User{Username: "nfs", Password: "nfs", BasePath: "/", Permission: 1 << 10})
+	if err != nil {
+		t.Fatalf("failed create user: %+v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler("nfs", http.Header{})
+	go func() {
+		_ = nfs.Serve(ln, h)
+	}()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		h.Close()
+	})
+
+	c, err := rpc.DialTCP("tcp", ln.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	mounter := &nfsc.Mount{Client: c}
+	target, err := mounter.Mount("/nfs_local", rpc.AuthNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = mounter.Unmount() })
+	return target, dir
+}
+
+func TestLoopback(t *testing.T) {
+	target, dir := mount(t)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("hello nfs"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := target.ReadDirPlus("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]uint64)
+	for _, e := range entries {
+		ids[e.Name()] = e.FileId
+	}
+	for _, name := range []string{"sub", "b.txt"} {
+		id, err := op.GetNfsHandle("/nfs_local/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[name] != id {
+			t.Errorf("the file id of %s is %d, want its handle %d", name, ids[name], id)
+		}
+	}
+
+	f, err := target.Open("/sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello nfs" {
+		t.Errorf("read %q, want %q", data, "hello nfs")
+	}
+
+	if _, err := target.Create("/c.txt", 0o666); err == nil {
+		t.Errorf("a file is created on the read-only export")
+	}
+}
@@ -0,0 +1,188 @@
+package nfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/go-git/go-billy/v5"
+)
+
+// readerIdleTimeout is how long an unused download stream is kept open
+const readerIdleTimeout = 30 * time.Second
+
+// readerCache keeps the download streams of recently read files open, so the
+// many small READ calls of an NFS client don't each resolve a new link.
+type readerCache struct {
+	mu      sync.Mutex
+	readers map[string]*cachedReader
+	done    chan struct{}
+}
+
+type cachedReader struct {
+	sync.Mutex
+	proxy    *ftp.FileDownloadProxy
+	lastUsed time.Time
+	closed   bool
+}
+
+func newReaderCache() *readerCache {
+	c := &readerCache{
+		readers: make(map[string]*cachedReader),
+		done:    make(chan struct{}),
+	}
+	go c.janitor()
+	return c
+}
+
+func readerKey(reqPath string, info os.FileInfo) string {
+	return reqPath + "\x00" + strconv.FormatInt(info.Size(), 10) + "\x00" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+}
+
+func (c *readerCache) readAt(ctx context.Context, reqPath string, info os.FileInfo, p []byte, off int64) (int, error) {
+	key := readerKey(reqPath, info)
+	for {
+		c.mu.Lock()
+		r, ok := c.readers[key]
+		if !ok {
+			r = &cachedReader{}
+			c.readers[key] = r
+		}
+		r.Lock()
+		c.mu.Unlock()
+		if r.closed {
+			r.Unlock()
+			continue
+		}
+		if r.proxy == nil {
+			proxy, err := ftp.OpenDownload(ctx, reqPath, 0)
+			if err != nil {
+				r.closed = true
+				r.Unlock()
+				c.remove(key, r)
+				return 0, err
+			}
+			r.proxy = proxy
+		}
+		r.lastUsed = time.Now()
+		n, err := r.proxy.ReadAt(p, off)
+		r.Unlock()
+		return n, err
+	}
+}
+
+func (c *readerCache) remove(key string, r *cachedReader) {
+	c.mu.Lock()
+	if c.readers[key] == r {
+		delete(c.readers, key)
+	}
+	c.mu.Unlock()
+}
+
+func (c *readerCache) janitor() {
+	ticker := time.NewTicker(readerIdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			c.closeIdle(0)
+			return
+		case <-ticker.C:
+			c.closeIdle(readerIdleTimeout)
+		}
+	}
+}
+
+func (c *readerCache) closeIdle(idle time.Duration) {
+	c.mu.Lock()
+	var expired []*cachedReader
+	for key, r := range c.readers {
+		if !r.TryLock() {
+			continue
+		}
+		if time.Since(r.lastUsed) >= idle {
+			r.closed = true
+			delete(c.readers, key)
+			expired = append(expired, r)
+		}
+		r.Unlock()
+	}
+	c.mu.Unlock()
+	for _, r := range expired {
+		if r.proxy != nil {
+			_ = r.proxy.Close()
+		}
+	}
+}
+
+func (c *readerCache) Close() {
+	close(c.done)
+}
+
+// file is a read-only handle of a file of the virtual filesystem
+type file struct {
+	vfs     *VFS
+	ctx     context.Context
+	name    string
+	reqPath string
+	info    os.FileInfo
+	offset  int64
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.info.Size() {
+		return 0, io.EOF
+	}
+	return f.vfs.h.readers.readAt(f.ctx, f.reqPath, f.info, p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Write(_ []byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (f *file) Truncate(_ int64) error {
+	return billy.ErrReadOnly
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package nfs
+
+import (
+	"context"
+	"os"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/go-git/go-billy/v5"
+	"github.com/pkg/errors"
+	nfsfile "github.com/willscott/go-nfs/file"
+)
+
+// VFS is a read-only billy.Filesystem view of the paths visible to the user
+// of a Handler. Its paths are relative to root, which is itself relative to
+// the base path of the user.
+type VFS struct {
+	h    *Handler
+	root string
+}
+
+func (v *VFS) path(name string) string {
+	return stdpath.Join(v.root, name)
+}
+
+// toOsErr maps the errors of the virtual filesystem to the os errors go-nfs
+// translates into NFS status codes
+func toOsErr(op, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errs.IsNotFoundError(err):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case errs.IsObjectAlreadyExists(err):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+	case errs.IsNotSupportError(err), errs.IsNotImplementError(err):
+		return &os.PathError{Op: op, Path: name, Err: billy.ErrNotSupported}
+	case errors.Is(err, errs.PermissionDenied), errors.Is(err, errs.RelativePath):
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo reports read-only modes and the persisted handle id as file id,
+// so that inode numbers stay stable across restarts
+type fileInfo struct {
+	os.FileInfo
+	id uint64
+}
+
+func (f *fileInfo) Mode() os.FileMode {
+	if f.IsDir() {
+		return os.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (f *fileInfo) Sys() any {
+	return &nfsfile.FileInfo{Nlink: 1, Fileid: f.id}
+}
+
+func (v *VFS) wrapInfo(ctx context.Context, name string, info os.FileInfo) (os.FileInfo, error) {
+	id, err := v.h.handleId(ctx, v.path(name))
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info, id: id}, nil
+}
+
+func (v *VFS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (v *VFS) Open(filename string) (billy.File, error) {
+	return v.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (v *VFS) OpenFile(filename string, flag int, _ os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+	ctx, err := v.h.context()
+	if err != nil {
+		return nil, toOsErr("open", filename, err)
+	}
+	p := v.path(filename)
+	info, err := ftp.Stat(ctx, p)
+	if err != nil {
+		return nil, toOsErr("open", filename, err)
+	}
+	if info.IsDir() {
+		return nil, toOsErr("open", filename, errs.NotFile)
+	}
+	reqPath, err := v.h.reqPath(ctx, p)
+	if err != nil {
+		return nil, toOsErr("open", filename, err)
+	}
+	return &file{vfs: v, ctx: ctx, name: filename, reqPath: reqPath, info: info}, nil
+}
+
+func (v *VFS) Stat(filename string) (os.FileInfo, error) {
+	ctx, err := v.h.context()
+	if err != nil {
+		return nil, toOsErr("stat", filename, err)
+	}
+	info, err := ftp.Stat(ctx, v.path(filename))
+	if err != nil {
+		return nil, toOsErr("stat", filename, err)
+	}
+	return v.wrapInfo(ctx, filename, info)
+}
+
+func (v *VFS) Lstat(filename string) (os.FileInfo, error) {
+	return v.Stat(filename)
+}
+
+func (v *VFS) ReadDir(path string) ([]os.FileInfo, error) {
+	ctx, err := v.h.context()
+	if err != nil {
+		return nil, toOsErr("readdir", path, err)
+	}
+	infos, err := ftp.List(ctx, v.path(path))
+	if err != nil {
+		return nil, toOsErr("readdir", path, err)
+	}
+	// the handles of the entries are got in one batch, and cached for the
+	// lookups of the client following the listing
+	paths := make([]string, len(infos))
+	for i, info := range infos {
+		if paths[i], err = v.h.reqPath(ctx, v.path(stdpath.Join(path, info.Name()))); err != nil {
+			return nil, toOsErr("readdir", path, err)
+		}
+	}
+	ids, err := op.GetNfsHandles(paths)
+	if err != nil {
+		return nil, toOsErr("readdir", path, err)
+	}
+	for i, info := range infos {
+		infos[i] = &fileInfo{FileInfo: info, id: ids[i]}
+	}
+	return infos, nil
+}
+
+func (v *VFS) Rename(_, _ string) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Remove(_ string) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) MkdirAll(_ string, _ os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) TempFile(_, _ string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (v *VFS) Symlink(_, _ string) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Readlink(_ string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+func (v *VFS) Chmod(_ string, _ os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Lchown(_ string, _, _ int) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Chown(_ string, _, _ int) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Chtimes(_ string, _ time.Time, _ time.Time) error {
+	return billy.ErrReadOnly
+}
+
+func (v *VFS) Join(elem ...string) string {
+	return stdpath.Join(elem...)
+}
+
+func (v *VFS) Chroot(path string) (billy.Filesystem, error) {
+	return &VFS{h: v.h, root: v.path(path)}, nil
+}
+
+func (v *VFS) Root() string {
+	return v.root
+}
+
+func (v *VFS) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability
+}
+
+var _ billy.Filesystem = (*VFS)(nil)
@@ -50,6 +50,15 @@ func Init(e *gin.Engine) {
 	g.GET("/p/*path", middlewares.PathParse, signCheck, downloadLimiter, handles.Proxy)
 	g.HEAD("/d/*path", middlewares.PathParse, signCheck, handles.Down)
 	g.HEAD("/p/*path", middlewares.PathParse, signCheck, handles.Proxy)
+	g.GET("/t/*path", middlewares.PathParse, signCheck, handles.Thumbnail)
+	g.HEAD("/t/*path", middlewares.PathParse, signCheck, handles.Thumbnail)
+	g.GET("/st/*path", middlewares.PathParse, signCheck, handles.Subtitle)
+	g.GET("/hls/:key/:name", handles.HLS)
+	wopi := g.Group("/wopi/files")
+	wopi.GET("/:id", handles.WOPICheckFileInfo)
+	wopi.POST("/:id", handles.WOPIFileOperation)
+	wopi.GET("/:id/contents", handles.WOPIGetFile)
+	wopi.POST("/:id/contents", handles.WOPIPutFile)
 	archiveSignCheck := middlewares.Down(sign.VerifyArchive)
 	g.GET("/ad/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, handles.ArchiveDown)
 	g.GET("/ap/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, handles.ArchiveProxy)
@@ -66,6 +75,9 @@ func Init(e *gin.Engine) {
 	g.GET("/sad/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, downloadLimiter, handles.SharingArchiveExtract)
 	g.HEAD("/sad/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, handles.SharingArchiveExtract)
 	g.HEAD("/sad/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, handles.SharingArchiveExtract)
+	g.GET("/sst/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, handles.SharingSubtitle)
+	g.PUT("/su/:sid", middlewares.SharingIdParse, handles.SharingUpload)
+	g.POST("/su/:sid", middlewares.SharingIdParse, handles.SharingUpload)
 
 	api := g.Group("/api")
 	auth := api.Group("", middlewares.Auth(false))
@@ -184,6 +196,13 @@ func admin(g *gin.RouterGroup) {
 	index.POST("/clear", middlewares.SearchIndex, handles.ClearIndex)
 	index.GET("/progress", middlewares.SearchIndex, handles.GetProgress)
 
+	blockCache := g.Group("/block_cache")
+	blockCache.GET("/stats", handles.GetBlockCacheStats)
+	blockCache.POST("/clear", handles.ClearBlockCache)
+
+	g.POST("/thumbnail/clear", handles.ClearThumbnail)
+	g.POST("/hls/clear", handles.ClearHLS)
+
 	scan := g.Group("/scan")
 	scan.POST("/start", handles.StartManualScan)
 	scan.POST("/stop", handles.StopManualScan)
@@ -193,6 +212,7 @@ func admin(g *gin.RouterGroup) {
 func fsAndShare(g *gin.RouterGroup) {
 	g.Any("/list", handles.FsListSplit)
 	g.Any("/get", handles.FsGetSplit)
+	g.Any("/related", handles.FsRelatedSplit)
 	a := g.Group("/archive")
 	a.Any("/meta", handles.FsArchiveMetaSplit)
 	a.Any("/list", handles.FsArchiveListSplit)
@@ -234,6 +254,28 @@ func _fs(g *gin.RouterGroup) {
 	g.POST("/torrent/generate", handles.GenerateTorrentForPath)
 	// Direct upload (client-side upload to storage)
 	g.POST("/get_direct_upload_info", middlewares.FsUp, handles.FsGetDirectUploadInfo)
+	g.POST("/hls", handles.FsHLS)
+	g.POST("/wopi", handles.FsWOPI)
+	text := g.Group("/text")
+	text.POST("/get", handles.FsTextGet)
+	text.POST("/save", handles.FsTextSave)
+	text.POST("/history", handles.FsTextHistory)
+	text.POST("/version", handles.FsTextVersion)
+	text.POST("/revert", handles.FsTextRevert)
+	versions := g.Group("/versions")
+	versions.POST("/list", handles.FsVersions)
+	versions.POST("/restore", handles.FsRestoreVersion)
+	versions.POST("/delete", handles.FsDeleteVersion)
+	// photo timeline and albums from the cached media meta
+	photos := g.Group("/photos")
+	photos.POST("/timeline", handles.PhotoTimeline)
+	albums := photos.Group("/albums", middlewares.AuthNotGuest)
+	albums.GET("/list", handles.ListPhotoAlbums)
+	albums.POST("/create", handles.CreatePhotoAlbum)
+	albums.POST("/update", handles.UpdatePhotoAlbum)
+	albums.POST("/delete", handles.DeletePhotoAlbum)
+	albums.POST("/timeline", handles.PhotoAlbumTimeline)
+	albums.POST("/share", handles.SharePhotoAlbum)
 }
 
 func _task(g *gin.RouterGroup) {
@@ -248,6 +290,8 @@ func _sharing(g *gin.RouterGroup) {
 	g.POST("/delete", handles.DeleteSharing)
 	g.POST("/enable", handles.SetEnableSharing(false))
 	g.POST("/disable", handles.SetEnableSharing(true))
+	g.GET("/access/list", handles.ListSharingAccesses)
+	g.GET("/access/stats", handles.GetSharingAccessStats)
 }
 
 func Cors(r *gin.Engine) {
@@ -23,6 +23,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/itsHenry35/gofakes3"
 	"github.com/ncw/swift/v2"
 	log "github.com/sirupsen/logrus"
@@ -181,7 +182,7 @@ func (b *s3Backend) GetObject(ctx context.Context, bucketName, objectName string
 		return nil, err
 	}
 
-	rrf, err := stream.GetRangeReaderFromLink(size, link)
+	rrf, err := stream.GetRangeReaderFromLink(size, common.CacheRange(fp, file, link))
 	if err != nil {
 		return nil, fmt.Errorf("the remote storage driver need to be enhanced to support s3")
 	}
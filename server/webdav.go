@@ -41,6 +41,8 @@ func WebDav(dav *gin.RouterGroup) {
 	dav.Handle("PROPPATCH", "/*path", ServeWebDAV)
 	dav.Handle("COPY", "/*path", ServeWebDAV)
 	dav.Handle("MOVE", "/*path", ServeWebDAV)
+	dav.Handle("REPORT", "/*path", ServeWebDAV)
+	dav.Handle("REPORT", "", ServeWebDAV)
 }
 
 func ServeWebDAV(c *gin.Context) {
@@ -0,0 +1,114 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"maps"
+	"net/http"
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+// deadPropsKey keys the dead props loaded ahead by the walks of a request
+type deadPropsKey struct{}
+
+// deadPropsCache holds the dead props of the members of the directories
+// walked, which are loaded in one query per directory
+type deadPropsCache struct {
+	mu    sync.Mutex
+	props map[string][]model.DeadProp
+}
+
+// withDeadPropsCache lets the walks with ctx load the dead props of the
+// members of a directory at once
+func withDeadPropsCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deadPropsKey{}, &deadPropsCache{props: make(map[string][]model.DeadProp)})
+}
+
+// preloadDeadProps loads the dead props of paths into the cache of ctx
+func preloadDeadProps(ctx context.Context, paths []string) error {
+	c, ok := ctx.Value(deadPropsKey{}).(*deadPropsCache)
+	if !ok || len(paths) == 0 {
+		return nil
+	}
+	props, err := op.GetDeadPropsOfPaths(paths)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	maps.Copy(c.props, props)
+	return nil
+}
+
+// getDeadProps returns the dead properties stored for the resource at
+// reqPath, the ones loaded ahead are taken out of the cache
+func getDeadProps(ctx context.Context, reqPath string) (map[xml.Name]Property, error) {
+	var dps []model.DeadProp
+	c, _ := ctx.Value(deadPropsKey{}).(*deadPropsCache)
+	cached := false
+	if c != nil {
+		c.mu.Lock()
+		dps, cached = c.props[reqPath]
+		delete(c.props, reqPath)
+		c.mu.Unlock()
+	}
+	if !cached {
+		var err error
+		if dps, err = op.GetDeadProps(reqPath); err != nil {
+			return nil, err
+		}
+	}
+	ret := make(map[xml.Name]Property, len(dps))
+	for _, dp := range dps {
+		name := xml.Name{Space: dp.Space, Local: dp.Local}
+		ret[name] = Property{
+			XMLName:  name,
+			Lang:     dp.Lang,
+			InnerXML: []byte(dp.InnerXML),
+		}
+	}
+	return ret, nil
+}
+
+// patchDeadProps applies patches to the dead properties of the resource at
+// reqPath. Patching is atomic, so either all patches succeed with 200 OK or
+// an error is returned.
+func patchDeadProps(reqPath string, patches []Proppatch) ([]Propstat, error) {
+	// later instructions override earlier ones on the same property
+	final := make(map[xml.Name]*model.DeadProp)
+	var order []xml.Name
+	pstat := Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			pstat.Props = append(pstat.Props, Property{XMLName: p.XMLName})
+			if _, ok := final[p.XMLName]; !ok {
+				order = append(order, p.XMLName)
+			}
+			if patch.Remove {
+				final[p.XMLName] = nil
+				continue
+			}
+			final[p.XMLName] = &model.DeadProp{
+				Space:    p.XMLName.Space,
+				Local:    p.XMLName.Local,
+				Lang:     p.Lang,
+				InnerXML: string(p.InnerXML),
+			}
+		}
+	}
+	var set, remove []model.DeadProp
+	for _, name := range order {
+		if dp := final[name]; dp != nil {
+			set = append(set, *dp)
+		} else {
+			remove = append(remove, model.DeadProp{Space: name.Space, Local: name.Local})
+		}
+	}
+	if err := op.PatchDeadProps(reqPath, set, remove); err != nil {
+		return nil, err
+	}
+	return []Propstat{pstat}, nil
+}
@@ -138,6 +138,13 @@ func walkFS(ctx context.Context, depth int, name string, info model.Obj, walkFn
 	if err != nil {
 		return walkFn(name, info, err)
 	}
+	paths := make([]string, len(objs))
+	for i, obj := range objs {
+		paths[i] = path.Join(name, obj.GetName())
+	}
+	if err := preloadDeadProps(ctx, paths); err != nil {
+		return walkFn(name, info, err)
+	}
 
 	for _, fileInfo := range objs {
 		filename := path.Join(name, fileInfo.GetName())
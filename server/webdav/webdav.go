@@ -6,6 +6,7 @@
 package webdav // import "golang.org/x/net/webdav"
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io"
@@ -13,11 +14,13 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/journal"
 	"github.com/OpenListTeam/OpenList/v4/internal/net"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
@@ -88,6 +91,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		case "PROPPATCH":
 			status, err = h.handleProppatch(brw, r)
+		case "REPORT":
+			status, err = h.handleReport(brw, r)
 		}
 	}
 
@@ -206,7 +211,7 @@ func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) (status
 	allow := "OPTIONS, LOCK, PUT, MKCOL"
 	if fi, err := fs.Get(ctx, reqPath, &fs.GetArgs{}); err == nil {
 		if fi.IsDir() {
-			allow = "OPTIONS, LOCK, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND"
+			allow = "OPTIONS, LOCK, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, REPORT"
 		} else {
 			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT"
 		}
@@ -251,6 +256,14 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request) (sta
 		}
 		return http.StatusMethodNotAllowed, nil
 	}
+	etag, err := findETag(ctx, h.LockSystem, reqPath, fi)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	w.Header().Set("Etag", etag)
+	if net.CheckPreconditions(w, r, fi.ModTime()) {
+		return 0, nil
+	}
 	// Let ServeContent determine the Content-Type header.
 	storage, _ := fs.GetStorage(reqPath, &fs.GetStoragesArgs{})
 	if storage.GetStorage().Webdav302() {
@@ -277,6 +290,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request) (sta
 	}
 	defer link.Close()
 
+	link = common.CacheRange(reqPath, fi, link)
 	if storage.GetStorage().ProxyRange {
 		link = common.ProxyRange(ctx, link, fi.GetSize())
 	}
@@ -355,14 +369,15 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) (status int,
 		return status, err
 	}
 	defer release()
-	// TODO(rost): Support the If-Match, If-None-Match headers? See bradfitz'
-	// comments in http.checkEtag.
 	ctx := r.Context()
 	user := ctx.Value(conf.UserKey).(*model.User)
 	reqPath, err = user.JoinPath(reqPath)
 	if err != nil {
 		return http.StatusForbidden, err
 	}
+	if status, err := h.checkPutPreconditions(ctx, r, reqPath); status != 0 {
+		return status, err
+	}
 	size := r.ContentLength
 	if size < 0 {
 		sizeStr := r.Header.Get("X-File-Size")
@@ -420,7 +435,40 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) (status int,
 		return http.StatusInternalServerError, err
 	}
 	w.Header().Set("Etag", etag)
-	return http.StatusCreated, nil
+	// write the status to w, otherwise the Etag header set above is dropped
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(StatusText(http.StatusCreated)))
+	return 0, nil
+}
+
+// checkPutPreconditions evaluates If-Match, If-None-Match and If-Unmodified-Since
+// against the current object at reqPath, so that clients can avoid lost updates.
+func (h *Handler) checkPutPreconditions(ctx context.Context, r *http.Request, reqPath string) (status int, err error) {
+	if r.Header.Get("If-Match") == "" && r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Unmodified-Since") == "" {
+		return 0, nil
+	}
+	fi, err := fs.Get(ctx, reqPath, &fs.GetArgs{})
+	if err != nil {
+		if !errs.IsObjectNotFound(err) {
+			return http.StatusInternalServerError, err
+		}
+		// https://www.rfc-editor.org/rfc/rfc9110#section-13.1.1
+		// If-Match is false if there is no current representation
+		if r.Header.Get("If-Match") != "" {
+			return http.StatusPreconditionFailed, nil
+		}
+		return 0, nil
+	}
+	etag, err := findETag(ctx, h.LockSystem, reqPath, fi)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	cw := newBufferedResponseWriter()
+	cw.Header().Set("Etag", etag)
+	if net.CheckPreconditions(cw, r, fi.ModTime()) {
+		return cw.statusCode, nil
+	}
+	return 0, nil
 }
 
 func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request) (status int, err error) {
@@ -751,6 +799,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 	}
 
 	mw := multistatusWriter{w: w}
+	ctx = withDeadPropsCache(ctx)
 
 	walkFn := func(reqPath string, info model.Obj, err error) error {
 		if err != nil {
@@ -758,7 +807,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 		}
 		var pstats []Propstat
 		if pf.Propname != nil {
-			pnames, err := propnames(ctx, h.LockSystem, info)
+			pnames, err := propnames(ctx, h.LockSystem, reqPath, info)
 			if err != nil {
 				return err
 			}
@@ -768,9 +817,9 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 			}
 			pstats = append(pstats, pstat)
 		} else if pf.Allprop != nil {
-			pstats, err = allprop(ctx, h.LockSystem, info, pf.Prop)
+			pstats, err = allprop(ctx, h.LockSystem, reqPath, info, pf.Prop)
 		} else {
-			pstats, err = props(ctx, h.LockSystem, info, pf.Prop)
+			pstats, err = props(ctx, h.LockSystem, reqPath, info, pf.Prop)
 		}
 		if err != nil {
 			return err
@@ -843,6 +892,171 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) (statu
 	return 0, nil
 }
 
+// handleReport implements the sync-collection report of RFC 6578, which lets
+// clients fetch the members of a collection changed since a sync token
+// instead of walking the whole tree again.
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) (status int, err error) {
+	reqPath, status, err := h.stripPrefix(r.URL.Path)
+	if err != nil {
+		return status, err
+	}
+	ctx := r.Context()
+	user := ctx.Value(conf.UserKey).(*model.User)
+	password, _ := ctx.Value(conf.MetaPassKey).(string)
+	reqPath, err = user.JoinPath(reqPath)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+		return http.StatusInternalServerError, err
+	}
+	if !common.CanAccess(user, meta, reqPath, password) {
+		return http.StatusForbidden, errs.PermissionDenied
+	}
+	fi, err := fs.Get(ctx, reqPath, &fs.GetArgs{})
+	if err != nil {
+		if errs.IsNotFoundError(err) {
+			return http.StatusNotFound, err
+		}
+		return http.StatusMethodNotAllowed, err
+	}
+	sc, status, err := readReport(r.Body)
+	if err == errUnsupportedReport {
+		return 0, writeErrorBody(w, status, "<D:supported-report/>")
+	}
+	if err != nil {
+		return status, err
+	}
+	// https://www.rfc-editor.org/rfc/rfc6578#section-3.2
+	// the report is only supported on collections
+	if !fi.IsDir() || !journal.Enabled() {
+		return 0, writeErrorBody(w, http.StatusForbidden, "<D:supported-report/>")
+	}
+	depth := infiniteDepth
+	if sc.SyncLevel == "1" {
+		depth = 1
+	}
+	latest, err := journal.Latest()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	mw := multistatusWriter{w: w, syncToken: makeSyncToken(latest)}
+	ctx = withDeadPropsCache(ctx)
+	hrefOf := func(p string, isDir bool) string {
+		href := path.Join(h.Prefix, strings.TrimPrefix(p, user.BasePath))
+		if href != "/" && isDir {
+			href += "/"
+		}
+		return href
+	}
+	writeProps := func(p string, info model.Obj) error {
+		pstats, err := props(ctx, h.LockSystem, p, info, sc.Prop)
+		if err != nil {
+			return err
+		}
+		return mw.write(makePropstatResponse(hrefOf(p, info.IsDir()), pstats))
+	}
+	writeRemoved := func(p string) error {
+		return mw.write(&response{
+			Href:   []string{(&url.URL{Path: hrefOf(p, false)}).EscapedPath()},
+			Status: fmt.Sprintf("HTTP/1.1 %d %s", http.StatusNotFound, StatusText(http.StatusNotFound)),
+		})
+	}
+
+	var reportErr error
+	if sc.SyncToken == "" {
+		// initial synchronization, report all members of the collection
+		reportErr = walkFS(ctx, depth, reqPath, fi, func(p string, info model.Obj, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == reqPath {
+				return nil
+			}
+			return writeProps(p, info)
+		})
+	} else {
+		token, ok := parseSyncToken(sc.SyncToken)
+		if ok {
+			ok, err = journal.Valid(token)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		if !ok {
+			return 0, writeErrorBody(w, http.StatusForbidden, "<D:valid-sync-token/>")
+		}
+		changes, err := journal.Changes(reqPath, token, depth)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		// https://www.rfc-editor.org/rfc/rfc6578#section-3.6
+		// the changes beyond the limit are left to the next report, whose
+		// sync token is the one of the last change reported
+		truncated := sc.NResults > 0 && len(changes) > sc.NResults
+		if truncated {
+			changes = truncateChanges(changes, sc.NResults)
+			mw.syncToken = makeSyncToken(changes[len(changes)-1].ID)
+		}
+		for _, c := range changes {
+			if c.ID > latest && !truncated {
+				latest = c.ID
+				mw.syncToken = makeSyncToken(latest)
+			}
+			if c.Path == reqPath {
+				continue
+			}
+			m, err := op.GetNearestMeta(c.Path)
+			if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+				reportErr = err
+				break
+			}
+			if !common.CanAccess(user, m, c.Path, password) {
+				continue
+			}
+			if c.Deleted {
+				reportErr = writeRemoved(c.Path)
+			} else if info, err := fs.Get(ctx, c.Path, &fs.GetArgs{}); err == nil {
+				reportErr = writeProps(c.Path, info)
+			} else if errs.IsObjectNotFound(err) {
+				reportErr = writeRemoved(c.Path)
+			} else {
+				reportErr = err
+			}
+			if reportErr != nil {
+				break
+			}
+		}
+		if truncated && reportErr == nil {
+			reportErr = mw.write(&response{
+				Href:   []string{(&url.URL{Path: hrefOf(reqPath, true)}).EscapedPath()},
+				Status: fmt.Sprintf("HTTP/1.1 %d %s", StatusInsufficientStorage, StatusText(StatusInsufficientStorage)),
+				Error:  &xmlError{InnerXML: []byte("<D:number-of-matches-within-limits/>")},
+			})
+		}
+	}
+	closeErr := mw.close()
+	if reportErr != nil {
+		return http.StatusInternalServerError, reportErr
+	}
+	if closeErr != nil {
+		return http.StatusInternalServerError, closeErr
+	}
+	return 0, nil
+}
+
+// truncateChanges returns the first n changes in the order they were made,
+// so that every change up to the last one returned is reported
+func truncateChanges(changes []model.FsChange, n int) []model.FsChange {
+	changes = slices.Clone(changes)
+	slices.SortFunc(changes, func(a, b model.FsChange) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return changes[:n]
+}
+
 func makePropstatResponse(href string, pstats []Propstat) *response {
 	resp := response{
 		Href:     []string{(&url.URL{Path: href}).EscapedPath()},
@@ -925,6 +1139,7 @@ var (
 	errInvalidLockToken        = errors.New("webdav: invalid lock token")
 	errInvalidPropfind         = errors.New("webdav: invalid propfind")
 	errInvalidProppatch        = errors.New("webdav: invalid proppatch")
+	errInvalidReport           = errors.New("webdav: invalid report")
 	errInvalidResponse         = errors.New("webdav: invalid response")
 	errInvalidTimeout          = errors.New("webdav: invalid timeout")
 	errNoFileSystem            = errors.New("webdav: no file system")
@@ -934,4 +1149,5 @@ var (
 	errRecursionTooDeep        = errors.New("webdav: recursion too deep")
 	errUnsupportedLockInfo     = errors.New("webdav: unsupported lock info")
 	errUnsupportedMethod       = errors.New("webdav: unsupported method")
+	errUnsupportedReport       = errors.New("webdav: unsupported report")
 )
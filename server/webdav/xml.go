@@ -13,6 +13,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	// As of https://go-review.googlesource.com/#/c/12772/ which was submitted
@@ -204,6 +206,79 @@ func readPropfind(r io.Reader) (pf propfind, status int, err error) {
 	return pf, 0, nil
 }
 
+// https://www.rfc-editor.org/rfc/rfc6578#section-6.1
+type syncCollection struct {
+	XMLName   ixml.Name     `xml:"DAV: sync-collection"`
+	SyncToken string        `xml:"DAV: sync-token"`
+	SyncLevel string        `xml:"DAV: sync-level"`
+	NResults  int           `xml:"DAV: limit>nresults"`
+	Prop      propfindProps `xml:"DAV: prop"`
+}
+
+// readReport reads the body of a REPORT request. Only the sync-collection
+// report is supported, any other report results in errUnsupportedReport.
+func readReport(r io.Reader) (sc syncCollection, status int, err error) {
+	d := ixml.NewDecoder(r)
+	for {
+		t, err := next(d)
+		if err != nil {
+			if err == io.EOF {
+				err = errInvalidReport
+			}
+			return syncCollection{}, http.StatusBadRequest, err
+		}
+		start, ok := t.(ixml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Space != "DAV:" || start.Name.Local != "sync-collection" {
+			return syncCollection{}, http.StatusForbidden, errUnsupportedReport
+		}
+		if err = d.DecodeElement(&sc, &start); err != nil {
+			return syncCollection{}, http.StatusBadRequest, err
+		}
+		break
+	}
+	sc.SyncToken = strings.TrimSpace(sc.SyncToken)
+	sc.SyncLevel = strings.TrimSpace(sc.SyncLevel)
+	if sc.SyncLevel != "1" && sc.SyncLevel != "infinite" {
+		return syncCollection{}, http.StatusBadRequest, errInvalidReport
+	}
+	if sc.NResults < 0 {
+		return syncCollection{}, http.StatusBadRequest, errInvalidReport
+	}
+	return sc, 0, nil
+}
+
+// syncTokenPrefix makes sync tokens valid URIs as required by RFC 6578 section 6.2
+const syncTokenPrefix = "https://oplist.org/ns/sync/"
+
+func makeSyncToken(id uint) string {
+	return syncTokenPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+func parseSyncToken(token string) (uint, bool) {
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeErrorBody writes a DAV:error body with the given precondition, which
+// reports the reason of a failed request in a way clients can act on.
+// See http://www.webdav.org/specs/rfc4918.html#ELEMENT_error
+func writeErrorBody(w http.ResponseWriter, status int, condition string) error {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<D:error xmlns:D="DAV:">%s</D:error>`, condition)
+	return err
+}
+
 // Property represents a single DAV resource property as defined in RFC 4918.
 // See http://www.webdav.org/specs/rfc4918.html#data.model.for.resource.properties
 type Property struct {
@@ -307,6 +382,10 @@ type response struct {
 // elements with a default namespace (no prefixed namespace). A less intrusive fix
 // should be possible after golang.org/cl/11074. See https://golang.org/issue/11177
 type multistatusWriter struct {
+	// syncToken is the optional sync-token of the multistatus XML element
+	// in a sync-collection report. If set, the multistatus element is written
+	// even if there is no response.
+	syncToken string
 	// ResponseDescription contains the optional responsedescription
 	// of the multistatus XML element. Only the latest content before
 	// close will be emitted. Empty response descriptions are not
@@ -377,9 +456,22 @@ func (w *multistatusWriter) writeHeader() error {
 // been written.
 func (w *multistatusWriter) close() error {
 	if w.enc == nil {
-		return nil
+		if w.syncToken == "" {
+			return nil
+		}
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
 	}
 	var end []ixml.Token
+	if w.syncToken != "" {
+		name := ixml.Name{Space: "DAV:", Local: "sync-token"}
+		end = append(end,
+			ixml.StartElement{Name: name},
+			ixml.CharData(w.syncToken),
+			ixml.EndElement{Name: name},
+		)
+	}
 	if w.responseDescription != "" {
 		name := ixml.Name{Space: "DAV:", Local: "responsedescription"}
 		end = append(end,
@@ -16,6 +16,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	ixml "github.com/OpenListTeam/OpenList/v4/server/webdav/internal/xml"
 )
 
@@ -348,6 +349,89 @@ func TestReadPropfind(t *testing.T) {
 	}
 }
 
+func TestReadReport(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		input      string
+		wantSC     syncCollection
+		wantStatus int
+	}{{
+		desc: "report: initial sync-collection",
+		input: "" +
+			"<?xml version='1.0' encoding='utf-8'?>\n" +
+			"<A:sync-collection xmlns:A='DAV:'>\n" +
+			"  <A:sync-token/>\n" +
+			"  <A:sync-level>1</A:sync-level>\n" +
+			"  <A:prop><A:getetag/></A:prop>\n" +
+			"</A:sync-collection>",
+		wantSC: syncCollection{
+			XMLName:   ixml.Name{Space: "DAV:", Local: "sync-collection"},
+			SyncLevel: "1",
+			Prop:      propfindProps{xml.Name{Space: "DAV:", Local: "getetag"}},
+		},
+	}, {
+		desc: "report: sync-collection with token and limit",
+		input: "" +
+			"<A:sync-collection xmlns:A='DAV:'>\n" +
+			"  <A:sync-token>https://oplist.org/ns/sync/42</A:sync-token>\n" +
+			"  <A:sync-level>infinite</A:sync-level>\n" +
+			"  <A:limit><A:nresults>100</A:nresults></A:limit>\n" +
+			"  <A:prop><A:displayname/></A:prop>\n" +
+			"</A:sync-collection>",
+		wantSC: syncCollection{
+			XMLName:   ixml.Name{Space: "DAV:", Local: "sync-collection"},
+			SyncToken: "https://oplist.org/ns/sync/42",
+			SyncLevel: "infinite",
+			NResults:  100,
+			Prop:      propfindProps{xml.Name{Space: "DAV:", Local: "displayname"}},
+		},
+	}, {
+		desc: "report: bad: missing sync-level",
+		input: "" +
+			"<A:sync-collection xmlns:A='DAV:'>\n" +
+			"  <A:sync-token/>\n" +
+			"  <A:prop><A:getetag/></A:prop>\n" +
+			"</A:sync-collection>",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		desc:       "report: bad: empty body",
+		input:      "",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		desc: "report: bad: unsupported report",
+		input: "" +
+			"<A:expand-property xmlns:A='DAV:'>\n" +
+			"  <A:property name='version-history'/>\n" +
+			"</A:expand-property>",
+		wantStatus: http.StatusForbidden,
+	}}
+
+	for _, tc := range testCases {
+		sc, status, err := readReport(strings.NewReader(tc.input))
+		if tc.wantStatus != 0 {
+			if err == nil {
+				t.Errorf("%s: got nil error, want non-nil", tc.desc)
+				continue
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(sc, tc.wantSC) || status != tc.wantStatus {
+			t.Errorf("%s:\ngot  sync-collection=%v, status=%v\nwant sync-collection=%v, status=%v",
+				tc.desc, sc, status, tc.wantSC, tc.wantStatus)
+			continue
+		}
+	}
+
+	if id, ok := parseSyncToken(makeSyncToken(42)); !ok || id != 42 {
+		t.Errorf("parseSyncToken(makeSyncToken(42)) = %d, %t, want 42, true", id, ok)
+	}
+	if _, ok := parseSyncToken("urn:uuid:42"); ok {
+		t.Errorf("parseSyncToken accepted a foreign token")
+	}
+}
+
 func TestMultistatusWriter(t *testing.T) {
 	///The "section x.y.z" test cases come from section x.y.z of the spec at
 	// http://www.webdav.org/specs/rfc4918.html
@@ -903,3 +987,19 @@ func (a byName) Less(i, j int) bool {
 	}
 	return a[i].Name.Local < a[j].Name.Local
 }
+
+func TestTruncateChanges(t *testing.T) {
+	changes := []model.FsChange{
+		{ID: 7, Path: "/a"},
+		{ID: 3, Path: "/b"},
+		{ID: 5, Path: "/c"},
+		{ID: 9, Path: "/d"},
+	}
+	got := truncateChanges(changes, 2)
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 5 {
+		t.Errorf("got %+v, want the changes 3 and 5", got)
+	}
+	if changes[0].ID != 7 {
+		t.Errorf("the changes given are reordered")
+	}
+}